@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/contour"
+	"github.com/willie68/osmltools/internal/grid"
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+type contourSrv interface {
+	Contours(inputs []string, output string, opts contour.Options) (*contour.Result, error)
+}
+
+// contoursCmd generates depth contour lines
+var contoursCmd = &cobra.Command{
+	Use:   "contours",
+	Short: "generates depth contour lines (isobaths) from tracks or xyz exports",
+	Long:  `generates depth contour lines (isobaths) from one or more track files or xyz exports. The soundings are gridded, the grid is interpolated (idw or tin), the lines are traced with marching squares, smoothed and simplified. Output is geojson or kml.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		inputs, _ := cmd.Flags().GetStringSlice("input")
+		output, _ := cmd.Flags().GetString("output")
+		cellSize, _ := cmd.Flags().GetFloat64("cell-size")
+		agg, _ := cmd.Flags().GetString("aggregation")
+		levels, _ := cmd.Flags().GetFloat64Slice("levels")
+		interp, _ := cmd.Flags().GetString("interpolation")
+		radius, _ := cmd.Flags().GetInt("radius")
+		smooth, _ := cmd.Flags().GetInt("smooth")
+		tolerance, _ := cmd.Flags().GetFloat64("tolerance")
+		format, _ := cmd.Flags().GetString("format")
+		return Contours(inputs, output, contour.Options{
+			Grid: grid.Options{
+				CellSize:    cellSize,
+				Aggregation: agg,
+			},
+			Levels:        levels,
+			Interpolation: interp,
+			Radius:        radius,
+			Smooth:        smooth,
+			Tolerance:     tolerance,
+			Format:        format,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(contoursCmd)
+
+	contoursCmd.Flags().StringSliceP("input", "i", []string{}, "track files (zip) or xyz exports, separated by commas")
+	contoursCmd.Flags().StringP("output", "o", "contours.geojson", "the output file, .geojson or .kml")
+	contoursCmd.Flags().Float64SliceP("levels", "l", contour.DefaultLevels, "the depths of the contour lines in meters")
+	contoursCmd.Flags().Float64P("cell-size", "c", 10, "the cell size of the grid in meters")
+	contoursCmd.Flags().StringP("aggregation", "a", grid.AggMean, fmt.Sprintf("aggregation of the depths per cell: %s, %s or %s", grid.AggMean, grid.AggMedian, grid.AggMin))
+	contoursCmd.Flags().String("interpolation", grid.InterpIDW, fmt.Sprintf("interpolation of empty cells: %s, %s or %s", grid.InterpIDW, grid.InterpTIN, grid.InterpNone))
+	contoursCmd.Flags().Int("radius", grid.DefaultRadius, "search radius (idw) or max triangle edge (tin) in cells")
+	contoursCmd.Flags().Int("smooth", 2, "number of smoothing iterations, 0 for none")
+	contoursCmd.Flags().Float64("tolerance", 2, "simplification tolerance in meters, 0 for none")
+	contoursCmd.Flags().StringP("format", "m", "", fmt.Sprintf("output format %s or %s, default from the output file extension", contour.FormatGeoJSON, contour.FormatKML))
+}
+
+// Contours get the contour service and generate the contour lines
+func Contours(inputs []string, output string, opts contour.Options) error {
+	cs := do.MustInvokeAs[contourSrv](internal.Inj)
+	td := time.Now()
+	res, err := cs.Contours(inputs, output, opts)
+	logging.Root.Infof("generating contours took %d seconds", time.Since(td).Abs().Milliseconds()/1000)
+	if err != nil {
+		return err
+	}
+	if JSONOutput {
+		OutputAsJSON(res)
+		return nil
+	}
+	fmt.Printf("%d contour lines with %d points for the levels %v from %d soundings\r\n", res.Lines, res.Points, res.Levels, res.Soundings)
+	fmt.Printf(" - %s\r\n", res.File)
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/samber/do/v2"
 	"github.com/spf13/cobra"
 	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/config"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 )
@@ -24,12 +25,17 @@ var convertCmd = &cobra.Command{
 		cmd.Root().SilenceErrors = true
 		JSONOutput = true
 		logging.Root.SetLevel(logging.None)
+		config.UserConfigFile = userConfigFile
 		internal.Init()
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		files, _ := cmd.Flags().GetStringSlice("files")
 		track, _ := cmd.Flags().GetString("track")
-		return Convert(sdCardFolder, files, track)
+		simp, err := simplificationFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		return Convert(sdCardFolder, files, track, simp)
 	},
 }
 
@@ -38,15 +44,17 @@ func init() {
 
 	convertCmd.Flags().StringSliceP("files", "f", []string{}, "files to process, separated by commas")
 	convertCmd.Flags().StringP("track", "t", "", "the track file to work with")
+	addSimplifyFlags(convertCmd)
 }
 
-// Convert get the exporter and execute it on the sd file set
-func Convert(sdCardFolder string, files []string, track string) error {
+// Convert get the exporter and execute it on the sd file set, the waypoints are simplified with simp
+func Convert(sdCardFolder string, files []string, track string, simp *model.Simplification) error {
 	cnv := do.MustInvokeAs[converter](internal.Inj)
 	res, err := cnv.Convert(sdCardFolder, files, track)
 	if err != nil {
 		return err
 	}
+	res.Simplify(simp)
 	res.LogLines = make([]*model.LogLine, 0)
 	js, err := json.Marshal(res)
 	if err != nil {
@@ -9,14 +9,17 @@ import (
 	"github.com/samber/do/v2"
 	"github.com/spf13/cobra"
 	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/config"
 	"github.com/willie68/osmltools/internal/export"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/geo"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 )
 
 type exporter interface {
-	Export(sdCardFolder, outputFolder string, files []string, format, name string) error
-	ExportTrack(trackfile, outputfile, format string) error
+	Export(sdCardFolder, outputFolder string, files []string, format, name string, opts model.ExportOptions) error
+	ExportTrack(trackfile, outputfile, format string, opts model.ExportOptions) error
 }
 
 // checkCmd represents the generate command
@@ -25,6 +28,10 @@ var exportCmd = &cobra.Command{
 	Short: "exports the data files into files",
 	Long:  `checks the data files of the open sea map logger, building tracks by day and write a cleanup version to output files with the specifig format`,
 	RunE: func(cmd *cobra.Command, _ []string) error {
+		listFormats, _ := cmd.Flags().GetBool("list-formats")
+		if listFormats {
+			return ListFormats()
+		}
 		output, _ := cmd.Flags().GetString("output")
 		format, _ := cmd.Flags().GetString("format")
 		files, _ := cmd.Flags().GetStringSlice("files")
@@ -35,28 +42,148 @@ var exportCmd = &cobra.Command{
 		if !slices.Contains(export.SupportedFormats, format) {
 			return fmt.Errorf("the format %s is not supported. Supported formats are: %v", format, export.SupportedFormats)
 		}
+		trim, err := trimFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		zones, err := privacyZones(cmd)
+		if err != nil {
+			return err
+		}
+		simp, err := simplificationFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		fopts, _ := cmd.Flags().GetStringArray("opt")
+		singleFile, _ := cmd.Flags().GetBool("single-file")
+		var series []string
+		if sf, _ := cmd.Flags().GetString("series"); sf != "" {
+			if series, err = model.ParseSeries(sf); err != nil {
+				return err
+			}
+		}
+		opts := model.ExportOptions{
+			Trim:           trim,
+			Simplification: simp,
+			PrivacyZones:   zones,
+			FormatOptions:  fopts,
+			SingleFile:     singleFile,
+			Series:         series,
+		}
+		if err := depthReductionFromFlags(cmd, &opts); err != nil {
+			return err
+		}
 		if track != "" {
-			return ExportTrack(track, output, format)
+			return ExportTrack(track, output, format, opts)
 		}
-		return Export(sdCardFolder, output, files, format, name)
+		return Export(sdCardFolder, output, files, format, name, opts)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(exportCmd)
 
-	exportCmd.Flags().StringSliceP("files", "f", []string{}, "files to process, separated by commas")
+	exportCmd.Flags().StringSliceP("files", "f", []string{}, "files to process: logger data files, gpx, kml, kmz, nmea, json, fit, sl2 or sl3, separated by commas")
 	exportCmd.Flags().StringP("output", "o", "./", "output folder/file. Default is the working dir. Naming track_####.nmea")
-	exportCmd.Flags().StringP("format", "m", export.NMEAFormat, "the format of the output file. Defaults to NMEA, also available: GPX, KML, KMZ, GEOJSON")
+	exportCmd.Flags().StringP("format", "m", export.NMEAFormat, fmt.Sprintf("the format of the output file. Defaults to NMEA, available: %s", strings.Join(export.SupportedFormats, ", ")))
 	exportCmd.Flags().StringP("name", "n", "", "give the track a name")
 	exportCmd.Flags().StringP("track", "t", "", "the track file to work with")
+	exportCmd.Flags().Bool("list-formats", false, "list all export formats with their options")
+	exportCmd.Flags().StringArray("opt", []string{}, "format specific option <format>.<name>=<value>, e.g. gpx.extensions=opencpn, can be used multiple times")
+	exportCmd.Flags().Bool("single-file", false, "write all tracks into one file tracks.<ext>, only for formats supporting multiple tracks")
+	exportCmd.Flags().String("series", "", fmt.Sprintf("export the sensor time series instead of the track as csv or json, comma separated: %s", strings.Join(model.SeriesNames, ",")))
+	addTrimFlags(exportCmd)
+	addNoPrivacyFlag(exportCmd)
+	addDepthReductionFlags(exportCmd)
+	addSimplifyFlags(exportCmd)
+}
+
+func addDepthReductionFlags(cmd *cobra.Command) {
+	cmd.Flags().Int32("vessel", 0, "use the sensor config of this vessel for the depth reduction, default is the vessel of the track")
+	cmd.Flags().String("depth-ref", "", fmt.Sprintf("reference of the depths: %s, %s or %s. Default is %s", model.DepthRefWaterline, model.DepthRefTransducer, model.DepthRefKeel, model.DepthRefWaterline))
+	cmd.Flags().String("water-level", "", "csv file with time and water level above chart datum, to reduce the depths to chart datum")
+}
+
+// depthReductionFromFlags sets the depth reduction options from the flags and the vessel configs of the user config
+func depthReductionFromFlags(cmd *cobra.Command, opts *model.ExportOptions) error {
+	opts.VesselID, _ = cmd.Flags().GetInt32("vessel")
+	opts.DepthReference, _ = cmd.Flags().GetString("depth-ref")
+	wl, _ := cmd.Flags().GetString("water-level")
+	if wl != "" {
+		wls, err := model.ReadWaterLevels(wl)
+		if err != nil {
+			return err
+		}
+		opts.WaterLevels = wls
+	}
+	cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+	if err != nil {
+		return err
+	}
+	opts.Vessels = cfg.Vessels
+	return nil
+}
+
+func addSimplifyFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("simplify", 0, "simplify the track with douglas peucker, tolerance in meters")
+	cmd.Flags().Duration("resample-time", 0, "resample the track to one point per time step, e.g. 10s")
+	cmd.Flags().Float64("resample-distance", 0, "resample the track to one point per distance in meters")
+	cmd.Flags().Float64("keep-corners", model.DefaultCornerAngle, "on simplify/resample keep a point every time the course changed by this angle in degrees, 0 to disable")
+	cmd.Flags().Bool("keep-depth-extremes", true, "on simplify/resample keep the shallowest and deepest points")
+}
+
+// simplificationFromFlags builds the simplification from the command flags, nil if no simplification is set
+func simplificationFromFlags(cmd *cobra.Command) (*model.Simplification, error) {
+	s := &model.Simplification{}
+	s.Tolerance, _ = cmd.Flags().GetFloat64("simplify")
+	s.Interval, _ = cmd.Flags().GetDuration("resample-time")
+	s.Distance, _ = cmd.Flags().GetFloat64("resample-distance")
+	s.CornerAngle, _ = cmd.Flags().GetFloat64("keep-corners")
+	s.KeepDepthExtremes, _ = cmd.Flags().GetBool("keep-depth-extremes")
+	if s.IsEmpty() {
+		return nil, s.Validate()
+	}
+	return s, s.Validate()
+}
+
+func addTrimFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from", "", "only use data from this time on (UTC), e.g. \"2016-09-11 10:15:00\"")
+	cmd.Flags().String("to", "", "only use data up to this time (UTC), e.g. \"2016-09-11 16:30:00\"")
+	cmd.Flags().String("clip", "", "geojson file with polygon(s), only data inside these polygons will be used")
+}
+
+// trimFromFlags builds the trim from the command flags, nil if no trim flag is set
+func trimFromFlags(cmd *cobra.Command) (*model.Trim, error) {
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+	clip, _ := cmd.Flags().GetString("clip")
+	var err error
+	trim := &model.Trim{}
+	trim.From, err = model.ParseTrimTime(from)
+	if err != nil {
+		return nil, err
+	}
+	trim.To, err = model.ParseTrimTime(to)
+	if err != nil {
+		return nil, err
+	}
+	if clip != "" {
+		trim.Clip, err = geo.ReadPolygons(clip)
+		if err != nil {
+			return nil, fmt.Errorf("can't read clip area %s: %w", clip, err)
+		}
+	}
+	if trim.IsEmpty() {
+		return nil, nil
+	}
+	return trim, trim.Validate()
 }
 
 // Export get the exporter and execute it on the sd file set
-func Export(sdCardFolder, outputFolder string, files []string, format, name string) error {
+func Export(sdCardFolder, outputFolder string, files []string, format, name string, opts model.ExportOptions) error {
 	exp := do.MustInvokeAs[exporter](internal.Inj)
 	td := time.Now()
-	err := exp.Export(sdCardFolder, outputFolder, files, format, name)
+	err := exp.Export(sdCardFolder, outputFolder, files, format, name, opts)
 	logging.Root.Infof("exporting files took %d seconds", time.Since(td).Abs().Milliseconds()/1000)
 	if err == nil {
 		if JSONOutput {
@@ -69,10 +196,10 @@ func Export(sdCardFolder, outputFolder string, files []string, format, name stri
 }
 
 // ExportTrack a single track file into the given format
-func ExportTrack(trackfile, outputFile, format string) error {
+func ExportTrack(trackfile, outputFile, format string, opts model.ExportOptions) error {
 	exp := do.MustInvokeAs[exporter](internal.Inj)
 	td := time.Now()
-	err := exp.ExportTrack(trackfile, outputFile, format)
+	err := exp.ExportTrack(trackfile, outputFile, format, opts)
 	logging.Root.Infof("exporting track took %d seconds", time.Since(td).Abs().Milliseconds()/1000)
 	if err == nil {
 		if JSONOutput {
@@ -86,3 +213,36 @@ func ExportTrack(trackfile, outputFile, format string) error {
 	}
 	return err
 }
+
+// ListFormats prints all registered export formats
+func ListFormats() error {
+	fs := registry.Formats()
+	if JSONOutput {
+		OutputAsJSON(fs)
+		return nil
+	}
+	for _, f := range fs {
+		caps := make([]string, 0)
+		if f.Capabilities.MultiTrack {
+			caps = append(caps, "multi-track")
+		}
+		if f.Capabilities.NeedsLogLines {
+			caps = append(caps, "needs log lines")
+		}
+		if f.Capabilities.Binary {
+			caps = append(caps, "binary")
+		}
+		fmt.Printf("%-8s .%-8s %-38s %s\r\n", f.Name, f.Extension, f.MIMEType, f.Description)
+		if len(caps) > 0 {
+			fmt.Printf("%18s capabilities: %s\r\n", "", strings.Join(caps, ", "))
+		}
+		for _, o := range f.Options {
+			def := ""
+			if o.Default != "" {
+				def = fmt.Sprintf(" (default: %s)", o.Default)
+			}
+			fmt.Printf("%18s --opt %s.%s: %s%s\r\n", "", strings.ToLower(f.Name), o.Name, o.Description, def)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/grid"
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+type gridSrv interface {
+	Grid(inputs []string, output string, opts grid.Options) (*grid.Result, error)
+}
+
+// gridCmd builds a gridded depth surface
+var gridCmd = &cobra.Command{
+	Use:   "grid",
+	Short: "builds a gridded depth surface from tracks or xyz exports",
+	Long:  `builds a gridded depth surface with the given cell size from one or more track files or xyz exports. Output is an esri ascii grid (with a count grid) or geojson polygons.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		inputs, _ := cmd.Flags().GetStringSlice("input")
+		output, _ := cmd.Flags().GetString("output")
+		cellSize, _ := cmd.Flags().GetFloat64("cell-size")
+		agg, _ := cmd.Flags().GetString("aggregation")
+		format, _ := cmd.Flags().GetString("format")
+		samples, _ := cmd.Flags().GetInt("max-samples")
+		return Grid(inputs, output, grid.Options{
+			CellSize:    cellSize,
+			Aggregation: agg,
+			Format:      format,
+			MaxSamples:  samples,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gridCmd)
+
+	gridCmd.Flags().StringSliceP("input", "i", []string{}, "track files (zip) or xyz exports, separated by commas")
+	gridCmd.Flags().StringP("output", "o", "grid.asc", "the output file, .asc for esri ascii grid, .geojson for geojson polygons")
+	gridCmd.Flags().Float64P("cell-size", "c", 10, "the cell size in meters")
+	gridCmd.Flags().StringP("aggregation", "a", grid.AggMean, fmt.Sprintf("aggregation of the depths per cell: %s, %s or %s", grid.AggMean, grid.AggMedian, grid.AggMin))
+	gridCmd.Flags().StringP("format", "m", "", fmt.Sprintf("output format %s or %s, default from the output file extension", grid.FormatASCII, grid.FormatGeoJSON))
+	gridCmd.Flags().Int("max-samples", grid.DefaultMaxSamples, "max soundings per cell kept for the median")
+}
+
+// Grid get the grid service and build the depth surface
+func Grid(inputs []string, output string, opts grid.Options) error {
+	gs := do.MustInvokeAs[gridSrv](internal.Inj)
+	td := time.Now()
+	res, err := gs.Grid(inputs, output, opts)
+	logging.Root.Infof("building grid took %d seconds", time.Since(td).Abs().Milliseconds()/1000)
+	if err != nil {
+		return err
+	}
+	if JSONOutput {
+		OutputAsJSON(res)
+		return nil
+	}
+	fmt.Printf("%d soundings from %d files in %d cells\r\n", res.Soundings, res.Inputs, res.Cells)
+	for _, f := range res.Files {
+		fmt.Printf(" - %s\r\n", f)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/geo"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+var (
+	privacyCmd = &cobra.Command{
+		Use:   "privacy",
+		Short: "manage the privacy zones",
+		Long:  `manage the privacy zones of the user config. Points inside these zones are dropped or fuzzed on export and upload.`,
+	}
+
+	listPrivacyCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list all privacy zones",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+			if err != nil {
+				return err
+			}
+			if JSONOutput {
+				OutputAsJSON(cfg.PrivacyZones)
+				return nil
+			}
+			fmt.Printf("Privacy zones from %s\r\n", cfg.File())
+			for _, z := range cfg.PrivacyZones {
+				mode := z.Mode
+				if mode == "" {
+					mode = model.PrivacyDrop
+				}
+				if len(z.Polygon) > 0 {
+					fmt.Printf(" - %s: polygon with %d points (%s)\r\n", z.Name, len(z.Polygon[0]), mode)
+					continue
+				}
+				fmt.Printf(" - %s: %.6f, %.6f radius %.0fm (%s)\r\n", z.Name, z.Lat, z.Lon, z.Radius, mode)
+			}
+			return nil
+		},
+	}
+
+	addPrivacyCmd = &cobra.Command{
+		Use:   "add",
+		Short: "add a privacy zone, as circle or polygon",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			lat, _ := cmd.Flags().GetFloat64("lat")
+			lon, _ := cmd.Flags().GetFloat64("lon")
+			radius, _ := cmd.Flags().GetFloat64("radius")
+			polygon, _ := cmd.Flags().GetString("polygon")
+			mode, _ := cmd.Flags().GetString("mode")
+			z := model.PrivacyZone{
+				Name:   name,
+				Lat:    lat,
+				Lon:    lon,
+				Radius: radius,
+				Mode:   mode,
+			}
+			if polygon != "" {
+				ps, err := geo.ReadPolygons(polygon)
+				if err != nil {
+					return err
+				}
+				z.Polygon = ps[0]
+				z.Lat, z.Lon, z.Radius = 0, 0, 0
+			}
+			return AddPrivacyZone(z)
+		},
+	}
+
+	removePrivacyCmd = &cobra.Command{
+		Use:   "remove",
+		Short: "remove a privacy zone",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			return RemovePrivacyZone(name)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(privacyCmd)
+
+	privacyCmd.AddCommand(listPrivacyCmd)
+
+	privacyCmd.AddCommand(addPrivacyCmd)
+	addPrivacyCmd.Flags().StringP("name", "n", "", "name of the zone")
+	addPrivacyCmd.Flags().Float64("lat", 0, "latitude of the center of the circle")
+	addPrivacyCmd.Flags().Float64("lon", 0, "longitude of the center of the circle")
+	addPrivacyCmd.Flags().Float64P("radius", "r", 0, "radius of the circle in meters")
+	addPrivacyCmd.Flags().StringP("polygon", "p", "", "geojson file with the polygon of the zone, the first polygon is used")
+	addPrivacyCmd.Flags().StringP("mode", "m", model.PrivacyDrop, "drop or fuzz the points inside the zone")
+
+	privacyCmd.AddCommand(removePrivacyCmd)
+	removePrivacyCmd.Flags().StringP("name", "n", "", "name of the zone")
+}
+
+// AddPrivacyZone adds or replaces a privacy zone in the user config
+func AddPrivacyZone(z model.PrivacyZone) error {
+	if err := z.Validate(); err != nil {
+		return err
+	}
+	cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+	if err != nil {
+		return err
+	}
+	cfg.PrivacyZones = slices.DeleteFunc(cfg.PrivacyZones, func(o model.PrivacyZone) bool {
+		return o.Name == z.Name
+	})
+	cfg.PrivacyZones = append(cfg.PrivacyZones, z)
+	return saveUserConfig(cfg)
+}
+
+// RemovePrivacyZone removes the privacy zone with the name from the user config
+func RemovePrivacyZone(name string) error {
+	cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+	if err != nil {
+		return err
+	}
+	l := len(cfg.PrivacyZones)
+	cfg.PrivacyZones = slices.DeleteFunc(cfg.PrivacyZones, func(o model.PrivacyZone) bool {
+		return o.Name == name
+	})
+	if l == len(cfg.PrivacyZones) {
+		return errors.New("privacy zone " + name + " not found")
+	}
+	return saveUserConfig(cfg)
+}
+
+func saveUserConfig(cfg *config.UserConfig) error {
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+	if JSONOutput {
+		fmt.Println(model.GeneralResult{Result: true}.JSON())
+		return nil
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func addNoPrivacyFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("no-privacy", false, "don't apply the privacy zones of the user config")
+}
+
+// privacyZones returns the privacy zones of the user config, none if --no-privacy is set
+func privacyZones(cmd *cobra.Command) (model.PrivacyZones, error) {
+	noPrivacy, _ := cmd.Flags().GetBool("no-privacy")
+	if noPrivacy {
+		return nil, nil
+	}
+	cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.PrivacyZones, nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/render"
+)
+
+type renderSrv interface {
+	Render(trackfile string, outputs []string, zones model.PrivacyZones, opts render.Options) (*render.Result, error)
+}
+
+// renderCmd renders a track as map image
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "renders a track as svg or png map",
+	Long: `renders a track as static map image without any tile server: the track coloured by depth or speed, start and end markers, a scale bar, a north arrow and optionally a coastline from a geojson file.
+The format is taken from the output file extension, .svg or .png. With --embed the images are stored in the track file as thumbnail.svg/thumbnail.png.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		trackfile, _ := cmd.Flags().GetString("track")
+		outputs, _ := cmd.Flags().GetStringSlice("output")
+		opts := render.Options{}
+		opts.Width, _ = cmd.Flags().GetInt("width")
+		opts.Height, _ = cmd.Flags().GetInt("height")
+		opts.ColorBy, _ = cmd.Flags().GetString("color-by")
+		opts.LineWidth, _ = cmd.Flags().GetFloat64("line-width")
+		opts.Coastline, _ = cmd.Flags().GetString("coastline")
+		opts.Embed, _ = cmd.Flags().GetBool("embed")
+		for _, l := range []struct {
+			name string
+			dst  **float64
+		}{{"min", &opts.Min}, {"max", &opts.Max}} {
+			if cmd.Flags().Changed(l.name) {
+				v, _ := cmd.Flags().GetFloat64(l.name)
+				*l.dst = &v
+			}
+		}
+		zones, err := privacyZones(cmd)
+		if err != nil {
+			return err
+		}
+		return Render(trackfile, outputs, zones, opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringP("track", "t", "", "the track file to render")
+	renderCmd.Flags().StringSliceP("output", "o", []string{}, "the output files, .svg or .png, separated by commas")
+	renderCmd.Flags().Int("width", render.DefaultWidth, "the width of the image in pixels")
+	renderCmd.Flags().Int("height", render.DefaultHeight, "the height of the image in pixels")
+	renderCmd.Flags().String("color-by", render.ColorByDepth, fmt.Sprintf("colour the track by %s, %s or %s", render.ColorByDepth, render.ColorBySpeed, render.ColorByNone))
+	renderCmd.Flags().Float64("min", 0, "lower limit of the colour scale, default is the minimum of the data")
+	renderCmd.Flags().Float64("max", 0, "upper limit of the colour scale, default is the maximum of the data")
+	renderCmd.Flags().Float64("line-width", render.DefaultLineWidth, "the width of the track line in pixels")
+	renderCmd.Flags().String("coastline", "", "geojson file with land polygons and/or coast lines")
+	renderCmd.Flags().Bool("embed", false, "store the images in the track file, without output as svg")
+	addNoPrivacyFlag(renderCmd)
+}
+
+// Render get the render service and draw the map
+func Render(trackfile string, outputs []string, zones model.PrivacyZones, opts render.Options) error {
+	rs := do.MustInvokeAs[renderSrv](internal.Inj)
+	td := time.Now()
+	res, err := rs.Render(trackfile, outputs, zones, opts)
+	logging.Root.Infof("rendering took %d seconds", time.Since(td).Abs().Milliseconds()/1000)
+	if err != nil {
+		return err
+	}
+	if JSONOutput {
+		OutputAsJSON(res)
+		return nil
+	}
+	fmt.Printf("map with %d waypoints rendered\r\n", res.Waypoints)
+	for _, f := range res.Files {
+		fmt.Printf(" - %s\r\n", f)
+	}
+	for _, f := range res.Embedded {
+		fmt.Printf(" - %s in %s\r\n", f, trackfile)
+	}
+	return nil
+}
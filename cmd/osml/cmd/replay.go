@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/replay"
+)
+
+type replaySrv interface {
+	Replay(ctx context.Context, opts replay.Options) error
+}
+
+// replayCmd replays a track for chart plotters
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "replays a track as nmea stream for chart plotters",
+	Long: `replays the corrected nmea data of a track file, or the raw DAT channels, as nmea sentences or signal k deltas over tcp, udp broadcast or a pseudo terminal with the original timing scaled by a speed factor.
+The replay can be controlled with a control socket, one command per line: pause, resume, seek <hh:mm:ss|+30s|-1m>, speed <factor>, loop on|off, status, stop`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opts := replay.Options{}
+		opts.Track, _ = cmd.Flags().GetString("track")
+		opts.Raw, _ = cmd.Flags().GetBool("raw")
+		opts.Channels, _ = cmd.Flags().GetStringSlice("channels")
+		opts.Output, _ = cmd.Flags().GetString("output")
+		opts.Address, _ = cmd.Flags().GetString("address")
+		opts.Speed, _ = cmd.Flags().GetFloat64("speed")
+		opts.Loop, _ = cmd.Flags().GetBool("loop")
+		opts.MaxPause, _ = cmd.Flags().GetDuration("max-pause")
+		opts.Control, _ = cmd.Flags().GetString("control")
+		opts.Format, _ = cmd.Flags().GetString("format")
+		opts.Context, _ = cmd.Flags().GetString("context")
+		for i, c := range opts.Channels {
+			opts.Channels[i] = strings.ToUpper(strings.TrimSpace(c))
+		}
+		return Replay(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringP("track", "t", "", "the track file to replay")
+	replayCmd.Flags().Bool("raw", false, "replay the raw DAT files of the track instead of the corrected nmea data")
+	replayCmd.Flags().StringSlice("channels", []string{}, "channels of the raw replay (A, B, I), separated by commas. Default all")
+	replayCmd.Flags().StringP("output", "o", replay.OutputTCP, fmt.Sprintf("the output: %s (server), %s (broadcast) or %s (pseudo terminal)", replay.OutputTCP, replay.OutputUDP, replay.OutputPTY))
+	replayCmd.Flags().StringP("address", "a", "", fmt.Sprintf("the address of the output. Default %s for tcp, %s for udp", replay.DefaultTCPAddress, replay.DefaultUDPAddress))
+	replayCmd.Flags().Float64("speed", 1, "the speed factor, 2 plays twice as fast")
+	replayCmd.Flags().Bool("loop", false, "start again at the end of the track")
+	replayCmd.Flags().Duration("max-pause", 0, "shorten longer pauses between two sentences to this duration, e.g. 10s")
+	replayCmd.Flags().StringP("format", "m", replay.FormatNMEA, fmt.Sprintf("the format of the stream: %s (sentences) or %s (signal k deltas)", replay.FormatNMEA, replay.FormatSignalK))
+	replayCmd.Flags().String("context", "", "the signal k context, default vessels.self")
+	replayCmd.Flags().String("control", "", "address of the control socket, e.g. localhost:10111 or unix:/tmp/osml.sock")
+}
+
+// Replay get the replay service and play the track until the end or ctrl-c
+func Replay(opts replay.Options) error {
+	rs := do.MustInvokeAs[replaySrv](internal.Inj)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	td := time.Now()
+	opts.Started = func(output, control string, lines int) {
+		if JSONOutput {
+			OutputAsJSON(map[string]any{"output": output, "control": control, "lines": lines})
+			return
+		}
+		fmt.Printf("replaying %d lines to %s %s\r\n", lines, opts.Output, output)
+		if control != "" {
+			fmt.Printf("control socket on %s\r\n", control)
+		}
+	}
+	err := rs.Replay(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if !JSONOutput {
+		fmt.Printf("replay finished after %s\r\n", time.Since(td).Round(time.Second))
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/config"
 	"github.com/willie68/osmltools/internal/logging"
 )
 
@@ -25,12 +26,14 @@ var (
 				cmd.Root().SilenceErrors = true
 				logging.Root.SetLevel(logging.None)
 			}
+			config.UserConfigFile = userConfigFile
 			internal.Init()
 		},
 	}
-	sdCardFolder string
-	verbose      bool
-	JSONOutput   bool
+	sdCardFolder   string
+	userConfigFile string
+	verbose        bool
+	JSONOutput     bool
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -51,6 +54,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&sdCardFolder, "sdcard", "s", "./", "root folder of the logger sd card")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&JSONOutput, "json", "", false, "output as json where applicable")
+	rootCmd.PersistentFlags().StringVarP(&userConfigFile, "config", "", "", "user config file. Default is osml/config.json in the user config dir")
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+type rpcSrv interface {
+	Serve(ctx context.Context, in io.Reader, out io.Writer) error
+}
+
+var rpcCmd = &cobra.Command{
+	Use:   "rpc",
+	Short: "json-rpc 2.0 over stdin/stdout for the UI",
+	Long: `a long running json-rpc 2.0 mode for the UI, one request per line on stdin, the responses and the progress notifications one per line on stdout.
+Methods: version, formats, check, convert, export, track.new, track.add, track.list, track.trim, logger.read, logger.write, cache.stats, cache.clear, shutdown.
+Logging is off, with --verbose the log is written to stderr.`,
+	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		cmd.Root().SilenceUsage = true
+		cmd.Root().SilenceErrors = true
+		JSONOutput = true
+		logging.Root.SetLevel(logging.None)
+		if verbose {
+			logging.Root.SetLevel(logging.Debug)
+		}
+		config.UserConfigFile = userConfigFile
+		internal.Init()
+	},
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return RPC(os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rpcCmd)
+}
+
+// RPC get the rpc service and serve the requests until the end of the input, shutdown or ctrl-c
+func RPC(in io.Reader, out io.Writer) error {
+	rs := do.MustInvokeAs[rpcSrv](internal.Inj)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return rs.Serve(ctx, in, out)
+}
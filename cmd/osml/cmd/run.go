@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/pipeline"
+)
+
+type pipelineSrv interface {
+	Run(ctx context.Context, file string, opts pipeline.Options) (*pipeline.Report, error)
+}
+
+// runCmd executes a pipeline file
+var runCmd = &cobra.Command{
+	Use:   "run <pipeline.yaml>",
+	Short: "executes a processing pipeline",
+	Long: `executes the steps (backup, check, touch, track, export) of a yaml pipeline file in the order of their dependencies.
+Steps which are up to date since the last run are skipped, so a failed or cancelled run is resumed by running it again.
+A summary report <name>.report.json is written to the work folder of the pipeline.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := pipeline.Options{}
+		opts.Force, _ = cmd.Flags().GetBool("force")
+		opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+		return Run(args[0], opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().Bool("force", false, "execute all steps, even if they are up to date")
+	runCmd.Flags().Bool("dry-run", false, "only show which steps would be executed")
+}
+
+// Run get the pipeline service and execute the pipeline file
+func Run(file string, opts pipeline.Options) error {
+	ps := do.MustInvokeAs[pipelineSrv](internal.Inj)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if !JSONOutput {
+		opts.Finished = func(sr pipeline.StepResult) {
+			fmt.Printf("%-12s %-8s %-10s %s\r\n", sr.ID, sr.Type, sr.Status, stepInfo(sr))
+		}
+	}
+	rep, err := ps.Run(ctx, file, opts)
+	if err != nil {
+		return err
+	}
+	if JSONOutput {
+		OutputAsJSON(rep)
+	} else {
+		fmt.Printf("%d done, %d up to date, %d pending, %d failed, %d blocked in %s\r\n", rep.Done, rep.UpToDate, rep.Pending, rep.Failed, rep.Blocked, rep.Finished.Sub(rep.Started).Round(time.Millisecond))
+	}
+	if rep.Failed > 0 {
+		return fmt.Errorf("%d steps of the pipeline %s failed", rep.Failed, rep.Name)
+	}
+	return nil
+}
+
+// stepInfo the error or the outputs of the step
+func stepInfo(sr pipeline.StepResult) string {
+	if sr.Error != "" {
+		if sr.Status == pipeline.StatusFailed && len(sr.Messages) > 0 {
+			return sr.Error + ": " + strings.Join(sr.Messages, "; ")
+		}
+		return sr.Error
+	}
+	return strings.Join(sr.Outputs, ", ")
+}
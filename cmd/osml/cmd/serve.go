@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/server"
+)
+
+type serverSrv interface {
+	Serve(ctx context.Context, opts server.Options) error
+}
+
+// serveCmd serves the rest api
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serves the osml tools as rest api",
+	Long: `serves check, export, backup and the track management as rest api under /api/v1, e.g. on the boat's raspberry pi for the crew's tablets.
+The card data is uploaded as zip, the work is done in a job queue, the status of the jobs can be polled and the results downloaded.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opts := server.Options{}
+		opts.Listen, _ = cmd.Flags().GetString("listen")
+		opts.DataDir, _ = cmd.Flags().GetString("data")
+		maxUpload, _ := cmd.Flags().GetInt64("max-upload")
+		opts.MaxUpload = maxUpload << 20
+		opts.Keep, _ = cmd.Flags().GetDuration("keep")
+		opts.QueueSize, _ = cmd.Flags().GetInt("queue")
+		opts.AllowNoPrivacy, _ = cmd.Flags().GetBool("allow-no-privacy")
+		if card, _ := cmd.Flags().GetBool("card"); card {
+			opts.SDCardFolder = sdCardFolder
+		}
+		return Serve(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringP("listen", "l", server.DefaultListen, "the listening address")
+	serveCmd.Flags().StringP("data", "d", "./osml-data", "the folder for the track files and the job results")
+	serveCmd.Flags().Int64("max-upload", server.DefaultMaxUpload>>20, "max size of an uploaded zip in MB")
+	serveCmd.Flags().Duration("keep", server.DefaultKeep, "finished jobs and their results are removed after this time")
+	serveCmd.Flags().Int("queue", server.DefaultQueueSize, "max number of waiting jobs")
+	serveCmd.Flags().Bool("allow-no-privacy", false, "clients may switch off the privacy zones with no-privacy=true, the api has no authentication")
+	serveCmd.Flags().Bool("card", false, "requests without uploaded zip work on the sd card of the server (--sdcard)")
+}
+
+// Serve get the server service and serve the rest api until ctrl-c
+func Serve(opts server.Options) error {
+	srv := do.MustInvokeAs[serverSrv](internal.Inj)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts.Started = func(address string) {
+		if JSONOutput {
+			OutputAsJSON(map[string]any{"address": address, "api": server.BasePath})
+			return
+		}
+		fmt.Printf("serving the rest api on %s%s\r\n", address, server.BasePath)
+	}
+	return srv.Serve(ctx, opts)
+}
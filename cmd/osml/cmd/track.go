@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/samber/do/v2"
 	"github.com/spf13/cobra"
@@ -13,6 +15,7 @@ type trackManager interface {
 	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
 	AddTrack(sdCardFolder string, files []string, trackfile string) error
 	ListTrack(trackfile string) (*model.Track, error)
+	TrimTrack(trackfile string, trim *model.Trim) (*model.Track, error)
 }
 
 var (
@@ -62,6 +65,28 @@ var (
 			return ListTrack(track)
 		},
 	}
+
+	trimTrackCmd = &cobra.Command{
+		Use:    "trim",
+		Short:  "trim the track by time range and/or clip area",
+		Long:   `trim the track by time range and/or clip area. The trim is saved in the track.json, the original data stays in the track file. Use --reset to remove the trim.`,
+		Hidden: false,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			trackfile, _ := cmd.Flags().GetString("track")
+			reset, _ := cmd.Flags().GetBool("reset")
+			trim, err := trimFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if trim == nil && !reset {
+				return errors.New("please give a time range or a clip area, or use --reset to remove the trim")
+			}
+			if reset {
+				trim = nil
+			}
+			return TrimTrack(trackfile, trim)
+		},
+	}
 )
 
 func init() {
@@ -69,15 +94,19 @@ func init() {
 	trackCmd.PersistentFlags().StringP("track", "t", "", "the track file to work with")
 
 	trackCmd.AddCommand(newTrackCmd)
-	newTrackCmd.Flags().StringSliceP("files", "f", []string{}, "files to process, separated by commas")
+	newTrackCmd.Flags().StringSliceP("files", "f", []string{}, "files to process: logger data files, gpx, kml, kmz, nmea, json, fit, sl2 or sl3, separated by commas")
 	newTrackCmd.Flags().StringP("name", "n", "track", "name of the track")
 	newTrackCmd.Flags().StringP("description", "d", "", "description of the track")
 	newTrackCmd.Flags().Int32P("vesselid", "i", 0, "vessel id")
 
 	trackCmd.AddCommand(addDataTrackCmd)
-	addDataTrackCmd.Flags().StringSliceP("files", "f", []string{}, "files to process, separated by commas")
+	addDataTrackCmd.Flags().StringSliceP("files", "f", []string{}, "files to process: logger data files, gpx, kml, kmz, nmea, json, fit, sl2 or sl3, separated by commas")
 
 	trackCmd.AddCommand(listTrackCmd)
+
+	trackCmd.AddCommand(trimTrackCmd)
+	addTrimFlags(trimTrackCmd)
+	trimTrackCmd.Flags().Bool("reset", false, "remove the trim, the whole data will be used again")
 }
 
 // NewTrack creates a new track file and adds the given data files to it
@@ -125,6 +154,9 @@ func ListTrack(trackfile string) error {
 		fmt.Printf("Name: %s\r\n", tr.Name)
 		fmt.Printf("Description: %s\r\n", tr.Description)
 		fmt.Printf("VesselID: %d\r\n", tr.VesselID)
+		if tr.Trim != nil {
+			fmt.Printf("Trim: from %s to %s, %d clip polygon(s)\r\n", formatTrimTime(tr.Trim.From), formatTrimTime(tr.Trim.To), len(tr.Trim.Clip))
+		}
 		fmt.Printf("Files: \r\n")
 		for _, f := range tr.Files {
 			fmt.Printf(" - %s (%d) \r\n", f.FileName, f.Size)
@@ -132,3 +164,28 @@ func ListTrack(trackfile string) error {
 	}
 	return err
 }
+
+// TrimTrack saves the trim into the given track file
+func TrimTrack(trackfile string, trim *model.Trim) error {
+	tm := do.MustInvokeAs[trackManager](internal.Inj)
+	tr, err := tm.TrimTrack(trackfile, trim)
+	if err == nil {
+		if JSONOutput {
+			js, err := tr.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Println(js)
+			return nil
+		}
+		fmt.Println("ok")
+	}
+	return err
+}
+
+func formatTrimTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
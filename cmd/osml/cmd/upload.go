@@ -6,6 +6,7 @@ import (
 	"github.com/samber/do/v2"
 	"github.com/spf13/cobra"
 	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/upload"
 )
 
 type credManager interface {
@@ -19,7 +20,7 @@ var (
 	uploadCmd = &cobra.Command{
 		Use:   "upload",
 		Short: "upload a track to oseam",
-		Long:  `upload a track to oseam using the given credetials`,
+		Long:  `upload a track to oseam using the given credetials. Positions inside the privacy zones are removed before uploading, use --no-privacy to upload the data unchanged.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cred := do.MustInvokeAs[credManager](internal.Inj)
 			user, _ := cmd.Flags().GetString("user")
@@ -38,7 +39,25 @@ var (
 				return nil
 			}
 			fmt.Println("Credentials ok")
-			return nil
+
+			file, _ := cmd.Flags().GetString("file")
+			if file == "" {
+				return nil
+			}
+			url, _ := cmd.Flags().GetString("url")
+			vesselID, _ := cmd.Flags().GetInt("vesselid")
+			zones, err := privacyZones(cmd)
+			if err != nil {
+				return err
+			}
+			fu := upload.FileUpload{
+				FilePath:     file,
+				VesselID:     vesselID,
+				Username:     user,
+				PrivacyZones: zones,
+				Checker:      do.MustInvokeAs[upload.Checker](internal.Inj),
+			}
+			return fu.Upload(url)
 		},
 	}
 )
@@ -48,4 +67,8 @@ func init() {
 
 	uploadCmd.Flags().StringP("user", "u", "", "user name of the credentials to use for the upload")
 	uploadCmd.Flags().StringP("password", "p", "", "password of the credentials to use for the upload")
+	uploadCmd.Flags().StringP("file", "f", "", "the track, nmea or logger data file to upload")
+	uploadCmd.Flags().String("url", "", "url of the upload service")
+	uploadCmd.Flags().IntP("vesselid", "i", 0, "vessel id")
+	addNoPrivacyFlag(uploadCmd)
 }
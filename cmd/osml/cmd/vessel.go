@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+var (
+	vesselCmd = &cobra.Command{
+		Use:   "vessel",
+		Short: "manage the sensor configuration of the vessels",
+		Long:  `manage the sensor configuration of the vessels in the user config. The configuration is used to reduce the measured depths to the waterline or the keel on export.`,
+	}
+
+	listVesselCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list all vessel configurations",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+			if err != nil {
+				return err
+			}
+			if JSONOutput {
+				OutputAsJSON(cfg.Vessels)
+				return nil
+			}
+			fmt.Printf("Vessels from %s\r\n", cfg.File())
+			for _, v := range cfg.Vessels {
+				fmt.Printf(" - %d %s: transducer %.2fm, keel offset %.2fm, lever arm forward %.2fm, starboard %.2fm\r\n",
+					v.VesselID, v.Name, v.TransducerDepth, v.KeelOffset, v.LeverArmForward, v.LeverArmStarboard)
+			}
+			return nil
+		},
+	}
+
+	setVesselCmd = &cobra.Command{
+		Use:   "set",
+		Short: "add or replace the sensor configuration of a vessel",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			id, _ := cmd.Flags().GetInt32("vesselid")
+			name, _ := cmd.Flags().GetString("name")
+			td, _ := cmd.Flags().GetFloat64("transducer-depth")
+			ko, _ := cmd.Flags().GetFloat64("keel-offset")
+			fwd, _ := cmd.Flags().GetFloat64("lever-forward")
+			stb, _ := cmd.Flags().GetFloat64("lever-starboard")
+			return SetVessel(model.VesselConfig{
+				VesselID:          id,
+				Name:              name,
+				TransducerDepth:   td,
+				KeelOffset:        ko,
+				LeverArmForward:   fwd,
+				LeverArmStarboard: stb,
+			})
+		},
+	}
+
+	removeVesselCmd = &cobra.Command{
+		Use:   "remove",
+		Short: "remove the sensor configuration of a vessel",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			id, _ := cmd.Flags().GetInt32("vesselid")
+			return RemoveVessel(id)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(vesselCmd)
+
+	vesselCmd.AddCommand(listVesselCmd)
+
+	vesselCmd.AddCommand(setVesselCmd)
+	setVesselCmd.Flags().Int32("vesselid", 0, "the id of the vessel")
+	setVesselCmd.Flags().StringP("name", "n", "", "name of the vessel")
+	setVesselCmd.Flags().Float64("transducer-depth", 0, "depth of the transducer below the waterline in meters")
+	setVesselCmd.Flags().Float64("keel-offset", 0, "distance from the transducer down to the keel in meters")
+	setVesselCmd.Flags().Float64("lever-forward", 0, "distance of the transducer forward of the gps antenna in meters, negative is aft")
+	setVesselCmd.Flags().Float64("lever-starboard", 0, "distance of the transducer to starboard of the gps antenna in meters, negative is port")
+
+	vesselCmd.AddCommand(removeVesselCmd)
+	removeVesselCmd.Flags().Int32("vesselid", 0, "the id of the vessel")
+}
+
+// SetVessel adds or replaces the sensor configuration of a vessel in the user config
+func SetVessel(v model.VesselConfig) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+	if err != nil {
+		return err
+	}
+	cfg.Vessels = slices.DeleteFunc(cfg.Vessels, func(o model.VesselConfig) bool {
+		return o.VesselID == v.VesselID
+	})
+	cfg.Vessels = append(cfg.Vessels, v)
+	return saveUserConfig(cfg)
+}
+
+// RemoveVessel removes the sensor configuration of the vessel from the user config
+func RemoveVessel(id int32) error {
+	cfg, err := do.Invoke[*config.UserConfig](internal.Inj)
+	if err != nil {
+		return err
+	}
+	l := len(cfg.Vessels)
+	cfg.Vessels = slices.DeleteFunc(cfg.Vessels, func(o model.VesselConfig) bool {
+		return o.VesselID == id
+	})
+	if l == len(cfg.Vessels) {
+		return fmt.Errorf("vessel %d not found", id)
+	}
+	return saveUserConfig(cfg)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/samber/do/v2"
+	"github.com/spf13/cobra"
+	"github.com/willie68/osmltools/internal"
+	"github.com/willie68/osmltools/internal/watch"
+)
+
+type watchSrv interface {
+	Watch(ctx context.Context, opts watch.Options) error
+}
+
+// watchCmd takes in the inserted sd cards
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "watches for inserted sd cards and takes in the new data",
+	Long: `watches the mount folder for sd cards with config.dat and DATA*.DAT files (inotify on linux, polling otherwise) and runs the pipeline on every inserted card:
+backup of the card, check with report, the new data is added to a track per trip and optional the tracks are uploaded.
+Files already taken in are recognized by their content hash and skipped.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opts := watch.Options{}
+		opts.Mount, _ = cmd.Flags().GetString("mount")
+		opts.DataDir, _ = cmd.Flags().GetString("data")
+		steps, _ := cmd.Flags().GetString("steps")
+		var err error
+		opts.Steps, err = watch.ParseSteps(steps)
+		if err != nil {
+			return err
+		}
+		opts.Interval, _ = cmd.Flags().GetDuration("interval")
+		opts.Settle, _ = cmd.Flags().GetDuration("settle")
+		opts.TripGap, _ = cmd.Flags().GetDuration("trip-gap")
+		opts.UploadURL, _ = cmd.Flags().GetString("url")
+		opts.UploadUser, _ = cmd.Flags().GetString("user")
+		opts.NoPrivacy, _ = cmd.Flags().GetBool("no-privacy")
+		opts.Once, _ = cmd.Flags().GetBool("once")
+		return Watch(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringP("mount", "m", "/media", "the folder the sd cards are mounted in")
+	watchCmd.Flags().StringP("data", "d", "./osml-data", "the folder for the backups, the intakes and the track files")
+	watchCmd.Flags().String("steps", "backup,check,track", "the pipeline, comma separated: backup, check, track, upload")
+	watchCmd.Flags().Duration("interval", watch.DefaultInterval, "the poll interval")
+	watchCmd.Flags().Duration("settle", watch.DefaultSettle, "wait time after a card is found, before it's read")
+	watchCmd.Flags().Duration("trip-gap", watch.DefaultTripGap, "files with a larger gap belong to different trips")
+	watchCmd.Flags().String("url", "", "url of the upload service, needed for the upload step")
+	watchCmd.Flags().StringP("user", "u", "", "user name for the upload")
+	watchCmd.Flags().Bool("once", false, "take in the cards already inserted and stop")
+	addNoPrivacyFlag(watchCmd)
+}
+
+// Watch get the watch service and take in the cards until ctrl-c
+func Watch(opts watch.Options) error {
+	ws := do.MustInvokeAs[watchSrv](internal.Inj)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts.Started = func(notify bool) {
+		if JSONOutput {
+			return
+		}
+		mode := "polling"
+		if notify {
+			mode = "notifications"
+		}
+		fmt.Printf("watching %s for sd cards (%s)\r\n", opts.Mount, mode)
+	}
+	opts.Intaken = func(in watch.Intake) {
+		if JSONOutput {
+			OutputAsJSON(in)
+			return
+		}
+		switch {
+		case in.Error != "" && len(in.Files) == 0:
+			fmt.Printf("%s: intake failed: %s\r\n", in.Card, in.Error)
+		case len(in.Files) == 0:
+			fmt.Printf("%s: no new data, %d files already taken in\r\n", in.Card, len(in.Duplicates))
+		default:
+			fmt.Printf("%s: %d new files, %d already taken in, %d errors, tracks: %v\r\n", in.Card, len(in.Files), len(in.Duplicates), in.ErrorCount, in.Tracks)
+			if in.Error != "" {
+				fmt.Printf("%s: %s\r\n", in.Card, in.Error)
+			}
+		}
+	}
+	return ws.Watch(ctx, opts)
+}
@@ -185,6 +185,7 @@ func (c *checker) AnalyseLoggerFile(fr *model.FileResult, lf string) ([]*model.L
 			}
 		}
 		if ok {
+			ll.Source = filepath.Base(lf)
 			ls = append(ls, ll)
 		}
 	}
@@ -17,6 +17,7 @@ const testdata = "../../testdata"
 
 type checkerSrv interface {
 	Check(sdCardFolder, outputFolder string, overwrite, report bool) (*model.CheckResult, error)
+	Touch(sdCardFolder string, files []string) (*model.GeneralResult, error)
 }
 
 type CheckSuite struct {
@@ -83,3 +84,18 @@ func (s *CheckSuite) TestCheckEmptyFile() {
 	s.ast.NoError(err)
 	s.ast.Equal(1, res.ErrorCount)
 }
+
+func (s *CheckSuite) TestTouchAllFiles() {
+	dir := s.T().TempDir()
+	df := filepath.Join(dir, "DATA001231.DAT")
+	data, err := os.ReadFile(filepath.Join(testdata, "sdcard", "DATA001231.DAT"))
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(df, data, 0o600))
+
+	res, err := s.chk.Touch(dir, nil)
+	s.Require().NoError(err)
+	s.True(res.Result, res.Messages)
+	fi, err := os.Stat(df)
+	s.Require().NoError(err)
+	s.Equal(2016, fi.ModTime().Year())
+}
@@ -21,10 +21,14 @@ func (c *checker) Touch(sdCardFolder string, files []string) (*model.GeneralResu
 	}
 
 	if fs.IsDir() && (len(files) == 0) {
-		files, err = osml.GetDataFiles(sdCardFolder)
+		dfs, err := osml.GetDataFiles(sdCardFolder)
 		if err != nil {
 			return nil, err
 		}
+		// the files are relative to the sd card folder
+		for _, df := range dfs {
+			files = append(files, filepath.Base(df))
+		}
 	}
 
 	if !fs.IsDir() {
@@ -0,0 +1,102 @@
+package colorscale
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/willie68/osmltools/internal/raster"
+)
+
+// Ramp the default colour ramp from blue (low values) to red (high values)
+var Ramp = []color.RGBA{
+	{R: 0x30, G: 0x12, B: 0x9a, A: 0xff},
+	{R: 0x1f, G: 0x5f, B: 0xe0, A: 0xff},
+	{R: 0x1b, G: 0xa6, B: 0xd8, A: 0xff},
+	{R: 0x2c, G: 0xc7, B: 0x8b, A: 0xff},
+	{R: 0x8c, G: 0xd6, B: 0x2e, A: 0xff},
+	{R: 0xe8, G: 0xc9, B: 0x2a, A: 0xff},
+	{R: 0xf5, G: 0x7f, B: 0x1e, A: 0xff},
+	{R: 0xc6, G: 0x1c, B: 0x12, A: 0xff},
+}
+
+// NoData the colour for points without a value
+var NoData = color.RGBA{R: 0x9e, G: 0x9e, B: 0x9e, A: 0xff}
+
+// Scale maps values between min and max to a colour ramp
+type Scale struct {
+	Min    float64
+	Max    float64
+	Colors []color.RGBA
+}
+
+// New creates a scale with the default ramp
+func New(minV, maxV float64) *Scale {
+	return &Scale{
+		Min:    minV,
+		Max:    maxV,
+		Colors: Ramp,
+	}
+}
+
+// Reversed returns a copy of the scale with the colours in reverse order, e.g. red for shallow water
+func (s *Scale) Reversed() *Scale {
+	cs := make([]color.RGBA, len(s.Colors))
+	for i, c := range s.Colors {
+		cs[len(s.Colors)-1-i] = c
+	}
+	return &Scale{Min: s.Min, Max: s.Max, Colors: cs}
+}
+
+// Index the index of the colour of the value, values outside the range are clamped
+func (s *Scale) Index(v float64) int {
+	n := len(s.Colors)
+	if s.Max <= s.Min || math.IsNaN(v) {
+		return 0
+	}
+	i := int((v - s.Min) / (s.Max - s.Min) * float64(n))
+	return max(0, min(n-1, i))
+}
+
+// Color the colour of the value
+func (s *Scale) Color(v float64) color.RGBA {
+	return s.Colors[s.Index(v)]
+}
+
+// Limits the lower limit of the colour class i
+func (s *Scale) Limits(i int) float64 {
+	return s.Min + (s.Max-s.Min)*float64(i)/float64(len(s.Colors))
+}
+
+// Legend draws a legend image with the title, one box per colour class with the value range
+func (s *Scale) Legend(title string, precision int) *raster.Canvas {
+	const (
+		scale = 2
+		pad   = 8
+		box   = 18
+	)
+	lines := make([]string, len(s.Colors))
+	width := raster.TextWidth(title, scale)
+	for i := range s.Colors {
+		lines[i] = fmt.Sprintf("%.*f - %.*f", precision, s.Limits(i), precision, s.Limits(i+1))
+		width = max(width, box+pad+raster.TextWidth(lines[i], scale))
+	}
+	lh := box + 4
+	height := pad + raster.GlyphHeight*scale + pad + len(s.Colors)*lh + pad
+	c := raster.New(width+2*pad, height, color.White)
+	c.StrokeRect(0, 0, width+2*pad, height, color.Black)
+	c.Text(pad, pad, title, scale, color.Black)
+	y := pad + raster.GlyphHeight*scale + pad
+	for i, col := range s.Colors {
+		c.FillRect(pad, y, pad+box, y+box, col)
+		c.Text(pad+box+pad, y+(box-raster.GlyphHeight*scale)/2, lines[i], scale, color.Black)
+		y += lh
+	}
+	return c
+}
+
+// LegendPNG writes the legend as png
+func (s *Scale) LegendPNG(w io.Writer, title string, precision int) error {
+	return s.Legend(title, precision).EncodePNG(w)
+}
@@ -0,0 +1,38 @@
+package colorscale
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ColorScaleSuite struct {
+	suite.Suite
+}
+
+func TestColorScaleSuite(t *testing.T) {
+	suite.Run(t, new(ColorScaleSuite))
+}
+
+func (s *ColorScaleSuite) TestIndex() {
+	sc := New(0, 8)
+	s.Equal(0, sc.Index(-1))
+	s.Equal(0, sc.Index(0.5))
+	s.Equal(3, sc.Index(3.5))
+	s.Equal(7, sc.Index(8))
+	s.Equal(7, sc.Index(100))
+	s.Equal(Ramp[7], sc.Color(100))
+	s.Equal(Ramp[0], sc.Reversed().Color(100))
+	s.Equal(0, New(1, 1).Index(5))
+}
+
+func (s *ColorScaleSuite) TestLegend() {
+	var buf bytes.Buffer
+	s.Require().NoError(New(0, 20).LegendPNG(&buf, "Depth (m)", 1))
+	img, err := png.Decode(&buf)
+	s.Require().NoError(err)
+	s.Positive(img.Bounds().Dx())
+	s.Positive(img.Bounds().Dy())
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+var (
+	// UserConfigFile the file of the user configuration, if empty the default location in the user config dir is used
+	UserConfigFile = ""
+)
+
+// UserConfig the configuration of the user, saved as json in the user config dir
+type UserConfig struct {
+	PrivacyZones model.PrivacyZones  `json:"privacyZones,omitempty"`
+	Vessels      model.VesselConfigs `json:"vessels,omitempty"`
+	file         string
+}
+
+func provideUserConfig(inj do.Injector) {
+	do.Provide(inj, func(_ do.Injector) (*UserConfig, error) {
+		fn, err := UserConfigFilename()
+		if err != nil {
+			return nil, err
+		}
+		return LoadUserConfig(fn)
+	})
+}
+
+// UserConfigFilename returns the file name of the user config, default is <user config dir>/osml/config.json
+func UserConfigFilename() (string, error) {
+	if UserConfigFile != "" {
+		return UserConfigFile, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "osml", "config.json"), nil
+}
+
+// LoadUserConfig loads the user config from the file. A missing file results in an empty config.
+func LoadUserConfig(fn string) (*UserConfig, error) {
+	cfg := &UserConfig{
+		PrivacyZones: make(model.PrivacyZones, 0),
+		file:         fn,
+	}
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	for _, z := range cfg.PrivacyZones {
+		if err := z.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range cfg.Vessels {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Save saves the user config to the file it was loaded from
+func (c *UserConfig) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.file), os.ModePerm); err != nil {
+		return err
+	}
+	js, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.file, js, 0o600)
+}
+
+// File the file of this user config
+func (c *UserConfig) File() string {
+	return c.file
+}
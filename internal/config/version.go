@@ -23,6 +23,7 @@ func Init(inj do.Injector) {
 	do.Provide(inj, func(_ do.Injector) (*Version, error) {
 		return NewVersion(), nil
 	})
+	provideUserConfig(inj)
 }
 
 // NewVersion creating a new version
@@ -0,0 +1,200 @@
+package contour
+
+import (
+	"math"
+	"sort"
+
+	"github.com/willie68/osmltools/internal/geo"
+	"github.com/willie68/osmltools/internal/grid"
+)
+
+// Line a contour line (isobath), the coordinates are [lon, lat]. Closed lines have the same first and last position.
+type Line struct {
+	Depth  float64
+	Closed bool
+	Coords [][2]float64
+}
+
+// eid the id of a raster edge, the horizontal (h = true) or vertical edge starting at node x, y
+type eid struct {
+	x, y int
+	h    bool
+}
+
+// Trace generates the contour lines of the level with the marching squares algorithm. Squares with an empty node are
+// skipped, so the lines end at the border of the data.
+func Trace(r *grid.Raster, level float64) []Line {
+	if r == nil {
+		return nil
+	}
+	pos := make(map[eid][2]float64)
+	segs := make([][2]eid, 0)
+	crossing := func(e eid, v0, v1 float64) {
+		if _, ok := pos[e]; ok {
+			return
+		}
+		t := (level - v0) / (v1 - v0)
+		if e.h {
+			pos[e] = [2]float64{float64(e.x) + t, float64(e.y)}
+			return
+		}
+		pos[e] = [2]float64{float64(e.x), float64(e.y) + t}
+	}
+
+	for y := 0; y < r.Rows-1; y++ {
+		for x := 0; x < r.Cols-1; x++ {
+			bl, br, tr, tl := r.At(x, y), r.At(x+1, y), r.At(x+1, y+1), r.At(x, y+1)
+			if math.IsNaN(bl) || math.IsNaN(br) || math.IsNaN(tr) || math.IsNaN(tl) {
+				continue
+			}
+			c := 0
+			for i, v := range []float64{bl, br, tr, tl} {
+				if v >= level {
+					c |= 1 << i
+				}
+			}
+			if c == 0 || c == 15 {
+				continue
+			}
+			b, rt, t, l := eid{x, y, true}, eid{x + 1, y, false}, eid{x, y + 1, true}, eid{x, y, false}
+			crossed := make([]eid, 0, 4)
+			if (c&1 != 0) != (c&2 != 0) {
+				crossing(b, bl, br)
+				crossed = append(crossed, b)
+			}
+			if (c&2 != 0) != (c&4 != 0) {
+				crossing(rt, br, tr)
+				crossed = append(crossed, rt)
+			}
+			if (c&8 != 0) != (c&4 != 0) {
+				crossing(t, tl, tr)
+				crossed = append(crossed, t)
+			}
+			if (c&1 != 0) != (c&8 != 0) {
+				crossing(l, bl, tl)
+				crossed = append(crossed, l)
+			}
+			if len(crossed) == 2 {
+				segs = append(segs, [2]eid{crossed[0], crossed[1]})
+				continue
+			}
+			// saddle, the centre value decides which corners are connected
+			centre := (bl+br+tr+tl)/4 >= level
+			if (c == 5) == centre {
+				segs = append(segs, [2]eid{b, rt}, [2]eid{t, l})
+			} else {
+				segs = append(segs, [2]eid{b, l}, [2]eid{rt, t})
+			}
+		}
+	}
+
+	lines := join(segs)
+	res := make([]Line, 0, len(lines))
+	for _, ids := range lines {
+		ln := Line{Depth: level, Closed: len(ids) > 2 && ids[0] == ids[len(ids)-1]}
+		ln.Coords = make([][2]float64, len(ids))
+		for i, id := range ids {
+			p := pos[id]
+			lat, lon := r.Position(p[0], p[1])
+			ln.Coords[i] = [2]float64{lon, lat}
+		}
+		res = append(res, ln)
+	}
+	return res
+}
+
+// join connects the segments to lines, open lines first, then the closed rings
+func join(segs [][2]eid) [][]eid {
+	adj := make(map[eid][]int)
+	for i, s := range segs {
+		adj[s[0]] = append(adj[s[0]], i)
+		adj[s[1]] = append(adj[s[1]], i)
+	}
+	used := make([]bool, len(segs))
+	follow := func(start eid, si int) []eid {
+		line := []eid{start}
+		cur := start
+		for si >= 0 {
+			used[si] = true
+			s := segs[si]
+			next := s[0]
+			if next == cur {
+				next = s[1]
+			}
+			line = append(line, next)
+			cur = next
+			si = -1
+			for _, j := range adj[cur] {
+				if !used[j] {
+					si = j
+					break
+				}
+			}
+		}
+		return line
+	}
+
+	// deterministic order of the start points
+	ends := make([]eid, 0)
+	for e, ss := range adj {
+		if len(ss) == 1 {
+			ends = append(ends, e)
+		}
+	}
+	sort.Slice(ends, func(i, j int) bool { return less(ends[i], ends[j]) })
+
+	lines := make([][]eid, 0)
+	for _, e := range ends {
+		if si := adj[e][0]; !used[si] {
+			lines = append(lines, follow(e, si))
+		}
+	}
+	for i, s := range segs {
+		if !used[i] {
+			lines = append(lines, follow(s[0], i))
+		}
+	}
+	return lines
+}
+
+func less(a, b eid) bool {
+	if a.y != b.y {
+		return a.y < b.y
+	}
+	if a.x != b.x {
+		return a.x < b.x
+	}
+	return !a.h && b.h
+}
+
+// Smooth smoothes the line with iterations of chaikins corner cutting, the ends of open lines are kept
+func (l *Line) Smooth(iterations int) {
+	for range iterations {
+		n := len(l.Coords)
+		if n < 3 {
+			return
+		}
+		cs := make([][2]float64, 0, 2*n)
+		if !l.Closed {
+			cs = append(cs, l.Coords[0])
+		}
+		for i := 0; i < n-1; i++ {
+			p, q := l.Coords[i], l.Coords[i+1]
+			cs = append(cs,
+				[2]float64{0.75*p[0] + 0.25*q[0], 0.75*p[1] + 0.25*q[1]},
+				[2]float64{0.25*p[0] + 0.75*q[0], 0.25*p[1] + 0.75*q[1]},
+			)
+		}
+		if l.Closed {
+			cs = append(cs, cs[0])
+		} else {
+			cs = append(cs, l.Coords[n-1])
+		}
+		l.Coords = cs
+	}
+}
+
+// Simplify simplifies the line with douglas peucker, tolerance in meters
+func (l *Line) Simplify(tolerance float64) {
+	l.Coords = geo.Simplify(l.Coords, tolerance)
+}
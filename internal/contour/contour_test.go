@@ -0,0 +1,183 @@
+package contour
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/grid"
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+type ContourSuite struct {
+	suite.Suite
+}
+
+func TestContourSuite(t *testing.T) {
+	suite.Run(t, new(ContourSuite))
+}
+
+// raster a raster with the values of f
+func (s *ContourSuite) raster(cols, rows int, f func(x, y float64) float64) *grid.Raster {
+	r := &grid.Raster{MinLon: 8.5, MinLat: 47.5, DLon: 0.0001, DLat: 0.0001, Cols: cols, Rows: rows}
+	r.Values = make([]float64, cols*rows)
+	for y := range rows {
+		for x := range cols {
+			r.Values[y*cols+x] = f(float64(x), float64(y))
+		}
+	}
+	return r
+}
+
+func (s *ContourSuite) TestTraceClosed() {
+	// a hole with 10 m in the middle
+	r := s.raster(11, 11, func(x, y float64) float64 {
+		return 10 - math.Hypot(x-5, y-5)
+	})
+	lines := Trace(r, 7)
+	s.Require().Len(lines, 1)
+	l := lines[0]
+	s.True(l.Closed)
+	s.Equal(l.Coords[0], l.Coords[len(l.Coords)-1])
+	s.Equal(7.0, l.Depth)
+	for _, c := range l.Coords {
+		dx, dy := (c[0]-8.5)/0.0001-5, (c[1]-47.5)/0.0001-5
+		s.InDelta(3.0, math.Hypot(dx, dy), 0.2)
+	}
+	s.Empty(Trace(r, 20))
+	s.Nil(Trace(nil, 5))
+}
+
+func (s *ContourSuite) TestTraceOpen() {
+	// a slope from 0 to 9 m to the east, the line runs north to south
+	r := s.raster(10, 5, func(x, _ float64) float64 { return x })
+	lines := Trace(r, 4.5)
+	s.Require().Len(lines, 1)
+	l := lines[0]
+	s.False(l.Closed)
+	s.Len(l.Coords, 5)
+	for _, c := range l.Coords {
+		s.InDelta(8.5+4.5*0.0001, c[0], 1e-9)
+	}
+}
+
+func (s *ContourSuite) TestTraceNoData() {
+	r := s.raster(10, 5, func(x, _ float64) float64 { return x })
+	r.Values[2*10+4] = math.NaN()
+	// the empty node interrupts the line
+	s.Len(Trace(r, 4.5), 2)
+}
+
+func (s *ContourSuite) TestTraceSaddle() {
+	r := s.raster(2, 2, func(x, y float64) float64 {
+		if x == y {
+			return 10
+		}
+		return 0
+	})
+	lines := Trace(r, 5)
+	s.Len(lines, 2)
+	for _, l := range lines {
+		s.Len(l.Coords, 2)
+	}
+}
+
+func (s *ContourSuite) TestSmoothSimplify() {
+	l := Line{Coords: [][2]float64{{8.5, 47.5}, {8.501, 47.5}, {8.501, 47.501}}}
+	l.Smooth(2)
+	s.Len(l.Coords, 12)
+	s.Equal([2]float64{8.5, 47.5}, l.Coords[0])
+	s.Equal([2]float64{8.501, 47.501}, l.Coords[len(l.Coords)-1])
+	l.Simplify(100)
+	s.Len(l.Coords, 2)
+
+	ring := Line{Closed: true, Coords: [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	ring.Smooth(1)
+	s.Len(ring.Coords, 9)
+	s.Equal(ring.Coords[0], ring.Coords[8])
+}
+
+func (s *ContourSuite) TestWriteGeoJSON() {
+	lines := []Line{
+		{Depth: 5, Coords: [][2]float64{{8.5, 47.5}, {8.6, 47.6}}},
+		{Depth: 2, Coords: [][2]float64{{8.5, 47.5}, {8.6, 47.6}}},
+		{Depth: 5, Coords: [][2]float64{{8.7, 47.5}, {8.8, 47.6}}},
+	}
+	var buf bytes.Buffer
+	s.Require().NoError(WriteGeoJSON(&buf, lines))
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Type        string         `json:"type"`
+				Coordinates [][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				Depth float64 `json:"depth"`
+				Lines int     `json:"lines"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	s.Require().NoError(json.Unmarshal(buf.Bytes(), &fc))
+	s.Require().Len(fc.Features, 2)
+	s.Equal(2.0, fc.Features[0].Properties.Depth)
+	s.Equal("MultiLineString", fc.Features[1].Geometry.Type)
+	s.Equal(2, fc.Features[1].Properties.Lines)
+	s.Len(fc.Features[1].Geometry.Coordinates, 2)
+
+	buf.Reset()
+	s.Require().NoError(WriteKML(&buf, "test", lines))
+	kml := buf.String()
+	s.Equal(2, strings.Count(kml, "<Placemark>"))
+	s.Equal(3, strings.Count(kml, "<LineString>"))
+	s.Contains(kml, "<name>5 m</name>")
+	s.Contains(kml, "8.7,47.5")
+}
+
+type gridMock struct {
+	g *grid.Grid
+}
+
+func (m *gridMock) Build(_ []string, _ grid.Options) (*grid.Grid, *grid.Result, error) {
+	return m.g, &grid.Result{Soundings: 100, Cells: m.g.Len()}, nil
+}
+
+func (s *ContourSuite) TestContours() {
+	g, err := grid.New(10, grid.AggMean, 0)
+	s.Require().NoError(err)
+	// a slope from 0 to 10 m to the east
+	for y := range 10 {
+		for x := range 10 {
+			if x == 5 && y == 5 {
+				continue
+			}
+			g.Add(47.5+float64(y)*0.00009, 8.5+float64(x)*0.00013, float64(x)+0.5)
+		}
+	}
+	c := &contourer{log: *logging.New().WithName("Contour"), grid: &gridMock{g: g}}
+	dir := s.T().TempDir()
+
+	out := filepath.Join(dir, "contours.kml")
+	res, err := c.Contours([]string{"x.xyz"}, out, Options{Levels: []float64{2, 5}, Smooth: 1, Tolerance: 1})
+	s.Require().NoError(err)
+	s.Equal(2, res.Lines)
+	s.Equal([]float64{2, 5}, res.Levels)
+	data, _ := os.ReadFile(out)
+	s.Contains(string(data), "<kml")
+
+	_, err = c.Contours([]string{"x.xyz"}, filepath.Join(dir, "c.geojson"), Options{Levels: []float64{-2}})
+	s.Error(err)
+	_, err = c.Contours([]string{"x.xyz"}, filepath.Join(dir, "c.geojson"), Options{Format: "shp"})
+	s.Error(err)
+	_, err = c.Contours([]string{"x.xyz"}, filepath.Join(dir, "c.geojson"), Options{Interpolation: "spline"})
+	s.Error(err)
+
+	// an outlier far away
+	g.Add(0.0001, 0.0001, 3.0)
+	_, err = c.Contours([]string{"x.xyz"}, filepath.Join(dir, "c.geojson"), Options{})
+	s.ErrorIs(err, grid.ErrTooLarge)
+}
@@ -0,0 +1,84 @@
+package contour
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/twpayne/go-kml/v3"
+	"github.com/willie68/osmltools/internal/colorscale"
+)
+
+// byLevel groups the lines by depth, sorted from shallow to deep
+func byLevel(lines []Line) ([]float64, map[float64][]Line) {
+	m := make(map[float64][]Line)
+	for _, l := range lines {
+		m[l.Depth] = append(m[l.Depth], l)
+	}
+	levels := make([]float64, 0, len(m))
+	for d := range m {
+		levels = append(levels, d)
+	}
+	slices.Sort(levels)
+	return levels, m
+}
+
+// WriteGeoJSON writes one MultiLineString feature per level with the properties depth and lines
+func WriteGeoJSON(w io.Writer, lines []Line) error {
+	levels, m := byLevel(lines)
+	features := make([]map[string]any, 0, len(levels))
+	for _, d := range levels {
+		coords := make([][][2]float64, 0, len(m[d]))
+		for _, l := range m[d] {
+			coords = append(coords, l.Coords)
+		}
+		features = append(features, map[string]any{
+			"type": "Feature",
+			"geometry": map[string]any{
+				"type":        "MultiLineString",
+				"coordinates": coords,
+			},
+			"properties": map[string]any{
+				"depth": d,
+				"lines": len(m[d]),
+			},
+		})
+	}
+	return json.NewEncoder(w).Encode(map[string]any{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
+
+// WriteKML writes one placemark per level, coloured from red (shallow) to blue (deep)
+func WriteKML(w io.Writer, name string, lines []Line) error {
+	levels, m := byLevel(lines)
+	doc := kml.Document(
+		kml.Name(name),
+		kml.Description(fmt.Sprintf("Depth contours generated with osmltools - %d levels, %d lines", len(levels), len(lines))),
+	)
+	if len(levels) > 0 {
+		scale := colorscale.New(levels[0], levels[len(levels)-1]).Reversed()
+		for i, d := range levels {
+			doc.Append(kml.SharedStyle(fmt.Sprintf("level%d", i), kml.LineStyle(kml.Color(scale.Color(d)), kml.Width(2))))
+		}
+	}
+	for i, d := range levels {
+		geoms := make([]kml.Element, 0, len(m[d]))
+		for _, l := range m[d] {
+			cs := make([]kml.Coordinate, 0, len(l.Coords))
+			for _, c := range l.Coords {
+				cs = append(cs, kml.Coordinate{Lon: c[0], Lat: c[1]})
+			}
+			geoms = append(geoms, kml.LineString(kml.Tessellate(true), kml.Coordinates(cs...)))
+		}
+		doc.Append(kml.Placemark(
+			kml.Name(strconv.FormatFloat(d, 'f', -1, 64)+" m"),
+			kml.StyleURL(fmt.Sprintf("#level%d", i)),
+			kml.MultiGeometry(geoms...),
+		))
+	}
+	return kml.KML(doc).WriteIndent(w, "", "  ")
+}
@@ -0,0 +1,145 @@
+package contour
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/grid"
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+const (
+	// FormatGeoJSON geojson with one MultiLineString per level
+	FormatGeoJSON = "geojson"
+	// FormatKML kml with one placemark per level
+	FormatKML = "kml"
+)
+
+// DefaultLevels the default depths of the contour lines in meters
+var DefaultLevels = []float64{2, 5, 10, 20}
+
+type gridSrv interface {
+	Build(inputs []string, opts grid.Options) (*grid.Grid, *grid.Result, error)
+}
+
+// Options options for the contour generation
+type Options struct {
+	// Grid cell size, aggregation and max samples of the grid the contours are traced on
+	Grid grid.Options
+	// Levels the depths of the contour lines
+	Levels []float64
+	// Interpolation idw, tin or none
+	Interpolation string
+	// Radius search radius (idw) or max triangle edge (tin) in cells
+	Radius int
+	// Smooth number of smoothing iterations
+	Smooth int
+	// Tolerance simplification tolerance in meters, 0 for no simplification
+	Tolerance float64
+	// Format geojson or kml, default is taken from the output file extension
+	Format string
+}
+
+// Result the result of the contour generation
+type Result struct {
+	Soundings int64     `json:"soundings"`
+	Cells     int       `json:"cells"`
+	Levels    []float64 `json:"levels"`
+	Lines     int       `json:"lines"`
+	Points    int       `json:"points"`
+	File      string    `json:"file"`
+}
+
+type contourer struct {
+	log  logging.Logger
+	grid gridSrv
+}
+
+// Init registers the contour service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(inj do.Injector) (*contourer, error) {
+		return &contourer{
+			log:  *logging.New().WithName("Contour"),
+			grid: do.MustInvokeAs[gridSrv](inj),
+		}, nil
+	})
+}
+
+// Contours generates the contour lines of the soundings of the inputs (track files or xyz exports). The soundings are
+// gridded, the empty cells interpolated and the lines traced with marching squares, smoothed and simplified.
+func (c *contourer) Contours(inputs []string, output string, opts Options) (*Result, error) {
+	format := opts.Format
+	if format == "" {
+		format = FormatGeoJSON
+		if strings.EqualFold(filepath.Ext(output), ".kml") {
+			format = FormatKML
+		}
+	}
+	if format != FormatGeoJSON && format != FormatKML {
+		return nil, fmt.Errorf("unknown contour format %s, use %s or %s", format, FormatGeoJSON, FormatKML)
+	}
+	levels := opts.Levels
+	if len(levels) == 0 {
+		levels = DefaultLevels
+	}
+	for _, l := range levels {
+		if l <= 0 {
+			return nil, fmt.Errorf("invalid contour level %g, levels must be > 0", l)
+		}
+	}
+
+	gr, gres, err := c.grid.Build(inputs, opts.Grid)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gr.Raster()
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("no soundings found")
+	}
+	if err := r.Interpolate(opts.Interpolation, opts.Radius); err != nil {
+		return nil, err
+	}
+
+	res := &Result{Soundings: gres.Soundings, Cells: gres.Cells, Levels: levels, File: output}
+	lines := make([]Line, 0)
+	for _, level := range levels {
+		for _, l := range Trace(r, level) {
+			l.Smooth(opts.Smooth)
+			l.Simplify(opts.Tolerance)
+			if len(l.Coords) < 2 {
+				continue
+			}
+			res.Points += len(l.Coords)
+			lines = append(lines, l)
+		}
+	}
+	res.Lines = len(lines)
+	c.log.Infof("%d contour lines with %d points", res.Lines, res.Points)
+
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	err = write(f, format, strings.TrimSuffix(filepath.Base(output), filepath.Ext(output)), lines)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return res, err
+}
+
+func write(w io.Writer, format, name string, lines []Line) error {
+	if format == FormatKML {
+		return WriteKML(w, name, lines)
+	}
+	return WriteGeoJSON(w, lines)
+}
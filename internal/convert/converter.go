@@ -56,6 +56,9 @@ func (c *converter) TrackPoints(trackfile string) (*model.TrackPoints, error) {
 
 func (c *converter) NewTrackPoints(trackfile string) (*model.TrackPoints, error) {
 	track, nmealines, err := trackutils.ReadTrackAndNmea(trackfile)
+	if err != nil {
+		return nil, err
+	}
 
 	lls, err := model.ParseLines2LogLines(nmealines, false)
 	if err != nil {
@@ -67,6 +70,7 @@ func (c *converter) NewTrackPoints(trackfile string) (*model.TrackPoints, error)
 		Name:     track.Name,
 		LogLines: lls,
 	}
+	tps.ApplyTrim(track.Trim)
 
 	tps, err = model.GetWaypoints(tps)
 	if err != nil {
@@ -0,0 +1,47 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/check"
+)
+
+type ConverterSuite struct {
+	suite.Suite
+	c   *converter
+	dir string
+}
+
+func TestConverterSuite(t *testing.T) {
+	suite.Run(t, new(ConverterSuite))
+}
+
+func (s *ConverterSuite) SetupTest() {
+	inj := do.New()
+	check.Init(inj)
+	Init(inj)
+	s.c = do.MustInvoke[*converter](inj)
+	s.dir = s.T().TempDir()
+}
+
+func (s *ConverterSuite) TestTrackPoints() {
+	tps, err := s.c.TrackPoints("../../testdata/tracks/Zürichsee.zip")
+	s.Require().NoError(err)
+	s.NotEmpty(tps.Waypoints)
+}
+
+func (s *ConverterSuite) TestInvalidTrack() {
+	corrupt := filepath.Join(s.dir, "corrupt.zip")
+	s.Require().NoError(os.WriteFile(corrupt, []byte("no zip file"), 0o600))
+	for _, tf := range []string{corrupt, filepath.Join(s.dir, "missing.zip")} {
+		tps, err := s.c.TrackPoints(tf)
+		s.Error(err, tf)
+		s.Nil(tps, tf)
+		_, err = s.c.Convert("", nil, tf)
+		s.Error(err, tf)
+	}
+}
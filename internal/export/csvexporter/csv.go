@@ -0,0 +1,263 @@
+package csvexporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// Format the name of the csv format
+	Format = "CSV"
+
+	defaultColumns = "time,lat,lon,sog,cog,depth"
+)
+
+// column a single csv column, with the header (documenting the unit) and the value function
+type column struct {
+	headers []string
+	values  func(e *CSVExporter, wpt *model.Waypoint) []string
+}
+
+var columns = map[string]column{
+	"time": {
+		headers: []string{"time (UTC)"},
+		values: func(_ *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{wpt.Time.UTC().Format(time.RFC3339Nano)}
+		},
+	},
+	"lat": {
+		headers: []string{"lat (deg)"},
+		values: func(e *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{e.float(wpt.Lat, 7)}
+		},
+	},
+	"lon": {
+		headers: []string{"lon (deg)"},
+		values: func(e *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{e.float(wpt.Lon, 7)}
+		},
+	},
+	"sog": {
+		headers: []string{"sog (kn)"},
+		values: func(e *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{e.float(wpt.Speed, 2)}
+		},
+	},
+	"cog": {
+		headers: []string{"cog (deg)"},
+		values: func(e *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{e.float(wpt.Course, 1)}
+		},
+	},
+	"depth": {
+		headers: []string{"depth (m)"},
+		values: func(e *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{e.float(wpt.Depth, 2)}
+		},
+	},
+	"elevation": {
+		headers: []string{"elevation (m)"},
+		values: func(e *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{e.float(wpt.Ele, 1)}
+		},
+	},
+	"acceleration": {
+		headers: []string{"acc x (raw)", "acc y (raw)", "acc z (raw)"},
+		values: func(_ *CSVExporter, wpt *model.Waypoint) []string {
+			return threePoints(wpt.Acceleration)
+		},
+	},
+	"gyro": {
+		headers: []string{"gyro x (raw)", "gyro y (raw)", "gyro z (raw)"},
+		values: func(_ *CSVExporter, wpt *model.Waypoint) []string {
+			return threePoints(wpt.GyroLocation)
+		},
+	},
+	"voltage": {
+		headers: []string{"voltage (mV)"},
+		values: func(_ *CSVExporter, wpt *model.Waypoint) []string {
+			if wpt.Supply == 0 {
+				return []string{""}
+			}
+			return []string{strconv.FormatInt(wpt.Supply, 10)}
+		},
+	},
+	"heart_rate": {
+		headers: []string{"heart rate (bpm)"},
+		values: func(_ *CSVExporter, wpt *model.Waypoint) []string {
+			if wpt.HeartRate == 0 {
+				return []string{""}
+			}
+			return []string{strconv.FormatInt(wpt.HeartRate, 10)}
+		},
+	},
+	"source": {
+		headers: []string{"source file"},
+		values: func(_ *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{wpt.Source}
+		},
+	},
+	"channel": {
+		headers: []string{"channel"},
+		values: func(_ *CSVExporter, wpt *model.Waypoint) []string {
+			return []string{wpt.Channel}
+		},
+	},
+}
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "one row per waypoint with selectable columns",
+		Extension:   "csv",
+		MIMEType:    "text/csv",
+		Options: []registry.Option{
+			{Name: "columns", Description: "comma separated list of columns: time, lat, lon, sog, cog, depth, elevation, acceleration, gyro, voltage, heart_rate, source, channel", Default: defaultColumns},
+			{Name: "delimiter", Description: "field delimiter, a single character or tab", Default: ","},
+			{Name: "decimal", Description: "decimal separator, . or ,", Default: "."},
+			{Name: "header", Description: "write a header row with the units", Default: "true"},
+		},
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			return NewWithOptions(opts)
+		},
+	})
+}
+
+// CSVExporter exports the waypoints as csv
+type CSVExporter struct {
+	log       logging.Logger
+	columns   []string
+	delimiter rune
+	decimal   string
+	header    bool
+}
+
+// New returns a new CSVExporter with the default settings
+func New() *CSVExporter {
+	return &CSVExporter{
+		log:       *logging.New().WithName("CSVExporter"),
+		columns:   strings.Split(defaultColumns, ","),
+		delimiter: ',',
+		decimal:   ".",
+		header:    true,
+	}
+}
+
+// NewWithOptions returns a new CSVExporter configured with the format options
+func NewWithOptions(opts registry.Options) (*CSVExporter, error) {
+	e := New()
+	if err := e.WithColumns(strings.Split(opts.String("columns", defaultColumns), ",")); err != nil {
+		return nil, err
+	}
+	if err := e.WithDelimiter(opts.String("delimiter", ",")); err != nil {
+		return nil, err
+	}
+	if err := e.WithDecimal(opts.String("decimal", ".")); err != nil {
+		return nil, err
+	}
+	header, err := opts.Bool("header", true)
+	if err != nil {
+		return nil, err
+	}
+	e.header = header
+	if string(e.delimiter) == e.decimal {
+		return nil, fmt.Errorf("delimiter and decimal separator must be different")
+	}
+	return e, nil
+}
+
+// WithColumns sets the columns to export
+func (e *CSVExporter) WithColumns(cols []string) error {
+	e.columns = make([]string, 0, len(cols))
+	for _, c := range cols {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if _, ok := columns[c]; !ok {
+			return fmt.Errorf("unknown csv column %s", c)
+		}
+		e.columns = append(e.columns, c)
+	}
+	if len(e.columns) == 0 {
+		return fmt.Errorf("no csv columns given")
+	}
+	return nil
+}
+
+// WithDelimiter sets the field delimiter, "tab" or "\t" for a tab
+func (e *CSVExporter) WithDelimiter(d string) error {
+	switch d {
+	case "tab", "\\t", "\t":
+		e.delimiter = '\t'
+		return nil
+	}
+	r := []rune(d)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' {
+		return fmt.Errorf("invalid csv delimiter %q", d)
+	}
+	e.delimiter = r[0]
+	return nil
+}
+
+// WithDecimal sets the decimal separator
+func (e *CSVExporter) WithDecimal(d string) error {
+	if d != "." && d != "," {
+		return fmt.Errorf("invalid decimal separator %q, use . or ,", d)
+	}
+	e.decimal = d
+	return nil
+}
+
+// ExportTrack exports the waypoints of the track as csv
+func (e *CSVExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
+	w := csv.NewWriter(output)
+	w.Comma = e.delimiter
+	if e.header {
+		if err := w.Write(e.headers()); err != nil {
+			return err
+		}
+	}
+	for _, wpt := range track.Waypoints {
+		row := make([]string, 0, len(e.columns))
+		for _, c := range e.columns {
+			row = append(row, columns[c].values(e, wpt)...)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (e *CSVExporter) headers() []string {
+	hs := make([]string, 0, len(e.columns))
+	for _, c := range e.columns {
+		hs = append(hs, columns[c].headers...)
+	}
+	return hs
+}
+
+func (e *CSVExporter) float(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if e.decimal != "." {
+		s = strings.Replace(s, ".", e.decimal, 1)
+	}
+	return s
+}
+
+func threePoints(tp *model.ThreePoints) []string {
+	if tp == nil {
+		return []string{"", "", ""}
+	}
+	return []string{strconv.FormatInt(tp.X, 10), strconv.FormatInt(tp.Y, 10), strconv.FormatInt(tp.Z, 10)}
+}
@@ -0,0 +1,64 @@
+package csvexporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type CSVSuite struct {
+	suite.Suite
+}
+
+func TestCSVSuite(t *testing.T) {
+	suite.Run(t, new(CSVSuite))
+}
+
+func (s *CSVSuite) track() model.TrackPoints {
+	return model.TrackPoints{
+		Waypoints: []*model.Waypoint{
+			{Lat: 47.5, Lon: 8.75, Time: time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC), Speed: 5.25, Course: 90, Depth: 10.5, Supply: 12500, Source: "DATA001231.DAT", Channel: "I"},
+		},
+	}
+}
+
+func (s *CSVSuite) export(opts registry.Options) string {
+	f, ok := registry.Get(Format)
+	s.Require().True(ok)
+	exp, err := f.Create(opts)
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTrack(s.track(), &buf))
+	return buf.String()
+}
+
+func (s *CSVSuite) TestDefault() {
+	lines := strings.Split(strings.TrimSpace(s.export(nil)), "\n")
+	s.Require().Len(lines, 2)
+	s.Equal("time (UTC),lat (deg),lon (deg),sog (kn),cog (deg),depth (m)", lines[0])
+	s.Equal("2016-09-11T10:00:00Z,47.5000000,8.7500000,5.25,90.0,10.50", lines[1])
+}
+
+func (s *CSVSuite) TestGermanExcel() {
+	out := s.export(registry.Options{"delimiter": ";", "decimal": ",", "columns": "lat,depth,voltage,source,channel", "header": "false"})
+	s.Equal("47,5000000;10,50;12500;DATA001231.DAT;I\n", out)
+}
+
+func (s *CSVSuite) TestHeartRate() {
+	out := s.export(registry.Options{"columns": "time,heart_rate"})
+	s.Equal("time (UTC),heart rate (bpm)\n2016-09-11T10:00:00Z,\n", out)
+}
+
+func (s *CSVSuite) TestInvalidOptions() {
+	_, err := NewWithOptions(registry.Options{"delimiter": ",", "decimal": ","})
+	s.Error(err)
+	_, err = NewWithOptions(registry.Options{"columns": "time,unknown"})
+	s.Error(err)
+	_, err = NewWithOptions(registry.Options{"delimiter": "ab"})
+	s.Error(err)
+}
@@ -2,8 +2,8 @@ package export
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -12,11 +12,15 @@ import (
 
 	"github.com/samber/do/v2"
 	"github.com/willie68/gowillie68/pkg/fileutils"
+	"github.com/willie68/osmltools/internal/export/csvexporter"
 	"github.com/willie68/osmltools/internal/export/geojsonexporter"
 	"github.com/willie68/osmltools/internal/export/gpxexporter"
 	"github.com/willie68/osmltools/internal/export/jsonexporter"
 	"github.com/willie68/osmltools/internal/export/kmlexporter"
 	"github.com/willie68/osmltools/internal/export/nmeaexporter"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/export/signalkexporter"
+	"github.com/willie68/osmltools/internal/export/xyzexporter"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 	"github.com/willie68/osmltools/internal/osml"
@@ -24,35 +28,51 @@ import (
 )
 
 const (
-	JSONFormat    = "JSON"
-	NMEAFormat    = "NMEA"
-	GPXFormat     = "GPX"
-	KMLFormat     = "KML"
-	KMZFormat     = "KMZ"
-	GEOJSONFormat = "GEOJSON"
+	JSONFormat     = jsonexporter.Format
+	NMEAFormat     = nmeaexporter.Format
+	GPXFormat      = gpxexporter.Format
+	KMLFormat      = kmlexporter.FormatKML
+	KMZFormat      = kmlexporter.FormatKMZ
+	GEOJSONFormat  = geojsonexporter.Format
+	GEOJSONLFormat = geojsonexporter.FormatLines
+	CSVFormat      = csvexporter.Format
+	XYZFormat      = xyzexporter.Format
+	SIGNALKFormat  = signalkexporter.Format
 )
 
 var (
 	// ErrUnknownExporter error for unknown exporter
-	ErrUnknownExporter = fmt.Errorf("unknown exporter")
-	// SupportedFormats all supported export formats
-	SupportedFormats = []string{JSONFormat, NMEAFormat, GPXFormat, KMLFormat, KMZFormat, GEOJSONFormat}
+	ErrUnknownExporter = registry.ErrUnknownFormat
+	// ErrEmptyAfterTrim error if the trim leaves no log lines of the track
+	ErrEmptyAfterTrim = errors.New("no log lines left after trimming")
+	// ErrOtherFormatOption error for a format option of another format than the export format
+	ErrOtherFormatOption = errors.New("format option for another format")
+	// SupportedFormats all supported export formats, every exporter registered in the format registry
+	SupportedFormats = registry.Names()
 )
 
-type formatExporter interface {
-	ExportTrack(track model.TrackPoints, output io.Writer) error
-}
+type formatExporter = registry.Exporter
 
 type checkerSrv interface {
 	AnalyseLoggerFile(fr *model.FileResult, lf string) ([]*model.LogLine, error)
 	CorrectTimeStamp(ls []*model.LogLine) ([]*model.LogLine, bool, error)
 }
 
+type importerSrv interface {
+	Import(file, format string) ([]*model.LogLine, error)
+}
+
 type exporter struct {
 	log    logging.Logger
 	chk    checkerSrv
+	imp    importerSrv
 	exp    formatExporter
+	format registry.Format
+	opts   model.ExportOptions
 	tracks map[string]trackFileData
+	// collected tracks for a single file export
+	collected []model.TrackPoints
+	reduction *model.DepthReduction
 }
 
 type trackFileData struct {
@@ -65,21 +85,44 @@ func Init(inj do.Injector) {
 		return &exporter{
 			log:    *logging.New().WithName("Exporter"),
 			chk:    do.MustInvokeAs[checkerSrv](inj),
+			imp:    do.MustInvokeAs[importerSrv](inj),
 			tracks: make(map[string]trackFileData),
 		}, nil
 	})
 }
 
 // Export get the exporter and execute it on the sd file set
-func (e *exporter) Export(sdCardFolder, outputFolder string, files []string, format, name string) error {
-	outTempl := filepath.Join(outputFolder, fmt.Sprintf("track_%%04d.%s", strings.ToLower(format)))
-	e.log.Infof("exporter called: sd %s, out: %s, format: %s", sdCardFolder, outTempl, format)
-
-	exp, err := e.checkExporter(format)
+func (e *exporter) Export(sdCardFolder, outputFolder string, files []string, format, name string, opts model.ExportOptions) error {
+	exp, err := e.checkExporter(format, opts.FormatOptions)
 	if err != nil {
 		return err
 	}
 	e.exp = exp
+	if err := e.checkSeries(opts); err != nil {
+		return err
+	}
+	prefix := "track"
+	if len(opts.Series) > 0 {
+		prefix = "series"
+	}
+	outTempl := filepath.Join(outputFolder, fmt.Sprintf("%s_%%04d.%s", prefix, e.format.Extension))
+	e.log.Infof("exporter called: sd %s, out: %s, format: %s", sdCardFolder, outTempl, format)
+	if err := opts.Trim.Validate(); err != nil {
+		return err
+	}
+	if err := opts.Simplification.Validate(); err != nil {
+		return err
+	}
+	e.opts = opts
+	e.collected = nil
+	e.tracks = make(map[string]trackFileData)
+	if opts.SingleFile && !e.format.Capabilities.MultiTrack {
+		return fmt.Errorf("the format %s can't write several tracks into one file", e.format.Name)
+	}
+	e.reduction, err = e.depthReduction(0)
+	if err != nil {
+		return err
+	}
 
 	fs, err := os.Stat(sdCardFolder)
 	if err != nil {
@@ -87,10 +130,14 @@ func (e *exporter) Export(sdCardFolder, outputFolder string, files []string, for
 	}
 
 	if fs.IsDir() && (len(files) == 0) {
-		files, err = osml.GetDataFiles(sdCardFolder)
+		dfs, err := osml.GetDataFiles(sdCardFolder)
 		if err != nil {
 			return err
 		}
+		// the files are relative to the sd card folder
+		for _, df := range dfs {
+			files = append(files, filepath.Base(df))
+		}
 	}
 
 	if !fs.IsDir() {
@@ -113,6 +160,10 @@ func (e *exporter) Export(sdCardFolder, outputFolder string, files []string, for
 	if err != nil {
 		return err
 	}
+	err = e.exportCollected(filepath.Join(outputFolder, fmt.Sprintf("tracks.%s", e.format.Extension)))
+	if err != nil {
+		return err
+	}
 
 	js, err := json.MarshalIndent(e.tracks, "", "  ")
 	if err != nil {
@@ -123,15 +174,25 @@ func (e *exporter) Export(sdCardFolder, outputFolder string, files []string, for
 	return err
 }
 
-// Export get the exporter and execute it on the sd file set
-func (e *exporter) ExportTrack(trackfile, outputfile, format string) error {
+// ExportTrack exports a track file into the given format
+func (e *exporter) ExportTrack(trackfile, outputfile, format string, opts model.ExportOptions) error {
 	e.log.Infof("track exporter called: track %s, out: %s, format: %s", trackfile, outputfile, format)
 
-	exp, err := e.checkExporter(format)
+	exp, err := e.checkExporter(format, opts.FormatOptions)
 	if err != nil {
 		return err
 	}
 	e.exp = exp
+	if err := e.checkSeries(opts); err != nil {
+		return err
+	}
+	if err := opts.Trim.Validate(); err != nil {
+		return err
+	}
+	if err := opts.Simplification.Validate(); err != nil {
+		return err
+	}
+	e.opts = opts
 
 	if !fileutils.FileExists(trackfile) {
 		return fmt.Errorf("the track file %s does not exist", trackfile)
@@ -156,12 +217,20 @@ func (e *exporter) ExportTrack(trackfile, outputfile, format string) error {
 		return err
 	}
 
-	err = e.exportTrackFile(lls, track.Name, outputfile)
+	tr := &model.TrackPoints{
+		Name:        track.Name,
+		Description: track.Description,
+		VesselID:    track.VesselID,
+		LogLines:    lls,
+	}
+	// first the trim saved in the track, than the trim of the export
+	tr.ApplyTrim(track.Trim)
+	e.reduction, err = e.depthReduction(track.VesselID)
 	if err != nil {
 		return err
 	}
 
-	return err
+	return e.exportTrackFile(tr, outputfile)
 }
 
 func ReadLogFiles(files []string, sdCardFolder string, e *exporter, outTempl string, name string) ([]*model.LogLine, int, []string, error) {
@@ -172,12 +241,7 @@ func ReadLogFiles(files []string, sdCardFolder string, e *exporter, outTempl str
 
 	for _, file := range files {
 		lf := filepath.Join(sdCardFolder, file)
-		e.log.Infof("analysing file: %s", lf)
-		lss, err := e.chk.AnalyseLoggerFile(nil, lf)
-		if err != nil {
-			return nil, 0, nil, err
-		}
-		lss, _, err = e.chk.CorrectTimeStamp(lss)
+		lss, err := e.readLogFile(lf)
 		if err != nil {
 			return nil, 0, nil, err
 		}
@@ -205,6 +269,20 @@ func ReadLogFiles(files []string, sdCardFolder string, e *exporter, outTempl str
 	return ls, count, processedFiles, nil
 }
 
+// readLogFile reads a logger data file (DAT) with corrected time stamps, other formats (GPX, SL2...) with the importers
+func (e *exporter) readLogFile(lf string) ([]*model.LogLine, error) {
+	if !strings.EqualFold(filepath.Ext(lf), ".dat") {
+		return e.imp.Import(lf, "")
+	}
+	e.log.Infof("analysing file: %s", lf)
+	lss, err := e.chk.AnalyseLoggerFile(nil, lf)
+	if err != nil {
+		return nil, err
+	}
+	lss, _, err = e.chk.CorrectTimeStamp(lss)
+	return lss, err
+}
+
 func (e *exporter) exportFile(ls []*model.LogLine, count int, outTempl, name string, filelist []string) error {
 	if len(ls) == 0 {
 		return nil
@@ -217,38 +295,53 @@ func (e *exporter) exportFile(ls []*model.LogLine, count int, outTempl, name str
 		Name:     name,
 		LogLines: ls,
 	}
+	tr.ApplyTrim(e.opts.Trim)
+	if len(tr.LogLines) == 0 {
+		e.log.Infof("no loglines left for %s after trimming", of)
+		return nil
+	}
+	tr.Reduction = e.reduction
 	tr, err := model.GetWaypoints(tr)
 	if err != nil {
 		return err
 	}
+	tr.ApplyPrivacyZones(e.opts.PrivacyZones)
+	tr.Simplify(e.opts.Simplification)
 
 	fn := filepath.Base(of)
+	if e.opts.SingleFile {
+		fn = fmt.Sprintf("tracks.%s#%d", e.format.Extension, len(e.collected))
+		e.collected = append(e.collected, *tr)
+	}
 	e.tracks[fn] = trackFileData{
 		Name:  name,
 		Files: filelist,
 	}
+	if e.opts.SingleFile {
+		return nil
+	}
 	fs, err := os.Create(of)
 	if err != nil {
 		return err
 	}
 	defer fs.Close()
 
-	e.log.Infof("exporting %d loglines to %s", len(ls), of)
-	return e.exp.ExportTrack(*tr, fs)
+	e.log.Infof("exporting %d loglines to %s", len(tr.LogLines), of)
+	return e.write(tr, fs)
 }
 
-func (e *exporter) exportTrackFile(ls []*model.LogLine, name, outputfile string) error {
-	if len(ls) == 0 {
-		return nil
-	}
-	tr := &model.TrackPoints{
-		Name:     name,
-		LogLines: ls,
+func (e *exporter) exportTrackFile(tr *model.TrackPoints, outputfile string) error {
+	tr.ApplyTrim(e.opts.Trim)
+	if len(tr.LogLines) == 0 {
+		return fmt.Errorf("%w: %s", ErrEmptyAfterTrim, outputfile)
 	}
+	tr.Reduction = e.reduction
 	tr, err := model.GetWaypoints(tr)
 	if err != nil {
 		return err
 	}
+	tr.ApplyPrivacyZones(e.opts.PrivacyZones)
+	tr.Simplify(e.opts.Simplification)
 
 	if err := os.MkdirAll(filepath.Dir(outputfile), os.ModePerm); err != nil {
 		return err
@@ -260,24 +353,81 @@ func (e *exporter) exportTrackFile(ls []*model.LogLine, name, outputfile string)
 	}
 	defer fs.Close()
 
-	e.log.Infof("exporting %d loglines to %s", len(ls), outputfile)
-	return e.exp.ExportTrack(*tr, fs)
+	e.log.Infof("exporting %d loglines to %s", len(tr.LogLines), outputfile)
+	return e.write(tr, fs)
+}
+
+// depthReduction the depth reduction of the export options. The vessel of the options is used, if not set the
+// vessel of the track. Without vessel config, water levels and depth reference the result is nil.
+func (e *exporter) depthReduction(trackVessel int32) (*model.DepthReduction, error) {
+	dr := &model.DepthReduction{
+		Reference:   e.opts.DepthReference,
+		WaterLevels: e.opts.WaterLevels,
+	}
+	switch {
+	case e.opts.VesselID != 0:
+		dr.Vessel = e.opts.Vessels.Find(e.opts.VesselID)
+		if dr.Vessel == nil {
+			return nil, fmt.Errorf("no sensor config for vessel %d", e.opts.VesselID)
+		}
+	case trackVessel != 0:
+		dr.Vessel = e.opts.Vessels.Find(trackVessel)
+	}
+	if err := dr.Validate(); err != nil {
+		return nil, err
+	}
+	if dr.Vessel == nil && dr.Reference == "" && len(dr.WaterLevels) == 0 {
+		return nil, nil
+	}
+	if dr.Vessel != nil {
+		e.log.Infof("reducing depths with the sensor config of vessel %d", dr.Vessel.VesselID)
+	}
+	return dr, nil
+}
+
+// exportCollected writes all collected tracks of a single file export into one file
+func (e *exporter) exportCollected(outputfile string) error {
+	if len(e.collected) == 0 {
+		return nil
+	}
+	mte, ok := e.exp.(registry.MultiTrackExporter)
+	if !ok {
+		return fmt.Errorf("the format %s can't write several tracks into one file", e.format.Name)
+	}
+	fs, err := os.Create(outputfile)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	e.log.Infof("exporting %d tracks to %s", len(e.collected), outputfile)
+	return mte.ExportTracks(e.collected, fs)
 }
 
-func (e *exporter) checkExporter(format string) (formatExporter, error) {
-	switch format {
-	case JSONFormat:
-		return jsonexporter.New(), nil
-	case NMEAFormat:
-		return nmeaexporter.New(), nil
-	case GPXFormat:
-		return gpxexporter.New(), nil
-	case KMLFormat:
-		return kmlexporter.New(), nil
-	case KMZFormat:
-		return kmlexporter.New().WithCompressed(true), nil
-	case GEOJSONFormat:
-		return geojsonexporter.New(), nil
-	}
-	return nil, ErrUnknownExporter
+// checkExporter creates the exporter for the format out of the format registry
+func (e *exporter) checkExporter(format string, formatOptions []string) (formatExporter, error) {
+	f, ok := registry.Get(format)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownExporter, format)
+	}
+	fopts, err := registry.ParseOptions(formatOptions)
+	if err != nil {
+		return nil, err
+	}
+	others := make([]string, 0)
+	for name := range fopts {
+		if name != f.Name {
+			others = append(others, strings.ToLower(name))
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		return nil, fmt.Errorf("%w: options for %v, the export format is %s", ErrOtherFormatOption, others, strings.ToLower(f.Name))
+	}
+	exp, err := f.Create(fopts[f.Name])
+	if err != nil {
+		return nil, err
+	}
+	e.format = f
+	return exp, nil
 }
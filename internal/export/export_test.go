@@ -0,0 +1,68 @@
+package export
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/check"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/track"
+)
+
+type trackManager interface {
+	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
+}
+
+type ExportSuite struct {
+	suite.Suite
+	exp *exporter
+	tf  string
+	dir string
+}
+
+func TestExportSuite(t *testing.T) {
+	suite.Run(t, new(ExportSuite))
+}
+
+func (s *ExportSuite) SetupTest() {
+	inj := do.New()
+	check.Init(inj)
+	importer.Init(inj)
+	track.Init(inj)
+	Init(inj)
+	s.exp = do.MustInvoke[*exporter](inj)
+	s.dir = s.T().TempDir()
+	s.tf = filepath.Join(s.dir, "trip.zip")
+	tm := do.MustInvokeAs[trackManager](inj)
+	s.Require().NoError(tm.NewTrack("../../testdata/sdcard", []string{"DATA001231.DAT"}, s.tf, model.Track{Name: "trip"}))
+}
+
+func (s *ExportSuite) TestExportTrack() {
+	of := filepath.Join(s.dir, "trip.gpx")
+	s.Require().NoError(s.exp.ExportTrack(s.tf, of, GPXFormat, model.ExportOptions{}))
+	s.FileExists(of)
+}
+
+func (s *ExportSuite) TestEmptyAfterTrim() {
+	of := filepath.Join(s.dir, "empty.gpx")
+	opts := model.ExportOptions{
+		Trim: &model.Trim{From: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	err := s.exp.ExportTrack(s.tf, of, GPXFormat, opts)
+	s.ErrorIs(err, ErrEmptyAfterTrim)
+	s.NoFileExists(of)
+}
+
+func (s *ExportSuite) TestOtherFormatOption() {
+	of := filepath.Join(s.dir, "trip.gpx")
+	opts := model.ExportOptions{
+		FormatOptions: []string{"csv.separator=;"},
+	}
+	err := s.exp.ExportTrack(s.tf, of, GPXFormat, opts)
+	s.ErrorIs(err, ErrOtherFormatOption)
+	s.NoFileExists(of)
+}
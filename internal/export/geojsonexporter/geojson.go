@@ -1,41 +1,187 @@
 package geojsonexporter
 
 import (
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/twpayne/go-geom"
 	"github.com/twpayne/go-geom/encoding/geojson"
+	"github.com/willie68/osmltools/internal/export/registry"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 )
 
+const (
+	// Format the name of the geojson format
+	Format = "GEOJSON"
+	// FormatLines the name of the newline delimited geojson format, one feature per line
+	FormatLines = "GEOJSONL"
+)
+
+func init() {
+	gapOption := registry.Option{Name: "gap", Description: "time between two fixes, after which a new line is started, 0 for a single line", Default: model.DefaultMaxGap.String()}
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "geojson feature collection with track, start and end point",
+		Extension:   "geojson",
+		MIMEType:    "application/geo+json",
+		Capabilities: registry.Capabilities{
+			MultiTrack: true,
+		},
+		Options: []registry.Option{
+			gapOption,
+			{Name: "points", Description: "write every waypoint as point feature with all sensor values", Default: "false"},
+		},
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			return NewWithOptions(opts)
+		},
+	})
+	registry.Register(registry.Format{
+		Name:        FormatLines,
+		Description: "geojson lines, one point feature per line with all sensor values, for streaming large tracks",
+		Extension:   "geojsonl",
+		MIMEType:    "application/x-ndjson",
+		Capabilities: registry.Capabilities{
+			MultiTrack: true,
+		},
+		New: func(_ registry.Options) (registry.Exporter, error) {
+			return New().WithLines(true), nil
+		},
+	})
+}
+
 type GeoJSONExporter struct {
-	log logging.Logger
+	log    logging.Logger
+	maxGap time.Duration
+	points bool
+	lines  bool
 }
 
+// New returns a new GeoJSONExporter writing a feature collection with the track, start and end point
 func New() *GeoJSONExporter {
 	return &GeoJSONExporter{
-		log: *logging.New().WithName("GeoJSONExporter"),
+		log:    *logging.New().WithName("GeoJSONExporter"),
+		maxGap: model.DefaultMaxGap,
 	}
 }
 
+// NewWithOptions returns a new GeoJSONExporter configured with the format options
+func NewWithOptions(opts registry.Options) (*GeoJSONExporter, error) {
+	e := New()
+	gap, err := time.ParseDuration(opts.String("gap", model.DefaultMaxGap.String()))
+	if err != nil {
+		return nil, fmt.Errorf("option gap: %w", err)
+	}
+	e.maxGap = gap
+	e.points, err = opts.Bool("points", false)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WithLines sets if the output should be geojson lines, every waypoint as a feature in its own line
+func (e *GeoJSONExporter) WithLines(lines bool) *GeoJSONExporter {
+	e.lines = lines
+	e.points = lines
+	return e
+}
+
+// ExportTrack exports the track
 func (e *GeoJSONExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
-	coords := make([]geom.Coord, 0)
-	depths := make([]float64, 0)
-	speeds := make([]float64, 0)
-	times := make([]string, 0)
-	// collect coordinates, depths, speeds and times
-	for _, wpt := range track.Waypoints {
-		coords = append(coords, geom.Coord{wpt.Lon, wpt.Lat, wpt.Ele})
-		depths = append(depths, wpt.Depth)
-		speeds = append(speeds, wpt.Speed)
-		times = append(times, wpt.Time.Format("2006-01-02T15:04:05Z"))
-	}
-
-	ls := geom.NewLineString(geom.XYZ)
-	ls.SetCoords(coords)
-	tf := &geojson.Feature{
-		Geometry: ls,
+	return e.ExportTracks([]model.TrackPoints{track}, output)
+}
+
+// ExportTracks exports several tracks into one feature collection or geojson lines file
+func (e *GeoJSONExporter) ExportTracks(tracks []model.TrackPoints, output io.Writer) error {
+	if e.lines {
+		return e.exportLines(tracks, output)
+	}
+	fc := geojson.FeatureCollection{
+		Features: make([]*geojson.Feature, 0),
+	}
+	for _, track := range tracks {
+		fc.Features = append(fc.Features, e.trackFeatures(track)...)
+	}
+
+	rawJSON, err := fc.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(rawJSON)
+	return err
+}
+
+// exportLines writes every waypoint as a feature in its own line, without building the whole document in memory
+func (e *GeoJSONExporter) exportLines(tracks []model.TrackPoints, output io.Writer) error {
+	for _, track := range tracks {
+		for _, wpt := range track.Waypoints {
+			js, err := pointFeature(track.Name, wpt).MarshalJSON()
+			if err != nil {
+				return err
+			}
+			if _, err := output.Write(append(js, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// trackFeatures the features of a track: the line, start and end point and, if configured, every waypoint
+func (e *GeoJSONExporter) trackFeatures(track model.TrackPoints) []*geojson.Feature {
+	fs := make([]*geojson.Feature, 0)
+	if lf := e.lineFeature(track); lf != nil {
+		fs = append(fs, lf)
+	}
+	if track.Start != nil {
+		fs = append(fs, markerFeature("start", track.Start))
+	}
+	if track.End != nil {
+		fs = append(fs, markerFeature("end", track.End))
+	}
+	if e.points {
+		for _, wpt := range track.Waypoints {
+			fs = append(fs, pointFeature(track.Name, wpt))
+		}
+	}
+	return fs
+}
+
+// lineFeature the track as LineString, or as MultiLineString if there are gaps between the fixes. Segments with a
+// single fix are no valid lines and are skipped, without any line the result is nil.
+func (e *GeoJSONExporter) lineFeature(track model.TrackPoints) *geojson.Feature {
+	depths := make([]float64, 0, len(track.Waypoints))
+	speeds := make([]float64, 0, len(track.Waypoints))
+	times := make([]string, 0, len(track.Waypoints))
+	segs := track.Segments(e.maxGap)
+	lines := make([][]geom.Coord, 0, len(segs))
+	for _, seg := range segs {
+		if len(seg) < 2 {
+			continue
+		}
+		coords := make([]geom.Coord, 0, len(seg))
+		for _, wpt := range seg {
+			coords = append(coords, geom.Coord{wpt.Lon, wpt.Lat, wpt.Ele})
+			depths = append(depths, wpt.Depth)
+			speeds = append(speeds, wpt.Speed)
+			times = append(times, formatTime(wpt.Time))
+		}
+		lines = append(lines, coords)
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	var g geom.T
+	if len(lines) == 1 {
+		g = geom.NewLineString(geom.XYZ).MustSetCoords(lines[0])
+	} else {
+		g = geom.NewMultiLineString(geom.XYZ).MustSetCoords(lines)
+	}
+	return &geojson.Feature{
+		Geometry: g,
 		Properties: map[string]any{
 			"name":   track.Name,
 			"depths": depths,
@@ -43,34 +189,60 @@ func (e *GeoJSONExporter) ExportTrack(track model.TrackPoints, output io.Writer)
 			"times":  times,
 		},
 	}
-	ts := &geojson.Feature{
-		Geometry: geom.NewPointFlat(geom.XY, []float64{track.Start.Lon, track.Start.Lat}),
-		Properties: map[string]any{
-			"name": "start",
-			"time": track.Start.Time.Format("2006-01-02T15:04:05Z"),
-		},
-	}
-	te := &geojson.Feature{
-		Geometry: geom.NewPointFlat(geom.XY, []float64{track.End.Lon, track.End.Lat}),
+}
+
+func markerFeature(name string, wpt *model.Waypoint) *geojson.Feature {
+	return &geojson.Feature{
+		Geometry: geom.NewPointFlat(geom.XY, []float64{wpt.Lon, wpt.Lat}),
 		Properties: map[string]any{
-			"name": "end",
-			"time": track.End.Time.Format("2006-01-02T15:04:05Z"),
+			"name": name,
+			"time": formatTime(wpt.Time),
 		},
 	}
+}
 
-	fc := geojson.FeatureCollection{
-		Features: []*geojson.Feature{
-			tf,
-			ts,
-			te,
-		},
+// pointFeature a waypoint as point feature with all available sensor values
+func pointFeature(track string, wpt *model.Waypoint) *geojson.Feature {
+	props := map[string]any{
+		"track": track,
+		"time":  formatTime(wpt.Time),
+		"speed": wpt.Speed,
 	}
-
-	rawJSON, err := fc.MarshalJSON()
-	if err != nil {
-		return err
+	optional := map[string]float64{
+		"course":    wpt.Course,
+		"elevation": wpt.Ele,
+		"depth":     wpt.Depth,
+		"watertemp": wpt.WaterTemp,
+	}
+	for k, v := range optional {
+		if v != 0.0 {
+			props[k] = v
+		}
+	}
+	if wpt.Acceleration != nil {
+		props["acc"] = wpt.Acceleration
+	}
+	if wpt.GyroLocation != nil {
+		props["gyro"] = wpt.GyroLocation
 	}
+	if wpt.Supply != 0 {
+		props["supply"] = wpt.Supply
+	}
+	if wpt.HeartRate != 0 {
+		props["heartrate"] = wpt.HeartRate
+	}
+	if wpt.Channel != "" {
+		props["channel"] = wpt.Channel
+	}
+	if wpt.Source != "" {
+		props["source"] = wpt.Source
+	}
+	return &geojson.Feature{
+		Geometry:   geom.NewPointFlat(geom.XYZ, []float64{wpt.Lon, wpt.Lat, wpt.Ele}),
+		Properties: props,
+	}
+}
 
-	_, err = output.Write(rawJSON)
-	return err
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
 }
@@ -0,0 +1,129 @@
+package geojsonexporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/geojson"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type GeoJSONSuite struct {
+	suite.Suite
+}
+
+func TestGeoJSONSuite(t *testing.T) {
+	suite.Run(t, new(GeoJSONSuite))
+}
+
+func (s *GeoJSONSuite) track() model.TrackPoints {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	start := time.Date(2016, 9, 11, 12, 0, 0, 0, berlin)
+	tp := model.TrackPoints{Name: "t1"}
+	for i, offset := range []int{0, 10, 20, 200, 210} {
+		tp.Waypoints = append(tp.Waypoints, &model.Waypoint{
+			Lat:          47.0 + float64(i)*0.01,
+			Lon:          8.0,
+			Time:         start.Add(time.Duration(offset) * time.Second),
+			Speed:        5.0,
+			Depth:        10.5,
+			Acceleration: &model.ThreePoints{X: 1, Y: 2, Z: 3},
+			Source:       "DATA001231.DAT",
+		})
+	}
+	tp.Start = tp.Waypoints[0]
+	tp.End = tp.Waypoints[len(tp.Waypoints)-1]
+	return tp
+}
+
+func (s *GeoJSONSuite) export(exp *GeoJSONExporter, track model.TrackPoints) []byte {
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTrack(track, &buf))
+	return buf.Bytes()
+}
+
+func (s *GeoJSONSuite) collection(exp *GeoJSONExporter, track model.TrackPoints) geojson.FeatureCollection {
+	var fc geojson.FeatureCollection
+	s.Require().NoError(json.Unmarshal(s.export(exp, track), &fc))
+	return fc
+}
+
+func (s *GeoJSONSuite) TestMultiLineString() {
+	fc := s.collection(New(), s.track())
+	s.Require().Len(fc.Features, 3)
+	mls, ok := fc.Features[0].Geometry.(*geom.MultiLineString)
+	s.Require().True(ok)
+	s.Equal(2, mls.NumLineStrings())
+	s.Equal("2016-09-11T10:00:00Z", fc.Features[1].Properties["time"])
+}
+
+func (s *GeoJSONSuite) TestNoFix() {
+	fc := s.collection(New(), model.TrackPoints{Name: "empty"})
+	s.Empty(fc.Features)
+}
+
+func (s *GeoJSONSuite) TestPoints() {
+	exp, err := NewWithOptions(registry.Options{"points": "true", "gap": "0"})
+	s.Require().NoError(err)
+	fc := s.collection(exp, s.track())
+	s.Require().Len(fc.Features, 8)
+	_, ok := fc.Features[0].Geometry.(*geom.LineString)
+	s.True(ok)
+	p := fc.Features[3].Properties
+	s.Equal("2016-09-11T10:00:00Z", p["time"])
+	s.Equal(10.5, p["depth"])
+	s.Equal("DATA001231.DAT", p["source"])
+	s.Equal(map[string]any{"x": 1.0, "y": 2.0, "z": 3.0}, p["acc"])
+}
+
+func (s *GeoJSONSuite) TestLines() {
+	out := s.export(New().WithLines(true), s.track())
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	count := 0
+	for sc.Scan() {
+		var f geojson.Feature
+		s.Require().NoError(json.Unmarshal(sc.Bytes(), &f))
+		s.Equal("t1", f.Properties["track"])
+		count++
+	}
+	s.Equal(5, count)
+	s.False(strings.Contains(string(out), "FeatureCollection"))
+}
+
+func (s *GeoJSONSuite) TestSinglePointSegments() {
+	// the last fix is a segment of its own
+	tp := s.track()
+	tp.Waypoints = tp.Waypoints[:4]
+	fc := s.collection(New(), tp)
+	s.Require().Len(fc.Features, 3)
+	ls, ok := fc.Features[0].Geometry.(*geom.LineString)
+	s.Require().True(ok)
+	s.Equal(3, ls.NumCoords())
+	s.Len(fc.Features[0].Properties["depths"], 3)
+
+	// no line at all, only start and end
+	tp.Waypoints = tp.Waypoints[2:]
+	fc = s.collection(New(), tp)
+	s.Require().Len(fc.Features, 2)
+	for _, f := range fc.Features {
+		_, ok := f.Geometry.(*geom.Point)
+		s.True(ok)
+	}
+}
+
+func (s *GeoJSONSuite) TestHeartRate() {
+	tp := s.track()
+	tp.Waypoints[0].HeartRate = 120
+	exp, err := NewWithOptions(registry.Options{"points": "true"})
+	s.Require().NoError(err)
+	fc := s.collection(exp, tp)
+	s.Equal(120.0, fc.Features[3].Properties["heartrate"])
+	s.NotContains(fc.Features[4].Properties, "heartrate")
+}
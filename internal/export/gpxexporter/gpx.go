@@ -1,86 +1,244 @@
 package gpxexporter
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"strconv"
+	"time"
 
 	"github.com/twpayne/go-gpx"
+	"github.com/willie68/osmltools/internal/export/registry"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 )
 
+const (
+	// Format the name of the gpx format
+	Format = "GPX"
+
+	// ExtGarmin depth, water temperature and speed in the garmin TrackPointExtension v2
+	ExtGarmin = "garmin"
+	// ExtOpenCPN depth, water temperature and speed in the OpenCPN extension namespace
+	ExtOpenCPN = "opencpn"
+	// ExtNone no extensions
+	ExtNone = "none"
+
+	// Creator the creator attribute of the gpx files
+	Creator = "osmltools - https://github.com/willie68/osmltools"
+
+	garminNS  = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+	opencpnNS = "http://www.opencpn.org"
+
+	// knots to m/s, gpx speed is always in m/s
+	knots2ms = 1852.0 / 3600.0
+)
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "gps exchange format with track segments, start and end waypoint",
+		Extension:   "gpx",
+		MIMEType:    "application/gpx+xml",
+		Capabilities: registry.Capabilities{
+			MultiTrack: true,
+		},
+		Options: []registry.Option{
+			{Name: "extensions", Description: "extension namespace for depth, water temperature and speed: garmin, opencpn or none", Default: ExtGarmin},
+			{Name: "gap", Description: "time between two fixes, after which a new segment is started, 0 for no segments", Default: model.DefaultMaxGap.String()},
+		},
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			return NewWithOptions(opts)
+		},
+	})
+}
+
+// GPXExporter exports the waypoints as gpx 1.1
 type GPXExporter struct {
-	log logging.Logger
+	log        logging.Logger
+	extensions string
+	maxGap     time.Duration
 }
 
+// New returns a new GPXExporter with the garmin extensions and the default gap
 func New() *GPXExporter {
 	return &GPXExporter{
-		log: *logging.New().WithName("GPXExporter"),
+		log:        *logging.New().WithName("GPXExporter"),
+		extensions: ExtGarmin,
+		maxGap:     model.DefaultMaxGap,
+	}
+}
+
+// NewWithOptions returns a new GPXExporter configured with the format options
+func NewWithOptions(opts registry.Options) (*GPXExporter, error) {
+	e := New()
+	switch ext := opts.String("extensions", ExtGarmin); ext {
+	case ExtGarmin, ExtOpenCPN, ExtNone:
+		e.extensions = ext
+	default:
+		return nil, fmt.Errorf("unknown gpx extensions %s, use %s, %s or %s", ext, ExtGarmin, ExtOpenCPN, ExtNone)
+	}
+	gap, err := time.ParseDuration(opts.String("gap", model.DefaultMaxGap.String()))
+	if err != nil {
+		return nil, fmt.Errorf("option gap: %w", err)
 	}
+	e.maxGap = gap
+	return e, nil
 }
 
+// ExportTrack exports a single track
 func (e *GPXExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
-	g := NewGPX()
+	return e.ExportTracks([]model.TrackPoints{track}, output)
+}
 
-	g.Trk = []*gpx.TrkType{{
-		Name: track.Name,
-		TrkSeg: []*gpx.TrkSegType{{
-			TrkPt: e.ConvertToWPTTypes(track.Waypoints),
-		}},
-	}}
-	g.Wpt = []*gpx.WptType{
-		e.ConvertToWPTType(track.Start),
-		e.ConvertToWPTType(track.End),
+// ExportTracks exports several tracks into one gpx file
+func (e *GPXExporter) ExportTracks(tracks []model.TrackPoints, output io.Writer) error {
+	g := e.NewGPX()
+	g.Metadata = e.metadata(tracks)
+	for _, track := range tracks {
+		g.Trk = append(g.Trk, e.ConvertToTrkType(track))
+		if track.Start != nil {
+			g.Wpt = append(g.Wpt, e.ConvertToWPTType(track.Start))
+		}
+		if track.End != nil && track.End != track.Start {
+			g.Wpt = append(g.Wpt, e.ConvertToWPTType(track.End))
+		}
 	}
-	g.XMLAttrs = make(map[string]string)
-	g.XMLAttrs["xmlns:gpxx"] = "http://www.garmin.com/xmlschemas/GpxExtensionsv3.xsd"
 
 	if _, err := fmt.Fprint(output, xml.Header); err != nil {
 		return err
 	}
+	return g.WriteIndent(output, "", "  ")
+}
 
-	if err := g.WriteIndent(output, "", "  "); err != nil {
-		return err
+// NewGPX returns an empty gpx document with the namespace of the configured extensions
+func (e *GPXExporter) NewGPX() *gpx.GPX {
+	g := &gpx.GPX{
+		Version:  "1.1",
+		Creator:  Creator,
+		XMLAttrs: make(map[string]string),
 	}
-	return nil
+	switch e.extensions {
+	case ExtGarmin:
+		g.XMLAttrs["xmlns:gpxtpx"] = garminNS
+	case ExtOpenCPN:
+		g.XMLAttrs["xmlns:opencpn"] = opencpnNS
+	}
+	return g
 }
 
-func NewGPX() *gpx.GPX {
-	return &gpx.GPX{
-		Version: "1.1",
-		Creator: "ExpertGPS 1.1 - http://www.topografix.com",
+func (e *GPXExporter) metadata(tracks []model.TrackPoints) *gpx.MetadataType {
+	md := &gpx.MetadataType{}
+	var bounds *model.Bounds
+	for i, track := range tracks {
+		if i == 0 {
+			md.Name = track.Name
+			md.Desc = track.Description
+			if track.VesselID != 0 {
+				md.Keywords = fmt.Sprintf("vessel %d", track.VesselID)
+			}
+		}
+		if track.Start != nil && (md.Time.IsZero() || track.Start.Time.Before(md.Time)) {
+			md.Time = track.Start.Time.UTC()
+		}
+		b := track.Bounds()
+		if b == nil {
+			continue
+		}
+		if bounds == nil {
+			bounds = b
+			continue
+		}
+		bounds.MinLat = min(bounds.MinLat, b.MinLat)
+		bounds.MinLon = min(bounds.MinLon, b.MinLon)
+		bounds.MaxLat = max(bounds.MaxLat, b.MaxLat)
+		bounds.MaxLon = max(bounds.MaxLon, b.MaxLon)
+	}
+	if bounds != nil {
+		md.Bounds = &gpx.BoundsType{
+			MinLat: bounds.MinLat,
+			MinLon: bounds.MinLon,
+			MaxLat: bounds.MaxLat,
+			MaxLon: bounds.MaxLon,
+		}
+	}
+	return md
+}
+
+// ConvertToTrkType converts the track into a gpx track, with a new segment at every fix gap
+func (e *GPXExporter) ConvertToTrkType(track model.TrackPoints) *gpx.TrkType {
+	trk := &gpx.TrkType{
+		Name: track.Name,
+		Desc: track.Description,
+	}
+	for _, seg := range track.Segments(e.maxGap) {
+		trk.TrkSeg = append(trk.TrkSeg, &gpx.TrkSegType{
+			TrkPt: e.ConvertToWPTTypes(seg),
+		})
 	}
+	return trk
 }
 
+// ConvertToWPTType converts a waypoint into a gpx waypoint, with depth, water temperature and speed as extension
 func (e *GPXExporter) ConvertToWPTType(wpt *model.Waypoint) *gpx.WptType {
 	if wpt == nil {
 		return nil
 	}
 
 	gwpt := &gpx.WptType{
-		Lat:   wpt.Lat,
-		Lon:   wpt.Lon,
-		Time:  wpt.Time,
-		Ele:   wpt.Ele,
-		Name:  wpt.Name,
-		Speed: wpt.Speed,
-	}
-	if wpt.Depth != 0.0 {
+		Lat:  wpt.Lat,
+		Lon:  wpt.Lon,
+		Time: wpt.Time.UTC(),
+		Ele:  wpt.Ele,
+		Name: wpt.Name,
+	}
+	if ext := e.extension(wpt); len(ext) > 0 {
 		gwpt.Extensions = &gpx.ExtensionsType{
-			XML: []byte(fmt.Sprintf("<gpxx:Depth>%f</gpxx:Depth>", wpt.Depth)),
+			XML: ext,
 		}
 	}
 	return gwpt
 }
 
+// ConvertToWPTTypes converts a list of waypoints
 func (e *GPXExporter) ConvertToWPTTypes(wpts []*model.Waypoint) []*gpx.WptType {
 	if wpts == nil {
 		return nil
 	}
-	gwpts := make([]*gpx.WptType, 0)
+	gwpts := make([]*gpx.WptType, 0, len(wpts))
 	for _, wpt := range wpts {
 		gwpts = append(gwpts, e.ConvertToWPTType(wpt))
 	}
 	return gwpts
 }
+
+func (e *GPXExporter) extension(wpt *model.Waypoint) []byte {
+	if wpt.Depth == 0.0 && wpt.WaterTemp == 0.0 && wpt.Speed == 0.0 {
+		return nil
+	}
+	var b bytes.Buffer
+	switch e.extensions {
+	case ExtGarmin:
+		// the order of the elements is defined by the schema: wtemp, depth, speed, course
+		b.WriteString("<gpxtpx:TrackPointExtension>")
+		writeElement(&b, "gpxtpx:wtemp", wpt.WaterTemp, 2)
+		writeElement(&b, "gpxtpx:depth", wpt.Depth, 2)
+		writeElement(&b, "gpxtpx:speed", wpt.Speed*knots2ms, 3)
+		writeElement(&b, "gpxtpx:course", wpt.Course, 1)
+		b.WriteString("</gpxtpx:TrackPointExtension>")
+	case ExtOpenCPN:
+		writeElement(&b, "opencpn:depth", wpt.Depth, 2)
+		writeElement(&b, "opencpn:wtemp", wpt.WaterTemp, 2)
+		writeElement(&b, "opencpn:speed", wpt.Speed*knots2ms, 3)
+		writeElement(&b, "opencpn:course", wpt.Course, 1)
+	}
+	return b.Bytes()
+}
+
+func writeElement(b *bytes.Buffer, name string, v float64, prec int) {
+	if v == 0.0 {
+		return
+	}
+	fmt.Fprintf(b, "<%s>%s</%s>", name, strconv.FormatFloat(v, 'f', prec, 64), name)
+}
@@ -0,0 +1,89 @@
+package gpxexporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/twpayne/go-gpx"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type GPXSuite struct {
+	suite.Suite
+}
+
+func TestGPXSuite(t *testing.T) {
+	suite.Run(t, new(GPXSuite))
+}
+
+func (s *GPXSuite) track(name string) model.TrackPoints {
+	start := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	tp := model.TrackPoints{Name: name, Description: "test trip", VesselID: 597}
+	for i, offset := range []int{0, 10, 20, 200, 210} {
+		tp.Waypoints = append(tp.Waypoints, &model.Waypoint{
+			Lat:       47.0 + float64(i)*0.1,
+			Lon:       8.0,
+			Time:      start.Add(time.Duration(offset) * time.Second),
+			Speed:     5.0,
+			Depth:     10.5,
+			WaterTemp: 21.25,
+		})
+	}
+	tp.Start = tp.Waypoints[0]
+	tp.End = tp.Waypoints[len(tp.Waypoints)-1]
+	return tp
+}
+
+func (s *GPXSuite) export(opts registry.Options, tracks ...model.TrackPoints) (string, *gpx.GPX) {
+	exp, err := NewWithOptions(opts)
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTracks(tracks, &buf))
+	g, err := gpx.Read(bytes.NewReader(buf.Bytes()))
+	s.Require().NoError(err)
+	return buf.String(), g
+}
+
+func (s *GPXSuite) TestSegmentsAndMetadata() {
+	out, g := s.export(nil, s.track("Track 0001"))
+
+	s.Equal(Creator, g.Creator)
+	s.NotContains(out, "ExpertGPS")
+	s.Require().NotNil(g.Metadata)
+	s.Equal("Track 0001", g.Metadata.Name)
+	s.Equal("test trip", g.Metadata.Desc)
+	s.Equal("vessel 597", g.Metadata.Keywords)
+	s.Require().NotNil(g.Metadata.Bounds)
+	s.InDelta(47.4, g.Metadata.Bounds.MaxLat, 1e-9)
+	s.Require().Len(g.Trk, 1)
+	s.Len(g.Trk[0].TrkSeg, 2)
+	s.Len(g.Wpt, 2)
+
+	s.Contains(out, `xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v2"`)
+	s.Contains(out, "<gpxtpx:TrackPointExtension><gpxtpx:wtemp>21.25</gpxtpx:wtemp><gpxtpx:depth>10.50</gpxtpx:depth><gpxtpx:speed>2.572</gpxtpx:speed></gpxtpx:TrackPointExtension>")
+}
+
+func (s *GPXSuite) TestOpenCPN() {
+	out, g := s.export(registry.Options{"extensions": ExtOpenCPN, "gap": "0"}, s.track("t"))
+	s.Len(g.Trk[0].TrkSeg, 1)
+	s.Contains(out, `xmlns:opencpn="http://www.opencpn.org"`)
+	s.Contains(out, "<opencpn:depth>10.50</opencpn:depth>")
+	s.NotContains(out, "gpxtpx")
+}
+
+func (s *GPXSuite) TestMultiTrack() {
+	_, g := s.export(nil, s.track("one"), s.track("two"))
+	s.Require().Len(g.Trk, 2)
+	s.Equal("two", g.Trk[1].Name)
+	s.Len(g.Wpt, 4)
+}
+
+func (s *GPXSuite) TestInvalidOptions() {
+	_, err := NewWithOptions(registry.Options{"extensions": "gpxx"})
+	s.Error(err)
+	_, err = NewWithOptions(registry.Options{"gap": "soon"})
+	s.Error(err)
+}
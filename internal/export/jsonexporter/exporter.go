@@ -4,29 +4,54 @@ import (
 	"encoding/json"
 	"io"
 
+	"github.com/willie68/osmltools/internal/export/registry"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 )
 
+// Format the name of the json format
+const Format = "JSON"
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "versioned json document with the waypoints and the log lines (raw and decoded sentences), can be imported again",
+		Extension:   "json",
+		MIMEType:    "application/json",
+		Options: []registry.Option{
+			{Name: "loglines", Description: "write the log lines, false for the waypoints only", Default: "true"},
+		},
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			return NewWithOptions(opts)
+		},
+	})
+}
+
 type JSONExporter struct {
-	log        logging.Logger
-	compressed bool
+	log      logging.Logger
+	logLines bool
 }
 
-// New returns a new KMLExporter
+// New returns a new JSONExporter writing the waypoints and log lines
 func New() *JSONExporter {
 	return &JSONExporter{
-		log:        *logging.New().WithName("JSONExporter"),
-		compressed: false,
+		log:      *logging.New().WithName("JSONExporter"),
+		logLines: true,
 	}
 }
 
-// ExportTrack exports the given track to a kml or kmz file
-func (e *JSONExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
-	js, err := json.Marshal(track.Waypoints)
+// NewWithOptions returns a new JSONExporter with the format options
+func NewWithOptions(opts registry.Options) (*JSONExporter, error) {
+	e := New()
+	var err error
+	e.logLines, err = opts.Bool("loglines", true)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = output.Write(js)
-	return err
+	return e, nil
+}
+
+// ExportTrack exports the given track as json document, see model.TrackDocument
+func (e *JSONExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
+	return json.NewEncoder(output).Encode(model.NewTrackDocument(track, e.logLines))
 }
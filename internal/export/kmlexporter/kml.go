@@ -1,17 +1,84 @@
 package kmlexporter
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
 
 	"github.com/twpayne/go-kml/v3"
+	"github.com/twpayne/go-kml/v3/icon"
+	"github.com/willie68/osmltools/internal/colorscale"
+	"github.com/willie68/osmltools/internal/export/registry"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 )
 
+const (
+	// FormatKML the name of the kml format
+	FormatKML = "KML"
+	// FormatKMZ the name of the compressed kml format
+	FormatKMZ = "KMZ"
+
+	// ColorByDepth colour the track segments by the water depth
+	ColorByDepth = "depth"
+	// ColorBySpeed colour the track segments by the speed over ground
+	ColorBySpeed = "speed"
+	// ColorByNone no coloured segments
+	ColorByNone = "none"
+
+	legendFile = "legend.png"
+	schemaID   = "trackdata"
+)
+
+var options = []registry.Option{
+	{Name: "color-by", Description: "colour the track segments by depth, speed or none", Default: ColorByDepth},
+	{Name: "min", Description: "lower limit of the colour scale, default is the minimum of the data"},
+	{Name: "max", Description: "upper limit of the colour scale, default is the maximum of the data"},
+}
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        FormatKML,
+		Description: "google earth kml with time stamped track, coloured segments and start/end placemarks",
+		Extension:   "kml",
+		MIMEType:    "application/vnd.google-earth.kml+xml",
+		Capabilities: registry.Capabilities{
+			MultiTrack: true,
+		},
+		Options: options,
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			return NewWithOptions(opts)
+		},
+	})
+	registry.Register(registry.Format{
+		Name:        FormatKMZ,
+		Description: "zip compressed kml with a legend image",
+		Extension:   "kmz",
+		MIMEType:    "application/vnd.google-earth.kmz",
+		Capabilities: registry.Capabilities{
+			MultiTrack: true,
+			Binary:     true,
+		},
+		Options: options,
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			e, err := NewWithOptions(opts)
+			if err != nil {
+				return nil, err
+			}
+			return e.WithCompressed(true), nil
+		},
+	})
+}
+
 type KMLExporter struct {
 	log        logging.Logger
 	compressed bool
+	colorBy    string
+	min        *float64
+	max        *float64
 }
 
 // New returns a new KMLExporter
@@ -19,7 +86,33 @@ func New() *KMLExporter {
 	return &KMLExporter{
 		log:        *logging.New().WithName("KMLExporter"),
 		compressed: false,
+		colorBy:    ColorByDepth,
+	}
+}
+
+// NewWithOptions returns a new KMLExporter configured with the format options
+func NewWithOptions(opts registry.Options) (*KMLExporter, error) {
+	e := New()
+	switch cb := opts.String("color-by", ColorByDepth); cb {
+	case ColorByDepth, ColorBySpeed, ColorByNone:
+		e.colorBy = cb
+	default:
+		return nil, fmt.Errorf("unknown kml color-by %s, use %s, %s or %s", cb, ColorByDepth, ColorBySpeed, ColorByNone)
 	}
+	for _, o := range []struct {
+		name string
+		dst  **float64
+	}{{"min", &e.min}, {"max", &e.max}} {
+		if opts.String(o.name, "") == "" {
+			continue
+		}
+		v, err := opts.Float(o.name, 0)
+		if err != nil {
+			return nil, err
+		}
+		*o.dst = &v
+	}
+	return e, nil
 }
 
 // WithCompressed sets if the output should be compressed to a kmz file
@@ -30,45 +123,245 @@ func (e *KMLExporter) WithCompressed(compressed bool) *KMLExporter {
 
 // ExportTrack exports the given track to a kml or kmz file
 func (e *KMLExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
-	kos := make([]kml.Coordinate, 0)
-	for _, wpt := range track.Waypoints {
-		kos = append(kos, kml.Coordinate{
-			Lon: wpt.Lon,
-			Lat: wpt.Lat,
-			Alt: wpt.Ele,
-		})
+	return e.ExportTracks([]model.TrackPoints{track}, output)
+}
+
+// ExportTracks exports several tracks into one kml or kmz file, with one folder per day
+func (e *KMLExporter) ExportTracks(tracks []model.TrackPoints, output io.Writer) error {
+	scale := e.scale(tracks)
+
+	count := 0
+	for _, track := range tracks {
+		count += len(track.Waypoints)
+	}
+	name := ""
+	if len(tracks) > 0 {
+		name = tracks[0].Name
+	}
+	doc := kml.Document(
+		kml.Name(name),
+		kml.Description(fmt.Sprintf("Exported with osmltools - %d tracks, %d points", len(tracks), count)),
+		kml.Schema(schemaID,
+			kml.GxSimpleArrayField("speed", "float", kml.DisplayName("Speed (kn)")),
+			kml.GxSimpleArrayField("depth", "float", kml.DisplayName("Depth (m)")),
+			kml.GxSimpleArrayField("watertemp", "float", kml.DisplayName("Water temperature (°C)")),
+		).WithName(schemaID),
+		kml.SharedStyle("track", kml.LineStyle(kml.Color(colorscale.NoData), kml.Width(2))),
+		kml.SharedStyle("start", kml.IconStyle(kml.Icon(kml.Href(icon.PaddleHref("go"))))),
+		kml.SharedStyle("end", kml.IconStyle(kml.Icon(kml.Href(icon.PaddleHref("stop"))))),
+		kml.SharedStyle("nodata", kml.LineStyle(kml.Color(colorscale.NoData), kml.Width(4))),
+	)
+	if scale != nil {
+		for i, col := range scale.Colors {
+			doc.Append(kml.SharedStyle(styleID(i), kml.LineStyle(kml.Color(col), kml.Width(4))))
+		}
+		if e.compressed {
+			doc.Append(kml.ScreenOverlay(
+				kml.Name("Legend"),
+				kml.Icon(kml.Href(legendFile)),
+				kml.OverlayXY(kml.Vec2{X: 0, Y: 1, XUnits: kml.UnitsFraction, YUnits: kml.UnitsFraction}),
+				kml.ScreenXY(kml.Vec2{X: 0.01, Y: 0.99, XUnits: kml.UnitsFraction, YUnits: kml.UnitsFraction}),
+			))
+		}
 	}
 
-	gxkos := make([]kml.Element, 0)
-	for _, wpt := range track.Waypoints {
-		gxkos = append(gxkos, kml.GxCoord(kml.Coordinate{
-			Lon: wpt.Lon,
-			Lat: wpt.Lat,
-			Alt: -wpt.Depth,
-		}))
+	if len(tracks) == 1 {
+		doc.Append(e.trackElements(tracks[0], scale)...)
+	} else {
+		e.appendDayFolders(doc, tracks, scale)
+	}
+
+	kd := kml.GxKML(doc)
+	if !e.compressed {
+		return kd.WriteIndent(output, "", "  ")
 	}
+	files := map[string]any{"doc.kml": kd}
+	if scale != nil {
+		var legend bytes.Buffer
+		if err := scale.LegendPNG(&legend, e.legendTitle(), 1); err != nil {
+			return err
+		}
+		files[legendFile] = legend.Bytes()
+	}
+	return kml.WriteKMZ(output, files)
+}
 
-	kd := kml.KML(kml.Document(
-		kml.Name(track.Name),
-		kml.Description(fmt.Sprintf("Exported with osmltools - %d points", len(kos))),
+// appendDayFolders adds one folder per day (UTC) with a folder for every track of the day
+func (e *KMLExporter) appendDayFolders(doc *kml.DocumentElement, tracks []model.TrackPoints, scale *colorscale.Scale) {
+	days := make(map[string]*kml.FolderElement)
+	for _, track := range tracks {
+		day := "unknown"
+		if track.Start != nil {
+			day = track.Start.Time.UTC().Format("2006-01-02")
+		}
+		folder, ok := days[day]
+		if !ok {
+			folder = kml.Folder(kml.Name(day))
+			days[day] = folder
+			doc.Append(folder)
+		}
+		folder.Append(kml.Folder(append([]kml.Element{kml.Name(track.Name)}, e.trackElements(track, scale)...)...))
+	}
+}
+
+// trackElements the elements of one track: the time stamped gx:Track, the coloured segments and the start/end placemarks
+func (e *KMLExporter) trackElements(track model.TrackPoints, scale *colorscale.Scale) []kml.Element {
+	els := []kml.Element{
 		kml.Placemark(
 			kml.Name(track.Name),
-			kml.LineString(kml.Coordinates(kos...)),
+			kml.StyleURL("#track"),
+			e.gxTrack(track),
 		),
-		kml.Placemark(
-			kml.Name("Water depth profile"),
-			kml.GxTrack(gxkos...),
-		),
-	))
+	}
+	if scale != nil {
+		els = append(els, kml.Folder(append([]kml.Element{kml.Name(fmt.Sprintf("Coloured by %s", e.colorBy))}, e.colouredSegments(track, scale)...)...))
+	}
+	if track.Start != nil {
+		els = append(els, e.placemark("Start", "#start", track.Start))
+	}
+	if track.End != nil && track.End != track.Start {
+		els = append(els, e.placemark("End", "#end", track.End))
+	}
+	return els
+}
 
-	if e.compressed {
-		if err := kml.WriteKMZ(output, map[string]any{"doc.kml": kd}); err != nil {
-			return err
+func (e *KMLExporter) gxTrack(track model.TrackPoints) *kml.GxTrackElement {
+	n := len(track.Waypoints)
+	whens := make([]kml.Element, 0, n)
+	coords := make([]kml.Element, 0, n)
+	speed := make([]string, 0, n)
+	depth := make([]string, 0, n)
+	temp := make([]string, 0, n)
+	for _, wpt := range track.Waypoints {
+		whens = append(whens, kml.When(wpt.Time.UTC()))
+		coords = append(coords, kml.GxCoord(kml.Coordinate{Lon: wpt.Lon, Lat: wpt.Lat, Alt: wpt.Ele}))
+		speed = append(speed, strconv.FormatFloat(wpt.Speed, 'f', 2, 64))
+		depth = append(depth, strconv.FormatFloat(wpt.Depth, 'f', 2, 64))
+		temp = append(temp, strconv.FormatFloat(wpt.WaterTemp, 'f', 2, 64))
+	}
+	gt := kml.GxTrack(kml.AltitudeMode(kml.AltitudeModeClampToGround))
+	gt.Append(whens...)
+	gt.Append(coords...)
+	gt.Append(kml.ExtendedData(kml.SchemaData("#"+schemaID,
+		simpleArrayData{name: "speed", values: speed},
+		simpleArrayData{name: "depth", values: depth},
+		simpleArrayData{name: "watertemp", values: temp},
+	)))
+	return gt
+}
+
+// colouredSegments splits the track into line strings of the same colour class.
+// Consecutive line strings share the point where the class changes.
+func (e *KMLExporter) colouredSegments(track model.TrackPoints, scale *colorscale.Scale) []kml.Element {
+	els := make([]kml.Element, 0)
+	var coords []kml.Coordinate
+	style := ""
+	flush := func() {
+		if len(coords) > 1 {
+			els = append(els, kml.Placemark(
+				kml.StyleURL("#"+style),
+				kml.LineString(kml.Coordinates(coords...)),
+			))
 		}
-	} else {
-		if err := kml.KML(kd).WriteIndent(output, "", "  "); err != nil {
+	}
+	for _, wpt := range track.Waypoints {
+		s := "nodata"
+		if v, ok := e.value(wpt); ok {
+			s = styleID(scale.Index(v))
+		}
+		c := kml.Coordinate{Lon: wpt.Lon, Lat: wpt.Lat}
+		if s != style && len(coords) > 0 {
+			flush()
+			coords = []kml.Coordinate{coords[len(coords)-1]}
+		}
+		style = s
+		coords = append(coords, c)
+	}
+	flush()
+	return els
+}
+
+func (e *KMLExporter) placemark(name, style string, wpt *model.Waypoint) *kml.PlacemarkElement {
+	return kml.Placemark(
+		kml.Name(name),
+		kml.StyleURL(style),
+		kml.TimeStamp(kml.When(wpt.Time.UTC())),
+		kml.Point(kml.Coordinates(kml.Coordinate{Lon: wpt.Lon, Lat: wpt.Lat})),
+	)
+}
+
+// scale the colour scale for all tracks, nil if the tracks should not be coloured
+func (e *KMLExporter) scale(tracks []model.TrackPoints) *colorscale.Scale {
+	if e.colorBy == ColorByNone {
+		return nil
+	}
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for _, track := range tracks {
+		for _, wpt := range track.Waypoints {
+			if v, ok := e.value(wpt); ok {
+				minV = math.Min(minV, v)
+				maxV = math.Max(maxV, v)
+			}
+		}
+	}
+	if e.min != nil {
+		minV = *e.min
+	}
+	if e.max != nil {
+		maxV = *e.max
+	}
+	if math.IsInf(minV, 0) || math.IsInf(maxV, 0) {
+		return nil
+	}
+	s := colorscale.New(minV, maxV)
+	if e.colorBy == ColorByDepth {
+		// shallow water is red
+		s = s.Reversed()
+	}
+	return s
+}
+
+// value the value for the colouring, a depth of 0 means no depth data
+func (e *KMLExporter) value(wpt *model.Waypoint) (float64, bool) {
+	switch e.colorBy {
+	case ColorByDepth:
+		return wpt.Depth, wpt.Depth != 0.0
+	case ColorBySpeed:
+		return wpt.Speed, true
+	}
+	return 0, false
+}
+
+func (e *KMLExporter) legendTitle() string {
+	if e.colorBy == ColorBySpeed {
+		return "Speed (kn)"
+	}
+	return "Depth (m)"
+}
+
+func styleID(i int) string {
+	return fmt.Sprintf("c%d", i)
+}
+
+// simpleArrayData a gx:SimpleArrayData element with its gx:value children, go-kml has no name attribute for it
+type simpleArrayData struct {
+	name   string
+	values []string
+}
+
+// MarshalXML implements encoding/xml.Marshaler.MarshalXML.
+func (e simpleArrayData) MarshalXML(encoder *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{
+		Name: xml.Name{Local: "gx:SimpleArrayData"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: e.name}},
+	}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, v := range e.values {
+		if err := encoder.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: "gx:value"}}); err != nil {
 			return err
 		}
 	}
-	return nil
+	return encoder.EncodeToken(start.End())
 }
@@ -0,0 +1,94 @@
+package kmlexporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type KMLSuite struct {
+	suite.Suite
+}
+
+func TestKMLSuite(t *testing.T) {
+	suite.Run(t, new(KMLSuite))
+}
+
+func (s *KMLSuite) track(name string, start time.Time) model.TrackPoints {
+	tp := model.TrackPoints{Name: name}
+	for i := range 6 {
+		tp.Waypoints = append(tp.Waypoints, &model.Waypoint{
+			Lat:   47.0 + float64(i)*0.01,
+			Lon:   8.0,
+			Time:  start.Add(time.Duration(i) * 10 * time.Second),
+			Speed: 4.0 + float64(i),
+			Depth: 2.0 + float64(i)*2,
+		})
+	}
+	tp.Start = tp.Waypoints[0]
+	tp.End = tp.Waypoints[len(tp.Waypoints)-1]
+	return tp
+}
+
+func (s *KMLSuite) export(exp *KMLExporter, tracks ...model.TrackPoints) string {
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTracks(tracks, &buf))
+	return buf.String()
+}
+
+func (s *KMLSuite) TestTrack() {
+	out := s.export(New(), s.track("t1", time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)))
+
+	// 6 track points and the time stamps of the start and end placemark
+	s.Equal(8, strings.Count(out, "<when>"))
+	s.Contains(out, "<when>2016-09-11T10:00:00Z</when>")
+	s.Equal(6, strings.Count(out, "<gx:coord>"))
+	s.Contains(out, `<gx:SimpleArrayData name="speed">`)
+	s.Contains(out, `<gx:SimpleArrayData name="depth">`)
+	s.Contains(out, "<gx:value>12.00</gx:value>")
+	s.Contains(out, "<styleUrl>#start</styleUrl>")
+	s.Contains(out, "<styleUrl>#end</styleUrl>")
+	// depth 2..12 in 8 classes, every point has its own colour class
+	s.Equal(5, strings.Count(out, "<LineString>"))
+	s.NotContains(out, "<ScreenOverlay>")
+}
+
+func (s *KMLSuite) TestDayFolders() {
+	day1 := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	exp, err := NewWithOptions(registry.Options{"color-by": ColorByNone})
+	s.Require().NoError(err)
+	out := s.export(exp, s.track("t1", day1), s.track("t2", day1.Add(time.Hour)), s.track("t3", day2))
+
+	s.Contains(out, "<name>2016-09-11</name>")
+	s.Contains(out, "<name>2016-09-12</name>")
+	s.Equal(5, strings.Count(out, "<Folder>"))
+	s.NotContains(out, "<LineString>")
+}
+
+func (s *KMLSuite) TestKMZLegend() {
+	exp, err := NewWithOptions(registry.Options{"color-by": ColorBySpeed, "min": "0", "max": "16"})
+	s.Require().NoError(err)
+	out := s.export(exp.WithCompressed(true), s.track("t1", time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)))
+
+	zr, err := zip.NewReader(strings.NewReader(out), int64(len(out)))
+	s.Require().NoError(err)
+	names := make([]string, 0)
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	s.Equal([]string{"doc.kml", "legend.png"}, names)
+}
+
+func (s *KMLSuite) TestInvalidOptions() {
+	_, err := NewWithOptions(registry.Options{"color-by": "temperature"})
+	s.Error(err)
+	_, err = NewWithOptions(registry.Options{"min": "low"})
+	s.Error(err)
+}
@@ -4,10 +4,29 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/willie68/osmltools/internal/export/registry"
 	"github.com/willie68/osmltools/internal/logging"
 	"github.com/willie68/osmltools/internal/model"
 )
 
+// Format the name of the nmea format
+const Format = "NMEA"
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "the corrected nmea sentences with timestamp",
+		Extension:   "nmea",
+		MIMEType:    "text/plain",
+		Capabilities: registry.Capabilities{
+			NeedsLogLines: true,
+		},
+		New: func(_ registry.Options) (registry.Exporter, error) {
+			return New(), nil
+		},
+	})
+}
+
 type NMEAExporter struct {
 	log logging.Logger
 }
@@ -0,0 +1,219 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/willie68/osmltools/internal/model"
+)
+
+var (
+	// ErrUnknownFormat error for a format which is not registered
+	ErrUnknownFormat = errors.New("unknown format")
+	// ErrUnknownOption error for an option which is not supported by the format
+	ErrUnknownOption = errors.New("unknown format option")
+
+	mu      sync.RWMutex
+	formats = make(map[string]Format)
+)
+
+// Exporter the interface every format exporter has to implement
+type Exporter interface {
+	ExportTrack(track model.TrackPoints, output io.Writer) error
+}
+
+// MultiTrackExporter an exporter which can write several tracks into one file
+type MultiTrackExporter interface {
+	Exporter
+	ExportTracks(tracks []model.TrackPoints, output io.Writer) error
+}
+
+// Capabilities what a format is able to do and what it needs
+type Capabilities struct {
+	// MultiTrack the format can hold several tracks in one file
+	MultiTrack bool `json:"multiTrack"`
+	// NeedsLogLines the format is written from the log lines, not from the waypoints
+	NeedsLogLines bool `json:"needsLogLines"`
+	// Binary the output is binary, not text
+	Binary bool `json:"binary"`
+}
+
+// Option a format specific option, set on the command line with --opt <format>.<name>=<value>
+type Option struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default,omitempty"`
+}
+
+// Format the description of an export format
+type Format struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	Extension    string       `json:"extension"`
+	MIMEType     string       `json:"mimeType"`
+	Capabilities Capabilities `json:"capabilities"`
+	Options      []Option     `json:"options,omitempty"`
+	// New creates a new exporter with the given options
+	New func(opts Options) (Exporter, error) `json:"-"`
+}
+
+// Register registers a format, the name is case insensitive. Registering a name twice panics.
+func Register(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := strings.ToUpper(f.Name)
+	if _, ok := formats[name]; ok {
+		panic(fmt.Sprintf("export format %s already registered", name))
+	}
+	if f.New == nil {
+		panic(fmt.Sprintf("export format %s has no constructor", name))
+	}
+	f.Name = name
+	formats[name] = f
+}
+
+// Get returns the format with the name
+func Get(name string) (Format, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := formats[strings.ToUpper(strings.TrimSpace(name))]
+	return f, ok
+}
+
+// Formats all registered formats sorted by name
+func Formats() []Format {
+	mu.RLock()
+	defer mu.RUnlock()
+	fs := make([]Format, 0, len(formats))
+	for _, f := range formats {
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool {
+		return fs[i].Name < fs[j].Name
+	})
+	return fs
+}
+
+// Names the names of all registered formats sorted
+func Names() []string {
+	fs := Formats()
+	names := make([]string, 0, len(fs))
+	for _, f := range fs {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Create creates the exporter of the format with the given options. Options not set are filled with the defaults.
+func (f Format) Create(opts Options) (Exporter, error) {
+	o := make(Options)
+	for _, d := range f.Options {
+		if d.Default != "" {
+			o[d.Name] = d.Default
+		}
+	}
+	for k, v := range opts {
+		if !f.HasOption(k) {
+			return nil, fmt.Errorf("%w %s.%s, available options: %v", ErrUnknownOption, strings.ToLower(f.Name), k, f.OptionNames())
+		}
+		o[k] = v
+	}
+	return f.New(o)
+}
+
+// HasOption checks if the format supports the option
+func (f Format) HasOption(name string) bool {
+	return slices.ContainsFunc(f.Options, func(o Option) bool {
+		return o.Name == name
+	})
+}
+
+// OptionNames the names of all options of this format
+func (f Format) OptionNames() []string {
+	names := make([]string, 0, len(f.Options))
+	for _, o := range f.Options {
+		names = append(names, o.Name)
+	}
+	return names
+}
+
+// Options the options of one format, name -> value
+type Options map[string]string
+
+// ParseOptions parses a list of format options in the form <format>.<name>=<value>, e.g. gpx.extensions=opencpn.
+// The result is grouped by the upper case format name.
+func ParseOptions(opts []string) (map[string]Options, error) {
+	res := make(map[string]Options)
+	for _, opt := range opts {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid format option %q, use <format>.<name>=<value>", opt)
+		}
+		format, name, ok := strings.Cut(strings.TrimSpace(key), ".")
+		if !ok || format == "" || name == "" {
+			return nil, fmt.Errorf("invalid format option %q, use <format>.<name>=<value>", opt)
+		}
+		format = strings.ToUpper(format)
+		if _, ok := Get(format); !ok {
+			return nil, fmt.Errorf("%w %s in option %q", ErrUnknownFormat, format, opt)
+		}
+		if _, ok := res[format]; !ok {
+			res[format] = make(Options)
+		}
+		res[format][name] = value
+	}
+	return res, nil
+}
+
+// String returns the option value or the default
+func (o Options) String(name, def string) string {
+	if v, ok := o[name]; ok {
+		return v
+	}
+	return def
+}
+
+// Bool returns the option value as bool, or the default
+func (o Options) Bool(name string, def bool) (bool, error) {
+	v, ok := o[name]
+	if !ok || v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("option %s: %w", name, err)
+	}
+	return b, nil
+}
+
+// Int returns the option value as int, or the default
+func (o Options) Int(name string, def int) (int, error) {
+	v, ok := o[name]
+	if !ok || v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return def, fmt.Errorf("option %s: %w", name, err)
+	}
+	return i, nil
+}
+
+// Float returns the option value as float, or the default
+func (o Options) Float(name string, def float64) (float64, error) {
+	v, ok := o[name]
+	if !ok || v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def, fmt.Errorf("option %s: %w", name, err)
+	}
+	return f, nil
+}
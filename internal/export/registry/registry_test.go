@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type testExporter struct {
+	opts Options
+}
+
+func (e *testExporter) ExportTrack(_ model.TrackPoints, _ io.Writer) error {
+	return nil
+}
+
+type RegistrySuite struct {
+	suite.Suite
+}
+
+func TestRegistrySuite(t *testing.T) {
+	suite.Run(t, new(RegistrySuite))
+}
+
+func (s *RegistrySuite) SetupSuite() {
+	Register(Format{
+		Name:      "test",
+		Extension: "tst",
+		Options: []Option{
+			{Name: "depth-ext", Description: "depth extension", Default: "garmin"},
+			{Name: "precision", Description: "decimal places"},
+		},
+		New: func(opts Options) (Exporter, error) {
+			return &testExporter{opts: opts}, nil
+		},
+	})
+}
+
+func (s *RegistrySuite) TestGet() {
+	f, ok := Get("Test")
+	s.True(ok)
+	s.Equal("TEST", f.Name)
+	s.Equal("tst", f.Extension)
+	s.Contains(Names(), "TEST")
+
+	_, ok = Get("unknown")
+	s.False(ok)
+}
+
+func (s *RegistrySuite) TestRegisterTwice() {
+	s.Panics(func() {
+		Register(Format{Name: "TEST", New: func(_ Options) (Exporter, error) { return nil, nil }})
+	})
+}
+
+func (s *RegistrySuite) TestParseOptions() {
+	opts, err := ParseOptions([]string{"test.depth-ext=opencpn", "TEST.precision=3"})
+	s.NoError(err)
+	s.Equal(Options{"depth-ext": "opencpn", "precision": "3"}, opts["TEST"])
+
+	_, err = ParseOptions([]string{"test.precision"})
+	s.Error(err)
+	_, err = ParseOptions([]string{"precision=3"})
+	s.Error(err)
+	_, err = ParseOptions([]string{"unknown.precision=3"})
+	s.ErrorIs(err, ErrUnknownFormat)
+}
+
+func (s *RegistrySuite) TestCreate() {
+	f, _ := Get("TEST")
+	exp, err := f.Create(Options{"precision": "3"})
+	s.NoError(err)
+	te := exp.(*testExporter)
+	s.Equal("garmin", te.opts.String("depth-ext", ""))
+	p, err := te.opts.Int("precision", 2)
+	s.NoError(err)
+	s.Equal(3, p)
+
+	_, err = f.Create(Options{"colour": "red"})
+	s.ErrorIs(err, ErrUnknownOption)
+}
+
+func (s *RegistrySuite) TestOptionValues() {
+	o := Options{"b": "true", "f": "1.5", "i": "x"}
+	b, err := o.Bool("b", false)
+	s.NoError(err)
+	s.True(b)
+	f, err := o.Float("f", 0)
+	s.NoError(err)
+	s.Equal(1.5, f)
+	_, err = o.Int("i", 0)
+	s.Error(err)
+	i, err := o.Int("missing", 7)
+	s.NoError(err)
+	s.Equal(7, i)
+}
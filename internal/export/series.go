@@ -0,0 +1,98 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const seriesTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// checkSeries checks the series names and the format of a series export
+func (e *exporter) checkSeries(opts model.ExportOptions) error {
+	if len(opts.Series) == 0 {
+		return nil
+	}
+	if e.format.Name != CSVFormat && e.format.Name != JSONFormat {
+		return fmt.Errorf("series can only be exported as %s or %s", CSVFormat, JSONFormat)
+	}
+	if opts.SingleFile {
+		return fmt.Errorf("series can't be exported into a single file")
+	}
+	_, err := model.ParseSeries(strings.Join(opts.Series, ","))
+	return err
+}
+
+// write writes the track with the format exporter or, if series are selected, the time series
+func (e *exporter) write(tr *model.TrackPoints, output io.Writer) error {
+	if len(e.opts.Series) == 0 {
+		return e.exp.ExportTrack(*tr, output)
+	}
+	s := tr.Series(e.opts.Series)
+	e.log.Infof("exporting %d series rows", len(s.Rows))
+	if e.format.Name == JSONFormat {
+		return writeSeriesJSON(output, s)
+	}
+	return writeSeriesCSV(output, s)
+}
+
+// writeSeriesCSV writes the series as csv with a header, missing samples are empty
+func writeSeriesCSV(w io.Writer, s *model.Series) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"time"}, s.Columns...)); err != nil {
+		return err
+	}
+	rec := make([]string, len(s.Columns)+1)
+	for _, r := range s.Rows {
+		rec[0] = r.Time.UTC().Format(seriesTimeFormat)
+		for i, v := range r.Values {
+			rec[i+1] = ""
+			if !math.IsNaN(v) {
+				rec[i+1] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeSeriesJSON writes the series as json array of records, missing samples are null
+func writeSeriesJSON(w io.Writer, s *model.Series) error {
+	bw := bufio.NewWriter(w)
+	keys := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		js, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		keys[i] = string(js)
+	}
+	bw.WriteString("[")
+	for i, r := range s.Rows {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n  {\"time\":\"" + r.Time.UTC().Format(seriesTimeFormat) + "\"")
+		for j, v := range r.Values {
+			bw.WriteString("," + keys[j] + ":")
+			if math.IsNaN(v) {
+				bw.WriteString("null")
+				continue
+			}
+			bw.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		bw.WriteString("}")
+	}
+	bw.WriteString("\n]\n")
+	return bw.Flush()
+}
@@ -0,0 +1,55 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type SeriesSuite struct {
+	suite.Suite
+}
+
+func TestSeriesSuite(t *testing.T) {
+	suite.Run(t, new(SeriesSuite))
+}
+
+func (s *SeriesSuite) series() *model.Series {
+	ts := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	return &model.Series{
+		Columns: []string{"supply", "depth"},
+		Rows: []model.SeriesRow{
+			{Time: ts, Values: []float64{4940, math.NaN()}},
+			{Time: ts.Add(500 * time.Millisecond), Values: []float64{math.NaN(), 4.5}},
+		},
+	}
+}
+
+func (s *SeriesSuite) TestCSV() {
+	var buf bytes.Buffer
+	s.Require().NoError(writeSeriesCSV(&buf, s.series()))
+	s.Equal("time,supply,depth\n"+
+		"2016-09-11T10:00:00.000Z,4940,\n"+
+		"2016-09-11T10:00:00.500Z,,4.5\n", buf.String())
+}
+
+func (s *SeriesSuite) TestJSON() {
+	var buf bytes.Buffer
+	s.Require().NoError(writeSeriesJSON(&buf, s.series()))
+	var recs []map[string]any
+	s.Require().NoError(json.Unmarshal(buf.Bytes(), &recs))
+	s.Require().Len(recs, 2)
+	s.Equal("2016-09-11T10:00:00.000Z", recs[0]["time"])
+	s.Equal(4940.0, recs[0]["supply"])
+	s.Nil(recs[0]["depth"])
+	s.Equal(4.5, recs[1]["depth"])
+
+	buf.Reset()
+	s.Require().NoError(writeSeriesJSON(&buf, &model.Series{}))
+	s.Equal("[\n]\n", buf.String())
+}
@@ -0,0 +1,192 @@
+package signalkexporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// Format the name of the signal k delta format
+	Format = "SIGNALK"
+
+	// DefaultContext the context of the deltas, the own vessel
+	DefaultContext = "vessels.self"
+	// DefaultLabel the label of the sources, the channel of the logger is appended
+	DefaultLabel = "osml"
+
+	// PathPosition path of the position
+	PathPosition = "navigation.position"
+	// PathSOG path of the speed over ground in m/s
+	PathSOG = "navigation.speedOverGround"
+	// PathCOG path of the course over ground in rad
+	PathCOG = "navigation.courseOverGroundTrue"
+	// PathDepth path of the depth below the transducer in m
+	PathDepth = "environment.depth.belowTransducer"
+	// PathWaterTemp path of the water temperature in K
+	PathWaterTemp = "environment.water.temperature"
+
+	timeFormat = "2006-01-02T15:04:05.000Z"
+	knots2ms   = 1852.0 / 3600.0
+	celsius2k  = 273.15
+)
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "signal k delta messages, one json object per line",
+		Extension:   "jsonl",
+		MIMEType:    "application/x-ndjson",
+		Capabilities: registry.Capabilities{
+			NeedsLogLines: true,
+		},
+		Options: []registry.Option{
+			{Name: "context", Description: "the context of the deltas, e.g. vessels.urn:mrn:imo:mmsi:211234560", Default: DefaultContext},
+			{Name: "label", Description: "the label of the sources, the logger channel is appended", Default: DefaultLabel},
+		},
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			return NewWithOptions(opts), nil
+		},
+	})
+}
+
+// Delta a signal k delta message
+type Delta struct {
+	Context string   `json:"context"`
+	Updates []Update `json:"updates"`
+}
+
+// Update one update of a delta, all values of one sentence
+type Update struct {
+	Source    Source  `json:"source"`
+	SourceRef string  `json:"$source"`
+	Timestamp string  `json:"timestamp"`
+	Values    []Value `json:"values"`
+}
+
+// Source the nmea 0183 source of an update
+type Source struct {
+	Label    string `json:"label"`
+	Type     string `json:"type"`
+	Talker   string `json:"talker,omitempty"`
+	Sentence string `json:"sentence,omitempty"`
+}
+
+// Value a value with its signal k path
+type Value struct {
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// Position the value of navigation.position
+type Position struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// SignalKExporter exports the log lines as signal k deltas
+type SignalKExporter struct {
+	log     logging.Logger
+	context string
+	label   string
+}
+
+// New returns a new SignalKExporter with the default context and label
+func New() *SignalKExporter {
+	return &SignalKExporter{
+		log:     *logging.New().WithName("SignalKExporter"),
+		context: DefaultContext,
+		label:   DefaultLabel,
+	}
+}
+
+// NewWithOptions returns a new SignalKExporter configured with the format options
+func NewWithOptions(opts registry.Options) *SignalKExporter {
+	e := New()
+	e.context = opts.String("context", DefaultContext)
+	e.label = opts.String("label", DefaultLabel)
+	return e
+}
+
+// ExportTrack writes a delta for every log line with a supported sentence, the timestamp is the corrected time stamp
+func (e *SignalKExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
+	w := bufio.NewWriter(output)
+	enc := json.NewEncoder(w)
+	count := 0
+	for _, ll := range track.LogLines {
+		d, ok := e.Delta(ll, ll.CorrectTimeStamp)
+		if !ok {
+			continue
+		}
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+		count++
+	}
+	e.log.Debugf("%d deltas written", count)
+	return w.Flush()
+}
+
+// Delta converts a log line into a delta with the given timestamp, ok is false if the sentence has no signal k value
+func (e *SignalKExporter) Delta(ll *model.LogLine, ts time.Time) (*Delta, bool) {
+	if ll == nil || ll.NMEAMessage == nil {
+		return nil, false
+	}
+	values := Values(ll)
+	if len(values) == 0 {
+		return nil, false
+	}
+	label := e.label
+	if ll.Channel != "" {
+		label = label + "." + ll.Channel
+	}
+	talker := ll.NMEAMessage.TalkerID()
+	return &Delta{
+		Context: e.context,
+		Updates: []Update{
+			{
+				Source: Source{
+					Label:    label,
+					Type:     "NMEA0183",
+					Talker:   talker,
+					Sentence: ll.NMEAMessage.DataType(),
+				},
+				SourceRef: strings.TrimSuffix(label+"."+talker, "."),
+				Timestamp: ts.UTC().Format(timeFormat),
+				Values:    values,
+			},
+		},
+	}, true
+}
+
+// Values the signal k values of a log line in si units
+func Values(ll *model.LogLine) []Value {
+	switch m := ll.NMEAMessage.(type) {
+	case nmea.RMC:
+		if m.Validity != nmea.ValidRMC {
+			return nil
+		}
+		return []Value{
+			{Path: PathPosition, Value: Position{Latitude: m.Latitude, Longitude: m.Longitude}},
+			{Path: PathSOG, Value: m.Speed * knots2ms},
+			{Path: PathCOG, Value: m.Course * math.Pi / 180.0},
+		}
+	case nmea.DBT, nmea.DPT:
+		if depth, ok := model.Depth(ll); ok {
+			return []Value{{Path: PathDepth, Value: depth}}
+		}
+	case nmea.MTW:
+		if m.CelsiusValid {
+			return []Value{{Path: PathWaterTemp, Value: m.Temperature + celsius2k}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package signalkexporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type SignalKSuite struct {
+	suite.Suite
+}
+
+func TestSignalKSuite(t *testing.T) {
+	suite.Run(t, new(SignalKSuite))
+}
+
+func (s *SignalKSuite) track() model.TrackPoints {
+	lines := []string{
+		"2016-09-11 10:00:00.000000: $GPRMC,100000,A,4720.000,N,00830.0000,E,5.0,90.0,110916,,*14",
+		"2016-09-11 10:00:05.000000: $SDDPT,4.5,0.3*55",
+		"2016-09-11 10:00:06.000000: $POSMVCC,4940*72",
+		"2016-09-11 10:00:07.000000: $SDDPT,0.0,0.3*54",
+	}
+	lls, err := model.ParseLines2LogLines(lines, false)
+	s.Require().NoError(err)
+	s.Require().Len(lls, 4)
+	lls[0].Channel = "B"
+	lls[1].Channel = "A"
+	return model.TrackPoints{LogLines: lls}
+}
+
+func (s *SignalKSuite) deltas(buf *bytes.Buffer) []map[string]any {
+	res := make([]map[string]any, 0)
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var d map[string]any
+		s.Require().NoError(json.Unmarshal(sc.Bytes(), &d))
+		res = append(res, d)
+	}
+	return res
+}
+
+func (s *SignalKSuite) TestExport() {
+	var buf bytes.Buffer
+	s.Require().NoError(New().ExportTrack(s.track(), &buf))
+	ds := s.deltas(&buf)
+	// the voltage and the depth 0 are skipped
+	s.Require().Len(ds, 2)
+
+	s.Equal(DefaultContext, ds[0]["context"])
+	up := ds[0]["updates"].([]any)[0].(map[string]any)
+	s.Equal("2016-09-11T10:00:00.000Z", up["timestamp"])
+	s.Equal("osml.B.GP", up["$source"])
+	src := up["source"].(map[string]any)
+	s.Equal("osml.B", src["label"])
+	s.Equal("NMEA0183", src["type"])
+	s.Equal("RMC", src["sentence"])
+	values := up["values"].([]any)
+	s.Require().Len(values, 3)
+	pos := values[0].(map[string]any)
+	s.Equal(PathPosition, pos["path"])
+	s.InDelta(47.333333, pos["value"].(map[string]any)["latitude"], 0.000001)
+	s.InDelta(8.5, pos["value"].(map[string]any)["longitude"], 0.000001)
+	s.Equal(PathSOG, values[1].(map[string]any)["path"])
+	s.InDelta(2.572222, values[1].(map[string]any)["value"], 0.000001)
+	s.InDelta(1.570796, values[2].(map[string]any)["value"], 0.000001)
+
+	up = ds[1]["updates"].([]any)[0].(map[string]any)
+	s.Equal("osml.A.SD", up["$source"])
+	depth := up["values"].([]any)[0].(map[string]any)
+	s.Equal(PathDepth, depth["path"])
+	s.InDelta(4.5, depth["value"], 0.000001)
+}
+
+func (s *SignalKSuite) TestOptions() {
+	f, ok := registry.Get("signalk")
+	s.Require().True(ok)
+	exp, err := f.Create(registry.Options{"context": "vessels.urn:mrn:imo:mmsi:211234560", "label": "logger"})
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTrack(s.track(), &buf))
+	ds := s.deltas(&buf)
+	s.Require().Len(ds, 2)
+	s.Equal("vessels.urn:mrn:imo:mmsi:211234560", ds[0]["context"])
+	s.Equal("logger.B.GP", ds[0]["updates"].([]any)[0].(map[string]any)["$source"])
+}
+
+func (s *SignalKSuite) TestDelta() {
+	tr := s.track()
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	d, ok := New().Delta(tr.LogLines[1], ts)
+	s.Require().True(ok)
+	s.Equal("2024-05-01T12:00:00.000Z", d.Updates[0].Timestamp)
+
+	_, ok = New().Delta(tr.LogLines[2], ts)
+	s.False(ok)
+	_, ok = New().Delta(&model.LogLine{Unknown: "$GPRMC,broken*00"}, ts)
+	s.False(ok)
+}
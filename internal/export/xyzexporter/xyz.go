@@ -0,0 +1,108 @@
+package xyzexporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// Format the name of the xyz bathymetry format
+	Format = "XYZ"
+
+	timeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "bathymetry soundings: lon, lat, depth and time for every valid depth, position interpolated between the fixes",
+		Extension:   "xyz",
+		MIMEType:    "text/plain",
+		Capabilities: registry.Capabilities{
+			NeedsLogLines: true,
+		},
+		Options: []registry.Option{
+			{Name: "precision", Description: "decimal places of lon and lat", Default: "6"},
+			{Name: "depth-precision", Description: "decimal places of the depth", Default: "2"},
+			{Name: "delimiter", Description: "field delimiter, a single character or tab", Default: ","},
+			{Name: "gap", Description: "max time between the fixes around a sounding, 0 for no limit", Default: model.DefaultMaxGap.String()},
+		},
+		New: func(opts registry.Options) (registry.Exporter, error) {
+			return NewWithOptions(opts)
+		},
+	})
+}
+
+// XYZExporter exports the depth soundings as xyz file
+type XYZExporter struct {
+	log            logging.Logger
+	precision      int
+	depthPrecision int
+	delimiter      string
+	maxGap         time.Duration
+}
+
+// New returns a new XYZExporter with the default settings
+func New() *XYZExporter {
+	return &XYZExporter{
+		log:            *logging.New().WithName("XYZExporter"),
+		precision:      6,
+		depthPrecision: 2,
+		delimiter:      ",",
+		maxGap:         model.DefaultMaxGap,
+	}
+}
+
+// NewWithOptions returns a new XYZExporter configured with the format options
+func NewWithOptions(opts registry.Options) (*XYZExporter, error) {
+	e := New()
+	var err error
+	if e.precision, err = opts.Int("precision", 6); err != nil {
+		return nil, err
+	}
+	if e.depthPrecision, err = opts.Int("depth-precision", 2); err != nil {
+		return nil, err
+	}
+	if e.precision < 0 || e.depthPrecision < 0 {
+		return nil, fmt.Errorf("the precision must not be negative")
+	}
+	switch d := opts.String("delimiter", ","); d {
+	case "tab", "\\t":
+		e.delimiter = "\t"
+	default:
+		if len([]rune(d)) != 1 {
+			return nil, fmt.Errorf("invalid xyz delimiter %q", d)
+		}
+		e.delimiter = d
+	}
+	if e.maxGap, err = time.ParseDuration(opts.String("gap", model.DefaultMaxGap.String())); err != nil {
+		return nil, fmt.Errorf("option gap: %w", err)
+	}
+	return e, nil
+}
+
+// ExportTrack writes one line per sounding: lon, lat, depth, time
+func (e *XYZExporter) ExportTrack(track model.TrackPoints, output io.Writer) error {
+	w := bufio.NewWriter(output)
+	sds := track.Soundings(e.maxGap)
+	for _, sd := range sds {
+		_, err := fmt.Fprint(w,
+			strconv.FormatFloat(sd.Lon, 'f', e.precision, 64), e.delimiter,
+			strconv.FormatFloat(sd.Lat, 'f', e.precision, 64), e.delimiter,
+			strconv.FormatFloat(sd.Depth, 'f', e.depthPrecision, 64), e.delimiter,
+			sd.Time.UTC().Format(timeFormat), "\n",
+		)
+		if err != nil {
+			return err
+		}
+	}
+	e.log.Infof("exported %d soundings", len(sds))
+	return w.Flush()
+}
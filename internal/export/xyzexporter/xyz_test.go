@@ -0,0 +1,77 @@
+package xyzexporter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type XYZSuite struct {
+	suite.Suite
+}
+
+func TestXYZSuite(t *testing.T) {
+	suite.Run(t, new(XYZSuite))
+}
+
+func (s *XYZSuite) track() model.TrackPoints {
+	lines := []string{
+		"2016-09-11 10:00:00.000000: $GPRMC,100000,A,4720.000,N,00830.0000,E,5.0,90.0,110916,,*14",
+		"2016-09-11 10:00:05.000000: $SDDPT,4.5,0.3*55",
+		"2016-09-11 10:00:10.000000: $GPRMC,100010,A,4720.000,N,00830.6000,E,5.0,90.0,110916,,*13",
+		"2016-09-11 10:00:12.000000: $SDDPT,0.0,0.3*54",
+	}
+	lls, err := model.ParseLines2LogLines(lines, false)
+	s.Require().NoError(err)
+	s.Require().Len(lls, 4)
+	return model.TrackPoints{LogLines: lls}
+}
+
+func (s *XYZSuite) TestExport() {
+	var buf bytes.Buffer
+	s.Require().NoError(New().ExportTrack(s.track(), &buf))
+	// position in the middle of the two fixes, the sounding with depth 0 is dropped
+	s.Equal("8.505000,47.333333,4.50,2016-09-11T10:00:05.000Z\n", buf.String())
+}
+
+func (s *XYZSuite) TestPrecision() {
+	exp, err := NewWithOptions(registry.Options{"precision": "3", "depth-precision": "1", "delimiter": "tab"})
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTrack(s.track(), &buf))
+	s.Equal("8.505\t47.333\t4.5\t2016-09-11T10:00:05.000Z\n", buf.String())
+
+	_, err = NewWithOptions(registry.Options{"precision": "-1"})
+	s.Error(err)
+}
+
+func (s *XYZSuite) TestPrivacyZones() {
+	lines := []string{
+		"2016-09-11 10:00:00.000000: $GPRMC,100000,A,4720.000,N,00830.0000,E,5.0,90.0,110916,,*14",
+		"2016-09-11 10:00:02.000000: $SDDPT,3.0,0.3*57",
+		"2016-09-11 10:00:04.000000: $SDDPT,4.0,0.3*50",
+		"2016-09-11 10:00:05.000000: $GPRMC,100005,A,4720.000,N,00830.1800,E,5.0,90.0,110916,,*18",
+		"2016-09-11 10:00:06.000000: $SDDPT,5.0,0.3*51",
+		"2016-09-11 10:00:08.000000: $SDDPT,6.0,0.3*52",
+		"2016-09-11 10:00:10.000000: $GPRMC,100010,A,4720.000,N,00830.3600,E,5.0,90.0,110916,,*10",
+	}
+	export := func(mode string) string {
+		lls, err := model.ParseLines2LogLines(lines, false)
+		s.Require().NoError(err)
+		s.Require().Len(lls, len(lines))
+		tp := model.TrackPoints{LogLines: lls}
+		// the zone is crossed in 10 seconds, the fix inside the zone is removed
+		tp.ApplyPrivacyZones(model.PrivacyZones{{Name: "mooring", Lat: 47.333333, Lon: 8.503, Radius: 100, Mode: mode}})
+		var buf bytes.Buffer
+		s.Require().NoError(New().ExportTrack(tp, &buf))
+		return buf.String()
+	}
+
+	s.Equal("8.501200,47.333333,3.00,2016-09-11T10:00:02.000Z\n"+
+		"8.504800,47.333333,6.00,2016-09-11T10:00:08.000Z\n", export(model.PrivacyDrop))
+	// a moved depth would be wrong, the soundings are dropped in fuzz mode too
+	s.Equal(export(model.PrivacyDrop), export(model.PrivacyFuzz))
+}
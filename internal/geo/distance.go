@@ -0,0 +1,18 @@
+package geo
+
+import "math"
+
+const (
+	// EarthRadius mean earth radius in meters
+	EarthRadius = 6371008.8
+)
+
+// Distance great circle distance in meters between two positions (haversine formula)
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	p1 := lat1 * math.Pi / 180
+	p2 := lat2 * math.Pi / 180
+	dp := (lat2 - lat1) * math.Pi / 180
+	dl := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dp/2)*math.Sin(dp/2) + math.Cos(p1)*math.Cos(p2)*math.Sin(dl/2)*math.Sin(dl/2)
+	return 2 * EarthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
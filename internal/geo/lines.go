@@ -0,0 +1,50 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/twpayne/go-geom"
+)
+
+var (
+	// ErrNoLine the geojson file does not contain any line
+	ErrNoLine = errors.New("no line found")
+)
+
+// Line a line string, every position is [lon, lat]
+type Line [][2]float64
+
+// Lines a list of lines
+type Lines []Line
+
+// ParseLines parses all line strings and multi line strings from geojson data
+func ParseLines(data []byte) (Lines, error) {
+	gs, typ, err := parseGeometries(data)
+	if err != nil {
+		return nil, err
+	}
+	ls := make(Lines, 0)
+	for _, g := range gs {
+		switch t := g.(type) {
+		case *geom.LineString:
+			ls = append(ls, fromCoords(t.Coords()))
+		case *geom.MultiLineString:
+			for i := range t.NumLineStrings() {
+				ls = append(ls, fromCoords(t.LineString(i).Coords()))
+			}
+		}
+	}
+	if len(ls) == 0 {
+		return nil, fmt.Errorf("%w in geojson of type %s", ErrNoLine, typ)
+	}
+	return ls, nil
+}
+
+func fromCoords(cs []geom.Coord) Line {
+	l := make(Line, 0, len(cs))
+	for _, c := range cs {
+		l = append(l, [2]float64{c.X(), c.Y()})
+	}
+	return l
+}
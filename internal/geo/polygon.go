@@ -0,0 +1,143 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/geojson"
+)
+
+var (
+	// ErrNoPolygon the geojson file does not contain any polygon
+	ErrNoPolygon = errors.New("no polygon found")
+)
+
+// Polygon a polygon with the same layout as in geojson, the first ring is the outer ring, all others are holes.
+// Every position is [lon, lat].
+type Polygon [][][2]float64
+
+// Contains checks if the given position is inside the polygon (and not in one of the holes)
+func (p Polygon) Contains(lat, lon float64) bool {
+	if len(p) == 0 {
+		return false
+	}
+	if !ringContains(p[0], lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains ray casting algorithm for a single ring
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	in := false
+	j := len(ring) - 1
+	for i := range ring {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+		j = i
+	}
+	return in
+}
+
+// Polygons a list of polygons
+type Polygons []Polygon
+
+// Contains checks if the given position is inside one of the polygons
+func (ps Polygons) Contains(lat, lon float64) bool {
+	for _, p := range ps {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPolygons reads all polygons from a geojson file. The file can contain a feature collection, a single feature or a plain geometry.
+func ReadPolygons(file string) (Polygons, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolygons(data)
+}
+
+// ParsePolygons parses all polygons and multi polygons from geojson data
+func ParsePolygons(data []byte) (Polygons, error) {
+	gs, typ, err := parseGeometries(data)
+	if err != nil {
+		return nil, err
+	}
+	ps := make(Polygons, 0)
+	for _, g := range gs {
+		switch t := g.(type) {
+		case *geom.Polygon:
+			ps = append(ps, fromGeomPolygon(t))
+		case *geom.MultiPolygon:
+			for i := range t.NumPolygons() {
+				ps = append(ps, fromGeomPolygon(t.Polygon(i)))
+			}
+		}
+	}
+	if len(ps) == 0 {
+		return nil, fmt.Errorf("%w in geojson of type %s", ErrNoPolygon, typ)
+	}
+	return ps, nil
+}
+
+// parseGeometries the geometries of a feature collection, a single feature or a plain geometry, typ is the geojson type
+func parseGeometries(data []byte) (gs []geom.T, typ string, err error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, "", err
+	}
+	gs = make([]geom.T, 0)
+	switch head.Type {
+	case "FeatureCollection":
+		fc := geojson.FeatureCollection{}
+		if err := fc.UnmarshalJSON(data); err != nil {
+			return nil, head.Type, err
+		}
+		for _, f := range fc.Features {
+			gs = append(gs, f.Geometry)
+		}
+	case "Feature":
+		f := geojson.Feature{}
+		if err := f.UnmarshalJSON(data); err != nil {
+			return nil, head.Type, err
+		}
+		gs = append(gs, f.Geometry)
+	default:
+		var g geom.T
+		if err := geojson.Unmarshal(data, &g); err != nil {
+			return nil, head.Type, err
+		}
+		gs = append(gs, g)
+	}
+	return gs, head.Type, nil
+}
+
+func fromGeomPolygon(gp *geom.Polygon) Polygon {
+	p := make(Polygon, 0, gp.NumLinearRings())
+	for i := range gp.NumLinearRings() {
+		lr := gp.LinearRing(i)
+		ring := make([][2]float64, 0, lr.NumCoords())
+		for _, c := range lr.Coords() {
+			ring = append(ring, [2]float64{c.X(), c.Y()})
+		}
+		p = append(p, ring)
+	}
+	return p
+}
@@ -0,0 +1,70 @@
+package geo
+
+import "math"
+
+// Simplify reduces the positions ([lon, lat]) of a line with the Douglas-Peucker algorithm, tolerance is the max
+// distance in meters of a removed position to the simplified line. The first and last position are always kept.
+func Simplify(line [][2]float64, tolerance float64) [][2]float64 {
+	keep := SimplifyIndex(line, tolerance)
+	res := make([][2]float64, 0, len(keep))
+	for _, i := range keep {
+		res = append(res, line[i])
+	}
+	return res
+}
+
+// SimplifyIndex the same as Simplify, but returns the indices of the kept positions in ascending order
+func SimplifyIndex(line [][2]float64, tolerance float64) []int {
+	n := len(line)
+	if n <= 2 || tolerance <= 0 {
+		idx := make([]int, n)
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx
+	}
+	// local equirectangular projection to meters
+	kx := EarthRadius * math.Pi / 180 * math.Cos(line[0][1]*math.Pi/180)
+	ky := EarthRadius * math.Pi / 180
+	pts := make([][2]float64, n)
+	for i, p := range line {
+		pts[i] = [2]float64{p[0] * kx, p[1] * ky}
+	}
+
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+	stack := [][2]int{{0, n - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dmax, imax := 0.0, -1
+		for i := s[0] + 1; i < s[1]; i++ {
+			if d := segmentDistance(pts[i], pts[s[0]], pts[s[1]]); d > dmax {
+				dmax, imax = d, i
+			}
+		}
+		if imax >= 0 && dmax > tolerance {
+			keep[imax] = true
+			stack = append(stack, [2]int{s[0], imax}, [2]int{imax, s[1]})
+		}
+	}
+	idx := make([]int, 0)
+	for i, k := range keep {
+		if k {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// segmentDistance distance of p to the segment a-b in a plane
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / l2
+	t = max(0, min(1, t))
+	return math.Hypot(p[0]-a[0]-t*dx, p[1]-a[1]-t*dy)
+}
@@ -0,0 +1,17 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimplify(t *testing.T) {
+	ast := assert.New(t)
+	// about 1.1 m off the straight line
+	line := [][2]float64{{8.5, 47.5}, {8.501, 47.50001}, {8.502, 47.5}, {8.503, 47.501}}
+	ast.Equal([][2]float64{{8.5, 47.5}, {8.502, 47.5}, {8.503, 47.501}}, Simplify(line, 2))
+	ast.Equal(line, Simplify(line, 0.5))
+	ast.Equal([]int{0, 1, 2, 3}, SimplifyIndex(line, 0))
+	ast.Len(Simplify(line[:2], 100), 2)
+}
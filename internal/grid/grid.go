@@ -0,0 +1,213 @@
+package grid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+const (
+	// AggMean the mean of all soundings of a cell
+	AggMean = "mean"
+	// AggMedian the median of the soundings of a cell, approximated from a sample for cells with many soundings
+	AggMedian = "median"
+	// AggMin the minimum depth of a cell, the conservative value for navigation
+	AggMin = "min"
+
+	// DefaultMaxSamples the default number of soundings per cell kept for the median
+	DefaultMaxSamples = 255
+	// MaxRasterCells the max number of cells (columns * rows) of the raster and the ascii grid. A single outlier far
+	// away from the other soundings would need billions of cells.
+	MaxRasterCells = 25_000_000
+)
+
+// ErrTooLarge error for a grid extent with more than MaxRasterCells cells
+var ErrTooLarge = errors.New("the grid extent is too large")
+
+// metersPerDegree length of one degree latitude
+var metersPerDegree = geo.EarthRadius * math.Pi / 180
+
+type key struct {
+	x, y int32
+}
+
+type cell struct {
+	count   int64
+	sum     float64
+	min     float64
+	samples []float64
+}
+
+// Cell an aggregated grid cell, the bounds are in degrees
+type Cell struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+	Depth  float64
+	Count  int64
+}
+
+// Grid aggregates soundings into cells of a fixed size. The memory depends on the number of cells, not on the number
+// of soundings: mean and minimum are calculated on the fly, for the median at most maxSamples soundings per cell are
+// kept (reservoir sampling).
+type Grid struct {
+	cellSize   float64
+	agg        string
+	maxSamples int
+	refLat     float64
+	dLat, dLon float64
+	cells      map[key]*cell
+	rnd        *rand.Rand
+}
+
+// New creates a new grid with the cell size in meters. The cells are aligned in degrees, the longitude size of the
+// cells is calculated at the latitude (rounded to degrees) of the first sounding.
+func New(cellSize float64, agg string, maxSamples int) (*Grid, error) {
+	if cellSize <= 0 {
+		return nil, errors.New("the cell size must be > 0")
+	}
+	switch agg {
+	case AggMean, AggMedian, AggMin:
+	case "":
+		agg = AggMean
+	default:
+		return nil, fmt.Errorf("unknown aggregation %s, use %s, %s or %s", agg, AggMean, AggMedian, AggMin)
+	}
+	if maxSamples <= 0 {
+		maxSamples = DefaultMaxSamples
+	}
+	return &Grid{
+		cellSize:   cellSize,
+		agg:        agg,
+		maxSamples: maxSamples,
+		cells:      make(map[key]*cell),
+		rnd:        rand.New(rand.NewPCG(1, 2)),
+	}, nil
+}
+
+// Add adds a sounding, soundings with a depth <= 0 are ignored
+func (g *Grid) Add(lat, lon, depth float64) {
+	if depth <= 0 || math.IsNaN(depth) {
+		return
+	}
+	if g.dLat == 0 {
+		g.refLat = math.Round(lat)
+		g.dLat = g.cellSize / metersPerDegree
+		g.dLon = g.cellSize / (metersPerDegree * math.Cos(g.refLat*math.Pi/180))
+	}
+	k := key{x: int32(math.Floor(lon / g.dLon)), y: int32(math.Floor(lat / g.dLat))}
+	c, ok := g.cells[k]
+	if !ok {
+		c = &cell{min: depth}
+		g.cells[k] = c
+	}
+	c.count++
+	c.sum += depth
+	c.min = math.Min(c.min, depth)
+	if g.agg != AggMedian {
+		return
+	}
+	if len(c.samples) < g.maxSamples {
+		c.samples = append(c.samples, depth)
+		return
+	}
+	if i := g.rnd.Int64N(c.count); i < int64(g.maxSamples) {
+		c.samples[i] = depth
+	}
+}
+
+// Len the number of cells with soundings
+func (g *Grid) Len() int {
+	return len(g.cells)
+}
+
+// CellSize the size of the cells in degrees, longitude and latitude
+func (g *Grid) CellSize() (dLon, dLat float64) {
+	return g.dLon, g.dLat
+}
+
+// Cells all cells with soundings, sorted from north to south and west to east
+func (g *Grid) Cells() []Cell {
+	keys := g.keys()
+	cs := make([]Cell, 0, len(keys))
+	for _, k := range keys {
+		cs = append(cs, g.cellOf(k))
+	}
+	return cs
+}
+
+// keys the keys of all cells, sorted from north to south and west to east
+func (g *Grid) keys() []key {
+	keys := make([]key, 0, len(g.cells))
+	for k := range g.cells {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].y != keys[j].y {
+			return keys[i].y > keys[j].y
+		}
+		return keys[i].x < keys[j].x
+	})
+	return keys
+}
+
+// extent the min and max keys of the grid
+// size the number of columns and rows of the extent, ErrTooLarge for more than MaxRasterCells cells
+func (g *Grid) size() (cols, rows int, err error) {
+	minK, maxK := g.extent()
+	cols = int(maxK.x) - int(minK.x) + 1
+	rows = int(maxK.y) - int(minK.y) + 1
+	if int64(cols)*int64(rows) > MaxRasterCells {
+		return cols, rows, fmt.Errorf("%w: %d x %d cells, max %d cells, check the soundings for outliers or use a larger cell size",
+			ErrTooLarge, cols, rows, MaxRasterCells)
+	}
+	return cols, rows, nil
+}
+
+func (g *Grid) extent() (minK, maxK key) {
+	first := true
+	for k := range g.cells {
+		if first {
+			minK, maxK = k, k
+			first = false
+			continue
+		}
+		minK.x, minK.y = min(minK.x, k.x), min(minK.y, k.y)
+		maxK.x, maxK.y = max(maxK.x, k.x), max(maxK.y, k.y)
+	}
+	return minK, maxK
+}
+
+func (g *Grid) cellOf(k key) Cell {
+	c := g.cells[k]
+	return Cell{
+		MinLat: float64(k.y) * g.dLat,
+		MinLon: float64(k.x) * g.dLon,
+		MaxLat: float64(k.y+1) * g.dLat,
+		MaxLon: float64(k.x+1) * g.dLon,
+		Depth:  g.value(c),
+		Count:  c.count,
+	}
+}
+
+func (g *Grid) value(c *cell) float64 {
+	switch g.agg {
+	case AggMin:
+		return c.min
+	case AggMedian:
+		s := slices.Clone(c.samples)
+		slices.Sort(s)
+		n := len(s)
+		if n%2 == 1 {
+			return s[n/2]
+		}
+		return (s[n/2-1] + s[n/2]) / 2
+	}
+	return c.sum / float64(c.count)
+}
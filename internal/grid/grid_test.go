@@ -0,0 +1,179 @@
+package grid
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+type GridSuite struct {
+	suite.Suite
+}
+
+func TestGridSuite(t *testing.T) {
+	suite.Run(t, new(GridSuite))
+}
+
+func (s *GridSuite) grid(agg string) *Grid {
+	g, err := New(10, agg, 0)
+	s.Require().NoError(err)
+	// three soundings in one cell, one in the cell to the east, a dry one that is ignored
+	g.Add(47.50001, 8.50001, 3.0)
+	g.Add(47.50002, 8.50002, 5.0)
+	g.Add(47.50003, 8.50003, 10.0)
+	dLon, _ := g.CellSize()
+	g.Add(47.50001, 8.50001+dLon, 2.0)
+	g.Add(47.50001, 8.50001, 0.0)
+	return g
+}
+
+func (s *GridSuite) TestNew() {
+	_, err := New(0, AggMean, 0)
+	s.Error(err)
+	_, err = New(10, "max", 0)
+	s.Error(err)
+	g, err := New(10, "", 0)
+	s.Require().NoError(err)
+	s.Equal(AggMean, g.agg)
+	s.Equal(DefaultMaxSamples, g.maxSamples)
+}
+
+func (s *GridSuite) TestAggregation() {
+	for agg, depth := range map[string]float64{AggMean: 6.0, AggMedian: 5.0, AggMin: 3.0} {
+		cs := s.grid(agg).Cells()
+		s.Require().Len(cs, 2, agg)
+		s.InDelta(depth, cs[0].Depth, 1e-9, agg)
+		s.EqualValues(3, cs[0].Count)
+		s.InDelta(2.0, cs[1].Depth, 1e-9, agg)
+		s.EqualValues(1, cs[1].Count)
+		s.Less(cs[0].MinLon, cs[1].MinLon)
+	}
+}
+
+func (s *GridSuite) TestCellSize() {
+	g := s.grid(AggMean)
+	dLon, dLat := g.CellSize()
+	s.InDelta(10.0/111195.0, dLat, 1e-8)
+	s.Greater(dLon, dLat)
+	c := g.Cells()[0]
+	s.InDelta(dLat, c.MaxLat-c.MinLat, 1e-12)
+	s.LessOrEqual(c.MinLat, 47.50001)
+	s.Greater(c.MaxLat, 47.50003)
+}
+
+func (s *GridSuite) TestMedianSamples() {
+	g, err := New(10, AggMedian, 10)
+	s.Require().NoError(err)
+	for i := range 1000 {
+		g.Add(47.5, 8.5, float64(i%10+1))
+	}
+	cs := g.Cells()
+	s.Require().Len(cs, 1)
+	s.EqualValues(1000, cs[0].Count)
+	s.Len(g.cells[g.keys()[0]].samples, 10)
+	s.InDelta(5.5, cs[0].Depth, 3)
+}
+
+func (s *GridSuite) TestWriteASCII() {
+	g := s.grid(AggMean)
+	var buf bytes.Buffer
+	s.Require().NoError(g.WriteASCII(&buf, false))
+	ls := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	s.Require().Len(ls, 8)
+	s.Equal("ncols 2", ls[0])
+	s.Equal("nrows 1", ls[1])
+	s.True(strings.HasPrefix(ls[4], "dx "))
+	s.Equal("NODATA_value -9999", ls[6])
+	s.Equal("6.00 2.00", ls[7])
+
+	buf.Reset()
+	s.Require().NoError(g.WriteASCII(&buf, true))
+	s.True(strings.HasSuffix(buf.String(), "3 1\n"))
+
+	empty, _ := New(10, AggMean, 0)
+	s.Error(empty.WriteASCII(&buf, false))
+}
+
+func (s *GridSuite) TestWriteASCIINoData() {
+	g, _ := New(10, AggMin, 0)
+	g.Add(47.5, 8.5, 1.0)
+	dLon, dLat := g.CellSize()
+	g.Add(47.5+dLat, 8.5+dLon, 2.0)
+	var buf bytes.Buffer
+	s.Require().NoError(g.WriteASCII(&buf, false))
+	s.True(strings.HasSuffix(buf.String(), "-9999 2.00\n1.00 -9999\n"))
+}
+
+func (s *GridSuite) TestWriteGeoJSON() {
+	var buf bytes.Buffer
+	s.Require().NoError(s.grid(AggMin).WriteGeoJSON(&buf))
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string         `json:"type"`
+				Coordinates [][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				Depth float64 `json:"depth"`
+				Count int64   `json:"count"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	s.Require().NoError(json.Unmarshal(buf.Bytes(), &fc))
+	s.Equal("FeatureCollection", fc.Type)
+	s.Require().Len(fc.Features, 2)
+	f := fc.Features[0]
+	s.Equal("Polygon", f.Geometry.Type)
+	s.Len(f.Geometry.Coordinates[0], 5)
+	s.Equal(f.Geometry.Coordinates[0][0], f.Geometry.Coordinates[0][4])
+	s.Equal(3.0, f.Properties.Depth)
+	s.EqualValues(3, f.Properties.Count)
+}
+
+func (s *GridSuite) TestXYZInput() {
+	dir := s.T().TempDir()
+	xyz := filepath.Join(dir, "soundings.xyz")
+	data := "8.500010,47.500010,3.00,2016-09-11T10:00:05.000Z\n" +
+		"8.500020;47.500020;5.00\n" +
+		"8.500030\t47.500030\t10.00\n" +
+		"\n"
+	s.Require().NoError(os.WriteFile(xyz, []byte(data), 0o644))
+
+	gs := &gridder{log: *logging.New().WithName("Grid")}
+	out := filepath.Join(dir, "out", "depth.asc")
+	res, err := gs.Grid([]string{xyz}, out, Options{CellSize: 10, Aggregation: AggMedian})
+	s.Require().NoError(err)
+	s.EqualValues(3, res.Soundings)
+	s.Equal(1, res.Cells)
+	s.Equal([]string{out, filepath.Join(dir, "out", "depth_count.asc"), filepath.Join(dir, "out", "depth.prj")}, res.Files)
+	for _, f := range res.Files {
+		s.FileExists(f)
+	}
+	asc, _ := os.ReadFile(out)
+	s.True(strings.HasSuffix(string(asc), "5.00\n"))
+
+	out = filepath.Join(dir, "depth.geojson")
+	res, err = gs.Grid([]string{xyz}, out, Options{CellSize: 10})
+	s.Require().NoError(err)
+	s.Equal([]string{out}, res.Files)
+
+	s.Require().NoError(os.WriteFile(xyz, []byte("8.5,abc,3.0\n"), 0o644))
+	_, err = gs.Grid([]string{xyz}, out, Options{CellSize: 10})
+	s.Error(err)
+	_, err = gs.Grid([]string{xyz}, out, Options{CellSize: 10, Format: "tif"})
+	s.Error(err)
+
+	// an outlier, the ascii grid would be too large
+	s.Require().NoError(os.WriteFile(xyz, []byte(data+"0.0001,0.0001,4.0\n"), 0o644))
+	out = filepath.Join(dir, "outlier.asc")
+	_, err = gs.Grid([]string{xyz}, out, Options{CellSize: 10})
+	s.ErrorIs(err, ErrTooLarge)
+	s.NoFileExists(out)
+}
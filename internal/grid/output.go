@@ -0,0 +1,97 @@
+package grid
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// NoData the value of empty cells in the ascii grid
+	NoData = -9999
+
+	// PRJWGS84 the esri projection file content for wgs84
+	PRJWGS84 = `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+)
+
+// WriteASCII writes the grid as esri ascii grid. With count = true the number of soundings per cell is written
+// instead of the depth. As the cells are not square in degrees, the cell size is written as dx and dy.
+func (g *Grid) WriteASCII(w io.Writer, count bool) error {
+	if g.Len() == 0 {
+		return fmt.Errorf("the grid is empty")
+	}
+	ncols, nrows, err := g.size()
+	if err != nil {
+		return err
+	}
+	minK, maxK := g.extent()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ncols %d\nnrows %d\n", ncols, nrows)
+	fmt.Fprintf(bw, "xllcorner %s\nyllcorner %s\n", fmtFloat(float64(minK.x)*g.dLon, 9), fmtFloat(float64(minK.y)*g.dLat, 9))
+	fmt.Fprintf(bw, "dx %s\ndy %s\n", fmtFloat(g.dLon, 12), fmtFloat(g.dLat, 12))
+	fmt.Fprintf(bw, "NODATA_value %d\n", NoData)
+	for y := maxK.y; y >= minK.y; y-- {
+		for x := minK.x; x <= maxK.x; x++ {
+			if x > minK.x {
+				bw.WriteByte(' ')
+			}
+			c, ok := g.cells[key{x: x, y: y}]
+			switch {
+			case !ok:
+				bw.WriteString(strconv.Itoa(NoData))
+			case count:
+				bw.WriteString(strconv.FormatInt(c.count, 10))
+			default:
+				bw.WriteString(fmtFloat(g.value(c), 2))
+			}
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// WriteGeoJSON writes every cell with soundings as polygon feature with the properties depth and count
+func (g *Grid) WriteGeoJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`{"type":"FeatureCollection","features":[`)
+	for i, c := range g.Cells() {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		f := map[string]any{
+			"type": "Feature",
+			"geometry": map[string]any{
+				"type": "Polygon",
+				"coordinates": [][][2]float64{{
+					{c.MinLon, c.MinLat},
+					{c.MaxLon, c.MinLat},
+					{c.MaxLon, c.MaxLat},
+					{c.MinLon, c.MaxLat},
+					{c.MinLon, c.MinLat},
+				}},
+			},
+			"properties": map[string]any{
+				"depth": roundTo(c.Depth, 2),
+				"count": c.Count,
+			},
+		}
+		js, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		bw.Write(js)
+	}
+	bw.WriteString("]}")
+	return bw.Flush()
+}
+
+func fmtFloat(v float64, prec int) string {
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+func roundTo(v float64, prec int) float64 {
+	r, _ := strconv.ParseFloat(fmtFloat(v, prec), 64)
+	return r
+}
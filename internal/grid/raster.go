@@ -0,0 +1,189 @@
+package grid
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// InterpNone no interpolation, only the cells with soundings
+	InterpNone = "none"
+	// InterpIDW empty cells are filled by inverse distance weighting of the cells around
+	InterpIDW = "idw"
+	// InterpTIN empty cells are filled from a triangulation (delaunay) of the cells with soundings
+	InterpTIN = "tin"
+
+	// DefaultRadius the default search radius (idw) or max edge length (tin) in cells
+	DefaultRadius = 4
+)
+
+// Raster the grid as regular raster, the nodes are the centres of the cells. Row 0 is the southern row, column 0 the
+// western column. Nodes without a value are NaN.
+type Raster struct {
+	// MinLon, MinLat the position of the south western node
+	MinLon float64
+	MinLat float64
+	// DLon, DLat the distance of the nodes in degrees
+	DLon   float64
+	DLat   float64
+	Cols   int
+	Rows   int
+	Values []float64
+}
+
+// Raster returns the cell values of the grid as raster, nil for an empty grid. ErrTooLarge for an extent with more
+// than MaxRasterCells cells.
+func (g *Grid) Raster() (*Raster, error) {
+	if g.Len() == 0 {
+		return nil, nil
+	}
+	cols, rows, err := g.size()
+	if err != nil {
+		return nil, err
+	}
+	minK, _ := g.extent()
+	r := &Raster{
+		MinLon: (float64(minK.x) + 0.5) * g.dLon,
+		MinLat: (float64(minK.y) + 0.5) * g.dLat,
+		DLon:   g.dLon,
+		DLat:   g.dLat,
+		Cols:   cols,
+		Rows:   rows,
+	}
+	r.Values = make([]float64, r.Cols*r.Rows)
+	for i := range r.Values {
+		r.Values[i] = math.NaN()
+	}
+	for k, c := range g.cells {
+		r.Values[int(k.y-minK.y)*r.Cols+int(k.x-minK.x)] = g.value(c)
+	}
+	return r, nil
+}
+
+// At the value of the node, NaN outside of the raster or without value
+func (r *Raster) At(x, y int) float64 {
+	if x < 0 || y < 0 || x >= r.Cols || y >= r.Rows {
+		return math.NaN()
+	}
+	return r.Values[y*r.Cols+x]
+}
+
+// Position converts raster coordinates (in nodes, may be fractional) into lat and lon
+func (r *Raster) Position(x, y float64) (lat, lon float64) {
+	return r.MinLat + y*r.DLat, r.MinLon + x*r.DLon
+}
+
+// Interpolate fills the empty nodes with the method, radius is the search radius (idw) or the max edge length of the
+// triangles (tin) in cells. Nodes farther away from the soundings stay empty.
+func (r *Raster) Interpolate(method string, radius int) error {
+	if radius <= 0 {
+		radius = DefaultRadius
+	}
+	switch method {
+	case InterpNone:
+		return nil
+	case "", InterpIDW:
+		r.fillIDW(radius)
+	case InterpTIN:
+		r.fillTIN(float64(radius))
+	default:
+		return fmt.Errorf("unknown interpolation %s, use %s, %s or %s", method, InterpIDW, InterpTIN, InterpNone)
+	}
+	return nil
+}
+
+// fillIDW fills every empty node with at least two values in the radius, weighted by 1/d²
+func (r *Raster) fillIDW(radius int) {
+	src := make([]float64, len(r.Values))
+	copy(src, r.Values)
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= r.Cols || y >= r.Rows {
+			return math.NaN()
+		}
+		return src[y*r.Cols+x]
+	}
+	r2 := radius * radius
+	for y := range r.Rows {
+		for x := range r.Cols {
+			if !math.IsNaN(src[y*r.Cols+x]) {
+				continue
+			}
+			var sum, wsum float64
+			n := 0
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					d2 := dx*dx + dy*dy
+					if d2 > r2 {
+						continue
+					}
+					v := at(x+dx, y+dy)
+					if math.IsNaN(v) {
+						continue
+					}
+					w := 1 / float64(d2)
+					sum += w * v
+					wsum += w
+					n++
+				}
+			}
+			if n >= 2 {
+				r.Values[y*r.Cols+x] = sum / wsum
+			}
+		}
+	}
+}
+
+// fillTIN triangulates the nodes with values and fills the empty nodes inside of triangles with no edge longer than
+// maxEdge by linear interpolation
+func (r *Raster) fillTIN(maxEdge float64) {
+	pts := make([][2]float64, 0)
+	vals := make([]float64, 0)
+	for y := range r.Rows {
+		for x := range r.Cols {
+			if v := r.Values[y*r.Cols+x]; !math.IsNaN(v) {
+				pts = append(pts, [2]float64{float64(x), float64(y)})
+				vals = append(vals, v)
+			}
+		}
+	}
+	if len(pts) < 3 {
+		return
+	}
+	src := make([]float64, len(r.Values))
+	copy(src, r.Values)
+	me2 := maxEdge * maxEdge
+	for _, t := range triangulate(pts) {
+		a, b, c := pts[t[0]], pts[t[1]], pts[t[2]]
+		if dist2(a, b) > me2 || dist2(b, c) > me2 || dist2(c, a) > me2 {
+			continue
+		}
+		det := (b[1]-c[1])*(a[0]-c[0]) + (c[0]-b[0])*(a[1]-c[1])
+		if det == 0 {
+			continue
+		}
+		x0, x1 := int(math.Ceil(min(a[0], b[0], c[0]))), int(math.Floor(max(a[0], b[0], c[0])))
+		y0, y1 := int(math.Ceil(min(a[1], b[1], c[1]))), int(math.Floor(max(a[1], b[1], c[1])))
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				i := y*r.Cols + x
+				if !math.IsNaN(src[i]) || !math.IsNaN(r.Values[i]) {
+					continue
+				}
+				px, py := float64(x), float64(y)
+				l1 := ((b[1]-c[1])*(px-c[0]) + (c[0]-b[0])*(py-c[1])) / det
+				l2 := ((c[1]-a[1])*(px-c[0]) + (a[0]-c[0])*(py-c[1])) / det
+				l3 := 1 - l1 - l2
+				const eps = -1e-9
+				if l1 < eps || l2 < eps || l3 < eps {
+					continue
+				}
+				r.Values[i] = l1*vals[t[0]] + l2*vals[t[1]] + l3*vals[t[2]]
+			}
+		}
+	}
+}
+
+func dist2(a, b [2]float64) float64 {
+	dx, dy := a[0]-b[0], a[1]-b[1]
+	return dx*dx + dy*dy
+}
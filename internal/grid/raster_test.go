@@ -0,0 +1,123 @@
+package grid
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RasterSuite struct {
+	suite.Suite
+}
+
+func TestRasterSuite(t *testing.T) {
+	suite.Run(t, new(RasterSuite))
+}
+
+// plane returns a raster with the values 1 + x + 2y, the nodes in holes are empty
+func (s *RasterSuite) plane(cols, rows int, holes ...[2]int) *Raster {
+	r := &Raster{MinLon: 8.5, MinLat: 47.5, DLon: 0.0001, DLat: 0.0001, Cols: cols, Rows: rows}
+	r.Values = make([]float64, cols*rows)
+	for y := range rows {
+		for x := range cols {
+			r.Values[y*cols+x] = 1 + float64(x) + 2*float64(y)
+		}
+	}
+	for _, h := range holes {
+		r.Values[h[1]*cols+h[0]] = math.NaN()
+	}
+	return r
+}
+
+func (s *RasterSuite) TestGridRaster() {
+	g, _ := New(10, AggMean, 0)
+	g.Add(47.5, 8.5, 1.0)
+	dLon, dLat := g.CellSize()
+	g.Add(47.5+2*dLat, 8.5+dLon, 3.0)
+	r, err := g.Raster()
+	s.Require().NoError(err)
+	s.Require().NotNil(r)
+	s.Equal(2, r.Cols)
+	s.Equal(3, r.Rows)
+	s.Equal(1.0, r.At(0, 0))
+	s.Equal(3.0, r.At(1, 2))
+	s.True(math.IsNaN(r.At(1, 0)))
+	s.True(math.IsNaN(r.At(5, 5)))
+	lat, lon := r.Position(0, 0)
+	s.InDelta(47.5, lat, dLat)
+	s.InDelta(8.5, lon, dLon)
+
+	empty, _ := New(10, AggMean, 0)
+	r, err = empty.Raster()
+	s.NoError(err)
+	s.Nil(r)
+}
+
+func (s *RasterSuite) TestOutlier() {
+	g, _ := New(1, AggMean, 0)
+	g.Add(47.5, 8.5, 1.0)
+	g.Add(47.5001, 8.5001, 2.0)
+	// a single fix on null island
+	g.Add(0.0001, 0.0001, 3.0)
+	_, err := g.Raster()
+	s.ErrorIs(err, ErrTooLarge)
+	s.ErrorIs(g.WriteASCII(io.Discard, false), ErrTooLarge)
+	// only the cells are written
+	s.NoError(g.WriteGeoJSON(io.Discard))
+}
+
+func (s *RasterSuite) TestIDW() {
+	r := s.plane(5, 5, [2]int{2, 2})
+	s.Require().NoError(r.Interpolate(InterpIDW, 1))
+	// symmetric neighbours, the weighted mean is exact on a plane
+	s.InDelta(7.0, r.At(2, 2), 1e-9)
+
+	r = s.plane(9, 1, [2]int{4, 0}, [2]int{5, 0}, [2]int{6, 0}, [2]int{7, 0}, [2]int{8, 0})
+	s.Require().NoError(r.Interpolate(InterpIDW, 2))
+	s.False(math.IsNaN(r.At(4, 0)))
+	// only one value in the radius
+	s.True(math.IsNaN(r.At(6, 0)))
+}
+
+func (s *RasterSuite) TestTIN() {
+	r := s.plane(6, 6, [2]int{2, 2}, [2]int{3, 2}, [2]int{2, 3})
+	s.Require().NoError(r.Interpolate(InterpTIN, 4))
+	for _, h := range [][2]int{{2, 2}, {3, 2}, {2, 3}} {
+		// linear interpolation is exact on a plane
+		s.InDelta(1+float64(h[0])+2*float64(h[1]), r.At(h[0], h[1]), 1e-6)
+	}
+
+	// the gap is larger than the max edge
+	r = s.plane(10, 2, [2]int{3, 0}, [2]int{4, 0}, [2]int{5, 0}, [2]int{6, 0}, [2]int{3, 1}, [2]int{4, 1}, [2]int{5, 1}, [2]int{6, 1})
+	s.Require().NoError(r.Interpolate(InterpTIN, 2))
+	s.True(math.IsNaN(r.At(4, 0)))
+}
+
+func (s *RasterSuite) TestInterpolateUnknown() {
+	r := s.plane(2, 2)
+	s.Error(r.Interpolate("kriging", 2))
+	s.NoError(r.Interpolate(InterpNone, 2))
+}
+
+func (s *RasterSuite) TestTriangulate() {
+	pts := make([][2]float64, 0)
+	for y := range 100 {
+		for x := range 100 {
+			pts = append(pts, [2]float64{float64(x), float64(y)})
+		}
+	}
+	td := time.Now()
+	tris := triangulate(pts)
+	s.Less(time.Since(td), 10*time.Second)
+	// a triangulation of a convex lattice: 2 triangles per square
+	area := 0.0
+	for _, t := range tris {
+		a, b, c := pts[t[0]], pts[t[1]], pts[t[2]]
+		area += math.Abs((b[0]-a[0])*(c[1]-a[1])-(b[1]-a[1])*(c[0]-a[0])) / 2
+	}
+	s.InDelta(99.0*99.0, area, 1e-6)
+	s.Len(tris, 2*99*99)
+}
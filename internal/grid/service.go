@@ -0,0 +1,194 @@
+package grid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// FormatASCII esri ascii grid, with a second grid for the counts and a prj file
+	FormatASCII = "asc"
+	// FormatGeoJSON geojson polygons with the properties depth and count
+	FormatGeoJSON = "geojson"
+)
+
+type converterSrv interface {
+	TrackPoints(trackfile string) (*model.TrackPoints, error)
+}
+
+// Options options for the grid generation
+type Options struct {
+	// CellSize the size of the cells in meters
+	CellSize float64
+	// Aggregation mean, median or min
+	Aggregation string
+	// Format asc or geojson, default is taken from the output file extension
+	Format string
+	// MaxSamples max number of soundings per cell for the median
+	MaxSamples int
+}
+
+// Result the result of the grid generation
+type Result struct {
+	Inputs    int      `json:"inputs"`
+	Soundings int64    `json:"soundings"`
+	Cells     int      `json:"cells"`
+	Files     []string `json:"files"`
+}
+
+type gridder struct {
+	log logging.Logger
+	cnv converterSrv
+}
+
+// Init registers the grid service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(inj do.Injector) (*gridder, error) {
+		return &gridder{
+			log: *logging.New().WithName("Grid"),
+			cnv: do.MustInvokeAs[converterSrv](inj),
+		}, nil
+	})
+}
+
+// Grid builds a gridded depth surface from the inputs. Inputs are track files (the waypoints with depth are used)
+// or xyz exports (lon, lat, depth), the xyz files are streamed.
+func (g *gridder) Grid(inputs []string, output string, opts Options) (*Result, error) {
+	format := opts.Format
+	if format == "" {
+		format = FormatASCII
+		if strings.EqualFold(filepath.Ext(output), ".geojson") || strings.EqualFold(filepath.Ext(output), ".json") {
+			format = FormatGeoJSON
+		}
+	}
+	if format != FormatASCII && format != FormatGeoJSON {
+		return nil, fmt.Errorf("unknown grid format %s, use %s or %s", format, FormatASCII, FormatGeoJSON)
+	}
+	gr, res, err := g.Build(inputs, opts)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatASCII {
+		// the ascii grid has all cells of the extent
+		if _, _, err := gr.size(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if format == FormatGeoJSON {
+		err = writeFile(output, gr.WriteGeoJSON)
+		res.Files = []string{output}
+		return res, err
+	}
+
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+	countFile := base + "_count.asc"
+	prjFile := base + ".prj"
+	err = writeFile(output, func(w io.Writer) error { return gr.WriteASCII(w, false) })
+	if err == nil {
+		err = writeFile(countFile, func(w io.Writer) error { return gr.WriteASCII(w, true) })
+	}
+	if err == nil {
+		err = os.WriteFile(prjFile, []byte(PRJWGS84), 0o644)
+	}
+	res.Files = []string{output, countFile, prjFile}
+	return res, err
+}
+
+// Build adds the soundings of all inputs to a new grid, see Grid
+func (g *gridder) Build(inputs []string, opts Options) (*Grid, *Result, error) {
+	if len(inputs) == 0 {
+		return nil, nil, fmt.Errorf("no input files given")
+	}
+	gr, err := New(opts.CellSize, opts.Aggregation, opts.MaxSamples)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := &Result{Inputs: len(inputs)}
+	for _, in := range inputs {
+		g.log.Infof("adding soundings of %s", in)
+		var n int64
+		if strings.EqualFold(filepath.Ext(in), ".xyz") {
+			n, err = addXYZ(gr, in)
+		} else {
+			n, err = g.addTrack(gr, in)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", in, err)
+		}
+		res.Soundings += n
+	}
+	res.Cells = gr.Len()
+	g.log.Infof("%d soundings in %d cells", res.Soundings, res.Cells)
+	return gr, res, nil
+}
+func (g *gridder) addTrack(gr *Grid, trackfile string) (int64, error) {
+	tps, err := g.cnv.TrackPoints(trackfile)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, wpt := range tps.Waypoints {
+		if wpt.Depth > 0 {
+			gr.Add(wpt.Lat, wpt.Lon, wpt.Depth)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// addXYZ streams a xyz file with lon, lat, depth (and more) per line, separated by comma, semicolon, tab or blank
+func addXYZ(gr *Grid, file string) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var n int64
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		fs := strings.FieldsFunc(sc.Text(), func(r rune) bool {
+			return r == ',' || r == ';' || r == '\t' || r == ' '
+		})
+		if len(fs) < 3 || strings.HasPrefix(fs[0], "#") {
+			continue
+		}
+		var v [3]float64
+		for i := range v {
+			v[i], err = strconv.ParseFloat(fs[i], 64)
+			if err != nil {
+				return n, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		gr.Add(v[1], v[0], v[2])
+		n++
+	}
+	return n, sc.Err()
+}
+
+func writeFile(fn string, write func(w io.Writer) error) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,159 @@
+package grid
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+type edge struct {
+	a, b int
+}
+
+type triangle struct {
+	v     [3]int
+	alive bool
+}
+
+// delaunay incremental Bowyer-Watson triangulation. The triangles are counter clockwise, the neighbours are found by
+// the reverse of the directed edges.
+type delaunay struct {
+	pts   [][2]float64
+	tris  []triangle
+	edges map[edge]int
+	last  int
+}
+
+// triangulate returns the delaunay triangles of the points as indices into pts. The points are slightly jittered
+// internally, as points on a regular raster are degenerated (cocircular).
+func triangulate(pts [][2]float64) [][3]int {
+	n := len(pts)
+	if n < 3 {
+		return nil
+	}
+	rnd := rand.New(rand.NewPCG(3, 4))
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	d := &delaunay{
+		pts:   make([][2]float64, n, n+3),
+		edges: make(map[edge]int),
+	}
+	for i, p := range pts {
+		d.pts[i] = [2]float64{p[0] + (rnd.Float64()-0.5)*1e-6, p[1] + (rnd.Float64()-0.5)*1e-6}
+		minX, minY = min(minX, p[0]), min(minY, p[1])
+		maxX, maxY = max(maxX, p[0]), max(maxY, p[1])
+	}
+	// super triangle around all points
+	size := max(maxX-minX, maxY-minY, 1) * 20
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	d.pts = append(d.pts, [2]float64{cx - size, cy - size}, [2]float64{cx + size, cy - size}, [2]float64{cx, cy + size})
+	d.add([3]int{n, n + 1, n + 2})
+
+	for i := range n {
+		d.insert(i)
+	}
+
+	res := make([][3]int, 0, len(d.tris)/2)
+	for _, t := range d.tris {
+		if t.alive && t.v[0] < n && t.v[1] < n && t.v[2] < n {
+			res = append(res, t.v)
+		}
+	}
+	return res
+}
+
+func (d *delaunay) add(v [3]int) {
+	i := len(d.tris)
+	d.tris = append(d.tris, triangle{v: v, alive: true})
+	for j := range 3 {
+		d.edges[edge{v[j], v[(j+1)%3]}] = i
+	}
+	d.last = i
+}
+
+func (d *delaunay) remove(i int) {
+	t := &d.tris[i]
+	t.alive = false
+	for j := range 3 {
+		delete(d.edges, edge{t.v[j], t.v[(j+1)%3]})
+	}
+}
+
+func (d *delaunay) insert(p int) {
+	start := d.locate(p)
+	// all triangles with the point in their circumcircle, connected to the containing triangle
+	bad := map[int]bool{start: true}
+	queue := []int{start}
+	for len(queue) > 0 {
+		t := d.tris[queue[0]]
+		queue = queue[1:]
+		for j := range 3 {
+			nb, ok := d.edges[edge{t.v[(j+1)%3], t.v[j]}]
+			if ok && !bad[nb] && d.inCircle(d.tris[nb].v, p) {
+				bad[nb] = true
+				queue = append(queue, nb)
+			}
+		}
+	}
+	boundary := make([]edge, 0)
+	for i := range bad {
+		t := d.tris[i]
+		for j := range 3 {
+			e := edge{t.v[j], t.v[(j+1)%3]}
+			if nb, ok := d.edges[edge{e.b, e.a}]; !ok || !bad[nb] {
+				boundary = append(boundary, e)
+			}
+		}
+	}
+	for i := range bad {
+		d.remove(i)
+	}
+	for _, e := range boundary {
+		d.add([3]int{e.a, e.b, p})
+	}
+}
+
+// locate walks from the last triangle to the triangle containing the point
+func (d *delaunay) locate(p int) int {
+	t := d.last
+	for steps := 0; steps < len(d.tris)+3; steps++ {
+		next := -1
+		v := d.tris[t].v
+		for j := range 3 {
+			if d.orient(v[j], v[(j+1)%3], p) < 0 {
+				if nb, ok := d.edges[edge{v[(j+1)%3], v[j]}]; ok {
+					next = nb
+					break
+				}
+			}
+		}
+		if next < 0 {
+			return t
+		}
+		t = next
+	}
+	// fallback, should not happen
+	for i, tr := range d.tris {
+		if tr.alive && d.inCircle(tr.v, p) {
+			return i
+		}
+	}
+	return d.last
+}
+
+func (d *delaunay) orient(a, b, c int) float64 {
+	pa, pb, pc := d.pts[a], d.pts[b], d.pts[c]
+	return (pb[0]-pa[0])*(pc[1]-pa[1]) - (pb[1]-pa[1])*(pc[0]-pa[0])
+}
+
+// inCircle checks if the point is inside the circumcircle of the counter clockwise triangle
+func (d *delaunay) inCircle(v [3]int, p int) bool {
+	pp := d.pts[p]
+	var m [3][3]float64
+	for j := range 3 {
+		dx, dy := d.pts[v[j]][0]-pp[0], d.pts[v[j]][1]-pp[1]
+		m[j] = [3]float64{dx, dy, dx*dx + dy*dy}
+	}
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	return det > 0
+}
@@ -0,0 +1,336 @@
+package fitimporter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	// global message numbers of the fit profile
+	mesgFileID     = 0
+	mesgSession    = 18
+	mesgLap        = 19
+	mesgRecord     = 20
+	mesgDeviceInfo = 23
+
+	fieldTimestamp = 253
+
+	// semicircles to degrees
+	semicircles = 180.0 / (1 << 31)
+)
+
+var (
+	// ErrInvalidFile error for files which are not fit files
+	ErrInvalidFile = errors.New("invalid fit file")
+	// ErrCRC error for a fit file with a wrong checksum
+	ErrCRC = errors.New("fit file checksum mismatch")
+
+	// fitEpoch the start of the fit time stamps
+	fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	// baseSizes the size of the base types
+	baseSizes = map[byte]int{
+		0x00: 1, 0x01: 1, 0x02: 1, 0x03: 2, 0x04: 2, 0x05: 4, 0x06: 4, 0x07: 1, 0x08: 4,
+		0x09: 8, 0x0A: 1, 0x0B: 2, 0x0C: 4, 0x0D: 1, 0x0E: 8, 0x0F: 8, 0x10: 8,
+	}
+
+	crcTable = [16]uint16{
+		0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+		0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+	}
+)
+
+// fieldDef a field of a definition message
+type fieldDef struct {
+	num      byte
+	size     int
+	baseType byte
+}
+
+// definition the layout of the data messages of a local message type
+type definition struct {
+	global  uint16
+	order   binary.ByteOrder
+	fields  []fieldDef
+	devSize int
+}
+
+// field the raw value of a field
+type field struct {
+	data     []byte
+	baseType byte
+	order    binary.ByteOrder
+}
+
+// message the fields of a data message by field number
+type message map[byte]field
+
+// crc16 the fit checksum
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[b&0xF]
+		tmp = crcTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ crcTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// Decode reads a fit file. Only the messages needed for tracks are decoded: file id, device info, records, laps
+// and sessions. Developer fields are skipped.
+func Decode(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[8:12]) != ".FIT" {
+		return nil, fmt.Errorf("%w: missing .FIT signature", ErrInvalidFile)
+	}
+	hs := int(data[0])
+	size := int(binary.LittleEndian.Uint32(data[4:8]))
+	if hs < 12 || len(data) < hs+size+2 {
+		return nil, fmt.Errorf("%w: file is truncated", ErrInvalidFile)
+	}
+	if crc16(data[:hs+size]) != binary.LittleEndian.Uint16(data[hs+size:]) {
+		return nil, ErrCRC
+	}
+	f := &File{
+		ProtocolVersion: data[1],
+		ProfileVersion:  binary.LittleEndian.Uint16(data[2:4]),
+	}
+	d := decoder{data: data[hs : hs+size], defs: make(map[byte]*definition), file: f}
+	if err := d.decode(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+	defs map[byte]*definition
+	// lastTime the last time stamp, the base of compressed time stamps
+	lastTime uint32
+	file     *File
+}
+
+// next returns the next n bytes
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected end of data at %d", ErrInvalidFile, d.pos)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) decode() error {
+	for d.pos < len(d.data) {
+		b, err := d.next(1)
+		if err != nil {
+			return err
+		}
+		h := b[0]
+		switch {
+		case h&0x80 != 0:
+			// compressed time stamp header
+			if err := d.message((h>>5)&0x03, true, uint32(h&0x1F)); err != nil {
+				return err
+			}
+		case h&0x40 != 0:
+			if err := d.definition(h&0x0F, h&0x20 != 0); err != nil {
+				return err
+			}
+		default:
+			if err := d.message(h&0x0F, false, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *decoder) definition(local byte, dev bool) error {
+	b, err := d.next(5)
+	if err != nil {
+		return err
+	}
+	def := &definition{order: binary.LittleEndian}
+	if b[1] == 1 {
+		def.order = binary.BigEndian
+	}
+	def.global = def.order.Uint16(b[2:4])
+	fs, err := d.next(int(b[4]) * 3)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(fs); i += 3 {
+		def.fields = append(def.fields, fieldDef{num: fs[i], size: int(fs[i+1]), baseType: fs[i+2] & 0x1F})
+	}
+	if dev {
+		n, err := d.next(1)
+		if err != nil {
+			return err
+		}
+		dfs, err := d.next(int(n[0]) * 3)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(dfs); i += 3 {
+			def.devSize += int(dfs[i+1])
+		}
+	}
+	d.defs[local] = def
+	return nil
+}
+
+func (d *decoder) message(local byte, compressed bool, offset uint32) error {
+	def, ok := d.defs[local]
+	if !ok {
+		return fmt.Errorf("%w: data message without definition for local type %d", ErrInvalidFile, local)
+	}
+	m := make(message, len(def.fields)+1)
+	for _, fd := range def.fields {
+		b, err := d.next(fd.size)
+		if err != nil {
+			return err
+		}
+		if fd.size < baseSizes[fd.baseType] || baseSizes[fd.baseType] == 0 {
+			// invalid field definition
+			continue
+		}
+		m[fd.num] = field{data: b, baseType: fd.baseType, order: def.order}
+	}
+	if _, err := d.next(def.devSize); err != nil {
+		return err
+	}
+	if compressed {
+		ts := d.lastTime&^0x1F + offset
+		if offset < d.lastTime&0x1F {
+			ts += 0x20
+		}
+		d.lastTime = ts
+		m[fieldTimestamp] = field{data: binary.LittleEndian.AppendUint32(nil, ts), baseType: 0x06, order: binary.LittleEndian}
+	} else if ts, ok := m.uint(fieldTimestamp); ok {
+		d.lastTime = uint32(ts)
+	}
+	d.file.add(def.global, m)
+	return nil
+}
+
+// uint the value of an unsigned field (enum, uint*, uint*z, byte), false if not available or invalid
+func (m message) uint(num byte) (uint64, bool) {
+	f, ok := m[num]
+	if !ok {
+		return 0, false
+	}
+	var v, invalid uint64
+	switch f.baseType {
+	case 0x00, 0x02, 0x0D:
+		v, invalid = uint64(f.data[0]), 0xFF
+	case 0x0A:
+		v = uint64(f.data[0])
+	case 0x04:
+		v, invalid = uint64(f.order.Uint16(f.data)), 0xFFFF
+	case 0x0B:
+		v = uint64(f.order.Uint16(f.data))
+	case 0x06:
+		v, invalid = uint64(f.order.Uint32(f.data)), 0xFFFFFFFF
+	case 0x0C:
+		v = uint64(f.order.Uint32(f.data))
+	case 0x0F:
+		v, invalid = f.order.Uint64(f.data), math.MaxUint64
+	case 0x10:
+		v = f.order.Uint64(f.data)
+	default:
+		return 0, false
+	}
+	// the z types are invalid with 0
+	if invalid == 0 && v == 0 {
+		return 0, false
+	}
+	return v, invalid == 0 || v != invalid
+}
+
+// int the value of a signed field, false if not available or invalid
+func (m message) int(num byte) (int64, bool) {
+	f, ok := m[num]
+	if !ok {
+		return 0, false
+	}
+	switch f.baseType {
+	case 0x01:
+		v := int8(f.data[0])
+		return int64(v), v != math.MaxInt8
+	case 0x03:
+		v := int16(f.order.Uint16(f.data))
+		return int64(v), v != math.MaxInt16
+	case 0x05:
+		v := int32(f.order.Uint32(f.data))
+		return int64(v), v != math.MaxInt32
+	case 0x0E:
+		v := int64(f.order.Uint64(f.data))
+		return v, v != math.MaxInt64
+	}
+	return 0, false
+}
+
+// str the value of a string field
+func (m message) str(num byte) string {
+	f, ok := m[num]
+	if !ok || f.baseType != 0x07 {
+		return ""
+	}
+	for i, b := range f.data {
+		if b == 0 {
+			return string(f.data[:i])
+		}
+	}
+	return string(f.data)
+}
+
+// scaled the value of a numeric field with scale and offset, NaN if not available
+func (m message) scaled(num byte, scale, offset float64) float64 {
+	if v, ok := m.uint(num); ok {
+		return float64(v)/scale - offset
+	}
+	if v, ok := m.int(num); ok {
+		return float64(v)/scale - offset
+	}
+	return math.NaN()
+}
+
+// time the value of a date time field, zero if not available
+func (m message) time(num byte) time.Time {
+	v, ok := m.uint(num)
+	if !ok {
+		return time.Time{}
+	}
+	return fitEpoch.Add(time.Duration(v) * time.Second)
+}
+
+// position the latitude and longitude fields in degrees, NaN if not available
+func (m message) position(lat, lon byte) (float64, float64) {
+	la, ok1 := m.int(lat)
+	lo, ok2 := m.int(lon)
+	if !ok1 || !ok2 {
+		return math.NaN(), math.NaN()
+	}
+	return float64(la) * semicircles, float64(lo) * semicircles
+}
+
+// duration the value of a time field in ms
+func (m message) duration(num byte) time.Duration {
+	v, ok := m.uint(num)
+	if !ok {
+		return 0
+	}
+	return time.Duration(v) * time.Millisecond
+}
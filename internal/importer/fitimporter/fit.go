@@ -0,0 +1,267 @@
+package fitimporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// Format the name of the fit format
+	Format = "FIT"
+
+	manufacturerGarmin = 1
+)
+
+// sports the names of the fit sports used on and around the water
+var sports = map[uint8]string{
+	0: "generic", 1: "running", 2: "cycling", 11: "walking", 15: "rowing", 17: "hiking", 19: "paddling",
+	23: "boating", 29: "fishing", 32: "sailing", 37: "stand up paddleboarding", 38: "surfing", 39: "wakeboarding",
+	40: "water skiing", 41: "kayaking", 42: "rafting", 43: "windsurfing", 44: "kitesurfing",
+}
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "garmin fit activities, position, speed and heart rate of the records",
+		Extensions:  []string{"fit"},
+		Detect: func(head []byte) bool {
+			return len(head) >= 12 && bytes.Equal(head[8:12], []byte(".FIT"))
+		},
+		New: func() registry.Importer {
+			return New()
+		},
+	})
+}
+
+// File the decoded messages of a fit file
+type File struct {
+	ProtocolVersion uint8
+	ProfileVersion  uint16
+	FileID          FileID
+	Devices         []DeviceInfo
+	Records         []Record
+	Laps            []Lap
+	Sessions        []Session
+}
+
+// FileID the file id message
+type FileID struct {
+	Type         uint8
+	Manufacturer uint16
+	Product      uint16
+	SerialNumber uint32
+	TimeCreated  time.Time
+}
+
+// DeviceInfo the device info message
+type DeviceInfo struct {
+	Timestamp       time.Time
+	DeviceIndex     uint8
+	Manufacturer    uint16
+	Product         uint16
+	SerialNumber    uint32
+	SoftwareVersion float64
+	ProductName     string
+}
+
+// Record a record message, values which are not available are NaN or 0 for the heart rate
+type Record struct {
+	Timestamp time.Time
+	Lat       float64
+	Lon       float64
+	// Altitude in m
+	Altitude float64
+	// Speed in m/s
+	Speed float64
+	// Distance the distance since the start in m
+	Distance  float64
+	HeartRate uint8
+	// Temperature the temperature of the device in °C
+	Temperature float64
+}
+
+// HasPosition checks if the record has a position and a time stamp
+func (r Record) HasPosition() bool {
+	return !r.Timestamp.IsZero() && !math.IsNaN(r.Lat) && !math.IsNaN(r.Lon)
+}
+
+// Summary the values of a lap or session
+type Summary struct {
+	Timestamp        time.Time
+	StartTime        time.Time
+	StartLat         float64
+	StartLon         float64
+	TotalElapsedTime time.Duration
+	TotalTimerTime   time.Duration
+	// TotalDistance in m
+	TotalDistance float64
+	// AvgSpeed, MaxSpeed in m/s
+	AvgSpeed     float64
+	MaxSpeed     float64
+	AvgHeartRate uint8
+	MaxHeartRate uint8
+}
+
+// Lap a lap message
+type Lap struct {
+	Summary
+	EndLat float64
+	EndLon float64
+}
+
+// Session a session message
+type Session struct {
+	Summary
+	Sport    uint8
+	SubSport uint8
+}
+
+// SportName the name of the sport
+func (s Session) SportName() string {
+	if n, ok := sports[s.Sport]; ok {
+		return n
+	}
+	return fmt.Sprintf("sport %d", s.Sport)
+}
+
+// add maps a data message into the file
+func (f *File) add(global uint16, m message) {
+	// invalid or missing values are 0
+	u8 := func(num byte) uint8 {
+		if v, ok := m.uint(num); ok {
+			return uint8(v)
+		}
+		return 0
+	}
+	u16 := func(num byte) uint16 {
+		if v, ok := m.uint(num); ok {
+			return uint16(v)
+		}
+		return 0
+	}
+	u32 := func(num byte) uint32 {
+		if v, ok := m.uint(num); ok {
+			return uint32(v)
+		}
+		return 0
+	}
+	switch global {
+	case mesgFileID:
+		f.FileID = FileID{Type: u8(0), Manufacturer: u16(1), Product: u16(2), SerialNumber: u32(3), TimeCreated: m.time(4)}
+	case mesgDeviceInfo:
+		f.Devices = append(f.Devices, DeviceInfo{
+			Timestamp:       m.time(fieldTimestamp),
+			DeviceIndex:     u8(0),
+			Manufacturer:    u16(2),
+			SerialNumber:    u32(3),
+			Product:         u16(4),
+			SoftwareVersion: m.scaled(5, 100, 0),
+			ProductName:     m.str(27),
+		})
+	case mesgRecord:
+		r := Record{
+			Timestamp:   m.time(fieldTimestamp),
+			Altitude:    m.scaled(2, 5, 500),
+			Speed:       m.scaled(6, 1000, 0),
+			Distance:    m.scaled(5, 100, 0),
+			HeartRate:   u8(3),
+			Temperature: m.scaled(13, 1, 0),
+		}
+		r.Lat, r.Lon = m.position(0, 1)
+		// the enhanced fields replace the 16 bit fields
+		if v := m.scaled(73, 1000, 0); !math.IsNaN(v) {
+			r.Speed = v
+		}
+		if v := m.scaled(78, 5, 500); !math.IsNaN(v) {
+			r.Altitude = v
+		}
+		f.Records = append(f.Records, r)
+	case mesgLap:
+		l := Lap{Summary: summary(m, 13, 14, 15, 16)}
+		l.EndLat, l.EndLon = m.position(5, 6)
+		f.Laps = append(f.Laps, l)
+	case mesgSession:
+		f.Sessions = append(f.Sessions, Session{Summary: summary(m, 14, 15, 16, 17), Sport: u8(5), SubSport: u8(6)})
+	}
+}
+
+// summary the common fields of laps and sessions, the numbers of the speed and heart rate fields differ
+func summary(m message, avgSpeed, maxSpeed, avgHR, maxHR byte) Summary {
+	s := Summary{
+		Timestamp:        m.time(fieldTimestamp),
+		StartTime:        m.time(2),
+		TotalElapsedTime: m.duration(7),
+		TotalTimerTime:   m.duration(8),
+		TotalDistance:    m.scaled(9, 100, 0),
+		AvgSpeed:         m.scaled(avgSpeed, 1000, 0),
+		MaxSpeed:         m.scaled(maxSpeed, 1000, 0),
+	}
+	s.StartLat, s.StartLon = m.position(3, 4)
+	if v, ok := m.uint(avgHR); ok {
+		s.AvgHeartRate = uint8(v)
+	}
+	if v, ok := m.uint(maxHR); ok {
+		s.MaxHeartRate = uint8(v)
+	}
+	return s
+}
+
+// Device the name of the recording device, the product name or the manufacturer and product number
+func (f *File) Device() string {
+	for _, d := range f.Devices {
+		if d.ProductName != "" {
+			return d.ProductName
+		}
+	}
+	if f.FileID.Manufacturer == manufacturerGarmin {
+		return fmt.Sprintf("garmin product %d", f.FileID.Product)
+	}
+	return fmt.Sprintf("manufacturer %d product %d", f.FileID.Manufacturer, f.FileID.Product)
+}
+
+// FITImporter imports the records of a fit activity
+type FITImporter struct {
+	log logging.Logger
+}
+
+// New returns a new FITImporter
+func New() *FITImporter {
+	return &FITImporter{
+		log: *logging.New().WithName("FITImporter"),
+	}
+}
+
+// Import reads the records with position, speed and heart rate
+func (i *FITImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	f, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	fixes := make([]registry.Fix, 0, len(f.Records))
+	for _, r := range f.Records {
+		if !r.HasPosition() {
+			continue
+		}
+		fix := registry.NewFix(r.Timestamp, r.Lat, r.Lon)
+		if !math.IsNaN(r.Speed) {
+			fix.Speed = registry.MS2Knots(r.Speed)
+		}
+		if r.HeartRate > 0 {
+			fix.HeartRate = float64(r.HeartRate)
+		}
+		fixes = append(fixes, fix)
+	}
+	if len(fixes) == 0 {
+		return nil, errors.New("no records with position found")
+	}
+	i.log.Infof("%d of %d records with position, %d laps, %d sessions, device %s", len(fixes), len(f.Records), len(f.Laps), len(f.Sessions), f.Device())
+	return registry.FixLines(fixes, source)
+}
@@ -0,0 +1,228 @@
+package fitimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+// fitWriter writes fit files for the tests
+type fitWriter struct {
+	buf bytes.Buffer
+}
+
+// def writes a definition message, a field is number, size and base type
+func (w *fitWriter) def(local byte, global uint16, order binary.AppendByteOrder, fields [][3]byte, devFields [][3]byte) {
+	h := 0x40 | local
+	if len(devFields) > 0 {
+		h |= 0x20
+	}
+	arch := byte(0)
+	if order == binary.BigEndian {
+		arch = 1
+	}
+	w.buf.Write([]byte{h, 0, arch})
+	w.buf.Write(order.AppendUint16(nil, global))
+	w.buf.WriteByte(byte(len(fields)))
+	for _, f := range fields {
+		w.buf.Write(f[:])
+	}
+	if len(devFields) > 0 {
+		w.buf.WriteByte(byte(len(devFields)))
+		for _, f := range devFields {
+			w.buf.Write(f[:])
+		}
+	}
+}
+
+// data writes a data message with a normal or a compressed time stamp header
+func (w *fitWriter) data(h byte, values ...[]byte) {
+	w.buf.WriteByte(h)
+	for _, v := range values {
+		w.buf.Write(v)
+	}
+}
+
+func (w *fitWriter) bytes() []byte {
+	var f bytes.Buffer
+	f.Write([]byte{14, 0x20})
+	f.Write(binary.LittleEndian.AppendUint16(nil, 2132))
+	f.Write(binary.LittleEndian.AppendUint32(nil, uint32(w.buf.Len())))
+	f.WriteString(".FIT")
+	f.Write(binary.LittleEndian.AppendUint16(nil, crc16(f.Bytes())))
+	f.Write(w.buf.Bytes())
+	f.Write(binary.LittleEndian.AppendUint16(nil, crc16(f.Bytes())))
+	return f.Bytes()
+}
+
+var (
+	le    = binary.LittleEndian
+	be    = binary.BigEndian
+	start = time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+)
+
+func u16(o binary.AppendByteOrder, v uint16) []byte { return o.AppendUint16(nil, v) }
+func u32(o binary.AppendByteOrder, v uint32) []byte { return o.AppendUint32(nil, v) }
+func ts(t time.Time) []byte                         { return u32(le, uint32(t.Sub(fitEpoch).Seconds())) }
+func semi(o binary.AppendByteOrder, deg float64) []byte {
+	return u32(o, uint32(int32(math.Round(deg/semicircles))))
+}
+
+// activity a sailing activity with 3 records, one without position
+func activity() []byte {
+	var w fitWriter
+	w.def(0, mesgFileID, le, [][3]byte{{0, 1, 0x00}, {1, 2, 0x84}, {2, 2, 0x84}, {4, 4, 0x86}}, nil)
+	w.data(0, []byte{4}, u16(le, manufacturerGarmin), u16(le, 3113), ts(start))
+	w.def(1, mesgDeviceInfo, le, [][3]byte{{253, 4, 0x86}, {0, 1, 0x02}, {5, 2, 0x84}, {27, 8, 0x07}}, nil)
+	w.data(1, ts(start), []byte{0}, u16(le, 2510), []byte("fenix 6\x00"))
+
+	// records with a developer field
+	w.def(2, mesgRecord, le, [][3]byte{{253, 4, 0x86}, {0, 4, 0x85}, {1, 4, 0x85}, {3, 1, 0x02}, {6, 2, 0x84}, {78, 4, 0x86}},
+		[][3]byte{{0, 2, 0}})
+	w.data(2, ts(start), semi(le, 53.5), semi(le, 9.9), []byte{120}, u16(le, 2500), u32(le, (12+500)*5), []byte{1, 2})
+	// big endian record with compressed time stamp, 3 seconds later
+	w.def(3, mesgRecord, be, [][3]byte{{0, 4, 0x85}, {1, 4, 0x85}, {3, 1, 0x02}, {73, 4, 0x86}}, nil)
+	offset := byte(uint32(start.Add(3*time.Second).Sub(fitEpoch).Seconds()) & 0x1F)
+	w.data(0x80|3<<5|offset, semi(be, 53.501), semi(be, 9.9), []byte{125}, u32(be, 3000))
+	// no position
+	w.data(2, ts(start.Add(5*time.Second)), u32(le, math.MaxInt32), u32(le, math.MaxInt32), []byte{130}, u16(le, 0xFFFF), u32(le, 0xFFFFFFFF), []byte{0, 0})
+
+	w.def(4, mesgLap, le, [][3]byte{{253, 4, 0x86}, {2, 4, 0x86}, {3, 4, 0x85}, {4, 4, 0x85}, {7, 4, 0x86}, {9, 4, 0x86}, {13, 2, 0x84}, {15, 1, 0x02}, {16, 1, 0x02}}, nil)
+	w.data(4, ts(start.Add(5*time.Second)), ts(start), semi(le, 53.5), semi(le, 9.9), u32(le, 5000), u32(le, 11100), u16(le, 2750), []byte{125}, []byte{130})
+	w.def(5, mesgSession, le, [][3]byte{{253, 4, 0x86}, {2, 4, 0x86}, {5, 1, 0x00}, {7, 4, 0x86}, {14, 2, 0x84}, {16, 1, 0x02}}, nil)
+	w.data(5, ts(start.Add(5*time.Second)), ts(start), []byte{32}, u32(le, 5000), u16(le, 2750), []byte{125})
+	return w.bytes()
+}
+
+type FITSuite struct {
+	suite.Suite
+}
+
+func TestFITSuite(t *testing.T) {
+	suite.Run(t, new(FITSuite))
+}
+
+func (s *FITSuite) TestDecode() {
+	f, err := Decode(bytes.NewReader(activity()))
+	s.Require().NoError(err)
+	s.Equal(uint16(2132), f.ProfileVersion)
+	s.Equal(uint8(4), f.FileID.Type)
+	s.Equal(start, f.FileID.TimeCreated)
+	s.Require().Len(f.Devices, 1)
+	s.Equal("fenix 6", f.Devices[0].ProductName)
+	s.InDelta(25.1, f.Devices[0].SoftwareVersion, 1e-9)
+	s.Equal("fenix 6", f.Device())
+
+	s.Require().Len(f.Records, 3)
+	r := f.Records[0]
+	s.Equal(start, r.Timestamp)
+	s.InDelta(53.5, r.Lat, 1e-6)
+	s.InDelta(9.9, r.Lon, 1e-6)
+	s.Equal(uint8(120), r.HeartRate)
+	s.InDelta(2.5, r.Speed, 1e-9)
+	s.InDelta(12.0, r.Altitude, 1e-9)
+	s.True(math.IsNaN(r.Temperature))
+
+	r = f.Records[1]
+	s.Equal(start.Add(3*time.Second), r.Timestamp)
+	s.InDelta(53.501, r.Lat, 1e-6)
+	s.InDelta(3.0, r.Speed, 1e-9)
+	s.True(math.IsNaN(r.Altitude))
+	s.True(r.HasPosition())
+
+	s.False(f.Records[2].HasPosition())
+	s.True(math.IsNaN(f.Records[2].Speed))
+
+	s.Require().Len(f.Laps, 1)
+	l := f.Laps[0]
+	s.Equal(start, l.StartTime)
+	s.Equal(5*time.Second, l.TotalElapsedTime)
+	s.InDelta(111.0, l.TotalDistance, 1e-9)
+	s.InDelta(2.75, l.AvgSpeed, 1e-9)
+	s.Equal(uint8(130), l.MaxHeartRate)
+	s.True(math.IsNaN(l.EndLat))
+
+	s.Require().Len(f.Sessions, 1)
+	s.Equal("sailing", f.Sessions[0].SportName())
+	s.Equal(uint8(125), f.Sessions[0].AvgHeartRate)
+}
+
+func (s *FITSuite) TestImport() {
+	data := activity()
+	fm, err := registry.Detect("activity.bin", data)
+	s.Require().NoError(err)
+	s.Equal(Format, fm.Name)
+
+	lls, err := New().Import(bytes.NewReader(data), "activity.fit")
+	s.Require().NoError(err)
+	tp, err := model.GetWaypoints(&model.TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+	s.Require().Len(tp.Waypoints, 2)
+	s.Equal(start, tp.Waypoints[0].Time)
+	s.Equal(int64(120), tp.Waypoints[0].HeartRate)
+	s.Equal(int64(125), tp.Waypoints[1].HeartRate)
+	s.InDelta(registry.MS2Knots(2.5), tp.Waypoints[0].Speed, 0.01)
+	s.InDelta(registry.MS2Knots(3.0), tp.Waypoints[1].Speed, 0.01)
+	s.Equal("activity.fit", tp.Waypoints[1].Source)
+}
+
+func (s *FITSuite) TestInvalid() {
+	data := activity()
+	data[20] ^= 0xFF
+	_, err := Decode(bytes.NewReader(data))
+	s.ErrorIs(err, ErrCRC)
+
+	_, err = Decode(bytes.NewReader([]byte("no fit file at all")))
+	s.ErrorIs(err, ErrInvalidFile)
+
+	var w fitWriter
+	w.data(1, []byte{0})
+	_, err = Decode(bytes.NewReader(w.bytes()))
+	s.ErrorIs(err, ErrInvalidFile)
+
+	w = fitWriter{}
+	w.def(0, mesgRecord, le, [][3]byte{{253, 4, 0x86}, {0, 4, 0x85}, {1, 4, 0x85}}, nil)
+	w.data(0, ts(start), u32(le, math.MaxInt32), u32(le, math.MaxInt32))
+	_, err = New().Import(bytes.NewReader(w.bytes()), "indoor.fit")
+	s.ErrorContains(err, "no records with position")
+}
+
+func (s *FITSuite) TestInvalidValues() {
+	// a heart rate monitor which is not connected, the invalid values are 0xFF...
+	var w fitWriter
+	w.def(0, mesgFileID, le, [][3]byte{{0, 1, 0x00}, {1, 2, 0x84}, {2, 2, 0x84}, {3, 4, 0x86}}, nil)
+	w.data(0, []byte{0xFF}, u16(le, 0xFFFF), u16(le, 0xFFFF), u32(le, 0xFFFFFFFF))
+	w.def(1, mesgDeviceInfo, le, [][3]byte{{0, 1, 0x02}, {2, 2, 0x84}, {3, 4, 0x86}, {4, 2, 0x84}}, nil)
+	w.data(1, []byte{0xFF}, u16(le, 0xFFFF), u32(le, 0xFFFFFFFF), u16(le, 0xFFFF))
+	w.def(2, mesgRecord, le, [][3]byte{{253, 4, 0x86}, {0, 4, 0x85}, {1, 4, 0x85}, {3, 1, 0x02}}, nil)
+	w.data(2, ts(start), semi(le, 53.5), semi(le, 9.9), []byte{0xFF})
+	data := w.bytes()
+
+	f, err := Decode(bytes.NewReader(data))
+	s.Require().NoError(err)
+	s.Equal(FileID{}, f.FileID)
+	s.Require().Len(f.Devices, 1)
+	d := f.Devices[0]
+	s.Zero(d.DeviceIndex)
+	s.Zero(d.Manufacturer)
+	s.Zero(d.SerialNumber)
+	s.Zero(d.Product)
+	s.Require().Len(f.Records, 1)
+	s.Zero(f.Records[0].HeartRate)
+
+	lls, err := New().Import(bytes.NewReader(data), "activity.fit")
+	s.Require().NoError(err)
+	for _, ll := range lls {
+		s.NotContains(ll.NMEAMessage.Prefix(), "MHR")
+	}
+	tp, err := model.GetWaypoints(&model.TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+	s.Require().Len(tp.Waypoints, 1)
+	s.Zero(tp.Waypoints[0].HeartRate)
+}
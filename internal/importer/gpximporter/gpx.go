@@ -0,0 +1,121 @@
+package gpximporter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/twpayne/go-gpx"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+// Format the name of the gpx format
+const Format = "GPX"
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "gpx tracks with time stamps, depth, water temperature, speed, course and heart rate from the garmin or opencpn extensions",
+		Extensions:  []string{"gpx"},
+		Detect: func(head []byte) bool {
+			return bytes.Contains(head, []byte("<gpx"))
+		},
+		New: func() registry.Importer {
+			return New()
+		},
+	})
+}
+
+// GPXImporter imports the track points of all tracks of a gpx file
+type GPXImporter struct {
+	log logging.Logger
+}
+
+// New returns a new GPXImporter
+func New() *GPXImporter {
+	return &GPXImporter{
+		log: *logging.New().WithName("GPXImporter"),
+	}
+}
+
+// Import reads all track points with a time stamp, points without time are skipped
+func (i *GPXImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	g, err := gpx.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	fixes := make([]registry.Fix, 0)
+	skipped := 0
+	for _, trk := range g.Trk {
+		for _, seg := range trk.TrkSeg {
+			for _, pt := range seg.TrkPt {
+				if pt.Time.IsZero() {
+					skipped++
+					continue
+				}
+				f := registry.NewFix(pt.Time, pt.Lat, pt.Lon)
+				// gpx 1.0 speed and course
+				if pt.Speed > 0 {
+					f.Speed = registry.MS2Knots(pt.Speed)
+				}
+				if pt.Course > 0 {
+					f.Course = pt.Course
+				}
+				if pt.Extensions != nil {
+					extensions(pt.Extensions.XML, &f)
+				}
+				fixes = append(fixes, f)
+			}
+		}
+	}
+	if skipped > 0 {
+		i.log.Infof("%d track points without time skipped", skipped)
+	}
+	if len(fixes) == 0 {
+		return nil, errors.New("no track points with time found")
+	}
+	sort.SliceStable(fixes, func(a, b int) bool { return fixes[a].Time.Before(fixes[b].Time) })
+	return registry.FixLines(fixes, source)
+}
+
+// extensions reads depth, water temperature, speed (m/s), course and heart rate of any extension namespace
+func extensions(data []byte, f *registry.Fix) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	name := ""
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name = strings.ToLower(t.Name.Local)
+		case xml.EndElement:
+			name = ""
+		case xml.CharData:
+			v, err := strconv.ParseFloat(strings.TrimSpace(string(t)), 64)
+			if err != nil || math.IsNaN(v) {
+				continue
+			}
+			switch name {
+			case "depth":
+				f.Depth = v
+			case "wtemp", "watertemp", "temp":
+				f.WaterTemp = v
+			case "speed":
+				f.Speed = registry.MS2Knots(v)
+			case "course":
+				f.Course = v
+			case "hr":
+				f.HeartRate = v
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package gpximporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/export/gpxexporter"
+	exreg "github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type GPXSuite struct {
+	suite.Suite
+}
+
+func TestGPXSuite(t *testing.T) {
+	suite.Run(t, new(GPXSuite))
+}
+
+func (s *GPXSuite) track() model.TrackPoints {
+	start := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	tp := model.TrackPoints{Name: "test"}
+	for i := range 4 {
+		tp.Waypoints = append(tp.Waypoints, &model.Waypoint{
+			Lat:       47.0 + float64(i)*0.001,
+			Lon:       8.0,
+			Time:      start.Add(time.Duration(i) * 10 * time.Second),
+			Speed:     5.0,
+			Depth:     10.5,
+			WaterTemp: 21.25,
+		})
+	}
+	return tp
+}
+
+// roundTrip exports the track as gpx and imports it again
+func (s *GPXSuite) roundTrip(ext string) *model.TrackPoints {
+	exp, err := gpxexporter.NewWithOptions(exreg.Options{"extensions": ext})
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTrack(s.track(), &buf))
+
+	lls, err := New().Import(&buf, "phone.gpx")
+	s.Require().NoError(err)
+	for _, ll := range lls {
+		s.Equal("phone.gpx", ll.Source)
+	}
+	tp, err := model.GetWaypoints(&model.TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+	return tp
+}
+
+func (s *GPXSuite) TestRoundTrip() {
+	for _, ext := range []string{gpxexporter.ExtGarmin, gpxexporter.ExtOpenCPN} {
+		tp := s.roundTrip(ext)
+		exp := s.track()
+		s.Require().Len(tp.Waypoints, len(exp.Waypoints), ext)
+		for i, wpt := range tp.Waypoints {
+			s.True(exp.Waypoints[i].Time.Equal(wpt.Time), ext)
+			s.InDelta(exp.Waypoints[i].Lat, wpt.Lat, 1e-6, ext)
+			s.InDelta(exp.Waypoints[i].Lon, wpt.Lon, 1e-6, ext)
+			s.InDelta(5.0, wpt.Speed, 0.01, ext)
+			s.InDelta(10.5, wpt.Depth, 0.01, ext)
+			s.InDelta(21.25, wpt.WaterTemp, 0.05, ext)
+		}
+	}
+}
+
+func (s *GPXSuite) TestCalculatedSpeed() {
+	data := `<?xml version="1.0"?>
+<gpx version="1.1" creator="phone" xmlns="http://www.topografix.com/GPX/1/1">
+<trk><trkseg>
+<trkpt lat="47.0" lon="8.0"><time>2016-09-11T10:00:00Z</time></trkpt>
+<trkpt lat="47.0" lon="8.1"></trkpt>
+<trkpt lat="47.001" lon="8.0"><time>2016-09-11T10:00:20Z</time></trkpt>
+</trkseg></trk>
+</gpx>`
+	lls, err := New().Import(strings.NewReader(data), "phone.gpx")
+	s.Require().NoError(err)
+	tp, err := model.GetWaypoints(&model.TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+	// the point without time is skipped
+	s.Require().Len(tp.Waypoints, 2)
+	// 111 m in 20 s, due north
+	for _, wpt := range tp.Waypoints {
+		s.InDelta(10.8, wpt.Speed, 0.1)
+		s.InDelta(0.0, wpt.Course, 0.1)
+		s.Zero(wpt.Depth)
+	}
+}
+
+func (s *GPXSuite) TestNoTime() {
+	data := `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><trk><trkseg><trkpt lat="47.0" lon="8.0"></trkpt></trkseg></trk></gpx>`
+	_, err := New().Import(strings.NewReader(data), "phone.gpx")
+	s.Error(err)
+}
@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/importer/fitimporter"
+	"github.com/willie68/osmltools/internal/importer/gpximporter"
+	"github.com/willie68/osmltools/internal/importer/jsonimporter"
+	"github.com/willie68/osmltools/internal/importer/kmlimporter"
+	"github.com/willie68/osmltools/internal/importer/nmeaimporter"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/importer/slimporter"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	FITFormat      = fitimporter.Format
+	GPXFormat      = gpximporter.Format
+	JSONFormat     = jsonimporter.Format
+	KMLFormat      = kmlimporter.FormatKML
+	KMZFormat      = kmlimporter.FormatKMZ
+	NMEAFormat     = nmeaimporter.Format
+	NMEA0183Format = nmeaimporter.FormatPlain
+	SL2Format      = slimporter.FormatSL2
+	SL3Format      = slimporter.FormatSL3
+
+	// headSize the number of bytes used for the format detection
+	headSize = 4096
+)
+
+var (
+	// ErrUnknownFormat error for an unknown format or a file which can't be detected
+	ErrUnknownFormat = registry.ErrUnknownFormat
+	// SupportedFormats all supported import formats, every importer registered in the format registry
+	SupportedFormats = registry.Names()
+)
+
+type importer struct {
+	log logging.Logger
+}
+
+// Init registers the import service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(_ do.Injector) (*importer, error) {
+		return &importer{
+			log: *logging.New().WithName("Importer"),
+		}, nil
+	})
+}
+
+// Import reads the file with the importer of the format, an empty format is detected by the file extension and
+// content. The log lines have the correct time stamp and the file name as source.
+func (i *importer) Import(file, format string) ([]*model.LogLine, error) {
+	fs, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+	r := bufio.NewReaderSize(fs, headSize)
+
+	var f registry.Format
+	if format != "" {
+		var ok bool
+		f, ok = registry.Get(format)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+		}
+	} else {
+		// Peek returns the available bytes and an error for files smaller than the head
+		head, _ := r.Peek(headSize)
+		f, err = registry.Detect(file, head)
+		if err != nil {
+			return nil, err
+		}
+	}
+	i.log.Infof("importing %s as %s", file, f.Name)
+	imp := f.New()
+	if ms, ok := imp.(registry.ModTimeSetter); ok {
+		if st, err := fs.Stat(); err == nil {
+			ms.SetModTime(st.ModTime())
+		}
+	}
+	lls, err := imp.Import(r, filepath.Base(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+	}
+	i.log.Infof("%d log lines imported from %s", len(lls), file)
+	return lls, nil
+}
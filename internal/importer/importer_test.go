@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const gpxData = `<?xml version="1.0"?>
+<gpx version="1.1" creator="phone" xmlns="http://www.topografix.com/GPX/1/1">
+<trk><trkseg>
+<trkpt lat="53.5" lon="9.9"><time>2016-09-11T10:00:00Z</time></trkpt>
+<trkpt lat="53.501" lon="9.9"><time>2016-09-11T10:00:10Z</time></trkpt>
+</trkseg></trk>
+</gpx>`
+
+type importerSrv interface {
+	Import(file, format string) ([]*model.LogLine, error)
+}
+
+type ImporterSuite struct {
+	suite.Suite
+	imp importerSrv
+	dir string
+}
+
+func TestImporterSuite(t *testing.T) {
+	suite.Run(t, new(ImporterSuite))
+}
+
+func (s *ImporterSuite) SetupTest() {
+	inj := do.New()
+	Init(inj)
+	s.imp = do.MustInvokeAs[importerSrv](inj)
+	s.dir = s.T().TempDir()
+}
+
+func (s *ImporterSuite) file(name, data string) string {
+	fn := filepath.Join(s.dir, name)
+	s.Require().NoError(os.WriteFile(fn, []byte(data), 0o644))
+	return fn
+}
+
+func (s *ImporterSuite) TestFormats() {
+	s.Equal([]string{FITFormat, GPXFormat, JSONFormat, KMLFormat, KMZFormat, NMEAFormat, NMEA0183Format, SL2Format, SL3Format}, SupportedFormats)
+}
+
+func (s *ImporterSuite) TestDetectByContent() {
+	// the extension doesn't match, the content decides
+	lls, err := s.imp.Import(s.file("export.xml", gpxData), "")
+	s.Require().NoError(err)
+	s.Len(lls, 2)
+	s.Equal("export.xml", lls[0].Source)
+
+	lls, err = s.imp.Import(s.file("recording.txt", registry.Sentence("GPRMC,100001.00,A,4700.000,N,00800.000,E,5.00,90.0,110916,,")), "")
+	s.Require().NoError(err)
+	s.Len(lls, 1)
+}
+
+func (s *ImporterSuite) TestFormat() {
+	fn := s.file("export.dat", gpxData)
+	lls, err := s.imp.Import(fn, "gpx")
+	s.Require().NoError(err)
+	s.Len(lls, 2)
+
+	_, err = s.imp.Import(fn, "unknown")
+	s.ErrorIs(err, ErrUnknownFormat)
+
+	_, err = s.imp.Import(s.file("notes.txt", "some notes"), "")
+	s.ErrorIs(err, ErrUnknownFormat)
+
+	_, err = s.imp.Import(filepath.Join(s.dir, "missing.gpx"), "")
+	s.Error(err)
+}
@@ -0,0 +1,94 @@
+package jsonimporter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+// Format the name of the json format
+const Format = "JSON"
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "the versioned json document of the json export, the log lines or the waypoints",
+		Extensions:  []string{"json"},
+		Detect: func(head []byte) bool {
+			return bytes.Contains(head, []byte(`"schema"`)) && bytes.Contains(head, []byte(`"`+model.DocumentSchema+`"`))
+		},
+		New: func() registry.Importer {
+			return New()
+		},
+	})
+}
+
+// JSONImporter imports the json documents of the json export
+type JSONImporter struct {
+	log logging.Logger
+}
+
+// New returns a new JSONImporter
+func New() *JSONImporter {
+	return &JSONImporter{
+		log: *logging.New().WithName("JSONImporter"),
+	}
+}
+
+// Import reads the log lines of the document, the source of the lines is kept. Documents without log lines are
+// imported from the waypoints.
+func (i *JSONImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	tp, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(tp.LogLines) > 0 {
+		for _, ll := range tp.LogLines {
+			if ll.Source == "" {
+				ll.Source = source
+			}
+		}
+		sort.SliceStable(tp.LogLines, func(a, b int) bool {
+			return tp.LogLines[a].CorrectTimeStamp.Before(tp.LogLines[b].CorrectTimeStamp)
+		})
+		return tp.LogLines, nil
+	}
+	fixes := make([]registry.Fix, 0, len(tp.Waypoints))
+	for _, wpt := range tp.Waypoints {
+		if wpt.Time.IsZero() {
+			continue
+		}
+		f := registry.NewFix(wpt.Time, wpt.Lat, wpt.Lon)
+		f.Speed = wpt.Speed
+		f.Course = wpt.Course
+		if wpt.Depth != 0 {
+			f.Depth = wpt.Depth
+		}
+		if wpt.WaterTemp != 0 {
+			f.WaterTemp = wpt.WaterTemp
+		}
+		if wpt.HeartRate != 0 {
+			f.HeartRate = float64(wpt.HeartRate)
+		}
+		fixes = append(fixes, f)
+	}
+	if len(fixes) == 0 {
+		return nil, errors.New("no log lines and no waypoints with time found")
+	}
+	i.log.Infof("document without log lines, %d waypoints imported", len(fixes))
+	return registry.FixLines(fixes, source)
+}
+
+// Read reads a json document into track points
+func Read(r io.Reader) (*model.TrackPoints, error) {
+	d, err := model.ReadTrackDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.TrackPoints(), nil
+}
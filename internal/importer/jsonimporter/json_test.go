@@ -0,0 +1,91 @@
+package jsonimporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/export/jsonexporter"
+	exreg "github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type JSONSuite struct {
+	suite.Suite
+}
+
+func TestJSONSuite(t *testing.T) {
+	suite.Run(t, new(JSONSuite))
+}
+
+func (s *JSONSuite) track() model.TrackPoints {
+	start := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	fixes := make([]registry.Fix, 0)
+	for i := range 3 {
+		f := registry.NewFix(start.Add(time.Duration(i)*time.Second), 47.5+float64(i)*0.001, 8.75)
+		f.Depth = 10.5
+		fixes = append(fixes, f)
+	}
+	lls, err := registry.FixLines(fixes, "DATA001231.DAT")
+	s.Require().NoError(err)
+	tp, err := model.GetWaypoints(&model.TrackPoints{Name: "test", LogLines: lls})
+	s.Require().NoError(err)
+	return *tp
+}
+
+func (s *JSONSuite) export(opts exreg.Options) []byte {
+	exp, err := jsonexporter.NewWithOptions(opts)
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(exp.ExportTrack(s.track(), &buf))
+	return buf.Bytes()
+}
+
+func (s *JSONSuite) TestRoundTrip() {
+	data := s.export(nil)
+	f, err := registry.Detect("export.txt", data)
+	s.Require().NoError(err)
+	s.Equal(Format, f.Name)
+
+	lls, err := New().Import(bytes.NewReader(data), "track_0000.json")
+	s.Require().NoError(err)
+	exp := s.track()
+	s.Require().Len(lls, len(exp.LogLines))
+	for i, ll := range lls {
+		s.Equal(exp.LogLines[i].Unknown, ll.Unknown)
+		s.Equal(exp.LogLines[i].CorrectTimeStamp, ll.CorrectTimeStamp)
+		// the source of the original data is kept
+		s.Equal("DATA001231.DAT", ll.Source)
+	}
+
+	tp, err := Read(bytes.NewReader(data))
+	s.Require().NoError(err)
+	s.Equal("test", tp.Name)
+	s.Len(tp.Waypoints, 3)
+}
+
+func (s *JSONSuite) TestWaypointsOnly() {
+	data := s.export(exreg.Options{"loglines": "false"})
+	lls, err := New().Import(bytes.NewReader(data), "track_0000.json")
+	s.Require().NoError(err)
+	tp, err := model.GetWaypoints(&model.TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+	exp := s.track()
+	s.Require().Len(tp.Waypoints, 3)
+	for i, wpt := range tp.Waypoints {
+		s.Equal(exp.Waypoints[i].Time, wpt.Time)
+		s.InDelta(exp.Waypoints[i].Lat, wpt.Lat, 1e-6)
+		s.InDelta(10.5, wpt.Depth, 0.01)
+		s.Equal("track_0000.json", wpt.Source)
+	}
+}
+
+func (s *JSONSuite) TestInvalid() {
+	_, err := New().Import(strings.NewReader(`{"schema":"osml-trackpoints","version":1,"waypoints":[]}`), "empty.json")
+	s.Error(err)
+	_, err = New().Import(strings.NewReader(`{"schema":"osml-trackpoints","version":99}`), "future.json")
+	s.ErrorIs(err, model.ErrUnsupportedDocument)
+}
@@ -0,0 +1,204 @@
+package kmlimporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// FormatKML the name of the kml format
+	FormatKML = "KML"
+	// FormatKMZ the name of the kmz format
+	FormatKMZ = "KMZ"
+)
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        FormatKML,
+		Description: "time stamped gx:Track elements, speed (kn), depth and water temperature from the extended data",
+		Extensions:  []string{"kml"},
+		Detect: func(head []byte) bool {
+			return bytes.Contains(head, []byte("<kml"))
+		},
+		New: func() registry.Importer {
+			return New()
+		},
+	})
+	registry.Register(registry.Format{
+		Name:        FormatKMZ,
+		Description: "zipped kml, the first kml file in the archive is used",
+		Extensions:  []string{"kmz"},
+		New: func() registry.Importer {
+			return &KMZImporter{KMLImporter: New()}
+		},
+	})
+}
+
+// KMLImporter imports the gx:Track elements of a kml file
+type KMLImporter struct {
+	log logging.Logger
+}
+
+// New returns a new KMLImporter
+func New() *KMLImporter {
+	return &KMLImporter{
+		log: *logging.New().WithName("KMLImporter"),
+	}
+}
+
+// gxTrack the collected data of one gx:Track
+type gxTrack struct {
+	whens  []string
+	coords []string
+	arrays map[string][]string
+}
+
+// Import reads all gx:Track elements, line strings without time stamps can't be imported
+func (i *KMLImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	tracks, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, errors.New("no gx:Track found, only time stamped tracks can be imported")
+	}
+	fixes := make([]registry.Fix, 0)
+	for n, t := range tracks {
+		if len(t.whens) != len(t.coords) {
+			return nil, fmt.Errorf("gx:Track %d: %d time stamps for %d coordinates", n+1, len(t.whens), len(t.coords))
+		}
+		for j := range t.whens {
+			ts, err := time.Parse(time.RFC3339, strings.TrimSpace(t.whens[j]))
+			if err != nil {
+				return nil, fmt.Errorf("gx:Track %d: %w", n+1, err)
+			}
+			c := strings.Fields(t.coords[j])
+			if len(c) < 2 {
+				return nil, fmt.Errorf("gx:Track %d: invalid coordinate %q", n+1, t.coords[j])
+			}
+			lon, err1 := strconv.ParseFloat(c[0], 64)
+			lat, err2 := strconv.ParseFloat(c[1], 64)
+			if err := errors.Join(err1, err2); err != nil {
+				return nil, fmt.Errorf("gx:Track %d: %w", n+1, err)
+			}
+			f := registry.NewFix(ts, lat, lon)
+			f.Speed = t.value("speed", j)
+			f.Depth = t.value("depth", j)
+			f.WaterTemp = t.value("watertemp", j)
+			fixes = append(fixes, f)
+		}
+	}
+	sort.SliceStable(fixes, func(a, b int) bool { return fixes[a].Time.Before(fixes[b].Time) })
+	i.log.Infof("%d points of %d tracks imported", len(fixes), len(tracks))
+	return registry.FixLines(fixes, source)
+}
+
+// value the value of the extended data array at index j, NaN if not available. A depth or temperature of 0 is
+// written by the kml export for missing values.
+func (t *gxTrack) value(name string, j int) float64 {
+	vs, ok := t.arrays[name]
+	if !ok || j >= len(vs) {
+		return math.NaN()
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(vs[j]), 64)
+	if err != nil || (v == 0 && name != "speed") {
+		return math.NaN()
+	}
+	return v
+}
+
+// parse collects the time stamps, coordinates and extended data arrays of all gx:Track elements
+func parse(r io.Reader) ([]*gxTrack, error) {
+	dec := xml.NewDecoder(r)
+	tracks := make([]*gxTrack, 0)
+	var track *gxTrack
+	array := ""
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return tracks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			text.Reset()
+			switch t.Name.Local {
+			case "Track":
+				track = &gxTrack{arrays: make(map[string][]string)}
+			case "SimpleArrayData":
+				for _, a := range t.Attr {
+					if a.Name.Local == "name" {
+						array = a.Value
+					}
+				}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if track == nil {
+				continue
+			}
+			switch t.Name.Local {
+			case "Track":
+				tracks = append(tracks, track)
+				track = nil
+			case "when":
+				track.whens = append(track.whens, text.String())
+			case "coord":
+				track.coords = append(track.coords, text.String())
+			case "value":
+				if array != "" {
+					track.arrays[array] = append(track.arrays[array], text.String())
+				}
+			case "SimpleArrayData":
+				array = ""
+			}
+		}
+	}
+}
+
+// KMZImporter imports the first kml file of a kmz archive
+type KMZImporter struct {
+	*KMLImporter
+}
+
+// Import unzips the archive in memory and imports the first kml file
+func (i *KMZImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if !strings.EqualFold(path.Ext(f.Name), ".kml") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return i.KMLImporter.Import(rc, source)
+	}
+	return nil, errors.New("no kml file found in kmz")
+}
@@ -0,0 +1,98 @@
+package kmlimporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/export/kmlexporter"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type KMLSuite struct {
+	suite.Suite
+}
+
+func TestKMLSuite(t *testing.T) {
+	suite.Run(t, new(KMLSuite))
+}
+
+func (s *KMLSuite) track() model.TrackPoints {
+	start := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	tp := model.TrackPoints{Name: "test"}
+	for i := range 4 {
+		tp.Waypoints = append(tp.Waypoints, &model.Waypoint{
+			Lat:   47.0 + float64(i)*0.001,
+			Lon:   8.0,
+			Time:  start.Add(time.Duration(i) * 10 * time.Second),
+			Speed: 5.0,
+			// the last point has no depth
+			Depth:     float64(3-i) * 2.5,
+			WaterTemp: 21.25,
+		})
+	}
+	tp.Start = tp.Waypoints[0]
+	tp.End = tp.Waypoints[len(tp.Waypoints)-1]
+	return tp
+}
+
+func (s *KMLSuite) export() []byte {
+	var buf bytes.Buffer
+	s.Require().NoError(kmlexporter.New().ExportTrack(s.track(), &buf))
+	return buf.Bytes()
+}
+
+func (s *KMLSuite) check(lls []*model.LogLine, source string) {
+	for _, ll := range lls {
+		s.Equal(source, ll.Source)
+	}
+	tp, err := model.GetWaypoints(&model.TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+	exp := s.track()
+	s.Require().Len(tp.Waypoints, len(exp.Waypoints))
+	for i, wpt := range tp.Waypoints {
+		s.True(exp.Waypoints[i].Time.Equal(wpt.Time))
+		s.InDelta(exp.Waypoints[i].Lat, wpt.Lat, 1e-6)
+		s.InDelta(exp.Waypoints[i].Lon, wpt.Lon, 1e-6)
+		s.InDelta(5.0, wpt.Speed, 0.01)
+		s.InDelta(exp.Waypoints[i].Depth, wpt.Depth, 0.01)
+		s.InDelta(21.25, wpt.WaterTemp, 0.01)
+	}
+}
+
+func (s *KMLSuite) TestRoundTrip() {
+	lls, err := New().Import(bytes.NewReader(s.export()), "plotter.kml")
+	s.Require().NoError(err)
+	s.check(lls, "plotter.kml")
+}
+
+func (s *KMLSuite) TestKMZ() {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("doc.kml")
+	s.Require().NoError(err)
+	_, err = w.Write(s.export())
+	s.Require().NoError(err)
+	s.Require().NoError(zw.Close())
+
+	lls, err := (&KMZImporter{KMLImporter: New()}).Import(&buf, "plotter.kmz")
+	s.Require().NoError(err)
+	s.check(lls, "plotter.kmz")
+}
+
+func (s *KMLSuite) TestNoTrack() {
+	data := `<kml xmlns="http://www.opengis.net/kml/2.2"><Placemark><LineString><coordinates>8,47 8.1,47</coordinates></LineString></Placemark></kml>`
+	_, err := New().Import(strings.NewReader(data), "plotter.kml")
+	s.ErrorContains(err, "no gx:Track")
+}
+
+func (s *KMLSuite) TestMismatch() {
+	data := `<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2"><Placemark><gx:Track>
+<when>2016-09-11T10:00:00Z</when><when>2016-09-11T10:00:10Z</when><gx:coord>8 47 0</gx:coord>
+</gx:Track></Placemark></kml>`
+	_, err := New().Import(strings.NewReader(data), "plotter.kml")
+	s.ErrorContains(err, "2 time stamps for 1 coordinates")
+}
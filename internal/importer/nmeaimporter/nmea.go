@@ -0,0 +1,206 @@
+package nmeaimporter
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// Format the name of the nmea format with time stamp, as written by the nmea export
+	Format = "NMEA"
+	// FormatPlain the name of the plain nmea 0183 format without time stamps
+	FormatPlain = "NMEA0183"
+)
+
+// timestamped a line of the nmea export: time stamp, colon, sentence
+var timestamped = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(\.\d+)?: `)
+
+func init() {
+	registry.Register(registry.Format{
+		Name:        Format,
+		Description: "nmea sentences with time stamp prefix, as written by the nmea export and in the track files",
+		Extensions:  []string{"nmea"},
+		Detect: func(head []byte) bool {
+			return timestamped.MatchString(registry.FirstLine(head))
+		},
+		New: func() registry.Importer {
+			return New()
+		},
+	})
+	registry.Register(registry.Format{
+		Name:        FormatPlain,
+		Description: "plain nmea 0183 recordings, e.g. opencpn vdr files, the time is taken from the RMC, ZDA, GGA and GLL sentences",
+		Extensions:  []string{"nmea", "txt", "log", "vdr"},
+		Detect: func(head []byte) bool {
+			l := registry.FirstLine(head)
+			return strings.HasPrefix(l, "$") || strings.HasPrefix(l, "!")
+		},
+		New: func() registry.Importer {
+			return NewPlain()
+		},
+	})
+}
+
+// NMEAImporter imports nmea sentences with time stamp prefix
+type NMEAImporter struct {
+	log logging.Logger
+}
+
+// New returns a new NMEAImporter
+func New() *NMEAImporter {
+	return &NMEAImporter{
+		log: *logging.New().WithName("NMEAImporter"),
+	}
+}
+
+// Import reads all lines with a valid sentence, empty and comment lines are skipped
+func (i *NMEAImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	lines := make([]string, 0)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	lls, err := model.ParseLines2LogLines(lines, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, ll := range lls {
+		ll.Unknown = strings.TrimSpace(ll.Unknown)
+		ll.Source = source
+	}
+	if len(lls) == 0 {
+		return nil, errors.New("no nmea sentences found")
+	}
+	return lls, nil
+}
+
+// PlainImporter imports nmea 0183 sentences without time stamps
+type PlainImporter struct {
+	log logging.Logger
+}
+
+// NewPlain returns a new PlainImporter
+func NewPlain() *PlainImporter {
+	return &PlainImporter{
+		log: *logging.New().WithName("NMEA0183Importer"),
+	}
+}
+
+// Import reads all valid sentences. The date is taken from RMC or ZDA, the time of day additionally from GGA and GLL.
+// Every sentence gets the time of the last sentence with a time, the sentences before the first complete time stamp
+// get the first one. A time of day jumping back without a new date is a new day.
+func (i *PlainImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	lls := make([]*model.LogLine, 0)
+	var date, current time.Time
+	var lastTOD time.Duration
+	pending := 0
+	skipped := 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if p := strings.IndexAny(l, "$!"); p > 0 {
+			// tolerate prefixes like a sequence number
+			l = l[p:]
+		}
+		if l == "" || (l[0] != '$' && l[0] != '!') {
+			continue
+		}
+		ll, ok, err := model.ParseNMEALogLine(l, true)
+		if ll == nil || !ok {
+			i.log.Debugf("skipping %s: %v", l, err)
+			skipped++
+			continue
+		}
+		ll.Source = source
+		lls = append(lls, ll)
+
+		d, tod, ok := sentenceTime(ll.NMEAMessage)
+		if !ok {
+			if !current.IsZero() {
+				ll.CorrectTimeStamp = current
+				pending = len(lls)
+			}
+			continue
+		}
+		switch {
+		case !d.IsZero():
+			date = d
+		case !date.IsZero() && tod < lastTOD-12*time.Hour:
+			date = date.AddDate(0, 0, 1)
+		}
+		lastTOD = tod
+		if date.IsZero() {
+			continue
+		}
+		current = date.Add(tod)
+		for _, p := range lls[pending:] {
+			p.CorrectTimeStamp = current
+		}
+		pending = len(lls)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		i.log.Infof("%d invalid sentences skipped", skipped)
+	}
+	if len(lls) == 0 {
+		return nil, errors.New("no nmea sentences found")
+	}
+	if current.IsZero() {
+		return nil, errors.New("no date found, the recording needs RMC or ZDA sentences")
+	}
+	if pending < len(lls) {
+		// the lines after the last time stamp
+		for _, p := range lls[pending:] {
+			p.CorrectTimeStamp = current
+		}
+	}
+	return lls, nil
+}
+
+// sentenceTime the date (zero if not part of the sentence) and the time of day of a sentence, ok is false for
+// sentences without a valid time
+func sentenceTime(s nmea.Sentence) (date time.Time, tod time.Duration, ok bool) {
+	var t nmea.Time
+	switch m := s.(type) {
+	case nmea.RMC:
+		t = m.Time
+		if m.Date.Valid {
+			date = time.Date(2000+m.Date.YY, time.Month(m.Date.MM), m.Date.DD, 0, 0, 0, 0, time.UTC)
+		}
+	case nmea.ZDA:
+		t = m.Time
+		if m.Year > 0 && m.Month > 0 && m.Day > 0 {
+			date = time.Date(int(m.Year), time.Month(m.Month), int(m.Day), 0, 0, 0, 0, time.UTC)
+		}
+	case nmea.GGA:
+		t = m.Time
+	case nmea.GLL:
+		t = m.Time
+	default:
+		return date, 0, false
+	}
+	if !t.Valid {
+		return date, 0, false
+	}
+	tod = time.Duration(t.Hour)*time.Hour + time.Duration(t.Minute)*time.Minute +
+		time.Duration(t.Second)*time.Second + time.Duration(t.Millisecond)*time.Millisecond
+	return date, tod, true
+}
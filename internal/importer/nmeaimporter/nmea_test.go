@@ -0,0 +1,98 @@
+package nmeaimporter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type NMEASuite struct {
+	suite.Suite
+}
+
+func TestNMEASuite(t *testing.T) {
+	suite.Run(t, new(NMEASuite))
+}
+
+func (s *NMEASuite) importPlain(lines ...string) []*model.LogLine {
+	lls, err := NewPlain().Import(strings.NewReader(strings.Join(lines, "\r\n")), "test.vdr")
+	s.Require().NoError(err)
+	return lls
+}
+
+func (s *NMEASuite) TestPlainBackfill() {
+	lls := s.importPlain(
+		registry.Sentence("SDDPT,12.50,0.0"),
+		registry.Sentence("GPGGA,100000.00,4700.000,N,00800.000,E,1,08,0.9,1.0,M,,,,"),
+		registry.Sentence("GPRMC,100001.00,A,4700.000,N,00800.000,E,5.00,90.0,110916,,"),
+		registry.Sentence("YXMTW,21.5,C"),
+		registry.Sentence("GNGLL,4700.001,N,00800.001,E,100002.00,A"),
+		"invalid line",
+	)
+	s.Len(lls, 5)
+	first := time.Date(2016, 9, 11, 10, 0, 1, 0, time.UTC)
+	// the lines before the first date get the first complete time stamp
+	s.Equal(first, lls[0].CorrectTimeStamp)
+	s.Equal(first, lls[1].CorrectTimeStamp)
+	s.Equal(first, lls[2].CorrectTimeStamp)
+	// lines without time get the time of the previous sentence
+	s.Equal(first, lls[3].CorrectTimeStamp)
+	s.Equal(first.Add(time.Second), lls[4].CorrectTimeStamp)
+	for _, ll := range lls {
+		s.Equal("test.vdr", ll.Source)
+	}
+}
+
+func (s *NMEASuite) TestPlainRollover() {
+	lls := s.importPlain(
+		registry.Sentence("GPRMC,235959.00,A,4700.000,N,00800.000,E,5.00,90.0,110916,,"),
+		registry.Sentence("GPGGA,000001.00,4700.000,N,00800.000,E,1,08,0.9,1.0,M,,,,"),
+		"0001 "+registry.Sentence("SDDPT,12.50,0.0"),
+	)
+	s.Len(lls, 3)
+	s.Equal(time.Date(2016, 9, 11, 23, 59, 59, 0, time.UTC), lls[0].CorrectTimeStamp)
+	s.Equal(time.Date(2016, 9, 12, 0, 0, 1, 0, time.UTC), lls[1].CorrectTimeStamp)
+	s.Equal(lls[1].CorrectTimeStamp, lls[2].CorrectTimeStamp)
+}
+
+func (s *NMEASuite) TestPlainNoDate() {
+	_, err := NewPlain().Import(strings.NewReader(registry.Sentence("GPGGA,100000.00,4700.000,N,00800.000,E,1,08,0.9,1.0,M,,,,")), "test.nmea")
+	s.ErrorContains(err, "no date")
+
+	_, err = NewPlain().Import(strings.NewReader("no nmea\n"), "test.nmea")
+	s.Error(err)
+}
+
+func (s *NMEASuite) TestTimestamped() {
+	data := "# comment\n\n" +
+		"2016-09-11 10:00:01.000000: " + registry.Sentence("GPRMC,100001.00,A,4700.000,N,00800.000,E,5.00,90.0,110916,,") + "\n" +
+		"2016-09-11 10:00:01.500000: " + registry.Sentence("SDDPT,12.50,0.0") + "\n"
+	f, ok := registry.Get(Format)
+	s.Require().True(ok)
+	s.True(f.Detect([]byte(data)))
+
+	lls, err := New().Import(strings.NewReader(data), "track.nmea")
+	s.Require().NoError(err)
+	s.Require().Len(lls, 2)
+	s.Equal(time.Date(2016, 9, 11, 10, 0, 1, 500000000, time.UTC), lls[1].CorrectTimeStamp)
+	s.Equal("track.nmea", lls[1].Source)
+	s.True(strings.HasPrefix(lls[1].Unknown, "$SDDPT"))
+}
+
+func (s *NMEASuite) TestDetect() {
+	plain := []byte("\ufeff\r\n" + registry.Sentence("GPRMC,100001.00,A,4700.000,N,00800.000,E,5.00,90.0,110916,,") + "\r\n")
+	f, err := registry.Detect("recording.nmea", plain)
+	s.NoError(err)
+	s.Equal(FormatPlain, f.Name)
+
+	f, err = registry.Detect("recording.nmea", []byte("2016-09-11 10:00:01.000000: $GPRMC"))
+	s.NoError(err)
+	s.Equal(Format, f.Name)
+
+	_, err = registry.Detect("recording.nmea", []byte("something else"))
+	s.ErrorIs(err, registry.ErrUnknownFormat)
+}
@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/willie68/osmltools/internal/geo"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const ms2knots = 3600.0 / 1852.0
+
+// Fix a time stamped position of a track format, values which are not available are NaN
+type Fix struct {
+	Time time.Time
+	Lat  float64
+	Lon  float64
+	// Speed speed over ground in knots
+	Speed float64
+	// Course course over ground in degrees
+	Course float64
+	// Depth in meters
+	Depth float64
+	// WaterTemp water temperature in °C
+	WaterTemp float64
+	// HeartRate heart rate in bpm
+	HeartRate float64
+}
+
+// NewFix creates a fix with all optional values set to NaN
+func NewFix(ts time.Time, lat, lon float64) Fix {
+	return Fix{Time: ts, Lat: lat, Lon: lon, Speed: math.NaN(), Course: math.NaN(), Depth: math.NaN(), WaterTemp: math.NaN(), HeartRate: math.NaN()}
+}
+
+// MS2Knots converts a speed in m/s to knots
+func MS2Knots(v float64) float64 {
+	return v * ms2knots
+}
+
+// FixLines converts the fixes into nmea log lines: a GPRMC for every fix, a SDDPT for the depth, a YXMTW for the
+// water temperature and a POSMHR for the heart rate. A missing speed or course is calculated from the previous fix
+// (or the next one for the first).
+func FixLines(fixes []Fix, source string) ([]*model.LogLine, error) {
+	lls := make([]*model.LogLine, 0, len(fixes))
+	for i, f := range fixes {
+		if f.Time.IsZero() {
+			return nil, fmt.Errorf("fix %d has no time", i)
+		}
+		speed, course := f.Speed, f.Course
+		if math.IsNaN(speed) || math.IsNaN(course) {
+			var a, b Fix
+			switch {
+			case i > 0:
+				a, b = fixes[i-1], f
+			case len(fixes) > 1:
+				a, b = f, fixes[1]
+			}
+			s, c := 0.0, 0.0
+			if dt := b.Time.Sub(a.Time).Seconds(); dt > 0 {
+				s = MS2Knots(geo.Distance(a.Lat, a.Lon, b.Lat, b.Lon) / dt)
+				c = bearing(a.Lat, a.Lon, b.Lat, b.Lon)
+			}
+			if math.IsNaN(speed) {
+				speed = s
+			}
+			if math.IsNaN(course) {
+				course = c
+			}
+		}
+		ts := f.Time.UTC()
+		sentences := []string{fmt.Sprintf("GPRMC,%s,A,%s,%s,%.2f,%.1f,%s,,", ts.Format("150405.00"), nmeaCoord(f.Lat, 2, "N", "S"), nmeaCoord(f.Lon, 3, "E", "W"), speed, course, ts.Format("020106"))}
+		if !math.IsNaN(f.Depth) && f.Depth > 0 {
+			sentences = append(sentences, fmt.Sprintf("SDDPT,%.2f,0.0", f.Depth))
+		}
+		if !math.IsNaN(f.WaterTemp) {
+			sentences = append(sentences, fmt.Sprintf("YXMTW,%.2f,C", f.WaterTemp))
+		}
+		if !math.IsNaN(f.HeartRate) && f.HeartRate > 0 {
+			sentences = append(sentences, fmt.Sprintf("POSMHR,%d", int64(math.Round(f.HeartRate))))
+		}
+		for _, s := range sentences {
+			ll, ok, err := model.ParseNMEALogLine(Sentence(s), true)
+			if err != nil || !ok {
+				return nil, fmt.Errorf("fix %d: invalid sentence %s: %v", i, s, err)
+			}
+			ll.CorrectTimeStamp = f.Time
+			ll.Source = source
+			lls = append(lls, ll)
+		}
+	}
+	return lls, nil
+}
+
+// Sentence adds the start character and the checksum to the sentence body
+func Sentence(body string) string {
+	var cs byte
+	for i := 0; i < len(body); i++ {
+		cs ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X", body, cs)
+}
+
+// nmeaCoord formats the coordinate as (d)ddmm.mmmmm with the hemisphere
+func nmeaCoord(v float64, digits int, pos, neg string) string {
+	h := pos
+	if v < 0 {
+		h = neg
+		v = -v
+	}
+	deg := math.Floor(v)
+	mins := (v - deg) * 60
+	// rounding may result in 60 minutes
+	if math.Round(mins*100000) >= 6000000 {
+		deg++
+		mins = 0
+	}
+	return fmt.Sprintf("%0*d%08.5f,%s", digits, int(deg), mins, h)
+}
+
+// bearing the initial course from the first to the second position in degrees
+func bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	p1, p2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dl := (lon2 - lon1) * math.Pi / 180
+	y := math.Sin(dl) * math.Cos(p2)
+	x := math.Cos(p1)*math.Sin(p2) - math.Sin(p1)*math.Cos(p2)*math.Cos(dl)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/willie68/osmltools/internal/model"
+)
+
+var (
+	// ErrUnknownFormat error for a format which is not registered or a file which can't be detected
+	ErrUnknownFormat = errors.New("unknown import format")
+
+	mu      sync.RWMutex
+	formats = make(map[string]Format)
+)
+
+// Importer the interface every format importer has to implement
+type Importer interface {
+	// Import reads the data and returns the log lines with the correct time stamps, source is set in every log line
+	Import(r io.Reader, source string) ([]*model.LogLine, error)
+}
+
+// ModTimeSetter an optional interface for importers of formats without absolute time stamps, the modification time
+// of the file is set before the import
+type ModTimeSetter interface {
+	SetModTime(t time.Time)
+}
+
+// Format the description of an import format
+type Format struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Extensions the file extensions without dot, lower case
+	Extensions []string `json:"extensions"`
+	// Detect checks the first bytes of a file, nil if the format is only detected by the extension
+	Detect func(head []byte) bool `json:"-"`
+	// New creates a new importer
+	New func() Importer `json:"-"`
+}
+
+// Register registers a format, the name is case insensitive. Registering a name twice panics.
+func Register(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := strings.ToUpper(f.Name)
+	if _, ok := formats[name]; ok {
+		panic(fmt.Sprintf("import format %s already registered", name))
+	}
+	if f.New == nil {
+		panic(fmt.Sprintf("import format %s has no constructor", name))
+	}
+	f.Name = name
+	formats[name] = f
+}
+
+// Get returns the format with the name
+func Get(name string) (Format, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := formats[strings.ToUpper(strings.TrimSpace(name))]
+	return f, ok
+}
+
+// Formats all registered formats sorted by name
+func Formats() []Format {
+	mu.RLock()
+	defer mu.RUnlock()
+	fs := make([]Format, 0, len(formats))
+	for _, f := range formats {
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool {
+		return fs[i].Name < fs[j].Name
+	})
+	return fs
+}
+
+// Names the names of all registered formats sorted
+func Names() []string {
+	fs := Formats()
+	names := make([]string, 0, len(fs))
+	for _, f := range fs {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Detect finds the format of a file by its extension and the first bytes. If several formats share the extension,
+// the content decides. If no format with this extension matches the content, all other formats are tried.
+func Detect(filename string, head []byte) (Format, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	all := Formats()
+	candidates := make([]Format, 0)
+	for _, f := range all {
+		if slices.Contains(f.Extensions, ext) {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) == 1 && candidates[0].Detect == nil {
+		return candidates[0], nil
+	}
+	for _, f := range append(candidates, all...) {
+		if f.Detect != nil && f.Detect(head) {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("%w: %s", ErrUnknownFormat, filepath.Base(filename))
+}
+
+// FirstLine the first line of the data which is not empty and no comment (#), without line end
+func FirstLine(head []byte) string {
+	for _, l := range strings.Split(string(head), "\n") {
+		l = strings.TrimSpace(strings.TrimPrefix(l, "\ufeff"))
+		if l != "" && !strings.HasPrefix(l, "#") {
+			return l
+		}
+	}
+	return ""
+}
@@ -0,0 +1,302 @@
+package slimporter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// FormatSL2 the name of the lowrance sl2 format
+	FormatSL2 = "SL2"
+	// FormatSL3 the name of the lowrance sl3 format
+	FormatSL3 = "SL3"
+
+	// fileHeaderSize format, version, block size and a reserved word
+	fileHeaderSize = 8
+	// maxFrameSize frames are limited by the 16 bit frame size field
+	maxFrameSize = math.MaxUint16
+
+	// earthRadius the polar radius used by lowrance for the mercator coordinates
+	earthRadius = 6356752.3142
+	feet2meter  = 0.3048
+	// minCreated time stamps before are not valid
+	minCreated = 946684800 // 2000-01-01
+)
+
+var (
+	// ErrInvalidFile error for files which are not sl2/sl3 files
+	ErrInvalidFile = errors.New("invalid lowrance sonar log")
+	// ErrNoStartTime error for recordings without absolute time stamps and without file modification time
+	ErrNoStartTime = errors.New("no start time for the recording")
+)
+
+// layout the offsets of the frame header fields
+type layout struct {
+	headerSize int
+	frameSize  int
+	channel    int
+	index      int
+	created    int // -1 if not available
+	depth      int
+	keelDepth  int // -1 if not available
+	speed      int
+	temp       int
+	lon        int
+	lat        int
+	waterSpeed int
+	course     int
+	altitude   int
+	heading    int
+	flags      int
+	time       int
+}
+
+var layouts = map[uint16]layout{
+	2: {
+		headerSize: 144, frameSize: 28, channel: 32, index: 36, created: -1, depth: 64, keelDepth: 68,
+		speed: 100, temp: 104, lon: 108, lat: 112, waterSpeed: 116, course: 120, altitude: 124, heading: 128,
+		flags: 132, time: 140,
+	},
+	3: {
+		headerSize: 128, frameSize: 8, channel: 12, index: 16, created: 40, depth: 48, keelDepth: -1,
+		speed: 84, temp: 88, lon: 92, lat: 96, waterSpeed: 100, course: 104, altitude: 108, heading: 112,
+		flags: 116, time: 124,
+	},
+}
+
+func init() {
+	for _, f := range []struct {
+		name    string
+		version uint16
+	}{{FormatSL2, 2}, {FormatSL3, 3}} {
+		version := f.version
+		registry.Register(registry.Format{
+			Name:        f.name,
+			Description: "lowrance sonar logs, position, depth, water temperature, speed and course of the sounder",
+			Extensions:  []string{fmt.Sprintf("sl%d", version)},
+			Detect: func(head []byte) bool {
+				return detect(head, version)
+			},
+			New: func() registry.Importer {
+				return New()
+			},
+		})
+	}
+}
+
+// detect checks the format in the file header and the size of the first frame
+func detect(head []byte, format uint16) bool {
+	l := layouts[format]
+	o := fileHeaderSize + l.frameSize
+	if len(head) < o+2 || binary.LittleEndian.Uint16(head) != format {
+		return false
+	}
+	return int(binary.LittleEndian.Uint16(head[o:])) >= l.headerSize
+}
+
+// Header the file header of a sonar log
+type Header struct {
+	// Format 2 for sl2, 3 for sl3
+	Format  uint16
+	Version uint16
+	// BlockSize the sonar block size, e.g. 1970 for 2D sonar, 3200 for DownScan
+	BlockSize uint16
+}
+
+// Frame the header data of one sonar frame, the sonar image data is not decoded
+type Frame struct {
+	Channel uint16
+	Index   uint32
+	// Created the absolute time stamp, only sl3, zero if not available
+	Created time.Time
+	// Time the time since the start of the recording
+	Time time.Duration
+	// Lat, Lon position in degrees, both 0 if not available
+	Lat float64
+	Lon float64
+	// Depth the water depth in meters, KeelDepth the transducer offset in meters
+	Depth     float64
+	KeelDepth float64
+	// Speed speed over ground in knots, WaterSpeed speed through water in knots
+	Speed      float64
+	WaterSpeed float64
+	// Course course over ground, Heading in degrees
+	Course  float64
+	Heading float64
+	// Altitude in meters
+	Altitude float64
+	// WaterTemp water temperature in °C
+	WaterTemp float64
+	Flags     uint16
+}
+
+// HasPosition checks if the frame has a gps position
+func (f Frame) HasPosition() bool {
+	return f.Lat != 0 || f.Lon != 0
+}
+
+// Decode reads the file header and calls fn for every frame. The frames are streamed, the sonar data is skipped.
+func Decode(r io.Reader, fn func(Frame) error) (Header, error) {
+	var h Header
+	buf := make([]byte, fileHeaderSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return h, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	h.Format = binary.LittleEndian.Uint16(buf)
+	h.Version = binary.LittleEndian.Uint16(buf[2:])
+	h.BlockSize = binary.LittleEndian.Uint16(buf[4:])
+	l, ok := layouts[h.Format]
+	if !ok {
+		return h, fmt.Errorf("%w: unsupported format %d", ErrInvalidFile, h.Format)
+	}
+
+	frame := make([]byte, maxFrameSize)
+	for n := 0; ; n++ {
+		// the frame size is part of the first bytes of the header
+		pre := frame[:l.frameSize+2]
+		_, err := io.ReadFull(r, pre)
+		if errors.Is(err, io.EOF) {
+			return h, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// a truncated last frame, e.g. the sounder was switched off while recording
+			return h, nil
+		}
+		if err != nil {
+			return h, err
+		}
+		size := int(binary.LittleEndian.Uint16(pre[l.frameSize:]))
+		if size < len(pre) {
+			return h, fmt.Errorf("%w: frame %d has an invalid size %d", ErrInvalidFile, n, size)
+		}
+		if _, err := io.ReadFull(r, frame[len(pre):size]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return h, nil
+			}
+			return h, err
+		}
+		if size < l.headerSize {
+			// frames with a shorter header have no gps data
+			continue
+		}
+		if err := fn(l.frame(frame[:size])); err != nil {
+			return h, err
+		}
+	}
+}
+
+// frame decodes the header fields of a frame
+func (l layout) frame(b []byte) Frame {
+	u16 := func(o int) uint16 { return binary.LittleEndian.Uint16(b[o:]) }
+	u32 := func(o int) uint32 { return binary.LittleEndian.Uint32(b[o:]) }
+	f32 := func(o int) float64 { return float64(math.Float32frombits(u32(o))) }
+	f := Frame{
+		Channel:    u16(l.channel),
+		Index:      u32(l.index),
+		Time:       time.Duration(u32(l.time)) * time.Millisecond,
+		Depth:      f32(l.depth) * feet2meter,
+		Speed:      f32(l.speed),
+		WaterSpeed: f32(l.waterSpeed),
+		Course:     f32(l.course) * 180 / math.Pi,
+		Heading:    f32(l.heading) * 180 / math.Pi,
+		Altitude:   f32(l.altitude) * feet2meter,
+		WaterTemp:  f32(l.temp),
+		Flags:      u16(l.flags),
+	}
+	if l.created >= 0 {
+		if c := u32(l.created); c >= minCreated {
+			f.Created = time.Unix(int64(c), 0).UTC()
+		}
+	}
+	if l.keelDepth >= 0 {
+		f.KeelDepth = f32(l.keelDepth) * feet2meter
+	}
+	x, y := int32(u32(l.lon)), int32(u32(l.lat))
+	if x != 0 || y != 0 {
+		f.Lon = float64(x) / earthRadius * 180 / math.Pi
+		f.Lat = (2*math.Atan(math.Exp(float64(y)/earthRadius)) - math.Pi/2) * 180 / math.Pi
+	}
+	return f
+}
+
+// SLImporter imports the frames of a lowrance sl2 or sl3 file
+type SLImporter struct {
+	log     logging.Logger
+	modTime time.Time
+}
+
+// New returns a new SLImporter
+func New() *SLImporter {
+	return &SLImporter{
+		log: *logging.New().WithName("SLImporter"),
+	}
+}
+
+// SetModTime sets the modification time of the file, it's the end of a recording without absolute time stamps
+func (i *SLImporter) SetModTime(t time.Time) {
+	i.modTime = t
+}
+
+// Import reads the frames with a position, one frame per second of the recording. The time stamps are taken from
+// the sl3 creation time, for sl2 the end of the recording is the modification time of the file.
+func (i *SLImporter) Import(r io.Reader, source string) ([]*model.LogLine, error) {
+	type offsetFix struct {
+		offset time.Duration
+		fix    registry.Fix
+	}
+	fixes := make([]offsetFix, 0)
+	var start time.Time
+	var last time.Duration
+	frames := 0
+	second := time.Duration(-1)
+	h, err := Decode(r, func(f Frame) error {
+		frames++
+		last = max(last, f.Time)
+		if start.IsZero() && !f.Created.IsZero() {
+			start = f.Created.Add(-f.Time)
+		}
+		// all channels of a ping have the same gps data
+		if !f.HasPosition() || f.Time.Truncate(time.Second) == second {
+			return nil
+		}
+		second = f.Time.Truncate(time.Second)
+		fix := registry.NewFix(time.Time{}, f.Lat, f.Lon)
+		fix.Speed = f.Speed
+		fix.Course = math.Mod(f.Course+360, 360)
+		if f.Depth > 0 {
+			fix.Depth = f.Depth
+		}
+		if f.WaterTemp != 0 {
+			fix.WaterTemp = f.WaterTemp
+		}
+		fixes = append(fixes, offsetFix{offset: f.Time, fix: fix})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(fixes) == 0 {
+		return nil, errors.New("no frames with position found")
+	}
+	if start.IsZero() {
+		if i.modTime.IsZero() {
+			return nil, ErrNoStartTime
+		}
+		start = i.modTime.UTC().Add(-last)
+	}
+	i.log.Infof("sl%d: %d frames, %d positions, recording start %s", h.Format, frames, len(fixes), start.Format(time.RFC3339))
+	fs := make([]registry.Fix, 0, len(fixes))
+	for _, f := range fixes {
+		f.fix.Time = start.Add(f.offset)
+		fs = append(fs, f.fix)
+	}
+	return registry.FixLines(fs, source)
+}
@@ -0,0 +1,191 @@
+package slimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+// testFrame the values of a frame in the units of the file
+type testFrame struct {
+	channel uint16
+	created uint32
+	ms      uint32
+	lat     float64
+	lon     float64
+	depthFt float32
+	speedKn float32
+	course  float32 // radians
+	temp    float32
+}
+
+// offsets of the frame header fields, positions are stored in mercator meters (about 1e-5 degrees)
+type offsets struct {
+	header, size, channel, created, depth, speed, temp, lon, lat, course, time int
+}
+
+var testOffsets = map[uint16]offsets{
+	2: {header: 144, size: 28, channel: 32, created: -1, depth: 64, speed: 100, temp: 104, lon: 108, lat: 112, course: 120, time: 140},
+	3: {header: 168, size: 8, channel: 12, created: 40, depth: 48, speed: 84, temp: 88, lon: 92, lat: 96, course: 104, time: 124},
+}
+
+// encode writes a sonar log with 16 bytes sonar data per frame
+func encode(format uint16, frames []testFrame) []byte {
+	o := testOffsets[format]
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.Write(le.AppendUint16(nil, format))
+	buf.Write(le.AppendUint16(nil, 0))
+	buf.Write(le.AppendUint16(nil, 1970))
+	buf.Write(le.AppendUint16(nil, 0))
+	for _, f := range frames {
+		b := make([]byte, o.header+16)
+		le.PutUint16(b[o.size:], uint16(len(b)))
+		le.PutUint16(b[o.channel:], f.channel)
+		if o.created >= 0 {
+			le.PutUint32(b[o.created:], f.created)
+		}
+		le.PutUint32(b[o.depth:], math.Float32bits(f.depthFt))
+		le.PutUint32(b[o.speed:], math.Float32bits(f.speedKn))
+		le.PutUint32(b[o.temp:], math.Float32bits(f.temp))
+		le.PutUint32(b[o.course:], math.Float32bits(f.course))
+		if f.lat != 0 || f.lon != 0 {
+			x := int32(math.Round(f.lon * math.Pi / 180 * earthRadius))
+			y := int32(math.Round(earthRadius * math.Log(math.Tan(math.Pi/4+f.lat*math.Pi/360))))
+			le.PutUint32(b[o.lon:], uint32(x))
+			le.PutUint32(b[o.lat:], uint32(y))
+		}
+		le.PutUint32(b[o.time:], f.ms)
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+type SLSuite struct {
+	suite.Suite
+}
+
+func TestSLSuite(t *testing.T) {
+	suite.Run(t, new(SLSuite))
+}
+
+// pings two channels per ping, 2 pings per second
+func (s *SLSuite) pings(created uint32) []testFrame {
+	frames := make([]testFrame, 0)
+	for i := range 6 {
+		for ch := range uint16(2) {
+			frames = append(frames, testFrame{
+				channel: ch,
+				created: created,
+				ms:      uint32(i * 500),
+				lat:     53.5 + float64(i)*0.0001,
+				lon:     9.9,
+				depthFt: 32.8084,
+				speedKn: 4.5,
+				course:  math.Pi / 2,
+				temp:    18.5,
+			})
+		}
+	}
+	// no position
+	frames = append(frames, testFrame{ms: 3500})
+	return frames
+}
+
+func (s *SLSuite) waypoints(lls []*model.LogLine) []*model.Waypoint {
+	tp, err := model.GetWaypoints(&model.TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+	return tp.Waypoints
+}
+
+func (s *SLSuite) TestDecode() {
+	frames := make([]Frame, 0)
+	h, err := Decode(bytes.NewReader(encode(2, s.pings(0))), func(f Frame) error {
+		frames = append(frames, f)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal(uint16(2), h.Format)
+	s.Equal(uint16(1970), h.BlockSize)
+	s.Require().Len(frames, 13)
+	f := frames[3]
+	s.Equal(uint16(1), f.Channel)
+	s.Equal(500*time.Millisecond, f.Time)
+	s.InDelta(53.5001, f.Lat, 1e-5)
+	s.InDelta(9.9, f.Lon, 1e-5)
+	s.InDelta(10.0, f.Depth, 1e-4)
+	s.InDelta(90.0, f.Course, 1e-4)
+	s.InDelta(4.5, f.Speed, 1e-6)
+	s.InDelta(18.5, f.WaterTemp, 1e-6)
+	s.True(f.HasPosition())
+	s.False(frames[12].HasPosition())
+}
+
+func (s *SLSuite) TestImportSL2() {
+	mod := time.Date(2024, 6, 1, 12, 0, 3, 500000000, time.UTC)
+	imp := New()
+	imp.SetModTime(mod)
+	lls, err := imp.Import(bytes.NewReader(encode(2, s.pings(0))), "Chart 06_01_2024.sl2")
+	s.Require().NoError(err)
+	wpts := s.waypoints(lls)
+	// one position per second
+	s.Require().Len(wpts, 3)
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, wpt := range wpts {
+		s.Equal(start.Add(time.Duration(i)*time.Second), wpt.Time)
+		s.InDelta(53.5+float64(i)*0.0002, wpt.Lat, 1e-5)
+		s.InDelta(10.0, wpt.Depth, 0.01)
+		s.InDelta(18.5, wpt.WaterTemp, 0.01)
+		s.InDelta(4.5, wpt.Speed, 0.01)
+		s.InDelta(90.0, wpt.Course, 0.1)
+		s.Equal("Chart 06_01_2024.sl2", wpt.Source)
+	}
+}
+
+func (s *SLSuite) TestImportSL3() {
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	data := encode(3, s.pings(uint32(created.Unix())))
+	f, err := registry.Detect("Sonar0001.sl3", data[:256])
+	s.Require().NoError(err)
+	s.Equal(FormatSL3, f.Name)
+
+	lls, err := New().Import(bytes.NewReader(data), "Sonar0001.sl3")
+	s.Require().NoError(err)
+	wpts := s.waypoints(lls)
+	s.Require().Len(wpts, 3)
+	s.Equal(created, wpts[0].Time)
+	s.Equal(created.Add(2*time.Second), wpts[2].Time)
+	s.InDelta(10.0, wpts[0].Depth, 0.01)
+}
+
+func (s *SLSuite) TestNoStartTime() {
+	_, err := New().Import(bytes.NewReader(encode(2, s.pings(0))), "test.sl2")
+	s.ErrorIs(err, ErrNoStartTime)
+}
+
+func (s *SLSuite) TestTruncated() {
+	data := encode(2, s.pings(0))
+	frames := 0
+	_, err := Decode(bytes.NewReader(data[:len(data)-50]), func(_ Frame) error {
+		frames++
+		return nil
+	})
+	s.NoError(err)
+	s.Equal(12, frames)
+}
+
+func (s *SLSuite) TestInvalid() {
+	_, err := Decode(bytes.NewReader([]byte{1, 0, 0, 0, 0, 0, 0, 0}), func(_ Frame) error { return nil })
+	s.ErrorIs(err, ErrInvalidFile)
+	_, err = Decode(bytes.NewReader([]byte{2, 0}), func(_ Frame) error { return nil })
+	s.ErrorIs(err, ErrInvalidFile)
+
+	_, err = registry.Detect("test.bin", []byte("$GPRMC,100001.00,A,4700.000,N,00800.000,E,5.00,90.0,110916,,*00"))
+	s.ErrorIs(err, registry.ErrUnknownFormat)
+}
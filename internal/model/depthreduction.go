@@ -0,0 +1,240 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+const (
+	// DepthRefTransducer depths are relative to the transducer, no reduction
+	DepthRefTransducer = "transducer"
+	// DepthRefWaterline depths are reduced to the waterline
+	DepthRefWaterline = "waterline"
+	// DepthRefKeel depths are reduced to the keel, the depth below keel
+	DepthRefKeel = "keel"
+)
+
+// ErrNoWaterLevel error for a time outside of the water level table
+var ErrNoWaterLevel = errors.New("no water level for this time")
+
+// VesselConfig the sensor configuration of a vessel, all values in meters
+type VesselConfig struct {
+	VesselID int32  `json:"vesselID"`
+	Name     string `json:"name,omitempty"`
+	// TransducerDepth depth of the transducer below the waterline
+	TransducerDepth float64 `json:"transducerDepth"`
+	// KeelOffset distance from the transducer down to the lowest point of the keel
+	KeelOffset float64 `json:"keelOffset,omitempty"`
+	// LeverArmForward distance of the transducer forward of the gps antenna, negative is aft
+	LeverArmForward float64 `json:"leverArmForward,omitempty"`
+	// LeverArmStarboard distance of the transducer to starboard of the gps antenna, negative is port
+	LeverArmStarboard float64 `json:"leverArmStarboard,omitempty"`
+}
+
+// VesselConfigs list of vessel configurations
+type VesselConfigs []VesselConfig
+
+// Validate checks the vessel configuration
+func (v VesselConfig) Validate() error {
+	if v.VesselID <= 0 {
+		return errors.New("vessel config needs a vessel id > 0")
+	}
+	if v.TransducerDepth < 0 || v.KeelOffset < 0 {
+		return fmt.Errorf("vessel %d: transducer depth and keel offset must not be negative", v.VesselID)
+	}
+	return nil
+}
+
+// Find returns the configuration of the vessel or nil
+func (vs VesselConfigs) Find(id int32) *VesselConfig {
+	for i := range vs {
+		if vs[i].VesselID == id {
+			return &vs[i]
+		}
+	}
+	return nil
+}
+
+// WaterLevel the water level above chart datum at a time
+type WaterLevel struct {
+	Time  time.Time `json:"time"`
+	Level float64   `json:"level"`
+}
+
+// WaterLevels a water level or tide table, sorted by time
+type WaterLevels []WaterLevel
+
+// ReadWaterLevels reads a water level table from a csv file, see ParseWaterLevels
+func ReadWaterLevels(file string) (WaterLevels, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseWaterLevels(f)
+}
+
+// ParseWaterLevels parses a csv with the columns time and level (meters above chart datum). The delimiter may be , or ;
+// with ; a decimal comma is accepted. A header line is skipped, times without a zone are UTC.
+func ParseWaterLevels(r io.Reader) (WaterLevels, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(strings.NewReader(string(data)))
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	if strings.Contains(strings.SplitN(string(data), "\n", 2)[0], ";") {
+		cr.Comma = ';'
+	}
+	recs, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	wls := make(WaterLevels, 0, len(recs))
+	for i, rec := range recs {
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("water levels line %d: needs time and level", i+1)
+		}
+		ts, err := ParseTrimTime(rec[0])
+		if err != nil {
+			if i == 0 {
+				// header
+				continue
+			}
+			return nil, fmt.Errorf("water levels line %d: %w", i+1, err)
+		}
+		lv, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(rec[1]), ",", ".", 1), 64)
+		if err != nil {
+			return nil, fmt.Errorf("water levels line %d: %w", i+1, err)
+		}
+		wls = append(wls, WaterLevel{Time: ts, Level: lv})
+	}
+	if len(wls) == 0 {
+		return nil, errors.New("no water levels found")
+	}
+	sort.SliceStable(wls, func(i, j int) bool {
+		return wls[i].Time.Before(wls[j].Time)
+	})
+	return wls, nil
+}
+
+// Level the water level at the time, linear interpolated between the table entries
+func (w WaterLevels) Level(ts time.Time) (float64, error) {
+	i := sort.Search(len(w), func(i int) bool {
+		return !w[i].Time.Before(ts)
+	})
+	if i == len(w) {
+		return 0, ErrNoWaterLevel
+	}
+	if w[i].Time.Equal(ts) {
+		return w[i].Level, nil
+	}
+	if i == 0 {
+		return 0, ErrNoWaterLevel
+	}
+	a, b := w[i-1], w[i]
+	f := float64(ts.Sub(a.Time)) / float64(b.Time.Sub(a.Time))
+	return a.Level + (b.Level-a.Level)*f, nil
+}
+
+// DepthReduction reduces the measured depths to a reference (waterline or keel) and with a water level table to chart datum
+type DepthReduction struct {
+	// Vessel the sensor configuration, may be nil
+	Vessel *VesselConfig
+	// Reference transducer, waterline or keel, default is waterline
+	Reference string
+	// WaterLevels the water levels above chart datum, only with reference waterline
+	WaterLevels WaterLevels
+}
+
+// Validate checks the reduction
+func (d *DepthReduction) Validate() error {
+	if d == nil {
+		return nil
+	}
+	switch d.Reference {
+	case "", DepthRefWaterline, DepthRefTransducer, DepthRefKeel:
+	default:
+		return fmt.Errorf("unknown depth reference %s, use %s, %s or %s", d.Reference, DepthRefWaterline, DepthRefTransducer, DepthRefKeel)
+	}
+	if len(d.WaterLevels) > 0 && d.reference() != DepthRefWaterline {
+		return errors.New("water levels can only be used with the depth reference waterline")
+	}
+	if d.Vessel != nil {
+		return d.Vessel.Validate()
+	}
+	return nil
+}
+
+func (d *DepthReduction) reference() string {
+	if d.Reference == "" {
+		return DepthRefWaterline
+	}
+	return d.Reference
+}
+
+// Reduce returns the reduced depth of a DBT or DPT log line. ok is false for other lines, invalid depths or
+// if there is no water level for the time of the line. A nil reduction returns the measured depth.
+func (d *DepthReduction) Reduce(ll *LogLine) (depth float64, ok bool) {
+	depth, ok = Depth(ll)
+	if !ok || d == nil {
+		return depth, ok
+	}
+	// the dpt offset is set in the sensor: positive is the distance to the waterline, negative to the keel
+	offset := 0.0
+	if dpt, isDPT := ll.NMEAMessage.(nmea.DPT); isDPT {
+		offset = dpt.Offset
+	}
+	switch d.reference() {
+	case DepthRefWaterline:
+		switch {
+		case offset > 0:
+			depth += offset
+		case d.Vessel != nil:
+			depth += d.Vessel.TransducerDepth
+		}
+		if len(d.WaterLevels) > 0 {
+			lv, err := d.WaterLevels.Level(ll.CorrectTimeStamp)
+			if err != nil {
+				return 0, false
+			}
+			depth -= lv
+		}
+	case DepthRefKeel:
+		switch {
+		case offset < 0:
+			depth += offset
+		case d.Vessel != nil:
+			depth -= d.Vessel.KeelOffset
+		}
+	}
+	return depth, true
+}
+
+// Position moves the position of the gps antenna to the position of the transducer, using the lever arms of the
+// vessel and the course over ground in degrees. It is only used for the soundings, the waypoints keep the position
+// of the antenna, so the track line is not moved by the lever arms.
+func (d *DepthReduction) Position(lat, lon, course float64) (float64, float64) {
+	if d == nil || d.Vessel == nil || (d.Vessel.LeverArmForward == 0 && d.Vessel.LeverArmStarboard == 0) {
+		return lat, lon
+	}
+	c := course * math.Pi / 180
+	fwd, stb := d.Vessel.LeverArmForward, d.Vessel.LeverArmStarboard
+	north := fwd*math.Cos(c) - stb*math.Sin(c)
+	east := fwd*math.Sin(c) + stb*math.Cos(c)
+	lat2 := lat + north/geo.EarthRadius*180/math.Pi
+	lon2 := lon + east/(geo.EarthRadius*math.Cos(lat*math.Pi/180))*180/math.Pi
+	return lat2, lon2
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+type DepthReductionSuite struct {
+	suite.Suite
+}
+
+func TestDepthReductionSuite(t *testing.T) {
+	suite.Run(t, new(DepthReductionSuite))
+}
+
+func (s *DepthReductionSuite) logLine(sentence string) *LogLine {
+	ll, ok, err := ParseNMEALogLine("2016-09-11 10:00:30.000000: "+nmeaChecksum(sentence), false)
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	return ll
+}
+
+func (s *DepthReductionSuite) TestReduce() {
+	vessel := &VesselConfig{VesselID: 597, TransducerDepth: 0.5, KeelOffset: 1.2}
+	dbt := s.logLine("$SDDBT,32.8,f,10.0,M,5.5,F")
+
+	var none *DepthReduction
+	d, ok := none.Reduce(dbt)
+	s.True(ok)
+	s.InDelta(10.0, d, 1e-9)
+
+	d, _ = (&DepthReduction{Vessel: vessel}).Reduce(dbt)
+	s.InDelta(10.5, d, 1e-9)
+	d, _ = (&DepthReduction{Vessel: vessel, Reference: DepthRefKeel}).Reduce(dbt)
+	s.InDelta(8.8, d, 1e-9)
+	d, _ = (&DepthReduction{Vessel: vessel, Reference: DepthRefTransducer}).Reduce(dbt)
+	s.InDelta(10.0, d, 1e-9)
+
+	// the offset of the dpt wins over the vessel config
+	dpt := s.logLine("$SDDPT,4.0,0.3")
+	d, _ = (&DepthReduction{Vessel: vessel}).Reduce(dpt)
+	s.InDelta(4.3, d, 1e-9)
+	dpt = s.logLine("$SDDPT,4.0,-0.8")
+	d, _ = (&DepthReduction{Vessel: vessel, Reference: DepthRefKeel}).Reduce(dpt)
+	s.InDelta(3.2, d, 1e-9)
+}
+
+func (s *DepthReductionSuite) TestWaterLevels() {
+	wls, err := ParseWaterLevels(strings.NewReader("time;level\n2016-09-11 10:00:00;1,0\n2016-09-11 10:01:00;2,0\n"))
+	s.Require().NoError(err)
+	s.Len(wls, 2)
+	lv, err := wls.Level(time.Date(2016, 9, 11, 10, 0, 30, 0, time.UTC))
+	s.NoError(err)
+	s.InDelta(1.5, lv, 1e-9)
+	_, err = wls.Level(time.Date(2016, 9, 11, 11, 0, 0, 0, time.UTC))
+	s.ErrorIs(err, ErrNoWaterLevel)
+
+	dr := &DepthReduction{Vessel: &VesselConfig{VesselID: 1, TransducerDepth: 0.5}, WaterLevels: wls}
+	d, ok := dr.Reduce(s.logLine("$SDDBT,32.8,f,10.0,M,5.5,F"))
+	s.True(ok)
+	s.InDelta(9.0, d, 1e-9)
+
+	s.Error((&DepthReduction{Reference: DepthRefKeel, WaterLevels: wls}).Validate())
+	_, err = ParseWaterLevels(strings.NewReader("time,level\n2016-09-11 10:00:00,high\n"))
+	s.Error(err)
+}
+
+func (s *DepthReductionSuite) TestLeverArm() {
+	dr := &DepthReduction{Vessel: &VesselConfig{VesselID: 1, LeverArmForward: 10}}
+	// heading east, the transducer is 10m east of the antenna
+	lat, lon := dr.Position(47.0, 8.0, 90)
+	s.InDelta(47.0, lat, 1e-9)
+	s.InDelta(10.0, geo.Distance(47.0, 8.0, lat, lon), 0.01)
+	s.Greater(lon, 8.0)
+}
+
+func (s *DepthReductionSuite) TestWaypointsAndSoundings() {
+	tp := &TrackPoints{
+		LogLines:  testLogLines(s.Require()),
+		Reduction: &DepthReduction{Vessel: &VesselConfig{VesselID: 1, TransducerDepth: 0.5}},
+	}
+	sds := tp.Soundings(DefaultMaxGap)
+	s.Require().NotEmpty(sds)
+	s.InDelta(10.5, sds[0].Depth, 1e-9)
+
+	tp, err := GetWaypoints(tp)
+	s.NoError(err)
+	s.InDelta(10.5, tp.Waypoints[0].Depth, 1e-9)
+}
+
+func (s *DepthReductionSuite) TestLeverArmWaypoints() {
+	lls := testLogLines(s.Require())
+	// a fix without depth at the end
+	lls = append(lls, s.logLine("$GPRMC,101000,A,4720.000,N,00836.0000,E,5.0,90.0,110916,,"))
+	antenna, err := GetWaypoints(&TrackPoints{LogLines: lls})
+	s.Require().NoError(err)
+
+	tp, err := GetWaypoints(&TrackPoints{
+		LogLines:  lls,
+		Reduction: &DepthReduction{Vessel: &VesselConfig{VesselID: 1, LeverArmForward: 10, LeverArmStarboard: 2}},
+	})
+	s.Require().NoError(err)
+	s.Require().Len(tp.Waypoints, 11)
+	s.InDelta(10.0, tp.Waypoints[0].Depth, 1e-9)
+	s.Zero(tp.Waypoints[10].Depth)
+	// the waypoints with and without depth keep the position of the antenna
+	for i, wp := range tp.Waypoints {
+		s.Equal(antenna.Waypoints[i].Lat, wp.Lat, i)
+		s.Equal(antenna.Waypoints[i].Lon, wp.Lon, i)
+	}
+	// the soundings are moved to the transducer
+	sds := tp.Soundings(0)
+	raw := antenna.Soundings(0)
+	s.Require().NotEmpty(sds)
+	s.InDelta(math.Hypot(10, 2), geo.Distance(raw[0].Lat, raw[0].Lon, sds[0].Lat, sds[0].Lon), 0.01)
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// DocumentSchema the identifier of the osml track points json
+	DocumentSchema = "osml-trackpoints"
+	// DocumentVersion the version of the json schema, incremented on incompatible changes
+	DocumentVersion = 1
+)
+
+// ErrUnsupportedDocument error for json documents with another schema or a newer version
+var ErrUnsupportedDocument = errors.New("unsupported json document")
+
+// baseSentenceFields the fields of the nmea base sentence, they are part of the raw sentence and not repeated in the
+// decoded fields
+var baseSentenceFields = []string{"Talker", "Type", "Fields", "Checksum", "Raw", "TagBlock"}
+
+// TrackDocument the versioned json representation of track points, the interchange format of the json export
+type TrackDocument struct {
+	Schema      string            `json:"schema"`
+	Version     int               `json:"version"`
+	Name        string            `json:"name,omitempty"`
+	Description string            `json:"description,omitempty"`
+	VesselID    int32             `json:"vessel_id,omitempty"`
+	Waypoints   []*Waypoint       `json:"waypoints"`
+	LogLines    []LogLineDocument `json:"log_lines,omitempty"`
+}
+
+// LogLineDocument the json representation of a log line. The raw sentence is the reference, the decoded fields are
+// informative for other tools and ignored when reading.
+type LogLineDocument struct {
+	Time time.Time `json:"time"`
+	// DurationMS the time since the start of the logger in ms, only for logger data
+	DurationMS int64          `json:"duration_ms,omitempty"`
+	Channel    string         `json:"channel,omitempty"`
+	Source     string         `json:"source,omitempty"`
+	Sentence   string         `json:"sentence"`
+	Talker     string         `json:"talker,omitempty"`
+	Type       string         `json:"type,omitempty"`
+	Fields     map[string]any `json:"fields,omitempty"`
+}
+
+// NewTrackDocument creates the json document of the track points, the log lines are optional
+func NewTrackDocument(tp TrackPoints, withLogLines bool) TrackDocument {
+	d := TrackDocument{
+		Schema:      DocumentSchema,
+		Version:     DocumentVersion,
+		Name:        tp.Name,
+		Description: tp.Description,
+		VesselID:    tp.VesselID,
+		Waypoints:   tp.Waypoints,
+	}
+	if d.Waypoints == nil {
+		d.Waypoints = make([]*Waypoint, 0)
+	}
+	if withLogLines {
+		d.LogLines = make([]LogLineDocument, 0, len(tp.LogLines))
+		for _, ll := range tp.LogLines {
+			d.LogLines = append(d.LogLines, newLogLineDocument(ll))
+		}
+	}
+	return d
+}
+
+func newLogLineDocument(ll *LogLine) LogLineDocument {
+	d := LogLineDocument{
+		Time:       ll.CorrectTimeStamp,
+		DurationMS: ll.Duration.Milliseconds(),
+		Channel:    ll.Channel,
+		Source:     ll.Source,
+		Sentence:   strings.TrimSpace(ll.Unknown),
+	}
+	if ll.NMEAMessage == nil {
+		return d
+	}
+	d.Talker = ll.NMEAMessage.TalkerID()
+	d.Type = ll.NMEAMessage.DataType()
+	js, err := json.Marshal(ll.NMEAMessage)
+	if err != nil {
+		return d
+	}
+	fields := make(map[string]any)
+	if err := json.Unmarshal(js, &fields); err != nil {
+		return d
+	}
+	for _, f := range baseSentenceFields {
+		delete(fields, f)
+	}
+	if len(fields) > 0 {
+		d.Fields = fields
+	}
+	return d
+}
+
+// ReadTrackDocument reads and checks a json document
+func ReadTrackDocument(r io.Reader) (*TrackDocument, error) {
+	var d TrackDocument
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+	if d.Schema != DocumentSchema {
+		return nil, fmt.Errorf("%w: schema %q", ErrUnsupportedDocument, d.Schema)
+	}
+	if d.Version < 1 || d.Version > DocumentVersion {
+		return nil, fmt.Errorf("%w: version %d, supported up to %d", ErrUnsupportedDocument, d.Version, DocumentVersion)
+	}
+	return &d, nil
+}
+
+// TrackPoints converts the document back into track points, the log lines are parsed from the raw sentences
+func (d *TrackDocument) TrackPoints() *TrackPoints {
+	tp := &TrackPoints{
+		Name:        d.Name,
+		Description: d.Description,
+		VesselID:    d.VesselID,
+		Waypoints:   make([]*Waypoint, 0, len(d.Waypoints)),
+		LogLines:    make([]*LogLine, 0, len(d.LogLines)),
+	}
+	for _, wpt := range d.Waypoints {
+		if wpt != nil {
+			tp.Waypoints = append(tp.Waypoints, wpt)
+		}
+	}
+	if len(tp.Waypoints) > 0 {
+		tp.Start = tp.Waypoints[0]
+		tp.End = tp.Waypoints[len(tp.Waypoints)-1]
+	}
+	for _, l := range d.LogLines {
+		// invalid sentences are kept as in the logger data
+		ll, _, _ := ParseNMEALogLine(l.Sentence, true)
+		ll.CorrectTimeStamp = l.Time
+		ll.Duration = time.Duration(l.DurationMS) * time.Millisecond
+		ll.Channel = l.Channel
+		ll.Source = l.Source
+		tp.LogLines = append(tp.LogLines, ll)
+	}
+	return tp
+}
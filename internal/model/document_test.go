@@ -0,0 +1,100 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DocumentSuite struct {
+	suite.Suite
+}
+
+func TestDocumentSuite(t *testing.T) {
+	suite.Run(t, new(DocumentSuite))
+}
+
+func (s *DocumentSuite) track() *TrackPoints {
+	lines := []string{
+		"00:00:01.000;A;" + nmeaChecksum("$GPRMC,100000,A,4720.000,N,00830.0000,E,5.0,90.0,110916,,"),
+		"00:00:01.500;B;" + nmeaChecksum("$SDDPT,4.5,0.3"),
+		"00:00:02.000;A;$GPRMC,broken*00",
+	}
+	tp := &TrackPoints{Name: "test", Description: "json", VesselID: 597}
+	for i, l := range lines {
+		ll, _, _ := ParseLogLine(l)
+		s.Require().NotNil(ll)
+		ll.CorrectTimeStamp = time.Date(2016, 9, 11, 10, 0, i, 0, time.UTC)
+		ll.Source = "DATA001231.DAT"
+		tp.LogLines = append(tp.LogLines, ll)
+	}
+	tp, err := GetWaypoints(tp)
+	s.Require().NoError(err)
+	return tp
+}
+
+func (s *DocumentSuite) TestRoundTrip() {
+	tp := s.track()
+	js, err := json.Marshal(NewTrackDocument(*tp, true))
+	s.Require().NoError(err)
+
+	d, err := ReadTrackDocument(bytes.NewReader(js))
+	s.Require().NoError(err)
+	s.Equal(DocumentVersion, d.Version)
+	s.Require().Len(d.LogLines, 3)
+	rmc := d.LogLines[0]
+	s.Equal("GP", rmc.Talker)
+	s.Equal("RMC", rmc.Type)
+	s.Equal(int64(1000), rmc.DurationMS)
+	s.InDelta(47.3333, rmc.Fields["Latitude"], 1e-4)
+	s.NotContains(rmc.Fields, "Raw")
+	// a broken sentence has no decoded fields
+	s.Empty(d.LogLines[2].Fields)
+
+	back := d.TrackPoints()
+	s.Equal("test", back.Name)
+	s.Equal(int32(597), back.VesselID)
+	s.Require().Len(back.LogLines, 3)
+	for i, ll := range back.LogLines {
+		s.Equal(tp.LogLines[i].CorrectTimeStamp, ll.CorrectTimeStamp)
+		s.Equal(tp.LogLines[i].Duration, ll.Duration)
+		s.Equal(tp.LogLines[i].Channel, ll.Channel)
+		s.Equal(tp.LogLines[i].Unknown, ll.Unknown)
+		s.Equal("DATA001231.DAT", ll.Source)
+	}
+	s.Equal(tp.LogLines[1].NMEAMessage, back.LogLines[1].NMEAMessage)
+	s.Require().Len(back.Waypoints, 1)
+	s.Equal(*tp.Waypoints[0], *back.Waypoints[0])
+	s.Equal("End", back.End.Name)
+
+	// the waypoints of the log lines are the same
+	wp, err := GetWaypoints(&TrackPoints{LogLines: back.LogLines})
+	s.Require().NoError(err)
+	s.Equal(tp.Waypoints[0].Depth, wp.Waypoints[0].Depth)
+}
+
+func (s *DocumentSuite) TestWithoutLogLines() {
+	js, err := json.Marshal(NewTrackDocument(*s.track(), false))
+	s.Require().NoError(err)
+	s.NotContains(string(js), "log_lines")
+	d, err := ReadTrackDocument(bytes.NewReader(js))
+	s.Require().NoError(err)
+	s.Len(d.TrackPoints().Waypoints, 1)
+
+	js, err = json.Marshal(NewTrackDocument(TrackPoints{}, true))
+	s.Require().NoError(err)
+	s.Contains(string(js), `"waypoints":[]`)
+}
+
+func (s *DocumentSuite) TestUnsupported() {
+	_, err := ReadTrackDocument(strings.NewReader(`{"schema":"osml-trackpoints","version":2}`))
+	s.ErrorIs(err, ErrUnsupportedDocument)
+	_, err = ReadTrackDocument(strings.NewReader(`[{"latitude":47.5}]`))
+	s.Error(err)
+	_, err = ReadTrackDocument(strings.NewReader(`{"schema":"other","version":1}`))
+	s.ErrorIs(err, ErrUnsupportedDocument)
+}
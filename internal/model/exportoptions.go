@@ -0,0 +1,25 @@
+package model
+
+// ExportOptions options for filtering the data before exporting
+type ExportOptions struct {
+	// Trim limits the exported data to a time range and/or clip area
+	Trim *Trim
+	// Simplification reduces the waypoints by resampling and/or douglas peucker
+	Simplification *Simplification
+	// PrivacyZones waypoints and position sentences inside these zones are dropped or fuzzed
+	PrivacyZones PrivacyZones
+	// FormatOptions format specific options in the form <format>.<name>=<value>
+	FormatOptions []string
+	// SingleFile all tracks are written into one file, if the format supports multiple tracks
+	SingleFile bool
+	// Series export these sensor time series (see SeriesNames) instead of the track, only csv and json
+	Series []string
+	// Vessels the sensor configurations of the vessels for the depth reduction
+	Vessels VesselConfigs
+	// VesselID the vessel used for the depth reduction, 0 for the vessel of the track
+	VesselID int32
+	// DepthReference the reference of the exported depths, transducer, waterline or keel
+	DepthReference string
+	// WaterLevels water level table to reduce the depths to chart datum
+	WaterLevels WaterLevels
+}
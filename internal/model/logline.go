@@ -19,6 +19,7 @@ type LogLine struct {
 	Duration         time.Duration `json:"duration,omitempty"`
 	CorrectTimeStamp time.Time     `json:"correct_time_stamp,omitempty"`
 	Channel          string        `json:"channel,omitempty"`
+	Source           string        `json:"source,omitempty"`
 	Unknown          string        `json:"unknown,omitempty"`
 	NMEAMessage      nmea.Sentence `json:"nmea_message,omitempty"`
 }
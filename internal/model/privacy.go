@@ -0,0 +1,142 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/adrianmo/go-nmea"
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+const (
+	// PrivacyDrop points inside the zone are removed
+	PrivacyDrop = "drop"
+	// PrivacyFuzz points inside the zone are moved to the position where the track entered the zone
+	PrivacyFuzz = "fuzz"
+)
+
+// PrivacyZone an area which should not be visible in shared tracks. The zone is a circle (center and radius) or a polygon.
+type PrivacyZone struct {
+	Name    string      `json:"name"`
+	Lat     float64     `json:"lat,omitempty"`
+	Lon     float64     `json:"lon,omitempty"`
+	Radius  float64     `json:"radius,omitempty"`
+	Polygon geo.Polygon `json:"polygon,omitempty"`
+	Mode    string      `json:"mode,omitempty"`
+}
+
+// PrivacyZones list of privacy zones
+type PrivacyZones []PrivacyZone
+
+// Validate checks that the zone is a valid circle or polygon
+func (z PrivacyZone) Validate() error {
+	if z.Name == "" {
+		return errors.New("privacy zone needs a name")
+	}
+	if len(z.Polygon) == 0 && z.Radius <= 0 {
+		return fmt.Errorf("privacy zone %s needs a radius > 0 or a polygon", z.Name)
+	}
+	if z.Mode != "" && z.Mode != PrivacyDrop && z.Mode != PrivacyFuzz {
+		return fmt.Errorf("unknown privacy mode %s for zone %s, use %s or %s", z.Mode, z.Name, PrivacyDrop, PrivacyFuzz)
+	}
+	return nil
+}
+
+// Contains checks if the position is inside the zone
+func (z PrivacyZone) Contains(lat, lon float64) bool {
+	if len(z.Polygon) > 0 {
+		return z.Polygon.Contains(lat, lon)
+	}
+	return geo.Distance(z.Lat, z.Lon, lat, lon) <= z.Radius
+}
+
+// Fuzz checks if positions inside the zone should be fuzzed instead of dropped
+func (z PrivacyZone) Fuzz() bool {
+	return z.Mode == PrivacyFuzz
+}
+
+// Find returns the first zone containing the position, nil if there is none
+func (zs PrivacyZones) Find(lat, lon float64) *PrivacyZone {
+	for i := range zs {
+		if zs[i].Contains(lat, lon) {
+			return &zs[i]
+		}
+	}
+	return nil
+}
+
+// ApplyPrivacyZones drops or fuzzes all waypoints inside the privacy zones and removes all position sentences (RMC, GGA, GLL, GNS)
+// inside the zones from the log lines. Fuzzed waypoints are moved to the last waypoint before the zone, at the start
+// of the track to the first waypoint after the zone, so the position inside the zone is never disclosed. Without
+// any waypoint outside of the zones the fuzzed waypoints are dropped. Start and end are set to the first and last
+// remaining waypoint. The zones are kept in the track, so the soundings interpolated into a zone are dropped too.
+func (tp *TrackPoints) ApplyPrivacyZones(zones PrivacyZones) {
+	if len(zones) == 0 {
+		return
+	}
+	tp.Privacy = zones
+	wps := make([]*Waypoint, 0, len(tp.Waypoints))
+	// anchor the last waypoint outside of the zones, leading the fuzzed waypoints before the first anchor
+	var anchor *Waypoint
+	leading := make([]*Waypoint, 0)
+	for _, wp := range tp.Waypoints {
+		z := zones.Find(wp.Lat, wp.Lon)
+		if z == nil {
+			for _, l := range leading {
+				l.Lat, l.Lon = wp.Lat, wp.Lon
+			}
+			leading = leading[:0]
+			anchor = wp
+			wps = append(wps, wp)
+			continue
+		}
+		if !z.Fuzz() {
+			continue
+		}
+		if anchor == nil {
+			leading = append(leading, wp)
+		} else {
+			wp.Lat, wp.Lon = anchor.Lat, anchor.Lon
+		}
+		wps = append(wps, wp)
+	}
+	if anchor == nil {
+		wps = wps[:0]
+	}
+	tp.Waypoints = wps
+	tp.Start = nil
+	tp.End = nil
+	if len(wps) > 0 {
+		tp.Start = wps[0]
+		tp.End = wps[len(wps)-1]
+		tp.Start.Name = "Start"
+		tp.End.Name = "End"
+	}
+
+	lls := make([]*LogLine, 0, len(tp.LogLines))
+	for _, ll := range tp.LogLines {
+		if lat, lon, ok := sentencePosition(ll); ok && zones.Find(lat, lon) != nil {
+			continue
+		}
+		lls = append(lls, ll)
+	}
+	tp.LogLines = lls
+}
+
+// sentencePosition the position of a position sentence (RMC, GGA, GLL, GNS), regardless of the validity
+func sentencePosition(ll *LogLine) (lat, lon float64, ok bool) {
+	if ll == nil || ll.NMEAMessage == nil {
+		return 0, 0, false
+	}
+	switch m := ll.NMEAMessage.(type) {
+	case nmea.RMC:
+		return m.Latitude, m.Longitude, true
+	case nmea.GGA:
+		return m.Latitude, m.Longitude, true
+	case nmea.GLL:
+		return m.Latitude, m.Longitude, true
+	case nmea.GNS:
+		return m.Latitude, m.Longitude, true
+	}
+	return 0, 0, false
+}
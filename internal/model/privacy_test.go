@@ -0,0 +1,103 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+type PrivacySuite struct {
+	suite.Suite
+}
+
+func TestPrivacySuite(t *testing.T) {
+	suite.Run(t, new(PrivacySuite))
+}
+
+func (s *PrivacySuite) trackPoints() *TrackPoints {
+	tp, err := GetWaypoints(&TrackPoints{LogLines: testLogLines(s.Require())})
+	s.Require().NoError(err)
+	s.Require().Len(tp.Waypoints, 10)
+	return tp
+}
+
+func (s *PrivacySuite) TestDropCircle() {
+	tp := s.trackPoints()
+	// first two points are inside, the points are ~750m apart
+	tp.ApplyPrivacyZones(PrivacyZones{{Name: "home", Lat: 47.3333, Lon: 8.505, Radius: 1000}})
+
+	s.Len(tp.Waypoints, 8)
+	s.InDelta(8.52, tp.Start.Lon, 0.0001)
+	s.Equal("Start", tp.Start.Name)
+	// the rmc lines are removed, the depth lines stay
+	s.Len(tp.LogLines, 18)
+	for _, ll := range tp.LogLines {
+		lat, lon, ok := Position(ll)
+		if ok {
+			s.Greater(geo.Distance(47.3333, 8.505, lat, lon), 1000.0)
+		}
+	}
+}
+
+func (s *PrivacySuite) TestFuzzPolygon() {
+	tp := s.trackPoints()
+	zone := PrivacyZone{
+		Name:    "mooring",
+		Polygon: geo.Polygon{{{8.585, 47.3}, {8.6, 47.3}, {8.6, 47.4}, {8.585, 47.4}, {8.585, 47.3}}},
+		Mode:    PrivacyFuzz,
+	}
+	s.NoError(zone.Validate())
+	tp.ApplyPrivacyZones(PrivacyZones{zone})
+
+	s.Len(tp.Waypoints, 10)
+	// the last point is moved to the point before the zone
+	s.Equal(tp.Waypoints[8].Lat, tp.End.Lat)
+	s.Equal(tp.Waypoints[8].Lon, tp.End.Lon)
+	s.InDelta(8.58, tp.End.Lon, 0.0001)
+	s.Equal("End", tp.End.Name)
+	s.Len(tp.LogLines, 19)
+}
+
+func (s *PrivacySuite) TestFuzzCircle() {
+	// the points are ~750m apart
+	for _, center := range []float64{8.55, 8.50, 8.59} {
+		tp := s.trackPoints()
+		zone := PrivacyZone{Name: "home", Lat: 47.3333, Lon: center, Radius: 1000, Mode: PrivacyFuzz}
+		tp.ApplyPrivacyZones(PrivacyZones{zone})
+		s.Len(tp.Waypoints, 10, center)
+		for _, wp := range append(tp.Waypoints, tp.Start, tp.End) {
+			s.Greater(geo.Distance(zone.Lat, zone.Lon, wp.Lat, wp.Lon), zone.Radius, center)
+		}
+		tp.Reduction = nil
+		for _, sd := range tp.Soundings(0) {
+			s.Greater(geo.Distance(zone.Lat, zone.Lon, sd.Lat, sd.Lon), zone.Radius, center)
+		}
+	}
+
+	// a zone in the middle, the points are moved to the last point before the zone
+	tp := s.trackPoints()
+	tp.ApplyPrivacyZones(PrivacyZones{{Name: "home", Lat: 47.3333, Lon: 8.55, Radius: 1000, Mode: PrivacyFuzz}})
+	for i := 4; i <= 6; i++ {
+		s.InDelta(8.53, tp.Waypoints[i].Lon, 0.0001)
+	}
+	// at the start, the points are moved to the first point after the zone
+	tp = s.trackPoints()
+	tp.ApplyPrivacyZones(PrivacyZones{{Name: "home", Lat: 47.3333, Lon: 8.50, Radius: 1000, Mode: PrivacyFuzz}})
+	s.InDelta(8.52, tp.Start.Lon, 0.0001)
+	s.InDelta(8.52, tp.Waypoints[1].Lon, 0.0001)
+
+	// all points inside
+	tp = s.trackPoints()
+	tp.ApplyPrivacyZones(PrivacyZones{{Name: "home", Lat: 47.3333, Lon: 8.55, Radius: 10000, Mode: PrivacyFuzz}})
+	s.Empty(tp.Waypoints)
+	s.Nil(tp.Start)
+	s.Nil(tp.End)
+}
+
+func (s *PrivacySuite) TestValidate() {
+	s.Error(PrivacyZone{Name: "nothing"}.Validate())
+	s.Error(PrivacyZone{Radius: 10}.Validate())
+	s.Error(PrivacyZone{Name: "x", Radius: 10, Mode: "hide"}.Validate())
+	s.NoError(PrivacyZone{Name: "x", Radius: 10}.Validate())
+}
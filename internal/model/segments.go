@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// DefaultMaxGap the default time between two fixes, after which a new segment is started
+const DefaultMaxGap = time.Minute
+
+// Bounds the bounding box of a list of waypoints
+type Bounds struct {
+	MinLat float64 `json:"minLat"`
+	MinLon float64 `json:"minLon"`
+	MaxLat float64 `json:"maxLat"`
+	MaxLon float64 `json:"maxLon"`
+}
+
+// Segments splits the waypoints into segments. A new segment is started, if the time between two
+// waypoints is greater than maxGap. With maxGap <= 0 all waypoints are in one segment.
+func (t *TrackPoints) Segments(maxGap time.Duration) [][]*Waypoint {
+	segs := make([][]*Waypoint, 0)
+	var seg []*Waypoint
+	for _, wpt := range t.Waypoints {
+		if len(seg) > 0 && maxGap > 0 && wpt.Time.Sub(seg[len(seg)-1].Time) > maxGap {
+			segs = append(segs, seg)
+			seg = nil
+		}
+		seg = append(seg, wpt)
+	}
+	if len(seg) > 0 {
+		segs = append(segs, seg)
+	}
+	return segs
+}
+
+// Bounds the bounding box of all waypoints, nil if there are no waypoints
+func (t *TrackPoints) Bounds() *Bounds {
+	if len(t.Waypoints) == 0 {
+		return nil
+	}
+	b := &Bounds{
+		MinLat: t.Waypoints[0].Lat,
+		MinLon: t.Waypoints[0].Lon,
+		MaxLat: t.Waypoints[0].Lat,
+		MaxLon: t.Waypoints[0].Lon,
+	}
+	for _, wpt := range t.Waypoints[1:] {
+		b.MinLat = min(b.MinLat, wpt.Lat)
+		b.MinLon = min(b.MinLon, wpt.Lon)
+		b.MaxLat = max(b.MaxLat, wpt.Lat)
+		b.MaxLon = max(b.MaxLon, wpt.Lon)
+	}
+	return b
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SegmentsSuite struct {
+	suite.Suite
+}
+
+func TestSegmentsSuite(t *testing.T) {
+	suite.Run(t, new(SegmentsSuite))
+}
+
+func (s *SegmentsSuite) track() *TrackPoints {
+	start := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	tp := &TrackPoints{}
+	for i, offset := range []int{0, 10, 20, 200, 210} {
+		tp.Waypoints = append(tp.Waypoints, &Waypoint{
+			Lat:  47.0 + float64(i)*0.1,
+			Lon:  8.0 - float64(i)*0.1,
+			Time: start.Add(time.Duration(offset) * time.Second),
+		})
+	}
+	return tp
+}
+
+func (s *SegmentsSuite) TestSegments() {
+	tp := s.track()
+	segs := tp.Segments(time.Minute)
+	s.Require().Len(segs, 2)
+	s.Len(segs[0], 3)
+	s.Len(segs[1], 2)
+
+	s.Len(tp.Segments(0), 1)
+	s.Empty((&TrackPoints{}).Segments(time.Minute))
+}
+
+func (s *SegmentsSuite) TestBounds() {
+	tp := s.track()
+	b := tp.Bounds()
+	s.Require().NotNil(b)
+	s.InDelta(47.0, b.MinLat, 1e-9)
+	s.InDelta(47.4, b.MaxLat, 1e-9)
+	s.InDelta(7.6, b.MinLon, 1e-9)
+	s.InDelta(8.0, b.MaxLon, 1e-9)
+
+	s.Nil((&TrackPoints{}).Bounds())
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+	"github.com/willie68/osmltools/internal/osmlnmea"
+)
+
+const (
+	// SeriesAccel the acceleration sensor of the logger (POSMACC), columns accel_x, accel_y, accel_z
+	SeriesAccel = "accel"
+	// SeriesGyro the gyro sensor of the logger (POSMGYR), columns gyro_x, gyro_y, gyro_z
+	SeriesGyro = "gyro"
+	// SeriesSupply the supply voltage of the logger (POSMVCC)
+	SeriesSupply = "supply"
+	// SeriesDepth the depth in meters (DBT/DPT), with the depth reduction of the track
+	SeriesDepth = "depth"
+	// SeriesSOG the speed over ground in knots (RMC)
+	SeriesSOG = "sog"
+	// SeriesCOG the course over ground in degrees (RMC)
+	SeriesCOG = "cog"
+	// SeriesWaterTemp the water temperature in °C (MTW)
+	SeriesWaterTemp = "watertemp"
+)
+
+// SeriesNames all available series
+var SeriesNames = []string{SeriesAccel, SeriesGyro, SeriesSupply, SeriesDepth, SeriesSOG, SeriesCOG, SeriesWaterTemp}
+
+var seriesColumns = map[string][]string{
+	SeriesAccel:     {"accel_x", "accel_y", "accel_z"},
+	SeriesGyro:      {"gyro_x", "gyro_y", "gyro_z"},
+	SeriesSupply:    {"supply"},
+	SeriesDepth:     {"depth"},
+	SeriesSOG:       {"sog"},
+	SeriesCOG:       {"cog"},
+	SeriesWaterTemp: {"watertemp"},
+}
+
+// Series sensor time series aligned by time
+type Series struct {
+	Columns []string
+	Rows    []SeriesRow
+}
+
+// SeriesRow the samples of all series at one time, a value is NaN if the series has no sample at this time
+type SeriesRow struct {
+	Time   time.Time
+	Values []float64
+}
+
+// ParseSeries parses a comma separated list of series names
+func ParseSeries(s string) ([]string, error) {
+	names := make([]string, 0)
+	for n := range strings.SplitSeq(s, ",") {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" {
+			continue
+		}
+		if !slices.Contains(SeriesNames, n) {
+			return nil, fmt.Errorf("unknown series %s, available: %s", n, strings.Join(SeriesNames, ", "))
+		}
+		if !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no series given, available: %s", strings.Join(SeriesNames, ", "))
+	}
+	return names, nil
+}
+
+// Series builds the time series of the log lines at the original sample rate. Samples with the same corrected time
+// stamp are written into one row, a second sample of the same series at the same time starts a new row.
+func (t *TrackPoints) Series(names []string) *Series {
+	s := &Series{Columns: make([]string, 0)}
+	offsets := make(map[string]int)
+	for _, n := range names {
+		offsets[n] = len(s.Columns)
+		s.Columns = append(s.Columns, seriesColumns[n]...)
+	}
+
+	lls := slices.Clone(t.LogLines)
+	sort.SliceStable(lls, func(i, j int) bool {
+		return lls[i].CorrectTimeStamp.Before(lls[j].CorrectTimeStamp)
+	})
+	var row *SeriesRow
+	for _, ll := range lls {
+		for _, sm := range t.seriesSamples(ll) {
+			off, ok := offsets[sm.name]
+			if !ok {
+				continue
+			}
+			if row == nil || !row.Time.Equal(ll.CorrectTimeStamp) || !math.IsNaN(row.Values[off]) {
+				s.Rows = append(s.Rows, SeriesRow{Time: ll.CorrectTimeStamp, Values: make([]float64, len(s.Columns))})
+				row = &s.Rows[len(s.Rows)-1]
+				for i := range row.Values {
+					row.Values[i] = math.NaN()
+				}
+			}
+			copy(row.Values[off:], sm.values)
+		}
+	}
+	return s
+}
+
+type seriesSample struct {
+	name   string
+	values []float64
+}
+
+// seriesSamples the samples of all series of a log line
+func (t *TrackPoints) seriesSamples(ll *LogLine) []seriesSample {
+	switch m := ll.NMEAMessage.(type) {
+	case osmlnmea.OSMACC:
+		return []seriesSample{{SeriesAccel, []float64{float64(m.XAcc), float64(m.YAcc), float64(m.ZAcc)}}}
+	case osmlnmea.OSMGYR:
+		return []seriesSample{{SeriesGyro, []float64{float64(m.XAxis), float64(m.YAxis), float64(m.ZAxis)}}}
+	case osmlnmea.OSMVCC:
+		return []seriesSample{{SeriesSupply, []float64{float64(m.Voltage)}}}
+	case nmea.DBT, nmea.DPT:
+		if depth, ok := t.Reduction.Reduce(ll); ok {
+			return []seriesSample{{SeriesDepth, []float64{depth}}}
+		}
+	case nmea.RMC:
+		if m.Validity == nmea.ValidRMC {
+			return []seriesSample{{SeriesSOG, []float64{m.Speed}}, {SeriesCOG, []float64{m.Course}}}
+		}
+	case nmea.MTW:
+		if m.CelsiusValid {
+			return []seriesSample{{SeriesWaterTemp, []float64{m.Temperature}}}
+		}
+	}
+	return nil
+}
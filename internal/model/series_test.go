@@ -0,0 +1,78 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SeriesSuite struct {
+	suite.Suite
+}
+
+func TestSeriesSuite(t *testing.T) {
+	suite.Run(t, new(SeriesSuite))
+}
+
+func (s *SeriesSuite) track() *TrackPoints {
+	lines := []string{
+		"2016-09-11 10:00:01.000000: " + nmeaChecksum("$POSMACC,168,10428,13928"),
+		"2016-09-11 10:00:00.000000: " + nmeaChecksum("$GPRMC,100000,A,4720.000,N,00830.0000,E,5.0,90.0,110916,,"),
+		"2016-09-11 10:00:00.000000: " + nmeaChecksum("$POSMACC,112,10372,14156"),
+		"2016-09-11 10:00:00.000000: " + nmeaChecksum("$POSMVCC,4940"),
+		"2016-09-11 10:00:00.500000: " + nmeaChecksum("$SDDPT,4.5,0.3"),
+		"2016-09-11 10:00:01.000000: " + nmeaChecksum("$POSMACC,48,10432,13788"),
+		"2016-09-11 10:00:01.000000: " + nmeaChecksum("$POSMGYR,-340,-107,-78"),
+		"2016-09-11 10:00:02.000000: " + nmeaChecksum("$GPRMC,100002,V,4720.000,N,00830.0000,E,5.0,90.0,110916,,"),
+	}
+	lls, err := ParseLines2LogLines(lines, false)
+	s.Require().NoError(err)
+	s.Require().Len(lls, 8)
+	return &TrackPoints{LogLines: lls}
+}
+
+func (s *SeriesSuite) TestParseSeries() {
+	names, err := ParseSeries(" accel, GYRO,accel,,depth")
+	s.Require().NoError(err)
+	s.Equal([]string{SeriesAccel, SeriesGyro, SeriesDepth}, names)
+	_, err = ParseSeries("accel,heartrate")
+	s.Error(err)
+	_, err = ParseSeries(" , ")
+	s.Error(err)
+}
+
+func (s *SeriesSuite) TestSeries() {
+	sr := s.track().Series([]string{SeriesAccel, SeriesSupply, SeriesSOG, SeriesCOG, SeriesDepth, SeriesGyro})
+	s.Equal([]string{"accel_x", "accel_y", "accel_z", "supply", "sog", "cog", "depth", "gyro_x", "gyro_y", "gyro_z"}, sr.Columns)
+	s.Require().Len(sr.Rows, 4)
+
+	start := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	// rmc, acc and vcc at the same time in one row
+	r := sr.Rows[0]
+	s.Equal(start, r.Time)
+	s.Equal([]float64{112, 10372, 14156, 4940, 5, 90}, r.Values[:6])
+	s.True(math.IsNaN(r.Values[6]))
+
+	s.Equal(start.Add(500*time.Millisecond), sr.Rows[1].Time)
+	s.InDelta(4.5, sr.Rows[1].Values[6], 1e-9)
+
+	// two acc samples at the same time, the second starts a new row, the invalid rmc is dropped
+	s.Equal(start.Add(time.Second), sr.Rows[2].Time)
+	s.Equal(start.Add(time.Second), sr.Rows[3].Time)
+	s.Equal(168.0, sr.Rows[2].Values[0])
+	s.Equal(48.0, sr.Rows[3].Values[0])
+	// the gyro sample is added to the last row of the time
+	s.True(math.IsNaN(sr.Rows[2].Values[7]))
+	s.Equal(-340.0, sr.Rows[3].Values[7])
+}
+
+func (s *SeriesSuite) TestSeriesReduction() {
+	tp := s.track()
+	tp.Reduction = &DepthReduction{Reference: DepthRefWaterline}
+	sr := tp.Series([]string{SeriesDepth})
+	s.Require().Len(sr.Rows, 1)
+	// the dpt offset of the sensor is added
+	s.InDelta(4.8, sr.Rows[0].Values[0], 1e-9)
+}
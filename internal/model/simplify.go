@@ -0,0 +1,182 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+// DefaultCornerAngle the default course change in degrees, after which a waypoint is kept as corner
+const DefaultCornerAngle = 45.0
+
+// cornerMinSpeed below this speed (knots) the course is too noisy to detect corners
+const cornerMinSpeed = 0.5
+
+// Simplification reduces the number of waypoints of a track. The waypoints are resampled (time or distance) and/or
+// simplified with douglas peucker, corners and depth extremes can be kept. The first and last waypoint of every
+// segment are always kept, the log lines are not changed.
+type Simplification struct {
+	// Tolerance douglas peucker tolerance in meters, 0 for no simplification
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// Interval resample to one waypoint per time interval, 0 for no time resampling
+	Interval time.Duration `json:"interval,omitempty"`
+	// Distance resample to one waypoint per distance in meters, 0 for no distance resampling
+	Distance float64 `json:"distance,omitempty"`
+	// CornerAngle keep a waypoint every time the course has changed by this angle in degrees, 0 for no corners
+	CornerAngle float64 `json:"cornerAngle,omitempty"`
+	// KeepDepthExtremes keep the shallowest and the deepest waypoint between two kept waypoints, so no shoal is lost
+	KeepDepthExtremes bool `json:"keepDepthExtremes,omitempty"`
+}
+
+// IsEmpty checks if the simplification will change anything
+func (s *Simplification) IsEmpty() bool {
+	return s == nil || (s.Tolerance <= 0 && s.Interval <= 0 && s.Distance <= 0)
+}
+
+// Validate checks the simplification
+func (s *Simplification) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Tolerance < 0 || s.Interval < 0 || s.Distance < 0 || s.CornerAngle < 0 {
+		return errors.New("simplification values must not be negative")
+	}
+	if s.Interval > 0 && s.Distance > 0 {
+		return errors.New("resample either by time or by distance")
+	}
+	return nil
+}
+
+// Simplify reduces the waypoints of the track, see Simplification. The segments (DefaultMaxGap) are simplified one by one.
+func (t *TrackPoints) Simplify(s *Simplification) {
+	if s.IsEmpty() || len(t.Waypoints) == 0 {
+		return
+	}
+	wpts := make([]*Waypoint, 0)
+	for _, seg := range t.Segments(DefaultMaxGap) {
+		keep := s.keep(seg)
+		for i, wpt := range seg {
+			if keep[i] {
+				wpts = append(wpts, wpt)
+			}
+		}
+	}
+	t.Waypoints = wpts
+}
+
+// keep marks the waypoints of the segment which are kept
+func (s *Simplification) keep(seg []*Waypoint) []bool {
+	n := len(seg)
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+
+	// resampling
+	cand := make([]int, 0, n)
+	switch {
+	case s.Interval > 0:
+		next := seg[0].Time
+		for i, wpt := range seg {
+			if !wpt.Time.Before(next) {
+				cand = append(cand, i)
+				next = wpt.Time.Add(s.Interval)
+			}
+		}
+	case s.Distance > 0:
+		dist := 0.0
+		cand = append(cand, 0)
+		for i := 1; i < n; i++ {
+			dist += geo.Distance(seg[i-1].Lat, seg[i-1].Lon, seg[i].Lat, seg[i].Lon)
+			if dist >= s.Distance {
+				cand = append(cand, i)
+				dist = 0
+			}
+		}
+	default:
+		for i := range seg {
+			cand = append(cand, i)
+		}
+	}
+	if cand[len(cand)-1] != n-1 {
+		cand = append(cand, n-1)
+	}
+
+	// douglas peucker on the resampled waypoints
+	if s.Tolerance > 0 {
+		line := make([][2]float64, len(cand))
+		for i, c := range cand {
+			line[i] = [2]float64{seg[c].Lon, seg[c].Lat}
+		}
+		for _, i := range geo.SimplifyIndex(line, s.Tolerance) {
+			keep[cand[i]] = true
+		}
+	} else {
+		for _, c := range cand {
+			keep[c] = true
+		}
+	}
+
+	if s.CornerAngle > 0 {
+		s.keepCorners(seg, keep)
+	}
+	if s.KeepDepthExtremes {
+		keepDepthExtremes(seg, keep)
+	}
+	return keep
+}
+
+// keepCorners keeps the waypoints where the course has changed by the corner angle since the last corner
+func (s *Simplification) keepCorners(seg []*Waypoint, keep []bool) {
+	ref := -1.0
+	for i, wpt := range seg {
+		if wpt.Speed < cornerMinSpeed {
+			continue
+		}
+		if ref < 0 {
+			ref = wpt.Course
+			continue
+		}
+		d := math.Abs(math.Mod(wpt.Course-ref+540, 360) - 180)
+		if d >= s.CornerAngle {
+			keep[i] = true
+			ref = wpt.Course
+		}
+	}
+}
+
+// keepDepthExtremes keeps the shallowest and the deepest waypoint between two kept waypoints, if they are shallower or
+// deeper than both kept waypoints
+func keepDepthExtremes(seg []*Waypoint, keep []bool) {
+	kept := make([]int, 0)
+	for i, k := range keep {
+		if k {
+			kept = append(kept, i)
+		}
+	}
+	for j := 1; j < len(kept); j++ {
+		minI, maxI := -1, -1
+		for i := kept[j-1] + 1; i < kept[j]; i++ {
+			d := seg[i].Depth
+			if d <= 0 {
+				continue
+			}
+			if minI < 0 || d < seg[minI].Depth {
+				minI = i
+			}
+			if maxI < 0 || d > seg[maxI].Depth {
+				maxI = i
+			}
+		}
+		if minI < 0 {
+			continue
+		}
+		a, b := seg[kept[j-1]].Depth, seg[kept[j]].Depth
+		if a <= 0 || b <= 0 || seg[minI].Depth < min(a, b) {
+			keep[minI] = true
+		}
+		if a <= 0 || b <= 0 || seg[maxI].Depth > max(a, b) {
+			keep[maxI] = true
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SimplifySuite struct {
+	suite.Suite
+	start time.Time
+}
+
+func TestSimplifySuite(t *testing.T) {
+	suite.Run(t, new(SimplifySuite))
+}
+
+func (s *SimplifySuite) SetupTest() {
+	s.start = time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+}
+
+// straight returns n waypoints one second and about 7.5 m apart on a straight line to the east
+func (s *SimplifySuite) straight(n int) *TrackPoints {
+	tp := &TrackPoints{}
+	for i := range n {
+		tp.Waypoints = append(tp.Waypoints, &Waypoint{
+			Lat:    47.5,
+			Lon:    8.5 + float64(i)*0.0001,
+			Time:   s.start.Add(time.Duration(i) * time.Second),
+			Speed:  14.6,
+			Course: 90,
+			Depth:  10,
+		})
+	}
+	return tp
+}
+
+func (s *SimplifySuite) TestEmpty() {
+	tp := s.straight(10)
+	tp.Simplify(nil)
+	s.Len(tp.Waypoints, 10)
+	tp.Simplify(&Simplification{CornerAngle: 45})
+	s.Len(tp.Waypoints, 10)
+	s.True((&Simplification{KeepDepthExtremes: true}).IsEmpty())
+}
+
+func (s *SimplifySuite) TestValidate() {
+	s.NoError((*Simplification)(nil).Validate())
+	s.Error((&Simplification{Tolerance: -1}).Validate())
+	s.Error((&Simplification{Interval: time.Second, Distance: 10}).Validate())
+	s.NoError((&Simplification{Interval: time.Second, Tolerance: 10}).Validate())
+}
+
+func (s *SimplifySuite) TestDouglasPeucker() {
+	tp := s.straight(100)
+	tp.Simplify(&Simplification{Tolerance: 1})
+	s.Require().Len(tp.Waypoints, 2)
+	s.Equal(s.start, tp.Waypoints[0].Time)
+	s.Equal(s.start.Add(99*time.Second), tp.Waypoints[1].Time)
+}
+
+func (s *SimplifySuite) TestSegmentsKept() {
+	tp := s.straight(100)
+	// a gap after the 50th point
+	for _, wpt := range tp.Waypoints[50:] {
+		wpt.Time = wpt.Time.Add(time.Hour)
+	}
+	tp.Simplify(&Simplification{Tolerance: 1})
+	s.Len(tp.Waypoints, 4)
+}
+
+func (s *SimplifySuite) TestResampleTime() {
+	tp := s.straight(100)
+	tp.Simplify(&Simplification{Interval: 10 * time.Second})
+	// 0, 10, ... 90 and the last one
+	s.Len(tp.Waypoints, 11)
+	s.Equal(s.start.Add(10*time.Second), tp.Waypoints[1].Time)
+}
+
+func (s *SimplifySuite) TestResampleDistance() {
+	tp := s.straight(100)
+	// about 75 m, every 10th point
+	tp.Simplify(&Simplification{Distance: 74})
+	s.Len(tp.Waypoints, 11)
+}
+
+func (s *SimplifySuite) TestCorners() {
+	tp := s.straight(100)
+	// slowly turning to the south in the second half, with only a small offset from the line
+	for i, wpt := range tp.Waypoints[50:] {
+		wpt.Course = 90 + float64(i)*2
+	}
+	tp.Simplify(&Simplification{Tolerance: 1, CornerAngle: 45})
+	// start, end and a corner at 45 and 90 degrees
+	s.Len(tp.Waypoints, 4)
+
+	// slow waypoints are ignored
+	tp = s.straight(100)
+	for i, wpt := range tp.Waypoints[50:] {
+		wpt.Course = 90 + float64(i)*2
+		wpt.Speed = 0.1
+	}
+	tp.Simplify(&Simplification{Tolerance: 1, CornerAngle: 45})
+	s.Len(tp.Waypoints, 2)
+}
+
+func (s *SimplifySuite) TestDepthExtremes() {
+	tp := s.straight(100)
+	tp.Waypoints[30].Depth = 2
+	tp.Waypoints[70].Depth = 25
+	tp.Waypoints[80].Depth = 0
+	simp := &Simplification{Tolerance: 1, KeepDepthExtremes: true}
+	tp.Simplify(simp)
+	s.Require().Len(tp.Waypoints, 4)
+	s.Equal(2.0, tp.Waypoints[1].Depth)
+	s.Equal(25.0, tp.Waypoints[2].Depth)
+
+	// no extremes on a flat bottom
+	tp = s.straight(100)
+	tp.Simplify(simp)
+	s.Len(tp.Waypoints, 2)
+}
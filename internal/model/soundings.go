@@ -0,0 +1,108 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+)
+
+// Sounding a single depth measurement with the position interpolated between the surrounding fixes
+type Sounding struct {
+	Time    time.Time `json:"time"`
+	Lat     float64   `json:"latitude"`
+	Lon     float64   `json:"longitude"`
+	Depth   float64   `json:"depth"`
+	Channel string    `json:"channel,omitempty"`
+	Source  string    `json:"source,omitempty"`
+}
+
+type fix struct {
+	time             time.Time
+	lat, lon, course float64
+}
+
+// Soundings returns every sounding of the log lines with a valid depth. The time is the corrected time stamp of the
+// DBT/DPT line, the position is interpolated between the RMC fixes before and after the sounding. Soundings without a
+// fix on both sides within maxGap are dropped, with maxGap <= 0 there is no limit.
+// The depth reduction of the track is applied to the depth and, with the lever arms of the vessel, to the position.
+// Soundings inside the privacy zones of the track are dropped, also in fuzz mode, a moved depth would be wrong.
+func (t *TrackPoints) Soundings(maxGap time.Duration) []Sounding {
+	fixes := make([]fix, 0)
+	for _, ll := range t.LogLines {
+		if rmc, ok := ll.NMEAMessage.(nmea.RMC); ok && rmc.Validity == nmea.ValidRMC {
+			fixes = append(fixes, fix{time: ll.CorrectTimeStamp, lat: rmc.Latitude, lon: rmc.Longitude, course: rmc.Course})
+		}
+	}
+	sort.SliceStable(fixes, func(i, j int) bool {
+		return fixes[i].time.Before(fixes[j].time)
+	})
+
+	sds := make([]Sounding, 0)
+	for _, ll := range t.LogLines {
+		depth, ok := t.Reduction.Reduce(ll)
+		if !ok {
+			continue
+		}
+		lat, lon, course, ok := interpolate(fixes, ll.CorrectTimeStamp, maxGap)
+		if !ok {
+			continue
+		}
+		lat, lon = t.Reduction.Position(lat, lon, course)
+		if t.Privacy.Find(lat, lon) != nil {
+			continue
+		}
+		sds = append(sds, Sounding{
+			Time:    ll.CorrectTimeStamp,
+			Lat:     lat,
+			Lon:     lon,
+			Depth:   depth,
+			Channel: ll.Channel,
+			Source:  ll.Source,
+		})
+	}
+	return sds
+}
+
+// Depth returns the depth in meters of a DBT or DPT log line, ok is false for other lines or for depths <= 0
+func Depth(ll *LogLine) (depth float64, ok bool) {
+	if ll == nil || ll.NMEAMessage == nil {
+		return 0, false
+	}
+	switch m := ll.NMEAMessage.(type) {
+	case nmea.DBT:
+		depth = m.DepthMeters
+		if depth == 0.0 {
+			depth = m.DepthFeet * 0.3048 // convert feet to meters
+		}
+	case nmea.DPT:
+		depth = m.Depth
+	default:
+		return 0, false
+	}
+	return depth, depth > 0.0
+}
+
+// interpolate the position at ts between the fixes before and after ts, the course is the course of the fix before
+func interpolate(fixes []fix, ts time.Time, maxGap time.Duration) (lat, lon, course float64, ok bool) {
+	i := sort.Search(len(fixes), func(i int) bool {
+		return !fixes[i].time.Before(ts)
+	})
+	if i == len(fixes) {
+		return 0, 0, 0, false
+	}
+	after := fixes[i]
+	if after.time.Equal(ts) {
+		return after.lat, after.lon, after.course, true
+	}
+	if i == 0 {
+		return 0, 0, 0, false
+	}
+	before := fixes[i-1]
+	span := after.time.Sub(before.time)
+	if maxGap > 0 && span > maxGap {
+		return 0, 0, 0, false
+	}
+	f := float64(ts.Sub(before.time)) / float64(span)
+	return before.lat + (after.lat-before.lat)*f, before.lon + (after.lon-before.lon)*f, before.course, true
+}
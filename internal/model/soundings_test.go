@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SoundingsSuite struct {
+	suite.Suite
+}
+
+func TestSoundingsSuite(t *testing.T) {
+	suite.Run(t, new(SoundingsSuite))
+}
+
+func (s *SoundingsSuite) TestInterpolation() {
+	tp := &TrackPoints{LogLines: testLogLines(s.Require())}
+	sds := tp.Soundings(DefaultMaxGap)
+
+	// the last sounding has no fix after it
+	s.Require().Len(sds, 9)
+	sd := sds[2]
+	s.Equal(time.Date(2016, 9, 11, 10, 2, 1, 0, time.UTC), sd.Time)
+	s.InDelta(47.3333333, sd.Lat, 1e-6)
+	// one second of the minute between the fixes at 8.52 and 8.53
+	s.InDelta(8.52+0.01/60, sd.Lon, 1e-9)
+	s.InDelta(10.0, sd.Depth, 1e-9)
+}
+
+func (s *SoundingsSuite) TestMaxGap() {
+	tp := &TrackPoints{LogLines: testLogLines(s.Require())}
+	s.Empty(tp.Soundings(30 * time.Second))
+	s.Len(tp.Soundings(0), 9)
+}
+
+func (s *SoundingsSuite) TestDepth() {
+	ll, ok, err := ParseNMEALogLine("2016-09-11 10:00:00.000000: "+nmeaChecksum("$SDDPT,4.5,0.3"), false)
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	d, ok := Depth(ll)
+	s.True(ok)
+	s.InDelta(4.5, d, 1e-9)
+
+	ll, _, err = ParseNMEALogLine("2016-09-11 10:00:00.000000: "+nmeaChecksum("$SDDBT,0.0,f,0.0,M,0.0,F"), false)
+	s.Require().NoError(err)
+	_, ok = Depth(ll)
+	s.False(ok)
+}
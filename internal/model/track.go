@@ -12,6 +12,7 @@ type Track struct {
 	VesselID    int32        `json:"vessel_id,omitempty"`
 	Files       []SourceData `json:"files,omitempty"`
 	MapFile     string       `json:"map_file,omitempty"`
+	Trim        *Trim        `json:"trim,omitempty"`
 }
 
 // SourceData information about a source data file
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/adrianmo/go-nmea"
+	"github.com/willie68/osmltools/internal/osmlnmea"
 )
 
 type ThreePoints struct {
@@ -19,19 +20,30 @@ type Waypoint struct {
 	Lon          float64      `json:"longitude,omitempty"`
 	Time         time.Time    `json:"time,omitempty"`
 	Speed        float64      `json:"speed,omitempty"`
+	Course       float64      `json:"course,omitempty"`
 	Ele          float64      `json:"elevation,omitempty"`
 	Depth        float64      `json:"depth,omitempty"`
+	WaterTemp    float64      `json:"water_temp,omitempty"`
 	Acceleration *ThreePoints `json:"acc,omitempty"`
 	GyroLocation *ThreePoints `json:"gyro,omitempty"`
 	Supply       int64        `json:"supply,omitempty"`
+	HeartRate    int64        `json:"heart_rate,omitempty"`
+	Channel      string       `json:"channel,omitempty"`
+	Source       string       `json:"source,omitempty"`
 }
 
 type TrackPoints struct {
-	Name      string      `json:"name,omitempty"`
-	Waypoints []*Waypoint `json:"waypoints,omitempty"`
-	Start     *Waypoint   `json:"start,omitempty"`
-	End       *Waypoint   `json:"end,omitempty"`
-	LogLines  []*LogLine  `json:"log_lines,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	VesselID    int32       `json:"vessel_id,omitempty"`
+	Waypoints   []*Waypoint `json:"waypoints,omitempty"`
+	Start       *Waypoint   `json:"start,omitempty"`
+	End         *Waypoint   `json:"end,omitempty"`
+	LogLines    []*LogLine  `json:"log_lines,omitempty"`
+	// Reduction the depth reduction used for the waypoints and soundings, nil for the measured depths
+	Reduction *DepthReduction `json:"-"`
+	// Privacy the privacy zones applied to the track, the soundings inside the zones are dropped or fuzzed
+	Privacy PrivacyZones `json:"-"`
 }
 
 // GetWaypoints extracts the waypoints from the log lines of the track
@@ -40,23 +52,27 @@ func GetWaypoints(track *TrackPoints) (*TrackPoints, error) {
 
 	for _, ll := range track.LogLines {
 		if ll.NMEAMessage != nil {
-			switch ll.NMEAMessage.Prefix() {
-			case "GPRMC":
+			// the data type without talker, imported data may come from other talkers than the logger, e.g. GNRMC
+			switch ll.NMEAMessage.DataType() {
+			case "RMC":
 				rmc, ok := ll.NMEAMessage.(nmea.RMC)
 				if ok && rmc.Validity == "A" { // only valid
 					track.End = &Waypoint{
-						Lat:   rmc.Latitude,
-						Lon:   rmc.Longitude,
-						Time:  ll.CorrectTimeStamp,
-						Speed: rmc.Speed,
-						Ele:   0.0,
+						Lat:     rmc.Latitude,
+						Lon:     rmc.Longitude,
+						Time:    ll.CorrectTimeStamp,
+						Speed:   rmc.Speed,
+						Course:  rmc.Course,
+						Ele:     0.0,
+						Channel: ll.Channel,
+						Source:  ll.Source,
 					}
 					track.Waypoints = append(track.Waypoints, track.End)
 					if track.Start == nil {
 						track.Start = track.End
 					}
 				}
-			case "GPGGA":
+			case "GGA":
 				if track.End != nil {
 					gga, ok := ll.NMEAMessage.(nmea.GGA)
 					if ok {
@@ -65,23 +81,45 @@ func GetWaypoints(track *TrackPoints) (*TrackPoints, error) {
 						}
 					}
 				}
-			case "SDDBT":
-				if track.End != nil {
-					dbt, ok := ll.NMEAMessage.(nmea.DBT)
+			case "DBT", "DPT":
+				if track.End != nil && track.End.Depth == 0.0 {
+					if depth, ok := track.Reduction.Reduce(ll); ok {
+						track.End.Depth = depth
+					}
+				}
+			case "MTW":
+				if track.End != nil && track.End.WaterTemp == 0.0 {
+					mtw, ok := ll.NMEAMessage.(nmea.MTW)
+					if ok && mtw.CelsiusValid {
+						track.End.WaterTemp = mtw.Temperature
+					}
+				}
+			case "OSMACC":
+				if track.End != nil && track.End.Acceleration == nil {
+					acc, ok := ll.NMEAMessage.(osmlnmea.OSMACC)
 					if ok {
-						depth := dbt.DepthFeet * 0.3048 // convert feet to meters
-						if track.End.Depth == 0.0 {
-							track.End.Depth = depth
-						}
+						track.End.Acceleration = &ThreePoints{X: acc.XAcc, Y: acc.YAcc, Z: acc.ZAcc}
 					}
 				}
-			case "SDDPT":
-				if track.End != nil {
-					dpt, ok := ll.NMEAMessage.(nmea.DPT)
+			case "OSMGYR":
+				if track.End != nil && track.End.GyroLocation == nil {
+					gyr, ok := ll.NMEAMessage.(osmlnmea.OSMGYR)
 					if ok {
-						if track.End.Depth == 0.0 {
-							track.End.Depth = dpt.Depth
-						}
+						track.End.GyroLocation = &ThreePoints{X: gyr.XAxis, Y: gyr.YAxis, Z: gyr.ZAxis}
+					}
+				}
+			case "OSMHR":
+				if track.End != nil && track.End.HeartRate == 0 {
+					hr, ok := ll.NMEAMessage.(osmlnmea.OSMHR)
+					if ok {
+						track.End.HeartRate = hr.HeartRate
+					}
+				}
+			case "OSMVCC":
+				if track.End != nil && track.End.Supply == 0 {
+					vcc, ok := ll.NMEAMessage.(osmlnmea.OSMVCC)
+					if ok {
+						track.End.Supply = vcc.Voltage
 					}
 				}
 			}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+var (
+	// ErrInvalidTimeRange the from time is after the to time
+	ErrInvalidTimeRange = errors.New("invalid time range, from is after to")
+
+	trimTimeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04",
+	}
+)
+
+// Trim describes which part of a track should be used, all lines outside of the time range or outside the clip area will be dropped.
+// The original data is not changed.
+type Trim struct {
+	From time.Time    `json:"from,omitzero"`
+	To   time.Time    `json:"to,omitzero"`
+	Clip geo.Polygons `json:"clip,omitempty"`
+}
+
+// ParseTrimTime parses a time value for the trim range. Times without a zone are taken as UTC, like the logger timestamps.
+func ParseTrimTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, l := range trimTimeLayouts {
+		t, err := time.Parse(l, s)
+		if err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time format %q, use e.g. \"2006-01-02 15:04:05\" or RFC3339", s)
+}
+
+// IsEmpty checks if the trim will change anything
+func (t *Trim) IsEmpty() bool {
+	return t == nil || (t.From.IsZero() && t.To.IsZero() && len(t.Clip) == 0)
+}
+
+// Validate checks the time range
+func (t *Trim) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if !t.From.IsZero() && !t.To.IsZero() && t.From.After(t.To) {
+		return ErrInvalidTimeRange
+	}
+	return nil
+}
+
+// InRange checks if the time stamp is inside the time range
+func (t *Trim) InRange(ts time.Time) bool {
+	if !t.From.IsZero() && ts.Before(t.From) {
+		return false
+	}
+	if !t.To.IsZero() && ts.After(t.To) {
+		return false
+	}
+	return true
+}
+
+// ApplyTrim drops all log lines outside the time range and outside the clip area. For the clip area the position of the last valid fix is used,
+// so all lines before the first fix are dropped. Waypoints have to be rebuild afterwards.
+func (tp *TrackPoints) ApplyTrim(t *Trim) {
+	if t.IsEmpty() {
+		return
+	}
+	lls := make([]*LogLine, 0, len(tp.LogLines))
+	inside := false
+	for _, ll := range tp.LogLines {
+		if len(t.Clip) > 0 {
+			if lat, lon, ok := Position(ll); ok {
+				inside = t.Clip.Contains(lat, lon)
+			}
+			if !inside {
+				continue
+			}
+		}
+		if !t.InRange(ll.CorrectTimeStamp) {
+			continue
+		}
+		lls = append(lls, ll)
+	}
+	tp.LogLines = lls
+}
+
+// Position returns the position of a log line, if the line contains a valid position fix (RMC, GGA or GLL)
+func Position(ll *LogLine) (lat, lon float64, ok bool) {
+	if ll == nil || ll.NMEAMessage == nil {
+		return 0, 0, false
+	}
+	switch m := ll.NMEAMessage.(type) {
+	case nmea.RMC:
+		return m.Latitude, m.Longitude, m.Validity == nmea.ValidRMC
+	case nmea.GGA:
+		return m.Latitude, m.Longitude, m.FixQuality != nmea.Invalid && m.FixQuality != ""
+	case nmea.GLL:
+		return m.Latitude, m.Longitude, m.Validity == nmea.ValidGLL
+	}
+	return 0, 0, false
+}
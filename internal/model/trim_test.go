@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/geo"
+)
+
+type TrimSuite struct {
+	suite.Suite
+}
+
+func TestTrimSuite(t *testing.T) {
+	suite.Run(t, new(TrimSuite))
+}
+
+func nmeaChecksum(s string) string {
+	var cs byte
+	for i := 1; i < len(s); i++ {
+		cs ^= s[i]
+	}
+	return fmt.Sprintf("%s*%02X", s, cs)
+}
+
+// testLogLines 10 rmc lines one minute apart, walking east from 8.50 to 8.59 lon, each followed by a depth line
+func testLogLines(r *require.Assertions) []*LogLine {
+	lls := make([]*LogLine, 0)
+	start := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	for i := range 10 {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		rmc := nmeaChecksum(fmt.Sprintf("$GPRMC,%s,A,4720.000,N,008%07.4f,E,5.0,90.0,110916,,", ts.Format("150405"), 30+float64(i)*0.6))
+		dbt := nmeaChecksum("$SDDBT,32.8,f,10.0,M,5.5,F")
+		for j, l := range []string{rmc, dbt} {
+			ll, ok, err := ParseNMEALogLine(fmt.Sprintf("%s: %s", formatNMEATime(ts.Add(time.Duration(j)*time.Second)), l), false)
+			r.NoError(err)
+			r.True(ok)
+			lls = append(lls, ll)
+		}
+	}
+	return lls
+}
+
+func (s *TrimSuite) TestTimeRange() {
+	tp := &TrackPoints{LogLines: testLogLines(s.Require())}
+	tp.ApplyTrim(&Trim{
+		From: time.Date(2016, 9, 11, 10, 2, 0, 0, time.UTC),
+		To:   time.Date(2016, 9, 11, 10, 5, 30, 0, time.UTC),
+	})
+	s.Len(tp.LogLines, 8)
+	s.Equal(time.Date(2016, 9, 11, 10, 2, 0, 0, time.UTC), tp.LogLines[0].CorrectTimeStamp)
+
+	tp, err := GetWaypoints(tp)
+	s.NoError(err)
+	s.Len(tp.Waypoints, 4)
+	s.InDelta(10.0, tp.Start.Depth, 0.01)
+}
+
+func (s *TrimSuite) TestClip() {
+	tp := &TrackPoints{LogLines: testLogLines(s.Require())}
+	// box around lon 8.52 - 8.545
+	tp.ApplyTrim(&Trim{
+		Clip: geo.Polygons{geo.Polygon{{{8.515, 47.3}, {8.545, 47.3}, {8.545, 47.4}, {8.515, 47.4}, {8.515, 47.3}}}},
+	})
+	// points 2, 3 and 4 with their depth lines
+	s.Len(tp.LogLines, 6)
+	tp, err := GetWaypoints(tp)
+	s.NoError(err)
+	s.Len(tp.Waypoints, 3)
+	s.InDelta(8.52, tp.Start.Lon, 0.0001)
+	s.InDelta(8.54, tp.End.Lon, 0.0001)
+}
+
+func (s *TrimSuite) TestEmptyTrim() {
+	tp := &TrackPoints{LogLines: testLogLines(s.Require())}
+	var trim *Trim
+	s.True(trim.IsEmpty())
+	tp.ApplyTrim(trim)
+	s.Len(tp.LogLines, 20)
+}
+
+func (s *TrimSuite) TestValidate() {
+	trim := &Trim{
+		From: time.Date(2016, 9, 11, 11, 0, 0, 0, time.UTC),
+		To:   time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC),
+	}
+	s.ErrorIs(trim.Validate(), ErrInvalidTimeRange)
+}
+
+func (s *TrimSuite) TestParseTrimTime() {
+	ts, err := ParseTrimTime("2016-09-11 10:15:00")
+	s.NoError(err)
+	s.Equal(time.Date(2016, 9, 11, 10, 15, 0, 0, time.UTC), ts)
+
+	ts, err = ParseTrimTime("2016-09-11T12:15:00+02:00")
+	s.NoError(err)
+	s.Equal(time.Date(2016, 9, 11, 10, 15, 0, 0, time.UTC), ts)
+
+	ts, err = ParseTrimTime("")
+	s.NoError(err)
+	s.True(ts.IsZero())
+
+	_, err = ParseTrimTime("yesterday")
+	s.Error(err)
+}
@@ -50,6 +50,12 @@ type OSMVCC struct {
 	NormVoltage int64
 }
 
+// $POSMHR,72*32 heart rate in bpm, not written by the logger, used for imported data, e.g. FIT files
+type OSMHR struct {
+	nmea.BaseSentence
+	HeartRate int64
+}
+
 // $POSMSO,Reason: times up*4C
 type OSMSO struct {
 	nmea.BaseSentence
@@ -151,6 +157,14 @@ func init() {
 		}, p.Err()
 	}
 
+	sp.CustomParsers["OSMHR"] = func(s nmea.BaseSentence) (nmea.Sentence, error) {
+		p := nmea.NewParser(s)
+		return OSMHR{
+			BaseSentence: s,
+			HeartRate:    p.Int64(0, "heartrate"),
+		}, p.Err()
+	}
+
 	sp.CustomParsers["GRMM"] = func(s nmea.BaseSentence) (nmea.Sentence, error) {
 		p := nmea.NewParser(s)
 		return GRMM{
@@ -30,7 +30,7 @@ func TestDateTime(t *testing.T) {
 func (s *OsmlnmeaSuite) TestRegistrationCheck() {
 	s.NotNil(sp)
 
-	s.Equal(8, len(sp.CustomParsers))
+	s.Equal(9, len(sp.CustomParsers))
 
 	_, ok := sp.CustomParsers["OSMST"]
 	s.True(ok)
@@ -44,6 +44,8 @@ func (s *OsmlnmeaSuite) TestRegistrationCheck() {
 	s.True(ok)
 	_, ok = sp.CustomParsers["OSMVCC"]
 	s.True(ok)
+	_, ok = sp.CustomParsers["OSMHR"]
+	s.True(ok)
 	_, ok = sp.CustomParsers["GRMM"]
 	s.True(ok)
 	_, ok = sp.CustomParsers["GRMZ"]
@@ -69,6 +71,7 @@ func (s *OsmlnmeaSuite) TestOSMLSentence() {
 		{line: "$POSMACC,168,10428,13928*5D", nmeatype: OSMACC{}},
 		{line: "$POSMVCC,4940*72", nmeatype: OSMVCC{}},
 		{line: "$POSMSO,Reason: times up*4C", nmeatype: OSMSO{}},
+		{line: "$POSMHR,72*32", nmeatype: OSMHR{}},
 	}
 
 	for _, tt := range myTests {
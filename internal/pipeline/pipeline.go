@@ -0,0 +1,314 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/willie68/osmltools/internal/export/registry"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// TypeBackup backup of the sd card into the output folder
+	TypeBackup = "backup"
+	// TypeCheck check of the sd card, the cleaned up files and the report are written to the output folder
+	TypeCheck = "check"
+	// TypeTouch sets the modification time of the data files to their first time stamp
+	TypeTouch = "touch"
+	// TypeTrack a new track file with the data files
+	TypeTrack = "track"
+	// TypeExport export of the sd card or a track
+	TypeExport = "export"
+)
+
+var (
+	// Types all step types
+	Types = []string{TypeBackup, TypeCheck, TypeTouch, TypeTrack, TypeExport}
+	// ErrInvalidPipeline the pipeline file is not valid
+	ErrInvalidPipeline = errors.New("invalid pipeline")
+)
+
+// Pipeline a processing pipeline, the steps are executed in the order of their dependencies
+type Pipeline struct {
+	Name string `yaml:"name" json:"name"`
+	// SDCard the default sd card folder of the steps
+	SDCard string `yaml:"sdcard,omitempty" json:"sdcard,omitempty"`
+	// WorkDir the outputs are relative to this folder, the inputs are relative to the pipeline file. Default is the
+	// folder of the pipeline file
+	WorkDir string  `yaml:"workdir,omitempty" json:"workdir,omitempty"`
+	Steps   []*Step `yaml:"steps" json:"steps"`
+
+	// file the pipeline file
+	file string
+}
+
+// Step a single step of the pipeline
+type Step struct {
+	ID   string `yaml:"id" json:"id"`
+	Type string `yaml:"type" json:"type"`
+	// Needs the ids of the steps which must be executed before
+	Needs []string `yaml:"needs,omitempty" json:"needs,omitempty"`
+	// SDCard the sd card folder, default is the sd card of the pipeline
+	SDCard string `yaml:"sdcard,omitempty" json:"sdcard,omitempty"`
+	// Files the files of the sd card, default are all data files
+	Files []string `yaml:"files,omitempty" json:"files,omitempty"`
+	// Input the id of the track step, whose track is exported
+	Input string `yaml:"input,omitempty" json:"input,omitempty"`
+	// Track the track file to create (output) or to export (input)
+	Track string `yaml:"track,omitempty" json:"track,omitempty"`
+	// Output the output folder, on export of a track the output file
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+
+	// Report check: write the report.json
+	Report bool `yaml:"report,omitempty" json:"report,omitempty"`
+	// Overwrite check: overwrite the cleaned up files
+	Overwrite bool `yaml:"overwrite,omitempty" json:"overwrite,omitempty"`
+
+	// Name track: the name of the track, export: the name of the exported track
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Description track: the description of the track
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// VesselID track: the vessel of the track
+	VesselID int32 `yaml:"vesselId,omitempty" json:"vesselId,omitempty"`
+
+	// Filter track: trims the track, export: trims the exported data
+	Filter *Filter `yaml:"filter,omitempty" json:"filter,omitempty"`
+	// Format export: the export format, default NMEA
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+	// Options export: format specific options <format>.<name>=<value>
+	Options []string `yaml:"options,omitempty" json:"options,omitempty"`
+	// SingleFile export: all tracks into one file
+	SingleFile bool `yaml:"singleFile,omitempty" json:"singleFile,omitempty"`
+	// Series export: the sensor time series, comma separated
+	Series string `yaml:"series,omitempty" json:"series,omitempty"`
+	// Simplify export: the simplification of the track
+	Simplify *Simplify `yaml:"simplify,omitempty" json:"simplify,omitempty"`
+	// Depth export: the depth reduction
+	Depth *Depth `yaml:"depth,omitempty" json:"depth,omitempty"`
+	// NoPrivacy export: the privacy zones of the user config are not applied
+	NoPrivacy bool `yaml:"noPrivacy,omitempty" json:"noPrivacy,omitempty"`
+}
+
+// Filter the time range and the clip area
+type Filter struct {
+	// From, To UTC, e.g. "2016-09-11 10:15:00"
+	From string `yaml:"from,omitempty" json:"from,omitempty"`
+	To   string `yaml:"to,omitempty" json:"to,omitempty"`
+	// Clip geojson file with polygon(s)
+	Clip string `yaml:"clip,omitempty" json:"clip,omitempty"`
+}
+
+// Simplify the simplification of the track, like the flags of the export
+type Simplify struct {
+	Tolerance         float64       `yaml:"tolerance,omitempty" json:"tolerance,omitempty"`
+	Interval          time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Distance          float64       `yaml:"distance,omitempty" json:"distance,omitempty"`
+	CornerAngle       *float64      `yaml:"cornerAngle,omitempty" json:"cornerAngle,omitempty"`
+	KeepDepthExtremes *bool         `yaml:"keepDepthExtremes,omitempty" json:"keepDepthExtremes,omitempty"`
+}
+
+// Depth the depth reduction, like the flags of the export
+type Depth struct {
+	// Vessel use the sensor config of this vessel, default is the vessel of the track
+	Vessel int32 `yaml:"vessel,omitempty" json:"vessel,omitempty"`
+	// Reference transducer, waterline or keel
+	Reference string `yaml:"reference,omitempty" json:"reference,omitempty"`
+	// WaterLevel csv file with time and water level above chart datum
+	WaterLevel string `yaml:"waterLevel,omitempty" json:"waterLevel,omitempty"`
+}
+
+// Load reads and validates the pipeline file, unknown fields are rejected
+func Load(file string) (*Pipeline, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var p Pipeline
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPipeline, file, err)
+	}
+	p.file, err = filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPipeline, file, err)
+	}
+	return &p, nil
+}
+
+// Dir the work folder of the pipeline
+func (p *Pipeline) Dir() string {
+	dir := filepath.Dir(p.file)
+	if p.WorkDir == "" {
+		return dir
+	}
+	if filepath.IsAbs(p.WorkDir) {
+		return p.WorkDir
+	}
+	return filepath.Join(dir, p.WorkDir)
+}
+
+// input the path of an input file relative to the folder of the pipeline file
+func (p *Pipeline) input(f string) string {
+	if f == "" || filepath.IsAbs(f) {
+		return f
+	}
+	return filepath.Join(filepath.Dir(p.file), f)
+}
+
+// output the path of an output relative to the work folder
+func (p *Pipeline) output(f string) string {
+	if f == "" || filepath.IsAbs(f) {
+		return f
+	}
+	return filepath.Join(p.Dir(), f)
+}
+
+// step the step with the id
+func (p *Pipeline) step(id string) *Step {
+	for _, s := range p.Steps {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// sdcard the sd card folder of the step
+func (p *Pipeline) sdcard(s *Step) string {
+	if s.SDCard != "" {
+		return p.input(s.SDCard)
+	}
+	return p.input(p.SDCard)
+}
+
+// dependencies the ids of the steps the step depends on
+func (s *Step) dependencies() []string {
+	deps := slices.Clone(s.Needs)
+	if s.Input != "" && !slices.Contains(deps, s.Input) {
+		deps = append(deps, s.Input)
+	}
+	return deps
+}
+
+func (p *Pipeline) validate() error {
+	if len(p.Steps) == 0 {
+		return errors.New("no steps")
+	}
+	ids := make(map[string]bool)
+	for i, s := range p.Steps {
+		if s.ID == "" {
+			return fmt.Errorf("step %d has no id", i+1)
+		}
+		if ids[s.ID] {
+			return fmt.Errorf("step id %s is not unique", s.ID)
+		}
+		ids[s.ID] = true
+	}
+	for _, s := range p.Steps {
+		if err := p.validateStep(s); err != nil {
+			return fmt.Errorf("step %s: %w", s.ID, err)
+		}
+	}
+	_, err := p.Order()
+	return err
+}
+
+func (p *Pipeline) validateStep(s *Step) error {
+	if !slices.Contains(Types, s.Type) {
+		return fmt.Errorf("unknown type %q, available: %s", s.Type, strings.Join(Types, ", "))
+	}
+	for _, n := range s.dependencies() {
+		if p.step(n) == nil {
+			return fmt.Errorf("unknown step %s", n)
+		}
+		if n == s.ID {
+			return errors.New("the step depends on itself")
+		}
+	}
+	card := p.sdcard(s) != ""
+	switch s.Type {
+	case TypeBackup:
+		if !card || s.Output == "" {
+			return errors.New("sdcard and output are needed")
+		}
+	case TypeCheck, TypeTouch:
+		if !card {
+			return errors.New("sdcard is needed")
+		}
+	case TypeTrack:
+		if !card || s.Track == "" {
+			return errors.New("sdcard and track are needed")
+		}
+	case TypeExport:
+		return p.validateExport(s, card)
+	}
+	return nil
+}
+
+func (p *Pipeline) validateExport(s *Step, card bool) error {
+	if s.Output == "" {
+		return errors.New("output is needed")
+	}
+	if s.Format != "" {
+		if _, ok := registry.Get(strings.ToUpper(s.Format)); !ok {
+			return fmt.Errorf("the format %s is not supported. Supported formats are: %v", s.Format, registry.Names())
+		}
+	}
+	if s.Input != "" && s.Track != "" {
+		return errors.New("only one of input and track can be used")
+	}
+	if s.Input != "" && p.step(s.Input).Type != TypeTrack {
+		return fmt.Errorf("the input %s is no track step", s.Input)
+	}
+	if s.Input == "" && s.Track == "" && !card {
+		return errors.New("input, track or sdcard is needed")
+	}
+	return nil
+}
+
+// Order the steps in the order of their dependencies, independent steps keep the order of the file
+func (p *Pipeline) Order() ([]*Step, error) {
+	done := make(map[string]bool)
+	order := make([]*Step, 0, len(p.Steps))
+	for len(order) < len(p.Steps) {
+		added := false
+		for _, s := range p.Steps {
+			if done[s.ID] {
+				continue
+			}
+			ready := true
+			for _, d := range s.dependencies() {
+				ready = ready && done[d]
+			}
+			if ready {
+				done[s.ID] = true
+				order = append(order, s)
+				added = true
+				break
+			}
+		}
+		if !added {
+			open := make([]string, 0)
+			for _, s := range p.Steps {
+				if !done[s.ID] {
+					open = append(open, s.ID)
+				}
+			}
+			return nil, fmt.Errorf("cyclic dependencies between the steps %s", strings.Join(open, ", "))
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,214 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/backup"
+	"github.com/willie68/osmltools/internal/check"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/export"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/track"
+)
+
+const sdcard = "../../testdata/sdcard"
+
+const testPipeline = `name: weekend
+sdcard: card
+workdir: out
+steps:
+  - id: gpx
+    type: export
+    input: trk
+    format: gpx
+    output: export/weekend.gpx
+    simplify:
+      tolerance: 5
+      interval: 10s
+    depth:
+      reference: keel
+  - id: bck
+    type: backup
+    output: backup
+  - id: touch
+    type: touch
+  - id: chk
+    type: check
+    needs: [touch]
+    output: check
+    report: true
+    overwrite: true
+  - id: trk
+    type: track
+    needs: [chk]
+    track: tracks/weekend.zip
+    description: the weekend trip
+    filter:
+      from: "2016-09-11 10:30:00"
+`
+
+type PipelineSuite struct {
+	suite.Suite
+	r   *runner
+	dir string
+	cfg string
+}
+
+func TestPipelineSuite(t *testing.T) {
+	suite.Run(t, new(PipelineSuite))
+}
+
+func (s *PipelineSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.cfg = config.UserConfigFile
+	config.UserConfigFile = filepath.Join(s.dir, "config.json")
+	inj := do.New()
+	check.Init(inj)
+	config.Init(inj)
+	importer.Init(inj)
+	export.Init(inj)
+	backup.Init(inj)
+	track.Init(inj)
+	Init(inj)
+	s.r = do.MustInvoke[*runner](inj)
+
+	card := filepath.Join(s.dir, "card")
+	s.Require().NoError(os.MkdirAll(card, os.ModePerm))
+	for _, f := range []string{"DATA001231.DAT", "DATA001232.DAT"} {
+		data, err := os.ReadFile(filepath.Join(sdcard, f))
+		s.Require().NoError(err)
+		s.Require().NoError(os.WriteFile(filepath.Join(card, f), data, 0o600))
+	}
+}
+
+func (s *PipelineSuite) TearDownTest() {
+	config.UserConfigFile = s.cfg
+}
+
+func (s *PipelineSuite) write(content string) string {
+	fn := filepath.Join(s.dir, "weekend.yaml")
+	s.Require().NoError(os.WriteFile(fn, []byte(content), 0o600))
+	return fn
+}
+
+func (s *PipelineSuite) run(file string, opts Options) *Report {
+	rep, err := s.r.Run(context.Background(), file, opts)
+	s.Require().NoError(err)
+	return rep
+}
+
+// status the status of the steps by id
+func status(rep *Report) map[string]string {
+	st := make(map[string]string)
+	for _, sr := range rep.Steps {
+		st[sr.ID] = sr.Status
+	}
+	return st
+}
+
+func (s *PipelineSuite) TestLoad() {
+	p, err := Load(s.write(testPipeline))
+	s.Require().NoError(err)
+	s.Equal("weekend", p.Name)
+	s.Equal(filepath.Join(s.dir, "out"), p.Dir())
+	s.Equal(filepath.Join(s.dir, "card"), p.sdcard(p.step("trk")))
+	s.Equal(int64(10e9), int64(p.step("gpx").Simplify.Interval))
+
+	order, err := p.Order()
+	s.Require().NoError(err)
+	ids := make([]string, 0)
+	for _, st := range order {
+		ids = append(ids, st.ID)
+	}
+	s.Equal([]string{"bck", "touch", "chk", "trk", "gpx"}, ids)
+
+	invalid := map[string]string{
+		"unknown field":  "steps:\n  - id: a\n    type: check\n    sdcard: card\n    unknown: 1\n",
+		"unknown type":   "steps:\n  - id: a\n    type: format\n    sdcard: card\n",
+		"unknown need":   "steps:\n  - id: a\n    type: check\n    sdcard: card\n    needs: [b]\n",
+		"cycle":          "sdcard: card\nsteps:\n  - id: a\n    type: check\n    needs: [b]\n  - id: b\n    type: touch\n    needs: [a]\n",
+		"duplicate id":   "sdcard: card\nsteps:\n  - id: a\n    type: check\n  - id: a\n    type: touch\n",
+		"no output":      "sdcard: card\nsteps:\n  - id: a\n    type: backup\n",
+		"no card":        "steps:\n  - id: a\n    type: check\n",
+		"input no track": "sdcard: card\nsteps:\n  - id: a\n    type: check\n  - id: b\n    type: export\n    input: a\n    output: x.gpx\n",
+		"format":         "sdcard: card\nsteps:\n  - id: a\n    type: export\n    format: doc\n    output: x\n",
+		"no steps":       "name: empty\n",
+	}
+	for name, content := range invalid {
+		_, err := Load(s.write(content))
+		s.ErrorIs(err, ErrInvalidPipeline, name)
+	}
+}
+
+func (s *PipelineSuite) TestRun() {
+	fn := s.write(testPipeline)
+	rep := s.run(fn, Options{})
+	s.Equal(5, rep.Done, rep.Steps)
+	s.Zero(rep.Failed)
+	out := filepath.Join(s.dir, "out")
+	s.FileExists(filepath.Join(out, "check", "report.json"))
+	s.FileExists(filepath.Join(out, "tracks", "weekend.zip"))
+	s.FileExists(filepath.Join(out, "export", "weekend.gpx"))
+	s.FileExists(filepath.Join(out, "weekend.report.json"))
+	s.FileExists(filepath.Join(out, "weekend.state.json"))
+
+	// nothing changed
+	rep = s.run(fn, Options{})
+	s.Equal(5, rep.UpToDate)
+
+	// a changed step and the steps depending on it are executed again
+	finished := make([]string, 0)
+	rep, err := s.r.Run(context.Background(), s.write(testPipeline+"    name: changed\n"), Options{
+		DryRun:   true,
+		Finished: func(sr StepResult) { finished = append(finished, sr.ID) },
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"bck", "touch", "chk", "trk", "gpx"}, finished)
+	s.Equal(map[string]string{"bck": StatusUpToDate, "touch": StatusUpToDate, "chk": StatusUpToDate, "trk": StatusPending, "gpx": StatusPending}, status(rep))
+	rep = s.run(fn, Options{})
+	s.Equal(2, rep.Done)
+	s.Equal(3, rep.UpToDate)
+
+	// a missing output
+	s.Require().NoError(os.Remove(filepath.Join(out, "export", "weekend.gpx")))
+	rep = s.run(fn, Options{})
+	s.Equal(StatusDone, status(rep)["gpx"])
+	s.Equal(1, rep.Done)
+
+	rep = s.run(fn, Options{Force: true})
+	s.Equal(5, rep.Done)
+}
+
+func (s *PipelineSuite) TestResume() {
+	fn := s.write(`sdcard: card
+steps:
+  - id: trk
+    type: track
+    track: weekend.zip
+    filter:
+      clip: clip.geojson
+  - id: kml
+    type: export
+    input: trk
+    format: kml
+    output: weekend.kml
+  - id: nmea
+    type: export
+    output: nmea
+`)
+	rep := s.run(fn, Options{})
+	s.Equal(map[string]string{"trk": StatusFailed, "kml": StatusBlocked, "nmea": StatusDone}, status(rep))
+	s.Contains(rep.Steps[0].Error, "clip.geojson")
+	s.DirExists(filepath.Join(s.dir, "nmea"))
+
+	clip := `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,40],[20,40],[20,60],[0,60],[0,40]]]}}]}`
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "clip.geojson"), []byte(clip), 0o600))
+	rep = s.run(fn, Options{})
+	s.Equal(map[string]string{"trk": StatusDone, "kml": StatusDone, "nmea": StatusUpToDate}, status(rep))
+	s.FileExists(filepath.Join(s.dir, "weekend.kml"))
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// StatusDone the step was executed
+	StatusDone = "done"
+	// StatusUpToDate the step was skipped, nothing changed since the last run
+	StatusUpToDate = "up-to-date"
+	// StatusPending the step would be executed, only on a dry run
+	StatusPending = "pending"
+	// StatusFailed the step failed
+	StatusFailed = "failed"
+	// StatusBlocked the step was not executed, a step it depends on has failed or the run was cancelled
+	StatusBlocked = "blocked"
+)
+
+// reportFile the summary report in the work folder, <name>.report.json
+const reportFile = "%s.report.json"
+
+// Report the summary of a pipeline run
+type Report struct {
+	Name     string       `json:"name"`
+	File     string       `json:"file"`
+	WorkDir  string       `json:"workdir"`
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished"`
+	DryRun   bool         `json:"dryRun,omitempty"`
+	Steps    []StepResult `json:"steps"`
+	Done     int          `json:"done"`
+	UpToDate int          `json:"upToDate"`
+	Pending  int          `json:"pending"`
+	Failed   int          `json:"failed"`
+	Blocked  int          `json:"blocked"`
+}
+
+// StepResult the result of a step
+type StepResult struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Outputs  []string      `json:"outputs,omitempty"`
+	Messages []string      `json:"messages,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// add adds the result and counts the status
+func (r *Report) add(sr StepResult) {
+	r.Steps = append(r.Steps, sr)
+	switch sr.Status {
+	case StatusDone:
+		r.Done++
+	case StatusUpToDate:
+		r.UpToDate++
+	case StatusPending:
+		r.Pending++
+	case StatusFailed:
+		r.Failed++
+	case StatusBlocked:
+		r.Blocked++
+	}
+}
+
+// write writes the report as json file
+func (r *Report) write(file string) error {
+	js, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(file, js, 0o600)
+}
@@ -0,0 +1,438 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/export"
+	"github.com/willie68/osmltools/internal/geo"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/osml"
+)
+
+// Options options of a pipeline run
+type Options struct {
+	// Force all steps are executed, even if they are up to date
+	Force bool
+	// DryRun only the status of the steps is evaluated, nothing is executed
+	DryRun bool
+	// Finished is called after each step
+	Finished func(sr StepResult)
+}
+
+type checkerSrv interface {
+	Check(sdCardFolder, outputFolder string, overwrite, report bool) (*model.CheckResult, error)
+	Touch(sdCardFolder string, files []string) (*model.GeneralResult, error)
+}
+
+type exporterSrv interface {
+	Export(sdCardFolder, outputFolder string, files []string, format, name string, opts model.ExportOptions) error
+	ExportTrack(trackfile, outputfile, format string, opts model.ExportOptions) error
+}
+
+type trackManager interface {
+	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
+	TrimTrack(trackfile string, trim *model.Trim) (*model.Track, error)
+}
+
+type backupSrv interface {
+	Backup(sdCardFolder, outputFolder string) (string, error)
+}
+
+// runner executes the pipelines with the services
+type runner struct {
+	log logging.Logger
+	chk checkerSrv
+	exp exporterSrv
+	tm  trackManager
+	bck backupSrv
+	cfg *config.UserConfig
+}
+
+// Init registers the pipeline service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(inj do.Injector) (*runner, error) {
+		cfg, err := do.Invoke[*config.UserConfig](inj)
+		if err != nil {
+			return nil, err
+		}
+		return &runner{
+			log: *logging.New().WithName("Pipeline"),
+			chk: do.MustInvokeAs[checkerSrv](inj),
+			exp: do.MustInvokeAs[exporterSrv](inj),
+			tm:  do.MustInvokeAs[trackManager](inj),
+			bck: do.MustInvokeAs[backupSrv](inj),
+			cfg: cfg,
+		}, nil
+	})
+}
+
+// Run executes the steps of the pipeline file in the order of their dependencies. Steps which are up to date are
+// skipped, so a failed or cancelled run is resumed by running the pipeline again. Steps depending on a failed step
+// are blocked, the other steps are executed. The state and the summary report are written to the work folder.
+func (r *runner) Run(ctx context.Context, file string, opts Options) (*Report, error) {
+	p, err := Load(file)
+	if err != nil {
+		return nil, err
+	}
+	order, err := p.Order()
+	if err != nil {
+		return nil, err
+	}
+	dir := p.Dir()
+	st, err := loadState(filepath.Join(dir, fmt.Sprintf(stateFile, p.Name)))
+	if err != nil {
+		return nil, err
+	}
+	rep := &Report{
+		Name:    p.Name,
+		File:    p.file,
+		WorkDir: dir,
+		Started: time.Now(),
+		DryRun:  opts.DryRun,
+		Steps:   make([]StepResult, 0, len(order)),
+	}
+	r.log.Infof("running pipeline %s with %d steps in %s", p.Name, len(order), dir)
+
+	fps := make(map[string]string)
+	status := make(map[string]string)
+	for _, s := range order {
+		sr := r.step(ctx, p, s, st, fps, status, opts)
+		status[s.ID] = sr.Status
+		rep.add(sr)
+		if opts.Finished != nil {
+			opts.Finished(sr)
+		}
+	}
+	rep.Finished = time.Now()
+	if opts.DryRun {
+		return rep, nil
+	}
+	return rep, rep.write(filepath.Join(dir, fmt.Sprintf(reportFile, p.Name)))
+}
+
+// step evaluates the status of the step and executes it
+func (r *runner) step(ctx context.Context, p *Pipeline, s *Step, st *state, fps, status map[string]string, opts Options) StepResult {
+	sr := StepResult{ID: s.ID, Type: s.Type}
+	for _, d := range s.dependencies() {
+		if status[d] == StatusFailed || status[d] == StatusBlocked {
+			sr.Status = StatusBlocked
+			sr.Error = fmt.Sprintf("step %s was not successful", d)
+			return sr
+		}
+	}
+	if ctx.Err() != nil {
+		sr.Status = StatusBlocked
+		sr.Error = ctx.Err().Error()
+		return sr
+	}
+	fp, err := r.fingerprint(p, s, fps)
+	if err != nil {
+		sr.Status = StatusFailed
+		sr.Error = err.Error()
+		return sr
+	}
+	fps[s.ID] = fp
+	pending := slices.ContainsFunc(s.dependencies(), func(d string) bool { return status[d] == StatusPending })
+	if !opts.Force && !pending && st.upToDate(s.ID, fp) {
+		sr.Status = StatusUpToDate
+		sr.Outputs = st.Steps[s.ID].Outputs
+		return sr
+	}
+	if opts.DryRun {
+		sr.Status = StatusPending
+		return sr
+	}
+
+	r.log.Infof("executing step %s (%s)", s.ID, s.Type)
+	started := time.Now()
+	sr.Outputs, sr.Messages, err = r.execute(p, s)
+	sr.Duration = time.Since(started)
+	if err != nil {
+		r.log.Errorf("step %s failed: %v", s.ID, err)
+		sr.Status = StatusFailed
+		sr.Error = err.Error()
+		delete(st.Steps, s.ID)
+	} else {
+		sr.Status = StatusDone
+		st.Steps[s.ID] = stepState{
+			Fingerprint: fp,
+			Finished:    time.Now(),
+			Outputs:     sr.Outputs,
+		}
+	}
+	if err := st.save(); err != nil {
+		r.log.Errorf("can't save the state: %v", err)
+	}
+	return sr
+}
+
+// fingerprint of the step: the definition, the input files, the user config used and the steps it depends on
+func (r *runner) fingerprint(p *Pipeline, s *Step, fps map[string]string) (string, error) {
+	f := newFingerprint()
+	if err := f.add(s); err != nil {
+		return "", err
+	}
+	for _, d := range s.dependencies() {
+		if err := f.add(d + "=" + fps[d]); err != nil {
+			return "", err
+		}
+	}
+	if usesCard(s) {
+		files, err := cardFiles(p.sdcard(s), s.Files)
+		if err != nil {
+			return "", err
+		}
+		for i, fn := range files {
+			files[i] = filepath.Join(p.sdcard(s), fn)
+		}
+		if err := f.addFiles(files...); err != nil {
+			return "", err
+		}
+	}
+	if s.Type == TypeExport && s.Track != "" {
+		if err := f.addFiles(p.input(s.Track)); err != nil {
+			return "", err
+		}
+	}
+	if s.Filter != nil {
+		if err := f.addFiles(p.input(s.Filter.Clip)); err != nil {
+			return "", err
+		}
+	}
+	if s.Depth != nil {
+		if err := f.addFiles(p.input(s.Depth.WaterLevel)); err != nil {
+			return "", err
+		}
+	}
+	if s.Type == TypeExport {
+		if err := f.add(r.cfg.Vessels); err != nil {
+			return "", err
+		}
+		if !s.NoPrivacy {
+			if err := f.add(r.cfg.PrivacyZones); err != nil {
+				return "", err
+			}
+		}
+	}
+	return f.String(), nil
+}
+
+// usesCard the step reads the data of the sd card
+func usesCard(s *Step) bool {
+	return s.Type != TypeExport || (s.Input == "" && s.Track == "")
+}
+
+// cardFiles the files of the step, default are all data files of the card
+func cardFiles(sdcard string, files []string) ([]string, error) {
+	if len(files) > 0 {
+		return slices.Clone(files), nil
+	}
+	dfs, err := osml.GetDataFiles(sdcard)
+	if err != nil {
+		return nil, err
+	}
+	for i, df := range dfs {
+		dfs[i] = filepath.Base(df)
+	}
+	return dfs, nil
+}
+
+// execute executes the step and returns the outputs
+func (r *runner) execute(p *Pipeline, s *Step) (outputs, messages []string, err error) {
+	card := p.sdcard(s)
+	out := p.output(s.Output)
+	switch s.Type {
+	case TypeBackup:
+		name, err := r.bck.Backup(card, out)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []string{filepath.Join(out, name)}, nil, nil
+	case TypeCheck:
+		res, err := r.chk.Check(card, out, s.Overwrite, s.Report)
+		if err != nil {
+			return nil, nil, err
+		}
+		res.Calc()
+		msg := fmt.Sprintf("%d files checked with %d errors and %d warnings", len(res.Files), res.ErrorCount, res.WarningCount)
+		if out == "" {
+			return nil, []string{msg}, nil
+		}
+		return []string{out}, []string{msg}, nil
+	case TypeTouch:
+		res, err := r.chk.Touch(card, s.Files)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !res.Result {
+			return nil, res.Messages, errors.New("not all files could be touched")
+		}
+		return nil, res.Messages, nil
+	case TypeTrack:
+		return r.track(p, s)
+	case TypeExport:
+		return r.export(p, s)
+	}
+	return nil, nil, fmt.Errorf("unknown type %s", s.Type)
+}
+
+// track creates the track file new, an existing file is replaced
+func (r *runner) track(p *Pipeline, s *Step) ([]string, []string, error) {
+	card := p.sdcard(s)
+	tf := p.output(s.Track)
+	files, err := cardFiles(card, s.Files)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no data files found in %s", card)
+	}
+	trim, err := r.trim(p, s.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.Remove(tf); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	name := s.Name
+	if name == "" {
+		name = s.ID
+	}
+	err = r.tm.NewTrack(card, files, tf, model.Track{
+		Name:        name,
+		Description: s.Description,
+		VesselID:    s.VesselID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs := []string{fmt.Sprintf("track %s with %d files", name, len(files))}
+	if trim != nil {
+		if _, err := r.tm.TrimTrack(tf, trim); err != nil {
+			return nil, nil, err
+		}
+		msgs = append(msgs, "track trimmed")
+	}
+	return []string{tf}, msgs, nil
+}
+
+// export exports the track of the input step, the track file or the sd card
+func (r *runner) export(p *Pipeline, s *Step) ([]string, []string, error) {
+	format := export.NMEAFormat
+	if s.Format != "" {
+		format = strings.ToUpper(strings.TrimSpace(s.Format))
+	}
+	opts, err := r.exportOptions(p, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := p.output(s.Output)
+	tf := p.input(s.Track)
+	if s.Input != "" {
+		tf = p.output(p.step(s.Input).Track)
+	}
+	if tf != "" {
+		err = r.exp.ExportTrack(tf, out, format, opts)
+	} else {
+		err = r.exp.Export(p.sdcard(s), out, s.Files, format, s.Name, opts)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{out}, []string{fmt.Sprintf("exported as %s", format)}, nil
+}
+
+// exportOptions the export options of the step, the privacy zones and the vessels are taken from the user config
+func (r *runner) exportOptions(p *Pipeline, s *Step) (model.ExportOptions, error) {
+	opts := model.ExportOptions{
+		FormatOptions: s.Options,
+		SingleFile:    s.SingleFile,
+		Vessels:       r.cfg.Vessels,
+	}
+	if !s.NoPrivacy {
+		opts.PrivacyZones = r.cfg.PrivacyZones
+	}
+	var err error
+	if opts.Trim, err = r.trim(p, s.Filter); err != nil {
+		return opts, err
+	}
+	if opts.Simplification, err = s.Simplify.simplification(); err != nil {
+		return opts, err
+	}
+	if s.Series != "" {
+		if opts.Series, err = model.ParseSeries(s.Series); err != nil {
+			return opts, err
+		}
+	}
+	if s.Depth != nil {
+		opts.VesselID = s.Depth.Vessel
+		opts.DepthReference = s.Depth.Reference
+		if s.Depth.WaterLevel != "" {
+			if opts.WaterLevels, err = model.ReadWaterLevels(p.input(s.Depth.WaterLevel)); err != nil {
+				return opts, err
+			}
+		}
+	}
+	return opts, nil
+}
+
+// trim the trim of the filter, nil if nothing is set
+func (r *runner) trim(p *Pipeline, f *Filter) (*model.Trim, error) {
+	if f == nil {
+		return nil, nil
+	}
+	t := &model.Trim{}
+	var err error
+	if t.From, err = model.ParseTrimTime(f.From); err != nil {
+		return nil, err
+	}
+	if t.To, err = model.ParseTrimTime(f.To); err != nil {
+		return nil, err
+	}
+	if f.Clip != "" {
+		if t.Clip, err = geo.ReadPolygons(p.input(f.Clip)); err != nil {
+			return nil, fmt.Errorf("can't read clip area %s: %w", f.Clip, err)
+		}
+	}
+	if t.IsEmpty() {
+		return nil, nil
+	}
+	return t, t.Validate()
+}
+
+// simplification the simplification with the defaults of the flags, nil for no simplification
+func (s *Simplify) simplification() (*model.Simplification, error) {
+	if s == nil {
+		return nil, nil
+	}
+	ms := &model.Simplification{
+		Tolerance:         s.Tolerance,
+		Interval:          s.Interval,
+		Distance:          s.Distance,
+		CornerAngle:       model.DefaultCornerAngle,
+		KeepDepthExtremes: true,
+	}
+	if s.CornerAngle != nil {
+		ms.CornerAngle = *s.CornerAngle
+	}
+	if s.KeepDepthExtremes != nil {
+		ms.KeepDepthExtremes = *s.KeepDepthExtremes
+	}
+	if err := ms.Validate(); err != nil {
+		return nil, err
+	}
+	if ms.IsEmpty() {
+		return nil, nil
+	}
+	return ms, nil
+}
@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// stateFile the state of the steps in the work folder, <name>.state.json
+const stateFile = "%s.state.json"
+
+// stepState the state of a finished step
+type stepState struct {
+	Fingerprint string    `json:"fingerprint"`
+	Finished    time.Time `json:"finished"`
+	Outputs     []string  `json:"outputs"`
+}
+
+// state the finished steps of the pipeline, a step is up to date, if its fingerprint is unchanged and all outputs exist
+type state struct {
+	file  string
+	Steps map[string]stepState `json:"steps"`
+}
+
+func loadState(file string) (*state, error) {
+	st := &state{
+		file:  file,
+		Steps: make(map[string]stepState),
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// save writes the state atomically
+func (st *state) save() error {
+	js, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.file), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := st.file + ".tmp"
+	if err := os.WriteFile(tmp, js, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.file)
+}
+
+// upToDate the step has run with the same fingerprint and all outputs exist
+func (st *state) upToDate(id, fp string) bool {
+	ss, ok := st.Steps[id]
+	if !ok || ss.Fingerprint != fp {
+		return false
+	}
+	for _, o := range ss.Outputs {
+		if _, err := os.Stat(o); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint hashes the definition of the step, the content of the input files and the fingerprints of the steps
+// it depends on
+type fingerprint struct {
+	h hash.Hash
+}
+
+func newFingerprint() *fingerprint {
+	return &fingerprint{h: sha256.New()}
+}
+
+func (f *fingerprint) add(v any) error {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = f.h.Write(append(js, '\n'))
+	return err
+}
+
+// addFiles adds the names and the content of the files
+func (f *fingerprint) addFiles(files ...string) error {
+	sort.Strings(files)
+	for _, fn := range files {
+		if fn == "" {
+			continue
+		}
+		if _, err := io.WriteString(f.h, fn+"\n"); err != nil {
+			return err
+		}
+		if err := hashFile(f.h, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fingerprint) String() string {
+	return hex.EncodeToString(f.h.Sum(nil))
+}
+
+func hashFile(w io.Writer, fn string) error {
+	file, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -0,0 +1,150 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// Canvas a simple rgba image with some drawing primitives, pure go without any font or graphics library
+type Canvas struct {
+	*image.RGBA
+}
+
+// New creates a new canvas filled with the background colour
+func New(width, height int, bg color.Color) *Canvas {
+	c := &Canvas{
+		RGBA: image.NewRGBA(image.Rect(0, 0, width, height)),
+	}
+	draw.Draw(c.RGBA, c.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	return c
+}
+
+// FillRect fills the rectangle x0,y0 (inclusive) to x1,y1 (exclusive)
+func (c *Canvas) FillRect(x0, y0, x1, y1 int, col color.Color) {
+	draw.Draw(c.RGBA, image.Rect(x0, y0, x1, y1), image.NewUniform(col), image.Point{}, draw.Over)
+}
+
+// StrokeRect draws the border of the rectangle with a width of one pixel
+func (c *Canvas) StrokeRect(x0, y0, x1, y1 int, col color.Color) {
+	c.FillRect(x0, y0, x1, y0+1, col)
+	c.FillRect(x0, y1-1, x1, y1, col)
+	c.FillRect(x0, y0, x0+1, y1, col)
+	c.FillRect(x1-1, y0, x1, y1, col)
+}
+
+// FillCircle fills a circle with the center cx, cy and radius r
+func (c *Canvas) FillCircle(cx, cy, r float64, col color.Color) {
+	if r < 0.5 {
+		r = 0.5
+	}
+	x0, x1 := int(math.Floor(cx-r)), int(math.Ceil(cx+r))
+	y0, y1 := int(math.Floor(cy-r)), int(math.Ceil(cy+r))
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy <= r*r {
+				c.blend(x, y, col)
+			}
+		}
+	}
+}
+
+// Line draws a line with the given width. The line is drawn as a row of overlapping circles.
+func (c *Canvas) Line(x0, y0, x1, y1, width float64, col color.Color) {
+	r := width / 2
+	l := math.Hypot(x1-x0, y1-y0)
+	steps := int(math.Ceil(l*2)) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		c.FillCircle(x0+(x1-x0)*t, y0+(y1-y0)*t, r, col)
+	}
+}
+
+// FillPolygon fills a polygon with the even-odd rule
+func (c *Canvas) FillPolygon(pts [][2]float64, col color.Color) {
+	c.FillRings([][][2]float64{pts}, col)
+}
+
+// FillRings fills several rings as one shape with the even-odd rule, e.g. a polygon with holes
+func (c *Canvas) FillRings(rings [][][2]float64, col color.Color) {
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, ring := range rings {
+		if len(ring) < 3 {
+			continue
+		}
+		for _, p := range ring {
+			minY = math.Min(minY, p[1])
+			maxY = math.Max(maxY, p[1])
+		}
+	}
+	if math.IsInf(minY, 0) {
+		return
+	}
+	b := c.Bounds()
+	minY = math.Max(minY, float64(b.Min.Y))
+	maxY = math.Min(maxY, float64(b.Max.Y))
+	for y := int(math.Floor(minY)); y <= int(math.Ceil(maxY)); y++ {
+		sy := float64(y) + 0.5
+		xs := make([]float64, 0)
+		for _, pts := range rings {
+			if len(pts) < 3 {
+				continue
+			}
+			for i := range pts {
+				a, b := pts[i], pts[(i+1)%len(pts)]
+				if (a[1] <= sy) != (b[1] <= sy) {
+					xs = append(xs, a[0]+(sy-a[1])/(b[1]-a[1])*(b[0]-a[0]))
+				}
+			}
+		}
+		sortFloats(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			x0 := max(int(math.Round(xs[i])), b.Min.X)
+			x1 := min(int(math.Round(xs[i+1])), b.Max.X)
+			for x := x0; x < x1; x++ {
+				c.blend(x, y, col)
+			}
+		}
+	}
+}
+
+// EncodePNG writes the canvas as png
+func (c *Canvas) EncodePNG(w io.Writer) error {
+	return png.Encode(w, c.RGBA)
+}
+
+func (c *Canvas) blend(x, y int, col color.Color) {
+	if !(image.Point{X: x, Y: y}.In(c.Bounds())) {
+		return
+	}
+	sr, sg, sb, sa := col.RGBA()
+	if sa == 0xffff {
+		c.Set(x, y, col)
+		return
+	}
+	d := c.RGBAAt(x, y)
+	a := 0xffff - sa
+	c.SetRGBA(x, y, color.RGBA{
+		R: uint8((sr + uint32(d.R)*0x101*a/0xffff) >> 8),
+		G: uint8((sg + uint32(d.G)*0x101*a/0xffff) >> 8),
+		B: uint8((sb + uint32(d.B)*0x101*a/0xffff) >> 8),
+		A: uint8((sa + uint32(d.A)*0x101*a/0xffff) >> 8),
+	})
+}
+
+func sortFloats(fs []float64) {
+	if len(fs) > 16 {
+		sort.Float64s(fs)
+		return
+	}
+	for i := 1; i < len(fs); i++ {
+		for j := i; j > 0 && fs[j] < fs[j-1]; j-- {
+			fs[j], fs[j-1] = fs[j-1], fs[j]
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/willie68/osmltools/internal/raster"
+)
+
+// painter the drawing primitives of the map, all coordinates are pixels with y downwards
+type painter interface {
+	// Rings fills one shape out of several rings with the even-odd rule
+	Rings(rings [][][2]float64, fill color.RGBA)
+	// Polyline strokes an open line with round joins
+	Polyline(pts [][2]float64, width float64, stroke color.RGBA)
+	// Circle a filled circle with an outline, an outline width of 0 for none
+	Circle(cx, cy, r float64, fill color.RGBA, outline float64, stroke color.RGBA)
+	// Rect a filled rectangle
+	Rect(x0, y0, x1, y1 float64, fill color.RGBA)
+	// Text with the upper left corner at x, y, the height is GlyphHeight * scale
+	Text(x, y float64, s string, scale int, fill color.RGBA)
+}
+
+// svgPainter writes the primitives as svg elements
+type svgPainter struct {
+	buf           bytes.Buffer
+	width, height int
+}
+
+func newSVGPainter(width, height int) *svgPainter {
+	return &svgPainter{width: width, height: height}
+}
+
+func (p *svgPainter) Rings(rings [][][2]float64, fill color.RGBA) {
+	var d strings.Builder
+	for _, ring := range rings {
+		if len(ring) < 3 {
+			continue
+		}
+		for i, pt := range ring {
+			if i == 0 {
+				d.WriteString("M")
+			} else {
+				d.WriteString(" L")
+			}
+			d.WriteString(num(pt[0]) + " " + num(pt[1]))
+		}
+		d.WriteString(" Z ")
+	}
+	if d.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(&p.buf, "<path d=\"%s\" fill-rule=\"evenodd\" %s/>\n", strings.TrimSpace(d.String()), paint("fill", fill))
+}
+
+func (p *svgPainter) Polyline(pts [][2]float64, width float64, stroke color.RGBA) {
+	if len(pts) < 2 {
+		return
+	}
+	ps := make([]string, len(pts))
+	for i, pt := range pts {
+		ps[i] = num(pt[0]) + "," + num(pt[1])
+	}
+	fmt.Fprintf(&p.buf, "<polyline points=\"%s\" fill=\"none\" %s stroke-width=\"%s\" stroke-linejoin=\"round\" stroke-linecap=\"round\"/>\n",
+		strings.Join(ps, " "), paint("stroke", stroke), num(width))
+}
+
+func (p *svgPainter) Circle(cx, cy, r float64, fill color.RGBA, outline float64, stroke color.RGBA) {
+	st := ""
+	if outline > 0 {
+		st = fmt.Sprintf(" %s stroke-width=\"%s\"", paint("stroke", stroke), num(outline))
+	}
+	fmt.Fprintf(&p.buf, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\" %s%s/>\n", num(cx), num(cy), num(r), paint("fill", fill), st)
+}
+
+func (p *svgPainter) Rect(x0, y0, x1, y1 float64, fill color.RGBA) {
+	fmt.Fprintf(&p.buf, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" %s/>\n", num(x0), num(y0), num(x1-x0), num(y1-y0), paint("fill", fill))
+}
+
+func (p *svgPainter) Text(x, y float64, s string, scale int, fill color.RGBA) {
+	// the cap height of a sans serif font is about 0.72 em, the baseline is at the bottom of the glyphs
+	h := float64(raster.GlyphHeight * scale)
+	fmt.Fprintf(&p.buf, "<text x=\"%s\" y=\"%s\" font-family=\"sans-serif\" font-size=\"%s\" %s>%s</text>\n",
+		num(x), num(y+h), num(h/0.72), paint("fill", fill), html.EscapeString(s))
+}
+
+// WriteTo writes the complete svg document
+func (p *svgPainter) WriteTo(w io.Writer) (int64, error) {
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		p.width, p.height, p.width, p.height)
+	doc.Write(p.buf.Bytes())
+	doc.WriteString("</svg>\n")
+	return doc.WriteTo(w)
+}
+
+// pngPainter draws the primitives on a raster canvas
+type pngPainter struct {
+	c *raster.Canvas
+}
+
+func newPNGPainter(width, height int) *pngPainter {
+	return &pngPainter{c: raster.New(width, height, color.Transparent)}
+}
+
+func (p *pngPainter) Rings(rings [][][2]float64, fill color.RGBA) {
+	p.c.FillRings(rings, fill)
+}
+
+func (p *pngPainter) Polyline(pts [][2]float64, width float64, stroke color.RGBA) {
+	for i := 1; i < len(pts); i++ {
+		p.c.Line(pts[i-1][0], pts[i-1][1], pts[i][0], pts[i][1], width, stroke)
+	}
+}
+
+func (p *pngPainter) Circle(cx, cy, r float64, fill color.RGBA, outline float64, stroke color.RGBA) {
+	if outline > 0 {
+		p.c.FillCircle(cx, cy, r+outline/2, stroke)
+		r -= outline / 2
+	}
+	p.c.FillCircle(cx, cy, r, fill)
+}
+
+func (p *pngPainter) Rect(x0, y0, x1, y1 float64, fill color.RGBA) {
+	p.c.FillRect(int(x0+0.5), int(y0+0.5), int(x1+0.5), int(y1+0.5), fill)
+}
+
+func (p *pngPainter) Text(x, y float64, s string, scale int, fill color.RGBA) {
+	p.c.Text(int(x+0.5), int(y+0.5), s, scale, fill)
+}
+
+// num formats a coordinate with at most one decimal place
+func num(f float64) string {
+	return strconv.FormatFloat(float64(int64(f*10+0.5*sign(f)))/10, 'f', -1, 64)
+}
+
+func sign(f float64) float64 {
+	if f < 0 {
+		return -1
+	}
+	return 1
+}
+
+// paint the svg attribute of a colour, with opacity if the colour is transparent
+func paint(attr string, c color.RGBA) string {
+	if c.A == 0 {
+		return attr + "=\"none\""
+	}
+	// color.RGBA is alpha premultiplied
+	r, g, b := uint32(c.R)*0xff/uint32(c.A), uint32(c.G)*0xff/uint32(c.A), uint32(c.B)*0xff/uint32(c.A)
+	s := fmt.Sprintf("%s=\"#%02x%02x%02x\"", attr, r, g, b)
+	if c.A < 0xff {
+		s += fmt.Sprintf(" %s-opacity=\"%s\"", attr, strconv.FormatFloat(float64(c.A)/255, 'f', 2, 64))
+	}
+	return s
+}
@@ -0,0 +1,425 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/willie68/osmltools/internal/colorscale"
+	"github.com/willie68/osmltools/internal/geo"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/raster"
+)
+
+const (
+	// ColorByDepth colour the track by the water depth
+	ColorByDepth = "depth"
+	// ColorBySpeed colour the track by the speed over ground
+	ColorBySpeed = "speed"
+	// ColorByNone draw the track in one colour
+	ColorByNone = "none"
+
+	// DefaultWidth default width of the image in pixels
+	DefaultWidth = 800
+	// DefaultHeight default height of the image in pixels
+	DefaultHeight = 600
+	// DefaultLineWidth default width of the track line in pixels
+	DefaultLineWidth = 3.0
+
+	// minSpan the smallest extent of the map in meters, e.g. for a track in the harbour
+	minSpan = 200.0
+)
+
+var (
+	// ErrNoWaypoints the track has no waypoints to draw
+	ErrNoWaypoints = errors.New("the track has no waypoints")
+
+	white      = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black      = color.RGBA{A: 0xff}
+	water      = color.RGBA{R: 0xdd, G: 0xec, B: 0xf7, A: 0xff}
+	land       = color.RGBA{R: 0xf2, G: 0xef, B: 0xe6, A: 0xff}
+	coast      = color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+	trackColor = color.RGBA{R: 0x1f, G: 0x5f, B: 0xe0, A: 0xff}
+	startColor = color.RGBA{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff}
+	endColor   = color.RGBA{R: 0xd6, G: 0x27, B: 0x28, A: 0xff}
+	// box the half transparent background of title, scale bar and legend (alpha premultiplied)
+	box = color.RGBA{R: 0xd9, G: 0xd9, B: 0xd9, A: 0xd9}
+)
+
+// Options options of the map rendering
+type Options struct {
+	// Width, Height the size of the image in pixels
+	Width  int
+	Height int
+	// ColorBy depth, speed or none
+	ColorBy string
+	// Min, Max the limits of the colour scale, nil for the range of the data
+	Min *float64
+	Max *float64
+	// LineWidth the width of the track line in pixels
+	LineWidth float64
+	// Coastline a geojson file with land polygons and/or coast lines
+	Coastline string
+	// Embed store the images in the track file
+	Embed bool
+}
+
+// Validate checks the options and sets the defaults
+func (o *Options) Validate() error {
+	if o.Width == 0 {
+		o.Width = DefaultWidth
+	}
+	if o.Height == 0 {
+		o.Height = DefaultHeight
+	}
+	if o.Width < 100 || o.Height < 100 || o.Width > 10000 || o.Height > 10000 {
+		return fmt.Errorf("invalid image size %dx%d, allowed are 100 to 10000 pixels", o.Width, o.Height)
+	}
+	if o.LineWidth == 0 {
+		o.LineWidth = DefaultLineWidth
+	}
+	if o.LineWidth < 0 {
+		return errors.New("the line width must not be negative")
+	}
+	switch o.ColorBy {
+	case "":
+		o.ColorBy = ColorByDepth
+	case ColorByDepth, ColorBySpeed, ColorByNone:
+	default:
+		return fmt.Errorf("unknown color-by %s, use %s, %s or %s", o.ColorBy, ColorByDepth, ColorBySpeed, ColorByNone)
+	}
+	return nil
+}
+
+// Coastline the land polygons and coast lines drawn under the track
+type Coastline struct {
+	Land  geo.Polygons
+	Lines geo.Lines
+}
+
+// ReadCoastline reads the polygons and lines of a geojson file, at least one of them must be present
+func ReadCoastline(file string) (*Coastline, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := &Coastline{}
+	var perr, lerr error
+	c.Land, perr = geo.ParsePolygons(data)
+	c.Lines, lerr = geo.ParseLines(data)
+	if perr != nil && lerr != nil {
+		if errors.Is(perr, geo.ErrNoPolygon) && errors.Is(lerr, geo.ErrNoLine) {
+			return nil, fmt.Errorf("no polygon or line found in %s", file)
+		}
+		return nil, perr
+	}
+	return c, nil
+}
+
+// Map a map of a track, projected with web mercator to fit into the image
+type Map struct {
+	track *model.TrackPoints
+	coast *Coastline
+	opts  Options
+	scale *colorscale.Scale
+	// x0, y0 the mercator coordinates of the upper left corner, k pixels per mercator unit
+	x0, y0, k float64
+	// mpp meters per pixel in the center of the map
+	mpp float64
+	// text scale of the raster font
+	ts int
+}
+
+// NewMap creates the map of the track, coast may be nil
+func NewMap(track *model.TrackPoints, coast *Coastline, opts Options) (*Map, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	b := track.Bounds()
+	if b == nil {
+		return nil, ErrNoWaypoints
+	}
+	m := &Map{
+		track: track,
+		coast: coast,
+		opts:  opts,
+		ts:    2,
+	}
+	if min(opts.Width, opts.Height) < 400 {
+		m.ts = 1
+	}
+	m.scale = m.colorScale()
+
+	// extent in mercator units with a minimum size and some padding for title, markers and scale bar
+	latc := (b.MinLat + b.MaxLat) / 2
+	cos := math.Cos(latc * math.Pi / 180)
+	span := minSpan / (geo.EarthRadius * cos)
+	x0, x1 := mercX(b.MinLon), mercX(b.MaxLon)
+	y0, y1 := mercY(b.MinLat), mercY(b.MaxLat)
+	cx, cy := (x0+x1)/2, (y0+y1)/2
+	sx, sy := math.Max(x1-x0, span), math.Max(y1-y0, span)
+	pad := 0.12 * float64(min(opts.Width, opts.Height))
+	m.k = math.Min((float64(opts.Width)-2*pad)/sx, (float64(opts.Height)-2*pad)/sy)
+	m.x0 = cx - float64(opts.Width)/2/m.k
+	m.y0 = cy + float64(opts.Height)/2/m.k
+	m.mpp = geo.EarthRadius * cos / m.k
+	return m, nil
+}
+
+// WriteSVG writes the map as svg
+func (m *Map) WriteSVG(w io.Writer) error {
+	p := newSVGPainter(m.opts.Width, m.opts.Height)
+	m.draw(p)
+	_, err := p.WriteTo(w)
+	return err
+}
+
+// WritePNG rasterises the map and writes it as png
+func (m *Map) WritePNG(w io.Writer) error {
+	p := newPNGPainter(m.opts.Width, m.opts.Height)
+	m.draw(p)
+	return p.c.EncodePNG(w)
+}
+
+func (m *Map) draw(p painter) {
+	w, h := float64(m.opts.Width), float64(m.opts.Height)
+	bg := white
+	if m.coast != nil {
+		bg = water
+	}
+	p.Rect(0, 0, w, h, bg)
+	m.drawCoast(p)
+	m.drawTrack(p)
+	m.drawTitle(p)
+	m.drawScaleBar(p)
+	m.drawNorthArrow(p)
+	m.drawLegend(p)
+}
+
+func (m *Map) drawCoast(p painter) {
+	if m.coast == nil {
+		return
+	}
+	for _, poly := range m.coast.Land {
+		rings := make([][][2]float64, 0, len(poly))
+		for _, ring := range poly {
+			rings = append(rings, m.projectAll(ring))
+		}
+		p.Rings(rings, land)
+	}
+	for _, poly := range m.coast.Land {
+		for _, ring := range poly {
+			p.Polyline(m.projectAll(ring), 1, coast)
+		}
+	}
+	for _, l := range m.coast.Lines {
+		p.Polyline(m.projectAll(l), 1, coast)
+	}
+}
+
+// drawTrack draws the segments of the track, consecutive points with the same colour are drawn as one line. Points
+// closer than a pixel are dropped.
+func (m *Map) drawTrack(p painter) {
+	for _, seg := range m.track.Segments(model.DefaultMaxGap) {
+		var pts [][2]float64
+		var col color.RGBA
+		for i, wpt := range seg {
+			c := m.color(wpt)
+			pt := m.project(wpt.Lat, wpt.Lon)
+			if len(pts) > 0 && c != col {
+				pts = append(pts, pt)
+				p.Polyline(pts, m.opts.LineWidth, col)
+				pts = pts[len(pts)-1:]
+			}
+			col = c
+			if len(pts) > 0 && i < len(seg)-1 && math.Hypot(pt[0]-pts[len(pts)-1][0], pt[1]-pts[len(pts)-1][1]) < 1 {
+				continue
+			}
+			pts = append(pts, pt)
+		}
+		p.Polyline(pts, m.opts.LineWidth, col)
+	}
+	r := math.Max(5, m.opts.LineWidth*1.5)
+	if s := m.track.Waypoints[0]; s != nil {
+		pt := m.project(s.Lat, s.Lon)
+		p.Circle(pt[0], pt[1], r, startColor, 2, white)
+	}
+	if e := m.track.Waypoints[len(m.track.Waypoints)-1]; e != nil {
+		pt := m.project(e.Lat, e.Lon)
+		p.Circle(pt[0], pt[1], r, endColor, 2, white)
+	}
+}
+
+// drawTitle the name and the date of the track in the upper left corner
+func (m *Map) drawTitle(p painter) {
+	title := m.track.Name
+	if t := m.track.Waypoints[0].Time; !t.IsZero() {
+		if title != "" {
+			title += " "
+		}
+		title += t.UTC().Format("2006-01-02")
+	}
+	if title == "" {
+		return
+	}
+	pad := float64(4 * m.ts)
+	th := float64(raster.GlyphHeight * m.ts)
+	p.Rect(pad, pad, 3*pad+float64(raster.TextWidth(title, m.ts)), 3*pad+th, box)
+	p.Text(2*pad, 2*pad, title, m.ts, black)
+}
+
+// drawScaleBar a scale bar with a length of 1, 2 or 5 * 10^n meters in the lower left corner
+func (m *Map) drawScaleBar(p painter) {
+	meters := niceDistance(float64(m.opts.Width) / 5 * m.mpp)
+	length := meters / m.mpp
+	label := strconv.FormatFloat(meters, 'f', -1, 64) + " m"
+	if meters >= 1000 {
+		label = strconv.FormatFloat(meters/1000, 'f', -1, 64) + " km"
+	}
+	pad := float64(4 * m.ts)
+	th := float64(raster.GlyphHeight * m.ts)
+	bh := float64(3 * m.ts)
+	x, y := 2*pad, float64(m.opts.Height)-2*pad-bh
+	p.Rect(pad, y-th-2*pad, x+length+pad, y+bh+pad, box)
+	p.Text(x, y-th-pad, label, m.ts, black)
+	p.Rect(x-1, y-1, x+length+1, y+bh+1, black)
+	p.Rect(x+length/2, y, x+length, y+bh, white)
+}
+
+// drawNorthArrow a north arrow in the upper right corner, north is always up in mercator
+func (m *Map) drawNorthArrow(p painter) {
+	size := float64(12 * m.ts)
+	th := float64(raster.GlyphHeight * m.ts)
+	cx := float64(m.opts.Width) - size
+	top := float64(4*m.ts) + th + float64(2*m.ts)
+	tip, base, mid := [2]float64{cx, top}, top+2*size, top+1.4*size
+	p.Text(cx-float64(raster.GlyphWidth*m.ts)/2, float64(4*m.ts), "N", m.ts, black)
+	p.Rings([][][2]float64{{tip, {cx - size/2, base}, {cx, mid}}}, black)
+	p.Rings([][][2]float64{{tip, {cx, mid}, {cx + size/2, base}}}, white)
+	p.Polyline([][2]float64{tip, {cx - size/2, base}, {cx, mid}, {cx + size/2, base}, tip}, 1, black)
+}
+
+// drawLegend the colour classes with the limits in the lower right corner
+func (m *Map) drawLegend(p painter) {
+	if m.scale == nil {
+		return
+	}
+	prec := 1
+	if m.scale.Max-m.scale.Min >= 20 {
+		prec = 0
+	}
+	title := "Depth (m)"
+	if m.opts.ColorBy == ColorBySpeed {
+		title = "Speed (kn)"
+	}
+	lo := strconv.FormatFloat(m.scale.Min, 'f', prec, 64)
+	hi := strconv.FormatFloat(m.scale.Max, 'f', prec, 64)
+	pad := float64(4 * m.ts)
+	th := float64(raster.GlyphHeight * m.ts)
+	bw := float64(8 * m.ts)
+	n := float64(len(m.scale.Colors))
+	width := math.Max(n*bw, float64(raster.TextWidth(title, m.ts)))
+	height := 2*th + bw + 1.5*pad
+	x, y := float64(m.opts.Width)-2*pad-width, float64(m.opts.Height)-2*pad-height
+	p.Rect(x-pad, y-pad, x+width+pad, y+height+pad, box)
+	p.Text(x, y, title, m.ts, black)
+	y += th + pad
+	for i, c := range m.scale.Colors {
+		p.Rect(x+float64(i)*bw, y, x+float64(i+1)*bw, y+bw, c)
+	}
+	y += bw + pad/2
+	p.Text(x, y, lo, m.ts, black)
+	p.Text(x+n*bw-float64(raster.TextWidth(hi, m.ts)), y, hi, m.ts, black)
+}
+
+// colorScale the colour scale of the track, nil if the track is drawn in one colour
+func (m *Map) colorScale() *colorscale.Scale {
+	if m.opts.ColorBy == ColorByNone {
+		return nil
+	}
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for _, wpt := range m.track.Waypoints {
+		if v, ok := m.value(wpt); ok {
+			minV = math.Min(minV, v)
+			maxV = math.Max(maxV, v)
+		}
+	}
+	if m.opts.Min != nil {
+		minV = *m.opts.Min
+	}
+	if m.opts.Max != nil {
+		maxV = *m.opts.Max
+	}
+	if math.IsInf(minV, 0) || math.IsInf(maxV, 0) {
+		return nil
+	}
+	s := colorscale.New(minV, maxV)
+	if m.opts.ColorBy == ColorByDepth {
+		// shallow water is red
+		s = s.Reversed()
+	}
+	return s
+}
+
+// value the value for the colouring, a depth of 0 means no depth data
+func (m *Map) value(wpt *model.Waypoint) (float64, bool) {
+	switch m.opts.ColorBy {
+	case ColorByDepth:
+		return wpt.Depth, wpt.Depth != 0.0
+	case ColorBySpeed:
+		return wpt.Speed, true
+	}
+	return 0, false
+}
+
+func (m *Map) color(wpt *model.Waypoint) color.RGBA {
+	if m.scale == nil {
+		return trackColor
+	}
+	if v, ok := m.value(wpt); ok {
+		return m.scale.Color(v)
+	}
+	return colorscale.NoData
+}
+
+// project the pixel position of lat, lon
+func (m *Map) project(lat, lon float64) [2]float64 {
+	return [2]float64{(mercX(lon) - m.x0) * m.k, (m.y0 - mercY(lat)) * m.k}
+}
+
+// projectAll projects a list of [lon, lat] positions
+func (m *Map) projectAll(pts [][2]float64) [][2]float64 {
+	res := make([][2]float64, len(pts))
+	for i, pt := range pts {
+		res[i] = m.project(pt[1], pt[0])
+	}
+	return res
+}
+
+func mercX(lon float64) float64 {
+	return lon * math.Pi / 180
+}
+
+func mercY(lat float64) float64 {
+	lat = math.Max(-85, math.Min(85, lat))
+	return math.Log(math.Tan(math.Pi/4 + lat*math.Pi/360))
+}
+
+// niceDistance the largest distance of 1, 2 or 5 * 10^n which is not greater than d
+func niceDistance(d float64) float64 {
+	if d <= 0 {
+		return 1
+	}
+	e := math.Pow(10, math.Floor(math.Log10(d)))
+	switch {
+	case d >= 5*e:
+		return 5 * e
+	case d >= 2*e:
+		return 2 * e
+	}
+	return e
+}
@@ -0,0 +1,188 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type RenderSuite struct {
+	suite.Suite
+}
+
+func TestRenderSuite(t *testing.T) {
+	suite.Run(t, new(RenderSuite))
+}
+
+// track 10 waypoints to the east with increasing depth, one every 10 seconds
+func (s *RenderSuite) track() *model.TrackPoints {
+	ts := time.Date(2016, 9, 11, 10, 0, 0, 0, time.UTC)
+	tp := &model.TrackPoints{Name: "Test"}
+	for i := range 10 {
+		tp.Waypoints = append(tp.Waypoints, &model.Waypoint{
+			Lat:   47.35,
+			Lon:   8.53 + float64(i)*0.001,
+			Time:  ts.Add(time.Duration(i) * 10 * time.Second),
+			Speed: 4,
+			Depth: float64(i),
+		})
+	}
+	return tp
+}
+
+func (s *RenderSuite) TestSVG() {
+	m, err := NewMap(s.track(), nil, Options{})
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(m.WriteSVG(&buf))
+	svg := buf.String()
+
+	// well formed xml
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := dec.Token()
+		if err != nil {
+			s.Require().ErrorContains(err, "EOF")
+			break
+		}
+	}
+	s.Contains(svg, `width="800" height="600"`)
+	s.Contains(svg, ">Test 2016-09-11</text>")
+	s.Contains(svg, ">Depth (m)</text>")
+	s.Contains(svg, ">N</text>")
+	// 9 segments with a depth, the first point has no depth
+	s.Equal(9, strings.Count(svg, "<polyline")-1)
+	s.Equal(2, strings.Count(svg, "<circle"))
+	// ~750 m, the scale bar is 100 m
+	s.Contains(svg, ">100 m</text>")
+}
+
+func (s *RenderSuite) TestPNG() {
+	m, err := NewMap(s.track(), nil, Options{Width: 300, Height: 200, ColorBy: ColorByNone})
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(m.WritePNG(&buf))
+	img, err := png.Decode(&buf)
+	s.Require().NoError(err)
+	s.Equal(300, img.Bounds().Dx())
+	s.Equal(200, img.Bounds().Dy())
+	// the track goes through the middle of the image
+	r, g, b, _ := img.At(150, 100).RGBA()
+	s.Equal(trackColor, color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xff})
+	r, g, b, _ = img.At(150, 20).RGBA()
+	s.Equal(white, color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xff})
+}
+
+func (s *RenderSuite) TestOptions() {
+	_, err := NewMap(s.track(), nil, Options{ColorBy: "temperature"})
+	s.Error(err)
+	_, err = NewMap(s.track(), nil, Options{Width: 50})
+	s.Error(err)
+	_, err = NewMap(&model.TrackPoints{}, nil, Options{})
+	s.ErrorIs(err, ErrNoWaypoints)
+
+	minV, maxV := 0.0, 20.0
+	m, err := NewMap(s.track(), nil, Options{ColorBy: ColorBySpeed, Min: &minV, Max: &maxV})
+	s.Require().NoError(err)
+	s.Equal(20.0, m.scale.Max)
+	// speed 4 of 0..20 is the second colour
+	s.Equal(m.scale.Colors[1], m.color(s.track().Waypoints[0]))
+}
+
+func (s *RenderSuite) TestCoastline() {
+	fn := filepath.Join(s.T().TempDir(), "coast.geojson")
+	s.Require().NoError(os.WriteFile(fn, []byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[8.52,47.351],[8.55,47.351],[8.55,47.36],[8.52,47.36],[8.52,47.351]]]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"MultiLineString","coordinates":[[[8.52,47.349],[8.55,47.349]],[[8.52,47.348],[8.55,47.348]]]}}]}`), 0o644))
+	c, err := ReadCoastline(fn)
+	s.Require().NoError(err)
+	s.Len(c.Land, 1)
+	s.Len(c.Lines, 2)
+
+	m, err := NewMap(s.track(), c, Options{Width: 300, Height: 200})
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(m.WritePNG(&buf))
+	img, err := png.Decode(&buf)
+	s.Require().NoError(err)
+	// land above the track, water below
+	r, g, b, _ := img.At(150, 30).RGBA()
+	s.Equal(land, color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xff})
+	r, g, b, _ = img.At(150, 140).RGBA()
+	s.Equal(water, color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xff})
+
+	s.Require().NoError(os.WriteFile(fn, []byte(`{"type":"Point","coordinates":[8.5,47.3]}`), 0o644))
+	_, err = ReadCoastline(fn)
+	s.Error(err)
+}
+
+func (s *RenderSuite) TestNiceDistance() {
+	s.Equal(100.0, niceDistance(160))
+	s.Equal(200.0, niceDistance(490))
+	s.Equal(5000.0, niceDistance(9999))
+	s.Equal(1.0, niceDistance(0))
+}
+
+type converterMock struct {
+	tp *model.TrackPoints
+}
+
+func (m *converterMock) TrackPoints(_ string) (*model.TrackPoints, error) {
+	return m.tp, nil
+}
+
+type trackMock struct {
+	attachments map[string][]byte
+}
+
+func (m *trackMock) AddAttachment(_ string, name string, data []byte) error {
+	if name == "track.json" {
+		return errors.New("not allowed")
+	}
+	m.attachments[name] = data
+	return nil
+}
+
+func (s *RenderSuite) TestRender() {
+	tm := &trackMock{attachments: make(map[string][]byte)}
+	r := &renderer{log: *logging.New().WithName("Render"), cnv: &converterMock{tp: s.track()}, trk: tm}
+	dir := s.T().TempDir()
+	svg, pngf := filepath.Join(dir, "map.svg"), filepath.Join(dir, "sub", "map.png")
+
+	res, err := r.Render("track.zip", []string{svg, pngf}, nil, Options{Embed: true})
+	s.Require().NoError(err)
+	s.Equal(10, res.Waypoints)
+	s.Equal([]string{svg, pngf}, res.Files)
+	s.Equal([]string{"thumbnail.svg", "thumbnail.png"}, res.Embedded)
+	s.FileExists(svg)
+	s.FileExists(pngf)
+	s.Len(tm.attachments, 2)
+
+	// only embed, svg is the default
+	tm.attachments = make(map[string][]byte)
+	res, err = r.Render("track.zip", nil, nil, Options{Embed: true})
+	s.Require().NoError(err)
+	s.Empty(res.Files)
+	s.Equal([]string{"thumbnail.svg"}, res.Embedded)
+
+	_, err = r.Render("track.zip", nil, nil, Options{})
+	s.Error(err)
+	_, err = r.Render("track.zip", []string{filepath.Join(dir, "map.jpg")}, nil, Options{})
+	s.Error(err)
+
+	// all waypoints in a privacy zone
+	zones := model.PrivacyZones{{Name: "home", Lat: 47.35, Lon: 8.535, Radius: 2000}}
+	_, err = r.Render("track.zip", []string{svg}, zones, Options{})
+	s.ErrorIs(err, ErrNoWaypoints)
+}
@@ -0,0 +1,148 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// FormatSVG svg vector image
+	FormatSVG = "svg"
+	// FormatPNG png raster image
+	FormatPNG = "png"
+
+	// EmbedName the name of the images in the track file, the format is the extension
+	EmbedName = "thumbnail"
+)
+
+type converterSrv interface {
+	TrackPoints(trackfile string) (*model.TrackPoints, error)
+}
+
+type trackSrv interface {
+	AddAttachment(trackfile, name string, data []byte) error
+}
+
+// Result the result of the rendering
+type Result struct {
+	Waypoints int      `json:"waypoints"`
+	Files     []string `json:"files"`
+	Embedded  []string `json:"embedded,omitempty"`
+}
+
+type renderer struct {
+	log logging.Logger
+	cnv converterSrv
+	trk trackSrv
+}
+
+// Init registers the render service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(inj do.Injector) (*renderer, error) {
+		return &renderer{
+			log: *logging.New().WithName("Render"),
+			cnv: do.MustInvokeAs[converterSrv](inj),
+			trk: do.MustInvokeAs[trackSrv](inj),
+		}, nil
+	})
+}
+
+// Render draws the track into the output files, the format is taken from the file extension (.svg or .png). With
+// embed the images are additionally stored in the track file as thumbnail.<format>.
+func (r *renderer) Render(trackfile string, outputs []string, zones model.PrivacyZones, opts Options) (*Result, error) {
+	if trackfile == "" {
+		return nil, errors.New("no track file given")
+	}
+	if len(outputs) == 0 && !opts.Embed {
+		return nil, errors.New("no output file given")
+	}
+	formats := make([]string, 0, len(outputs))
+	for _, o := range outputs {
+		f := strings.ToLower(strings.TrimPrefix(filepath.Ext(o), "."))
+		if f != FormatSVG && f != FormatPNG {
+			return nil, fmt.Errorf("unknown image format of %s, use .%s or .%s", o, FormatSVG, FormatPNG)
+		}
+		formats = append(formats, f)
+	}
+	if len(formats) == 0 {
+		formats = append(formats, FormatSVG)
+	}
+	var coast *Coastline
+	if opts.Coastline != "" {
+		var err error
+		coast, err = ReadCoastline(opts.Coastline)
+		if err != nil {
+			return nil, fmt.Errorf("can't read coastline %s: %w", opts.Coastline, err)
+		}
+	}
+
+	tps, err := r.cnv.TrackPoints(trackfile)
+	if err != nil {
+		return nil, err
+	}
+	tps.ApplyPrivacyZones(zones)
+	m, err := NewMap(tps, coast, opts)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{
+		Waypoints: len(tps.Waypoints),
+		Files:     make([]string, 0, len(outputs)),
+	}
+	images := make(map[string][]byte)
+	for i, f := range formats {
+		data, ok := images[f]
+		if !ok {
+			data, err = m.image(f)
+			if err != nil {
+				return nil, err
+			}
+			images[f] = data
+		}
+		if i >= len(outputs) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(outputs[i]), os.ModePerm); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(outputs[i], data, 0o644); err != nil {
+			return nil, err
+		}
+		r.log.Infof("map of %s written to %s", trackfile, outputs[i])
+		res.Files = append(res.Files, outputs[i])
+	}
+	if opts.Embed {
+		for _, f := range []string{FormatSVG, FormatPNG} {
+			data, ok := images[f]
+			if !ok {
+				continue
+			}
+			name := EmbedName + "." + f
+			if err := r.trk.AddAttachment(trackfile, name, data); err != nil {
+				return nil, err
+			}
+			res.Embedded = append(res.Embedded, name)
+		}
+	}
+	return res, nil
+}
+
+// image the map in the format
+func (m *Map) image(format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == FormatPNG {
+		err = m.WritePNG(&buf)
+	} else {
+		err = m.WriteSVG(&buf)
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,88 @@
+package replay
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Control a small text protocol to control the player: one command per line, one reply per line starting with ok or
+// error. The address is a tcp address (localhost:10111) or a unix socket (unix:/tmp/osml.sock).
+type Control struct {
+	ln    net.Listener
+	p     *Player
+	mu    sync.RWMutex
+	conns map[net.Conn]bool
+}
+
+// NewControl starts the control socket for the player
+func NewControl(address string, p *Player) (*Control, error) {
+	network := "tcp"
+	if a, ok := strings.CutPrefix(address, "unix:"); ok {
+		network, address = "unix", a
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Control{ln: ln, p: p, conns: make(map[net.Conn]bool)}
+	go c.accept()
+	return c, nil
+}
+
+func (c *Control) accept() {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.conns[conn] = true
+		c.mu.Unlock()
+		go c.serve(conn)
+	}
+}
+
+func (c *Control) serve(conn net.Conn) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.conns, conn)
+		c.mu.Unlock()
+		conn.Close()
+	}()
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !c.execute(conn, line) {
+			return
+		}
+	}
+}
+
+// execute runs the command and writes the reply, Close waits for running commands
+func (c *Control) execute(conn net.Conn, line string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, err := conn.Write([]byte(c.p.Command(line) + "\n"))
+	return err == nil
+}
+
+// Address the listening address
+func (c *Control) Address() string {
+	return c.ln.Addr().String()
+}
+
+// Close stops the control socket and disconnects all clients
+func (c *Control) Close() error {
+	err := c.ln.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package replay
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// OutputTCP a tcp server, every connected client gets the lines
+	OutputTCP = "tcp"
+	// OutputUDP udp datagrams, e.g. to the broadcast address
+	OutputUDP = "udp"
+	// OutputPTY a pseudo terminal, the plotter opens the serial port /dev/pts/<n>
+	OutputPTY = "pty"
+
+	// DefaultTCPAddress the default address of the tcp server, 10110 is the registered nmea-0183 port
+	DefaultTCPAddress = ":10110"
+	// DefaultUDPAddress the default udp broadcast address
+	DefaultUDPAddress = "255.255.255.255:10110"
+
+	writeTimeout = 2 * time.Second
+)
+
+// Output the target of the replay
+type Output interface {
+	// Write writes one or more complete lines
+	Write(p []byte) (int, error)
+	Close() error
+	// Address where the plotter can connect to
+	Address() string
+}
+
+// NewOutput creates the output of the kind, an empty address uses the default of the kind
+func NewOutput(kind, address string) (Output, error) {
+	switch kind {
+	case "", OutputTCP:
+		if address == "" {
+			address = DefaultTCPAddress
+		}
+		return NewTCPOutput(address)
+	case OutputUDP:
+		if address == "" {
+			address = DefaultUDPAddress
+		}
+		return NewUDPOutput(address)
+	case OutputPTY:
+		return NewPTYOutput()
+	}
+	return nil, fmt.Errorf("unknown replay output %s, use %s, %s or %s", kind, OutputTCP, OutputUDP, OutputPTY)
+}
+
+// TCPOutput tcp server sending the lines to all connected clients
+type TCPOutput struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// NewTCPOutput starts the tcp server on the address
+func NewTCPOutput(address string) (*TCPOutput, error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	o := &TCPOutput{ln: ln, conns: make(map[net.Conn]bool)}
+	go o.accept()
+	return o, nil
+}
+
+func (o *TCPOutput) accept() {
+	for {
+		c, err := o.ln.Accept()
+		if err != nil {
+			return
+		}
+		o.mu.Lock()
+		o.conns[c] = true
+		o.mu.Unlock()
+	}
+}
+
+// Clients the number of connected clients
+func (o *TCPOutput) Clients() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.conns)
+}
+
+// Write sends the data to all clients, clients with errors are disconnected
+func (o *TCPOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for c := range o.conns {
+		_ = c.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := c.Write(p); err != nil {
+			c.Close()
+			delete(o.conns, c)
+		}
+	}
+	return len(p), nil
+}
+
+// Close stops the server and disconnects all clients
+func (o *TCPOutput) Close() error {
+	err := o.ln.Close()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for c := range o.conns {
+		c.Close()
+		delete(o.conns, c)
+	}
+	return err
+}
+
+// Address the listening address
+func (o *TCPOutput) Address() string {
+	return o.ln.Addr().String()
+}
+
+// UDPOutput sends every write as one datagram
+type UDPOutput struct {
+	conn *net.UDPConn
+}
+
+// NewUDPOutput creates the udp output to the address, broadcast addresses are allowed
+func NewUDPOutput(address string) (*UDPOutput, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPOutput{conn: conn}, nil
+}
+
+// Write sends the data as datagram
+func (o *UDPOutput) Write(p []byte) (int, error) {
+	return o.conn.Write(p)
+}
+
+// Close closes the socket
+func (o *UDPOutput) Close() error {
+	return o.conn.Close()
+}
+
+// Address the target address
+func (o *UDPOutput) Address() string {
+	return o.conn.RemoteAddr().String()
+}
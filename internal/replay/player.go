@@ -0,0 +1,284 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrStopped the replay was stopped by the control command stop
+var ErrStopped = errors.New("replay stopped")
+
+// Formatter converts a line into the bytes written to the output, ok = false skips the line
+type Formatter func(l Line) (data []byte, ok bool)
+
+// NMEAFormatter writes the sentence with cr lf
+func NMEAFormatter(l Line) ([]byte, bool) {
+	return []byte(l.Text + "\r\n"), true
+}
+
+// Status the state of the player
+type Status struct {
+	Paused   bool          `json:"paused"`
+	Position time.Duration `json:"position"`
+	Duration time.Duration `json:"duration"`
+	Line     int           `json:"line"`
+	Lines    int           `json:"lines"`
+	Speed    float64       `json:"speed"`
+	Loop     bool          `json:"loop"`
+	Loops    int           `json:"loops"`
+}
+
+// String the status as single line
+func (s Status) String() string {
+	state := "playing"
+	if s.Paused {
+		state = "paused"
+	}
+	return fmt.Sprintf("%s position=%s duration=%s line=%d/%d speed=%g loop=%t", state, fmtDuration(s.Position),
+		fmtDuration(s.Duration), s.Line, s.Lines, s.Speed, s.Loop)
+}
+
+type command struct {
+	args  []string
+	reply chan string
+}
+
+// Player replays the lines with the original timing scaled by the speed factor
+type Player struct {
+	lines    []Line
+	out      io.Writer
+	format   Formatter
+	speed    float64
+	loop     bool
+	maxPause time.Duration
+	cmds     chan command
+	done     chan struct{}
+
+	idx    int
+	paused bool
+	loops  int
+}
+
+// NewPlayer creates a player, speed <= 0 is taken as 1. Pauses between two lines longer than maxPause (> 0) are
+// shortened to maxPause.
+func NewPlayer(lines []Line, out io.Writer, speed float64, loop bool, maxPause time.Duration) *Player {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &Player{
+		lines:    lines,
+		out:      out,
+		format:   NMEAFormatter,
+		speed:    speed,
+		loop:     loop,
+		maxPause: maxPause,
+		cmds:     make(chan command),
+		done:     make(chan struct{}),
+	}
+}
+
+// WithFormatter sets the formatter of the lines
+func (p *Player) WithFormatter(f Formatter) *Player {
+	p.format = f
+	return p
+}
+
+// Run plays the lines until the end (without loop), the context is done or the stop command
+func (p *Player) Run(ctx context.Context) error {
+	defer close(p.done)
+	var timer *time.Timer
+	var next time.Time
+	for {
+		if p.idx >= len(p.lines) {
+			if !p.loop || len(p.lines) == 0 {
+				return nil
+			}
+			p.idx = 0
+			p.loops++
+			next = time.Time{}
+		}
+		var wait <-chan time.Time
+		if !p.paused {
+			if next.IsZero() {
+				next = time.Now().Add(p.delay())
+			}
+			timer = time.NewTimer(time.Until(next))
+			wait = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			stop(timer)
+			return ctx.Err()
+		case c := <-p.cmds:
+			stop(timer)
+			if c.args[0] == "stop" || c.args[0] == "quit" {
+				c.reply <- "ok stopped"
+				return ErrStopped
+			}
+			reply, reset, err := p.execute(c.args)
+			if err != nil {
+				c.reply <- "error " + err.Error()
+			} else {
+				c.reply <- "ok " + reply
+			}
+			if reset {
+				next = time.Time{}
+			}
+		case <-wait:
+			if data, ok := p.format(p.lines[p.idx]); ok {
+				if _, err := p.out.Write(data); err != nil {
+					return err
+				}
+			}
+			p.idx++
+			next = time.Time{}
+		}
+	}
+}
+
+func stop(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// delay the time to wait before the current line
+func (p *Player) delay() time.Duration {
+	if p.idx == 0 {
+		return 0
+	}
+	d := p.lines[p.idx].Offset - p.lines[p.idx-1].Offset
+	if p.maxPause > 0 {
+		d = min(d, p.maxPause)
+	}
+	return time.Duration(float64(max(d, 0)) / p.speed)
+}
+
+// Command executes a control command and returns the reply starting with ok or error. Commands: pause, resume,
+// seek <pos>|+<d>|-<d>, speed <f>, loop on|off, status, stop.
+func (p *Player) Command(line string) string {
+	args := strings.Fields(strings.ToLower(line))
+	if len(args) == 0 {
+		return "error empty command"
+	}
+	c := command{args: args, reply: make(chan string, 1)}
+	select {
+	case p.cmds <- c:
+		return <-c.reply
+	case <-p.done:
+		return "error replay not running"
+	}
+}
+
+// execute executes the command in the player loop, reset is true if the timing has to be restarted
+func (p *Player) execute(args []string) (reply string, reset bool, err error) {
+	switch args[0] {
+	case "pause":
+		p.paused = true
+	case "resume", "play":
+		p.paused = false
+		reset = true
+	case "seek":
+		if len(args) < 2 {
+			return "", false, errors.New("seek needs a position, e.g. seek 01:10:00, seek +30s or seek -1m")
+		}
+		if err := p.seek(args[1]); err != nil {
+			return "", false, err
+		}
+		reset = true
+	case "speed":
+		if len(args) < 2 {
+			return "", false, errors.New("speed needs a factor")
+		}
+		f, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || f <= 0 {
+			return "", false, fmt.Errorf("invalid speed %s", args[1])
+		}
+		p.speed = f
+		reset = true
+	case "loop":
+		p.loop = len(args) < 2 || args[1] == "on" || args[1] == "true"
+	case "status":
+	default:
+		return "", false, fmt.Errorf("unknown command %s, use pause, resume, seek, speed, loop, status or stop", args[0])
+	}
+	return p.status().String(), reset, nil
+}
+
+// seek sets the position, absolute as duration or hh:mm:ss, relative with + or -
+func (p *Player) seek(s string) error {
+	rel := 0
+	if strings.HasPrefix(s, "+") {
+		rel = 1
+		s = s[1:]
+	} else if strings.HasPrefix(s, "-") {
+		rel = -1
+		s = s[1:]
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	pos := d
+	if rel != 0 {
+		pos = p.position() + time.Duration(rel)*d
+	}
+	p.idx = sort.Search(len(p.lines), func(i int) bool {
+		return p.lines[i].Offset >= pos
+	})
+	return nil
+}
+
+func (p *Player) position() time.Duration {
+	if len(p.lines) == 0 {
+		return 0
+	}
+	return p.lines[min(p.idx, len(p.lines)-1)].Offset
+}
+
+func (p *Player) status() Status {
+	s := Status{
+		Paused:   p.paused,
+		Position: p.position(),
+		Line:     p.idx,
+		Lines:    len(p.lines),
+		Speed:    p.speed,
+		Loop:     p.loop,
+		Loops:    p.loops,
+	}
+	if len(p.lines) > 0 {
+		s.Duration = p.lines[len(p.lines)-1].Offset
+	}
+	return s
+}
+
+// parseDuration parses a go duration (1m30s) or a time hh:mm:ss
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid position %s", s)
+	}
+	var d time.Duration
+	for _, pt := range parts {
+		v, err := strconv.ParseFloat(pt, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid position %s", s)
+		}
+		d = d*60 + time.Duration(v*float64(time.Second))
+	}
+	return d, nil
+}
+
+func fmtDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// PTYOutput the master side of a pseudo terminal, the plotter opens the slave side as serial port
+type PTYOutput struct {
+	master int
+	// slave is kept open, so writes don't fail while no plotter has opened the port
+	slave *os.File
+	name  string
+}
+
+// NewPTYOutput opens a new pseudo terminal. The master is non blocking, lines are dropped if the plotter doesn't
+// read them.
+func NewPTYOutput() (*PTYOutput, error) {
+	master, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	var unlock int32
+	if err := ioctl(uintptr(master), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		syscall.Close(master)
+		return nil, fmt.Errorf("unlock pty: %w", err)
+	}
+	var n uint32
+	if err := ioctl(uintptr(master), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		syscall.Close(master)
+		return nil, fmt.Errorf("get pty number: %w", err)
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		syscall.Close(master)
+		return nil, err
+	}
+	if err := makeRaw(slave.Fd()); err != nil {
+		syscall.Close(master)
+		slave.Close()
+		return nil, err
+	}
+	return &PTYOutput{master: master, slave: slave, name: name}, nil
+}
+
+// makeRaw disables echo and the line conversions of the terminal
+func makeRaw(fd uintptr) error {
+	var t syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	return ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Write writes to the terminal, if the buffer of the terminal is full the data is dropped
+func (o *PTYOutput) Write(p []byte) (int, error) {
+	n, err := syscall.Write(o.master, p)
+	if errors.Is(err, syscall.EAGAIN) {
+		return len(p), nil
+	}
+	return n, err
+}
+
+// Close closes the terminal
+func (o *PTYOutput) Close() error {
+	o.slave.Close()
+	return syscall.Close(o.master)
+}
+
+// Address the device name of the slave side
+func (o *PTYOutput) Address() string {
+	return o.name
+}
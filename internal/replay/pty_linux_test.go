@@ -0,0 +1,29 @@
+//go:build linux
+
+package replay
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPTY(t *testing.T) {
+	out, err := NewPTYOutput()
+	if err != nil {
+		t.Skipf("no pty available: %v", err)
+	}
+	defer out.Close()
+	f, err := os.Open(out.Address())
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = out.Write([]byte("$POSMVCC,4940*72\r\n"))
+	require.NoError(t, err)
+	buf := make([]byte, 64)
+	n, err := f.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "$POSMVCC,4940*72\r\n", string(buf[:n]))
+}
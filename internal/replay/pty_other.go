@@ -0,0 +1,31 @@
+//go:build !linux
+
+package replay
+
+import (
+	"errors"
+	"runtime"
+)
+
+// PTYOutput pseudo terminals are only supported on linux
+type PTYOutput struct{}
+
+// NewPTYOutput returns an error, pseudo terminals are only supported on linux
+func NewPTYOutput() (*PTYOutput, error) {
+	return nil, errors.New("pty output is not supported on " + runtime.GOOS)
+}
+
+// Write not supported
+func (o *PTYOutput) Write(p []byte) (int, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// Close not supported
+func (o *PTYOutput) Close() error {
+	return nil
+}
+
+// Address not supported
+func (o *PTYOutput) Address() string {
+	return ""
+}
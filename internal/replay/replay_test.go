@@ -0,0 +1,254 @@
+package replay
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReplaySuite struct {
+	suite.Suite
+	track string
+}
+
+func TestReplaySuite(t *testing.T) {
+	suite.Run(t, new(ReplaySuite))
+}
+
+func checksum(s string) string {
+	var cs byte
+	for i := 1; i < len(s); i++ {
+		cs ^= s[i]
+	}
+	return fmt.Sprintf("%s*%02X", s, cs)
+}
+
+// SetupTest creates a track file with 5 corrected lines, one per second, and two DAT files
+func (s *ReplaySuite) SetupTest() {
+	s.track = filepath.Join(s.T().TempDir(), "track.zip")
+	f, err := os.Create(s.track)
+	s.Require().NoError(err)
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("track.nmea")
+	s.Require().NoError(err)
+	for i := range 5 {
+		fmt.Fprintf(w, "2016-09-11 10:00:0%d.000000: %s\n", i, checksum(fmt.Sprintf("$POSMVCC,%d", 4900+i)))
+	}
+	w, err = zw.Create("0000_DATA000002.DAT")
+	s.Require().NoError(err)
+	fmt.Fprintf(w, "00:00:01.000;A;%s\n00:00:01.500;I;%s\n", checksum("$SDDBT,1.0,f,0.3,M,0.2,F"), checksum("$POSMVCC,5002"))
+	w, err = zw.Create("0000_DATA000001.DAT")
+	s.Require().NoError(err)
+	fmt.Fprintf(w, "# do not change\n00:00:10.000;I;%s\n00:00:12.000;B;\x01\x02\n00:00:13.000;B;%s\n",
+		checksum("$POSMVCC,5001"), "$GPRMC,broken*00")
+	s.Require().NoError(zw.Close())
+	s.Require().NoError(f.Close())
+}
+
+func (s *ReplaySuite) TestLoadCorrected() {
+	lines, err := LoadTrack(s.track, false, nil)
+	s.Require().NoError(err)
+	s.Require().Len(lines, 5)
+	s.Equal(checksum("$POSMVCC,4900"), lines[0].Text)
+	s.Equal(4*time.Second, lines[4].Offset)
+	s.NotNil(lines[4].LogLine)
+}
+
+func (s *ReplaySuite) TestLoadRaw() {
+	lines, err := LoadTrack(s.track, true, nil)
+	s.Require().NoError(err)
+	s.Require().Len(lines, 4)
+	// DATA000001 first, the broken sentence is kept, the binary line is skipped
+	s.Equal(checksum("$POSMVCC,5001"), lines[0].Text)
+	s.Equal("$GPRMC,broken*00", lines[1].Text)
+	s.Equal(3*time.Second, lines[1].Offset)
+	// the second file continues after the first one
+	s.Equal(3*time.Second, lines[2].Offset)
+	s.Equal(3500*time.Millisecond, lines[3].Offset)
+
+	lines, err = LoadTrack(s.track, true, []string{"I"})
+	s.Require().NoError(err)
+	s.Len(lines, 2)
+
+	_, err = LoadTrack(filepath.Join(s.T().TempDir(), "missing.zip"), false, nil)
+	s.Error(err)
+}
+
+// client connects to the tcp output and collects the lines
+func (s *ReplaySuite) client(addr string) (<-chan string, net.Conn) {
+	conn, err := net.Dial("tcp", addr)
+	s.Require().NoError(err)
+	ch := make(chan string, 100)
+	go func() {
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			ch <- sc.Text()
+		}
+		close(ch)
+	}()
+	return ch, conn
+}
+
+func (s *ReplaySuite) waitClients(out *TCPOutput, n int) {
+	s.Eventually(func() bool { return out.Clients() == n }, time.Second, 5*time.Millisecond)
+}
+
+func (s *ReplaySuite) TestPlayTCP() {
+	lines, err := LoadTrack(s.track, false, nil)
+	s.Require().NoError(err)
+	out, err := NewTCPOutput("127.0.0.1:0")
+	s.Require().NoError(err)
+	defer out.Close()
+	ch, conn := s.client(out.Address())
+	defer conn.Close()
+	s.waitClients(out, 1)
+
+	td := time.Now()
+	// 4 seconds with speed 20
+	s.Require().NoError(NewPlayer(lines, out, 20, false, 0).Run(context.Background()))
+	s.GreaterOrEqual(time.Since(td), 150*time.Millisecond)
+	for i := range 5 {
+		s.Equal(checksum(fmt.Sprintf("$POSMVCC,%d", 4900+i)), <-ch)
+	}
+}
+
+func (s *ReplaySuite) TestMaxPause() {
+	lines, err := LoadTrack(s.track, false, nil)
+	s.Require().NoError(err)
+	out, err := NewTCPOutput("127.0.0.1:0")
+	s.Require().NoError(err)
+	defer out.Close()
+	td := time.Now()
+	s.Require().NoError(NewPlayer(lines, out, 1, false, 10*time.Millisecond).Run(context.Background()))
+	s.Less(time.Since(td), time.Second)
+}
+
+func (s *ReplaySuite) TestControl() {
+	lines, err := LoadTrack(s.track, false, nil)
+	s.Require().NoError(err)
+	out, err := NewTCPOutput("127.0.0.1:0")
+	s.Require().NoError(err)
+	defer out.Close()
+	ch, conn := s.client(out.Address())
+	defer conn.Close()
+	s.waitClients(out, 1)
+
+	p := NewPlayer(lines, out, 1, true, 0)
+	ctrl, err := NewControl("127.0.0.1:0", p)
+	s.Require().NoError(err)
+	defer ctrl.Close()
+	done := make(chan error)
+	go func() { done <- p.Run(context.Background()) }()
+	s.Equal(checksum("$POSMVCC,4900"), <-ch)
+
+	cc, err := net.Dial("tcp", ctrl.Address())
+	s.Require().NoError(err)
+	defer cc.Close()
+	cr := bufio.NewReader(cc)
+	cmd := func(c string) string {
+		_, err := cc.Write([]byte(c + "\n"))
+		s.Require().NoError(err)
+		reply, err := cr.ReadString('\n')
+		s.Require().NoError(err)
+		return strings.TrimSpace(reply)
+	}
+	s.True(strings.HasPrefix(cmd("pause"), "ok paused"))
+	s.Contains(cmd("seek 00:00:03"), "position=00:00:03 duration=00:00:04 line=3/5")
+	s.Contains(cmd("seek -2s"), "line=1/5")
+	s.Contains(cmd("seek +1s"), "line=2/5")
+	s.True(strings.HasPrefix(cmd("seek"), "error"))
+	s.True(strings.HasPrefix(cmd("speed 0"), "error"))
+	s.True(strings.HasPrefix(cmd("jump"), "error unknown command"))
+	s.Contains(cmd("speed 100"), "speed=100")
+	s.Contains(cmd("resume"), "ok playing")
+	s.Equal(checksum("$POSMVCC,4902"), <-ch)
+	s.Equal(checksum("$POSMVCC,4903"), <-ch)
+	s.Equal(checksum("$POSMVCC,4904"), <-ch)
+	// loop
+	s.Equal(checksum("$POSMVCC,4900"), <-ch)
+	s.Contains(cmd("loop off"), "loop=false")
+	s.Equal("ok stopped", cmd("stop"))
+	s.ErrorIs(<-done, ErrStopped)
+	s.Equal("error replay not running", p.Command("status"))
+}
+
+func (s *ReplaySuite) TestUDP() {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer pc.Close()
+	out, err := NewOutput(OutputUDP, pc.LocalAddr().String())
+	s.Require().NoError(err)
+	defer out.Close()
+	s.Equal(pc.LocalAddr().String(), out.Address())
+
+	lines, err := LoadTrack(s.track, false, nil)
+	s.Require().NoError(err)
+	s.Require().NoError(NewPlayer(lines[:2], out, 1000, false, 0).Run(context.Background()))
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	s.Require().NoError(err)
+	s.Equal(checksum("$POSMVCC,4900")+"\r\n", string(buf[:n]))
+}
+
+func (s *ReplaySuite) TestCancel() {
+	lines, err := LoadTrack(s.track, false, nil)
+	s.Require().NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	out, err := NewOutput(OutputTCP, "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer out.Close()
+	s.ErrorIs(NewPlayer(lines, out, 1, true, 0).Run(ctx), context.DeadlineExceeded)
+
+	_, err = NewOutput("serial", "")
+	s.Error(err)
+}
+
+func (s *ReplaySuite) TestParseDuration() {
+	for in, exp := range map[string]time.Duration{
+		"1m30s":    90 * time.Second,
+		"01:10:00": 70 * time.Minute,
+		"02:30":    150 * time.Second,
+		"45":       45 * time.Second,
+	} {
+		d, err := parseDuration(in)
+		s.Require().NoError(err, in)
+		s.Equal(exp, d, in)
+	}
+	_, err := parseDuration("1:2:3:4")
+	s.Error(err)
+	_, err = parseDuration("ab")
+	s.Error(err)
+}
+
+func (s *ReplaySuite) TestSignalK() {
+	lines, err := LoadTrack(s.track, true, nil)
+	s.Require().NoError(err)
+	out, err := NewTCPOutput("127.0.0.1:0")
+	s.Require().NoError(err)
+	defer out.Close()
+	ch, conn := s.client(out.Address())
+	defer conn.Close()
+	s.waitClients(out, 1)
+
+	p := NewPlayer(lines, out, 1000, false, 0).WithFormatter(SignalKFormatter("vessels.test"))
+	s.Require().NoError(p.Run(context.Background()))
+	// only the depth has a signal k value
+	js := <-ch
+	s.Contains(js, `"context":"vessels.test"`)
+	s.Contains(js, `"$source":"osml.A.SD"`)
+	s.Contains(js, `{"path":"environment.depth.belowTransducer","value":0.3}`)
+	conn.Close()
+	_, more := <-ch
+	s.False(more)
+}
@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+const (
+	// FormatNMEA replay the nmea sentences
+	FormatNMEA = "nmea"
+	// FormatSignalK replay signal k deltas
+	FormatSignalK = "signalk"
+)
+
+// Options options of the replay
+type Options struct {
+	// Track the track file
+	Track string
+	// Raw replay the raw DAT files instead of the corrected track
+	Raw bool
+	// Channels the channels (A, B, I) of the raw replay, empty for all
+	Channels []string
+	// Output tcp, udp or pty
+	Output string
+	// Address the address of the output, empty for the default
+	Address string
+	// Speed the speed factor, 2 plays twice as fast
+	Speed float64
+	// Loop start again at the end
+	Loop bool
+	// MaxPause longer pauses between two lines are shortened, 0 for no limit
+	MaxPause time.Duration
+	// Format nmea or signalk
+	Format string
+	// Context the signal k context, empty for vessels.self
+	Context string
+	// Control the address of the control socket, empty for none
+	Control string
+	// Started is called after the output and the control socket are ready
+	Started func(output, control string, lines int)
+}
+
+type replayer struct {
+	log logging.Logger
+}
+
+// Init registers the replay service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(_ do.Injector) (*replayer, error) {
+		return &replayer{
+			log: *logging.New().WithName("Replay"),
+		}, nil
+	})
+}
+
+// Replay plays the track to the output until the end, the context is done or the stop command is received
+func (r *replayer) Replay(ctx context.Context, opts Options) error {
+	if opts.Track == "" {
+		return errors.New("no track file given")
+	}
+	var format Formatter
+	switch strings.ToLower(opts.Format) {
+	case "", FormatNMEA:
+		format = NMEAFormatter
+	case FormatSignalK:
+		format = SignalKFormatter(opts.Context)
+	default:
+		return fmt.Errorf("unknown replay format %s, use %s or %s", opts.Format, FormatNMEA, FormatSignalK)
+	}
+	lines, err := LoadTrack(opts.Track, opts.Raw, opts.Channels)
+	if err != nil {
+		return err
+	}
+	out, err := NewOutput(opts.Output, opts.Address)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p := NewPlayer(lines, out, opts.Speed, opts.Loop, opts.MaxPause).WithFormatter(format)
+	ctrl := ""
+	if opts.Control != "" {
+		c, err := NewControl(opts.Control, p)
+		if err != nil {
+			return fmt.Errorf("control socket: %w", err)
+		}
+		defer c.Close()
+		ctrl = c.Address()
+	}
+	r.log.Infof("replaying %d lines of %s to %s %s", len(lines), opts.Track, opts.Output, out.Address())
+	if opts.Started != nil {
+		opts.Started(out.Address(), ctrl, len(lines))
+	}
+	err = p.Run(ctx)
+	if errors.Is(err, ErrStopped) || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package replay
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/willie68/osmltools/internal/export/signalkexporter"
+)
+
+// SignalKFormatter writes a signal k delta per line with the current time as timestamp. Lines without signal k
+// values are skipped.
+func SignalKFormatter(context string) Formatter {
+	e := signalkexporter.New()
+	if context != "" {
+		e = signalkexporter.NewWithOptions(map[string]string{"context": context})
+	}
+	return func(l Line) ([]byte, bool) {
+		d, ok := e.Delta(l.LogLine, time.Now())
+		if !ok {
+			return nil, false
+		}
+		js, err := json.Marshal(d)
+		if err != nil {
+			return nil, false
+		}
+		return append(js, '\r', '\n'), true
+	}
+}
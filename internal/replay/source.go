@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/trackutils"
+)
+
+// Line a line to replay, Offset is the time since the start of the replay
+type Line struct {
+	Offset  time.Duration
+	Channel string
+	// Text the raw sentence without line end
+	Text string
+	// LogLine the parsed line, the nmea message is nil for broken sentences
+	LogLine *model.LogLine
+}
+
+// LoadTrack loads the lines of a track file. Without raw the corrected track.nmea is used, the timing is taken from
+// the corrected time stamps. With raw the DAT files of the track are used, with the logger time and the channels
+// (A, B, I), an empty channel list means all channels.
+func LoadTrack(trackfile string, raw bool, channels []string) ([]Line, error) {
+	r, err := zip.OpenReader(trackfile)
+	if err != nil {
+		return nil, fmt.Errorf("error opening track file %s: %w", trackfile, err)
+	}
+	defer r.Close()
+	if raw {
+		return loadRaw(r.File, channels)
+	}
+	for _, f := range r.File {
+		if f.Name == trackutils.NMEAFile {
+			nls, err := trackutils.NMEA(f)
+			if err != nil {
+				return nil, err
+			}
+			return corrected(nls)
+		}
+	}
+	return nil, fmt.Errorf("no %s found in %s, try the raw replay", trackutils.NMEAFile, trackfile)
+}
+
+// corrected builds the lines from the nmea lines with the corrected time stamp prefix
+func corrected(nls []string) ([]Line, error) {
+	lls, err := model.ParseLines2LogLines(nls, false)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(lls, func(i, j int) bool {
+		return lls[i].CorrectTimeStamp.Before(lls[j].CorrectTimeStamp)
+	})
+	lines := make([]Line, 0, len(lls))
+	for _, ll := range lls {
+		text := strings.TrimSpace(ll.Unknown)
+		if text == "" {
+			continue
+		}
+		lines = append(lines, Line{
+			Offset:  ll.CorrectTimeStamp.Sub(lls[0].CorrectTimeStamp),
+			Channel: ll.Channel,
+			Text:    text,
+			LogLine: ll,
+		})
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("no nmea lines found")
+	}
+	return lines, nil
+}
+
+// loadRaw builds the lines from the DAT files of the track in file name order. The logger time restarts with every
+// file, so the files are played one after the other.
+func loadRaw(files []*zip.File, channels []string) ([]Line, error) {
+	dats := make([]*zip.File, 0)
+	for _, f := range files {
+		if strings.EqualFold(path.Ext(f.Name), ".dat") {
+			dats = append(dats, f)
+		}
+	}
+	sort.Slice(dats, func(i, j int) bool { return dats[i].Name < dats[j].Name })
+
+	lines := make([]Line, 0)
+	var base time.Duration
+	for _, f := range dats {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		first, last := time.Duration(-1), time.Duration(0)
+		sc := bufio.NewScanner(rc)
+		for sc.Scan() {
+			// broken sentences are replayed as they are, only lines without a sentence are skipped
+			ll, _, _ := model.ParseLogLine(sc.Text())
+			if ll == nil || !isSentence(strings.TrimSpace(ll.Unknown)) {
+				continue
+			}
+			if len(channels) > 0 && !slices.Contains(channels, ll.Channel) {
+				continue
+			}
+			if first < 0 {
+				first = ll.Duration
+			}
+			off := base + max(ll.Duration-first, last-base)
+			last = off
+			lines = append(lines, Line{Offset: off, Channel: ll.Channel, Text: strings.TrimSpace(ll.Unknown), LogLine: ll})
+		}
+		rc.Close()
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		base = last
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("no raw lines found")
+	}
+	return lines, nil
+}
+
+func isSentence(s string) bool {
+	return strings.HasPrefix(s, "$") || strings.HasPrefix(s, "!")
+}
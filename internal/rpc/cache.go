@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultCacheSize the default number of cached results
+const DefaultCacheSize = 32
+
+// Cache caches parsed data between the calls. The key contains the identity of the files (path, size and
+// modification time), so changed files are parsed again. If the cache is full, the least recently used entry is
+// dropped.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]any
+	// order the keys, the least recently used first
+	order []string
+	hits  int
+}
+
+// NewCache creates a new cache with the max number of entries
+func NewCache(size int) *Cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &Cache{
+		size:    size,
+		entries: make(map[string]any),
+		order:   make([]string, 0),
+	}
+}
+
+// Key builds the key of the cache from the name and the identity of the files
+func Key(name string, files ...string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, f := range files {
+		fn, err := filepath.Abs(f)
+		if err != nil {
+			return "", err
+		}
+		fi, err := os.Stat(fn)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "|%s;%d;%d", fn, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return sb.String(), nil
+}
+
+// Get returns the cached value of the key
+func (c *Cache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[key]
+	if ok {
+		c.hits++
+		c.touch(key)
+	}
+	return v, ok
+}
+
+// Put stores the value for the key
+func (c *Cache) Put(key string, v any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = v
+	c.touch(key)
+}
+
+// Clear removes all entries, the number of removed entries is returned
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	c.entries = make(map[string]any)
+	c.order = make([]string, 0)
+	return n
+}
+
+// Stats the number of entries and cache hits
+func (c *Cache) Stats() (entries, hits int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries), c.hits
+}
+
+// touch moves the key to the end of the order
+func (c *Cache) touch(key string) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
@@ -0,0 +1,498 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	exreg "github.com/willie68/osmltools/internal/export/registry"
+	"github.com/willie68/osmltools/internal/geo"
+	imreg "github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logger"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/osml"
+)
+
+// CardParams the data files to work with: a sd card folder with optional file names, or a single file
+type CardParams struct {
+	SDCard string   `json:"sdcard"`
+	Files  []string `json:"files,omitempty"`
+}
+
+// paths the paths of the files, without files the data files of the folder or the single file
+func (p CardParams) paths() ([]string, error) {
+	if p.SDCard == "" {
+		return nil, NewError(CodeInvalidParams, "sdcard is needed")
+	}
+	if len(p.Files) > 0 {
+		ps := make([]string, 0, len(p.Files))
+		for _, f := range p.Files {
+			ps = append(ps, filepath.Join(p.SDCard, strings.TrimSpace(f)))
+		}
+		return ps, nil
+	}
+	fi, err := os.Stat(p.SDCard)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{p.SDCard}, nil
+	}
+	return osml.GetDataFiles(p.SDCard)
+}
+
+// CheckParams the params of check
+type CheckParams struct {
+	CardParams
+	Output    string `json:"output,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+	Report    bool   `json:"report,omitempty"`
+}
+
+// SimplifyParams the simplification of the waypoints, like the flags of the export
+type SimplifyParams struct {
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// Interval a duration like 10s
+	Interval          string   `json:"interval,omitempty"`
+	Distance          float64  `json:"distance,omitempty"`
+	CornerAngle       *float64 `json:"cornerAngle,omitempty"`
+	KeepDepthExtremes *bool    `json:"keepDepthExtremes,omitempty"`
+}
+
+// ConvertParams the params of convert, the card data or a track
+type ConvertParams struct {
+	CardParams
+	Track    string          `json:"track,omitempty"`
+	Simplify *SimplifyParams `json:"simplify,omitempty"`
+}
+
+// ExportParams the params of export, the card data or a track
+type ExportParams struct {
+	CardParams
+	Track      string          `json:"track,omitempty"`
+	Output     string          `json:"output"`
+	Format     string          `json:"format,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Options    []string        `json:"options,omitempty"`
+	SingleFile bool            `json:"singleFile,omitempty"`
+	Series     string          `json:"series,omitempty"`
+	From       string          `json:"from,omitempty"`
+	To         string          `json:"to,omitempty"`
+	Clip       string          `json:"clip,omitempty"`
+	NoPrivacy  bool            `json:"noPrivacy,omitempty"`
+	Simplify   *SimplifyParams `json:"simplify,omitempty"`
+	Vessel     int32           `json:"vessel,omitempty"`
+	DepthRef   string          `json:"depthRef,omitempty"`
+	WaterLevel string          `json:"waterLevel,omitempty"`
+}
+
+// TrackParams the params of the track methods
+type TrackParams struct {
+	CardParams
+	Track       string `json:"track"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	VesselID    int32  `json:"vesselId,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	Clip        string `json:"clip,omitempty"`
+	Reset       bool   `json:"reset,omitempty"`
+}
+
+// LoggerParams the params of the logger config methods
+type LoggerParams struct {
+	SDCard string `json:"sdcard"`
+	// Config the logger config to write, missing values are taken from the default config
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+func (s *service) register() {
+	s.methods = map[string]method{
+		"version":      s.versionInfo,
+		"formats":      s.formats,
+		"check":        s.check,
+		"convert":      s.convert,
+		"export":       s.export,
+		"track.new":    s.trackNew,
+		"track.add":    s.trackAdd,
+		"track.list":   s.trackList,
+		"track.trim":   s.trackTrim,
+		"logger.read":  s.loggerRead,
+		"logger.write": s.loggerWrite,
+		"cache.stats":  s.cacheStats,
+		"cache.clear":  s.cacheClear,
+		"shutdown":     s.shutdown,
+	}
+}
+
+func (s *service) versionInfo(_ *call) (any, error) {
+	return map[string]string{
+		"version": s.version.Version(),
+		"commit":  s.version.Commit(),
+		"date":    s.version.Date(),
+	}, nil
+}
+
+func (s *service) formats(_ *call) (any, error) {
+	return map[string]any{
+		"export": exreg.Formats(),
+		"import": imreg.Formats(),
+	}, nil
+}
+
+// check checks the files one by one with a progress notification per file. Without output the results of the
+// files are cached.
+func (s *service) check(c *call) (any, error) {
+	var p CheckParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	files, err := p.paths()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no data files found in %s", p.SDCard)
+	}
+	result := model.NewCheckResult()
+	for i, f := range files {
+		c.progress(filepath.Base(f), i+1, len(files))
+		fr, err := s.checkFile(f, p)
+		if err != nil {
+			return nil, err
+		}
+		for fn, r := range fr.Files {
+			result.WithFileResult(fn, r)
+		}
+		result.ErrorTags += fr.ErrorTags
+		result.UnknownTags += fr.UnknownTags
+	}
+	result.Calc()
+	if p.Report && p.Output != "" {
+		if err := s.chk.WriteResult(p.Output, *result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *service) checkFile(f string, p CheckParams) (*model.CheckResult, error) {
+	if p.Output != "" {
+		return s.chk.Check(f, p.Output, p.Overwrite, false)
+	}
+	key, err := Key("check", f)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := s.cache.Get(key); ok {
+		return v.(*model.CheckResult), nil
+	}
+	res, err := s.chk.Check(f, "", false, false)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Put(key, res)
+	return res, nil
+}
+
+// convert converts the card data or the track into waypoints for the ui, the parsed waypoints are cached and only
+// the simplification is done on every call
+func (s *service) convert(c *call) (any, error) {
+	var p ConvertParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	simp, err := p.Simplify.simplification()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if p.Track != "" {
+		files = []string{p.Track}
+	} else {
+		if files, err = p.paths(); err != nil {
+			return nil, err
+		}
+	}
+	key, err := Key("convert", files...)
+	if err != nil {
+		return nil, err
+	}
+	var tps *model.TrackPoints
+	if v, ok := s.cache.Get(key); ok {
+		tps = v.(*model.TrackPoints)
+	} else {
+		c.progress(fmt.Sprintf("parsing %d files", len(files)), 1, 2)
+		tps, err = s.cnv.Convert(p.SDCard, p.Files, p.Track)
+		if err != nil {
+			return nil, err
+		}
+		// only the waypoints are needed
+		tps.LogLines = nil
+		s.cache.Put(key, tps)
+	}
+	c.progress("simplifying", 2, 2)
+	res := *tps
+	res.Simplify(simp)
+	res.LogLines = make([]*model.LogLine, 0)
+	return res, nil
+}
+
+// export exports the card data or the track with the exporter
+func (s *service) export(c *call) (any, error) {
+	var p ExportParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	if p.Output == "" {
+		return nil, NewError(CodeInvalidParams, "output is needed")
+	}
+	format := "NMEA"
+	if p.Format != "" {
+		format = strings.ToUpper(strings.TrimSpace(p.Format))
+	}
+	if _, ok := exreg.Get(format); !ok {
+		return nil, NewError(CodeInvalidParams, "the format %s is not supported. Supported formats are: %v", format, exreg.Names())
+	}
+	opts, err := s.exportOptions(p)
+	if err != nil {
+		return nil, err
+	}
+	c.progress(fmt.Sprintf("exporting to %s", p.Output), 1, 1)
+	if p.Track != "" {
+		err = s.exp.ExportTrack(p.Track, p.Output, format, opts)
+	} else {
+		if p.SDCard == "" {
+			return nil, NewError(CodeInvalidParams, "sdcard or track is needed")
+		}
+		err = s.exp.Export(p.SDCard, p.Output, p.Files, format, p.Name, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return model.GeneralResult{Result: true, Messages: []string{fmt.Sprintf("exported as %s to %s", format, p.Output)}}, nil
+}
+
+// exportOptions the export options of the params, the privacy zones and the vessels are taken from the user config
+func (s *service) exportOptions(p ExportParams) (model.ExportOptions, error) {
+	opts := model.ExportOptions{
+		FormatOptions:  p.Options,
+		SingleFile:     p.SingleFile,
+		Vessels:        s.cfg.Vessels,
+		VesselID:       p.Vessel,
+		DepthReference: p.DepthRef,
+	}
+	if !p.NoPrivacy {
+		opts.PrivacyZones = s.cfg.PrivacyZones
+	}
+	var err error
+	if opts.Trim, err = trim(p.From, p.To, p.Clip); err != nil {
+		return opts, err
+	}
+	if opts.Simplification, err = p.Simplify.simplification(); err != nil {
+		return opts, err
+	}
+	if p.Series != "" {
+		if opts.Series, err = model.ParseSeries(p.Series); err != nil {
+			return opts, NewError(CodeInvalidParams, "%v", err)
+		}
+	}
+	if p.WaterLevel != "" {
+		if opts.WaterLevels, err = model.ReadWaterLevels(p.WaterLevel); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+func (s *service) trackNew(c *call) (any, error) {
+	var p TrackParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	if err := p.check(true); err != nil {
+		return nil, err
+	}
+	name := p.Name
+	if name == "" {
+		name = "track"
+	}
+	c.progress(fmt.Sprintf("creating track %s", p.Track), 1, 1)
+	t := model.Track{
+		Files:       make([]model.SourceData, 0),
+		Name:        name,
+		Description: p.Description,
+		VesselID:    p.VesselID,
+	}
+	if err := s.tm.NewTrack(p.SDCard, p.Files, p.Track, t); err != nil {
+		return nil, err
+	}
+	return s.tm.ListTrack(p.Track)
+}
+
+func (s *service) trackAdd(c *call) (any, error) {
+	var p TrackParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	if err := p.check(true); err != nil {
+		return nil, err
+	}
+	c.progress(fmt.Sprintf("adding %d files to track %s", len(p.Files), p.Track), 1, 1)
+	if err := s.tm.AddTrack(p.SDCard, p.Files, p.Track); err != nil {
+		return nil, err
+	}
+	return s.tm.ListTrack(p.Track)
+}
+
+func (s *service) trackList(c *call) (any, error) {
+	var p TrackParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	if err := p.check(false); err != nil {
+		return nil, err
+	}
+	return s.tm.ListTrack(p.Track)
+}
+
+func (s *service) trackTrim(c *call) (any, error) {
+	var p TrackParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	if err := p.check(false); err != nil {
+		return nil, err
+	}
+	t, err := trim(p.From, p.To, p.Clip)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil && !p.Reset {
+		return nil, NewError(CodeInvalidParams, "from, to or clip is needed, or reset to remove the trim")
+	}
+	if p.Reset {
+		t = nil
+	}
+	return s.tm.TrimTrack(p.Track, t)
+}
+
+func (s *service) loggerRead(c *call) (any, error) {
+	var p LoggerParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	if p.SDCard == "" {
+		return nil, NewError(CodeInvalidParams, "sdcard is needed")
+	}
+	return logger.ReadFromSDCard(p.SDCard)
+}
+
+func (s *service) loggerWrite(c *call) (any, error) {
+	var p LoggerParams
+	if err := c.params(&p); err != nil {
+		return nil, err
+	}
+	if p.SDCard == "" {
+		return nil, NewError(CodeInvalidParams, "sdcard is needed")
+	}
+	cfg := logger.NewLoggerConfig()
+	if len(p.Config) > 0 {
+		if err := json.Unmarshal(p.Config, cfg); err != nil {
+			return nil, NewError(CodeInvalidParams, "invalid logger config: %v", err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, NewError(CodeInvalidParams, "%v", err)
+	}
+	if err := cfg.WriteToSDCard(p.SDCard); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (s *service) cacheStats(_ *call) (any, error) {
+	entries, hits := s.cache.Stats()
+	return map[string]int{"entries": entries, "hits": hits}, nil
+}
+
+func (s *service) cacheClear(_ *call) (any, error) {
+	return map[string]int{"removed": s.cache.Clear()}, nil
+}
+
+// shutdown ends the session after the response
+func (s *service) shutdown(c *call) (any, error) {
+	c.s.stop = true
+	return nil, nil
+}
+
+// check checks the needed params of the track methods
+func (p TrackParams) check(withFiles bool) error {
+	if p.Track == "" {
+		return NewError(CodeInvalidParams, "track is needed")
+	}
+	if withFiles && (p.SDCard == "" || len(p.Files) == 0) {
+		return NewError(CodeInvalidParams, "sdcard and files are needed")
+	}
+	return nil
+}
+
+// simplification the simplification of the params with the defaults of the flags, nil for no simplification
+func (p *SimplifyParams) simplification() (*model.Simplification, error) {
+	if p == nil {
+		return nil, nil
+	}
+	s := &model.Simplification{
+		Tolerance:         p.Tolerance,
+		Distance:          p.Distance,
+		CornerAngle:       model.DefaultCornerAngle,
+		KeepDepthExtremes: true,
+	}
+	if p.Interval != "" {
+		d, err := time.ParseDuration(p.Interval)
+		if err != nil {
+			return nil, NewError(CodeInvalidParams, "invalid interval %s", p.Interval)
+		}
+		s.Interval = d
+	}
+	if p.CornerAngle != nil {
+		s.CornerAngle = *p.CornerAngle
+	}
+	if p.KeepDepthExtremes != nil {
+		s.KeepDepthExtremes = *p.KeepDepthExtremes
+	}
+	if err := s.Validate(); err != nil {
+		return nil, NewError(CodeInvalidParams, "%v", err)
+	}
+	if s.IsEmpty() {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// trim the trim of the params, nil if nothing is set
+func trim(from, to, clip string) (*model.Trim, error) {
+	t := &model.Trim{}
+	var err error
+	if t.From, err = model.ParseTrimTime(from); err != nil {
+		return nil, NewError(CodeInvalidParams, "%v", err)
+	}
+	if t.To, err = model.ParseTrimTime(to); err != nil {
+		return nil, NewError(CodeInvalidParams, "%v", err)
+	}
+	if clip != "" {
+		if t.Clip, err = geo.ReadPolygons(clip); err != nil {
+			return nil, fmt.Errorf("can't read clip area %s: %w", clip, err)
+		}
+	}
+	if t.IsEmpty() {
+		return nil, nil
+	}
+	if err := t.Validate(); err != nil {
+		return nil, NewError(CodeInvalidParams, "%v", err)
+	}
+	return t, nil
+}
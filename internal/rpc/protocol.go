@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version the json-rpc version
+const Version = "2.0"
+
+// the error codes of json-rpc 2.0
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeServerError error of a service, e.g. a missing file
+	CodeServerError = -32000
+)
+
+// ProgressMethod the method of the progress notifications
+const ProgressMethod = "progress"
+
+// Request a json-rpc request, a request without id is a notification and gets no response
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification checks if the request is a notification
+func (r *Request) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// Response a json-rpc response, either with result or with error
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Notification a json-rpc notification of the server, e.g. the progress of a request
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// Progress the params of the progress notification
+type Progress struct {
+	// ID the id of the request
+	ID json.RawMessage `json:"id"`
+	// Method the method of the request
+	Method string `json:"method"`
+	// Message what is done, e.g. the file name
+	Message string `json:"message,omitempty"`
+	// Current the number of the current step, starting with 1
+	Current int `json:"current"`
+	// Total the number of all steps
+	Total int `json:"total"`
+}
+
+// Error a json-rpc error
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// NewError creates a new error with code and message
+func NewError(code int, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
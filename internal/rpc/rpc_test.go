@@ -0,0 +1,279 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/check"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/convert"
+	"github.com/willie68/osmltools/internal/export"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/track"
+)
+
+const sdcard = "../../testdata/sdcard"
+
+// message a response or a notification
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+	Params Progress        `json:"params"`
+}
+
+type RPCSuite struct {
+	suite.Suite
+	srv *service
+	dir string
+	cfg string
+}
+
+func TestRPCSuite(t *testing.T) {
+	suite.Run(t, new(RPCSuite))
+}
+
+func (s *RPCSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.cfg = config.UserConfigFile
+	config.UserConfigFile = filepath.Join(s.dir, "config.json")
+	inj := do.New()
+	check.Init(inj)
+	config.Init(inj)
+	importer.Init(inj)
+	export.Init(inj)
+	track.Init(inj)
+	convert.Init(inj)
+	Init(inj)
+	s.srv = do.MustInvoke[*service](inj)
+}
+
+func (s *RPCSuite) TearDownTest() {
+	config.UserConfigFile = s.cfg
+}
+
+// run sends the lines and returns all written messages
+func (s *RPCSuite) run(lines ...string) []message {
+	var out bytes.Buffer
+	err := s.srv.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &out)
+	s.Require().NoError(err)
+	msgs := make([]message, 0)
+	for l := range strings.SplitSeq(strings.TrimSpace(out.String()), "\n") {
+		if l == "" {
+			continue
+		}
+		var m message
+		s.Require().NoError(json.Unmarshal([]byte(l), &m), l)
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func request(id int, method string, params any) string {
+	r := map[string]any{"jsonrpc": Version, "id": id, "method": method}
+	if params != nil {
+		r["params"] = params
+	}
+	js, _ := json.Marshal(r)
+	return string(js)
+}
+
+// responses the responses without the notifications
+func responses(msgs []message) []message {
+	res := make([]message, 0)
+	for _, m := range msgs {
+		if m.Method == "" {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+func (s *RPCSuite) TestProtocol() {
+	msgs := s.run(
+		request(1, "version", nil),
+		`{"jsonrpc":"2.0","method":"version"}`,
+		`{"jsonrpc":"2.0","id":"a","method":"unknown"}`,
+		`{"jsonrpc":"1.0","id":2,"method":"version"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"version"`,
+		request(4, "convert", map[string]any{"sdcard": sdcard, "file": "DATA001231.DAT"}),
+		`[]`,
+	)
+	s.Require().Len(msgs, 6)
+	s.Equal("1", string(msgs[0].ID))
+	s.Nil(msgs[0].Error)
+	s.Contains(string(msgs[0].Result), `"version"`)
+	s.Equal(CodeMethodNotFound, msgs[1].Error.Code)
+	s.Equal(`"a"`, string(msgs[1].ID))
+	s.Equal(CodeInvalidRequest, msgs[2].Error.Code)
+	s.Equal(CodeParseError, msgs[3].Error.Code)
+	s.Equal("null", string(msgs[3].ID))
+	s.Equal(CodeInvalidParams, msgs[4].Error.Code)
+	s.Contains(msgs[4].Error.Message, "unknown field")
+	s.Equal(CodeInvalidRequest, msgs[5].Error.Code)
+
+	// the batch is answered as array
+	var out bytes.Buffer
+	s.Require().NoError(s.srv.Serve(context.Background(), strings.NewReader(`[`+request(5, "version", nil)+`,{"jsonrpc":"2.0","method":"version"}]`), &out))
+	var batch []message
+	s.Require().NoError(json.Unmarshal(out.Bytes(), &batch))
+	s.Len(batch, 1)
+}
+
+func (s *RPCSuite) TestShutdown() {
+	msgs := s.run(request(1, "shutdown", nil), request(2, "version", nil))
+	s.Require().Len(msgs, 1)
+	s.JSONEq(`{"result":true,"message":null}`, string(msgs[0].Result))
+}
+
+func (s *RPCSuite) TestConvertCached() {
+	params := map[string]any{"sdcard": sdcard, "files": []string{"DATA001231.DAT"}}
+	simplified := map[string]any{"sdcard": sdcard, "files": []string{"DATA001231.DAT"}, "simplify": map[string]any{"tolerance": 10}}
+	msgs := s.run(
+		request(1, "convert", params),
+		request(2, "convert", simplified),
+		request(3, "cache.stats", nil),
+	)
+	s.Require().Len(msgs, 6)
+	// only the first call parses the files
+	s.Equal(ProgressMethod, msgs[0].Method)
+	s.Equal("1", string(msgs[0].Params.ID))
+	s.Equal(1, msgs[0].Params.Current)
+	s.Equal(2, msgs[0].Params.Total)
+	s.Equal("simplifying", msgs[1].Params.Message)
+	s.Equal("simplifying", msgs[3].Params.Message)
+
+	res := responses(msgs)
+	var full, simp struct {
+		Waypoints []json.RawMessage `json:"waypoints"`
+		LogLines  []json.RawMessage `json:"log_lines"`
+	}
+	s.Require().NoError(json.Unmarshal(res[0].Result, &full))
+	s.Require().NoError(json.Unmarshal(res[1].Result, &simp))
+	s.NotEmpty(full.Waypoints)
+	s.Empty(full.LogLines)
+	s.Less(len(simp.Waypoints), len(full.Waypoints))
+	s.JSONEq(`{"entries":1,"hits":1}`, string(res[2].Result))
+
+	// a changed file is parsed again
+	df := filepath.Join(s.dir, "DATA001231.DAT")
+	data, err := os.ReadFile(filepath.Join(sdcard, "DATA001231.DAT"))
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(df, data, 0o600))
+	params = map[string]any{"sdcard": s.dir, "files": []string{"DATA001231.DAT"}}
+	s.run(request(1, "convert", params))
+	s.Require().NoError(os.Chtimes(df, time.Now(), time.Now().Add(time.Minute)))
+	msgs = s.run(request(1, "convert", params), request(2, "cache.clear", nil))
+	s.Equal("parsing 1 files", msgs[0].Params.Message)
+	s.JSONEq(`{"removed":3}`, string(responses(msgs)[1].Result))
+}
+
+func (s *RPCSuite) TestCheck() {
+	params := map[string]any{"sdcard": sdcard, "files": []string{"DATA001231.DAT", "DATA001232.DAT"}}
+	msgs := s.run(request(1, "check", params), request(2, "check", params), request(3, "cache.stats", nil))
+	s.Require().Len(msgs, 7)
+	s.Equal("DATA001231.DAT", msgs[0].Params.Message)
+	s.Equal("DATA001232.DAT", msgs[1].Params.Message)
+	s.Equal(2, msgs[1].Params.Total)
+	res := responses(msgs)
+	var cr, cached struct {
+		Files map[string]json.RawMessage `json:"files"`
+	}
+	s.Require().NoError(json.Unmarshal(res[0].Result, &cr))
+	s.Require().NoError(json.Unmarshal(res[1].Result, &cached))
+	s.Len(cr.Files, 2)
+	s.Equal(cr.Files, cached.Files)
+	s.JSONEq(`{"entries":2,"hits":2}`, string(res[2].Result))
+
+	// with output the files are written
+	out := filepath.Join(s.dir, "out")
+	params = map[string]any{"sdcard": sdcard, "files": []string{"DATA001231.DAT"}, "output": out, "report": true}
+	msgs = responses(s.run(request(1, "check", params)))
+	s.Nil(msgs[0].Error)
+	s.FileExists(filepath.Join(out, "report.json"))
+}
+
+func (s *RPCSuite) TestTrackAndExport() {
+	tf := filepath.Join(s.dir, "track.zip")
+	msgs := responses(s.run(
+		request(1, "track.new", map[string]any{"track": tf, "sdcard": sdcard, "files": []string{"DATA001231.DAT"}, "name": "test", "vesselId": 597}),
+		request(2, "track.list", map[string]any{"track": tf}),
+		request(3, "track.trim", map[string]any{"track": tf}),
+		request(4, "export", map[string]any{"track": tf, "output": filepath.Join(s.dir, "track.gpx"), "format": "gpx"}),
+		request(5, "export", map[string]any{"track": tf, "output": filepath.Join(s.dir, "track.doc"), "format": "doc"}),
+		request(6, "track.new", map[string]any{"track": tf}),
+	))
+	s.Require().Len(msgs, 6)
+	s.Nil(msgs[0].Error)
+	var t struct {
+		Name     string `json:"name"`
+		VesselID int32  `json:"vesselID"`
+	}
+	s.Require().NoError(json.Unmarshal(msgs[1].Result, &t))
+	s.Equal("test", t.Name)
+	s.Equal(CodeInvalidParams, msgs[2].Error.Code)
+	s.Nil(msgs[3].Error)
+	s.FileExists(filepath.Join(s.dir, "track.gpx"))
+	s.Equal(CodeInvalidParams, msgs[4].Error.Code)
+	s.Equal(CodeInvalidParams, msgs[5].Error.Code)
+
+	msgs = responses(s.run(request(1, "track.list", map[string]any{"track": filepath.Join(s.dir, "unknown.zip")})))
+	s.Equal(CodeServerError, msgs[0].Error.Code)
+}
+
+func (s *RPCSuite) TestLoggerConfig() {
+	msgs := responses(s.run(
+		request(1, "logger.write", map[string]any{"sdcard": s.dir, "config": map[string]any{"baudA": 9600, "vesselID": 597}}),
+		request(2, "logger.read", map[string]any{"sdcard": s.dir}),
+		request(3, "logger.write", map[string]any{"sdcard": s.dir, "config": map[string]any{"baudB": 9600}}),
+	))
+	s.Require().Len(msgs, 3)
+	s.Nil(msgs[0].Error)
+	var cfg struct {
+		BaudA    int16 `json:"baudA"`
+		BaudB    int16 `json:"baudB"`
+		Gyro     bool  `json:"gyro"`
+		VesselID int16 `json:"vesselID"`
+	}
+	s.Require().NoError(json.Unmarshal(msgs[1].Result, &cfg))
+	s.Equal(int16(9600), cfg.BaudA)
+	s.Equal(int16(4800), cfg.BaudB)
+	s.True(cfg.Gyro)
+	s.Equal(int16(597), cfg.VesselID)
+	s.Equal(CodeInvalidParams, msgs[2].Error.Code)
+}
+
+func (s *RPCSuite) TestCache() {
+	c := NewCache(2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	_, ok := c.Get("a")
+	s.True(ok)
+	// b is the least recently used
+	c.Put("c", 3)
+	_, ok = c.Get("b")
+	s.False(ok)
+	v, ok := c.Get("a")
+	s.True(ok)
+	s.Equal(1, v)
+	entries, hits := c.Stats()
+	s.Equal(2, entries)
+	s.Equal(2, hits)
+
+	_, err := Key("x", filepath.Join(s.dir, "missing"))
+	s.Error(err)
+	k1, err := Key("x", filepath.Join(sdcard, "DATA001231.DAT"))
+	s.Require().NoError(err)
+	k2, err := Key("y", filepath.Join(sdcard, "DATA001231.DAT"))
+	s.Require().NoError(err)
+	s.NotEqual(k1, k2)
+}
@@ -0,0 +1,231 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+type checkerSrv interface {
+	Check(sdCardFolder, outputFolder string, overwrite, report bool) (*model.CheckResult, error)
+	WriteResult(of string, res model.CheckResult) error
+}
+
+type exporterSrv interface {
+	Export(sdCardFolder, outputFolder string, files []string, format, name string, opts model.ExportOptions) error
+	ExportTrack(trackfile, outputfile, format string, opts model.ExportOptions) error
+}
+
+type trackManager interface {
+	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
+	AddTrack(sdCardFolder string, files []string, trackfile string) error
+	ListTrack(trackfile string) (*model.Track, error)
+	TrimTrack(trackfile string, trim *model.Trim) (*model.Track, error)
+}
+
+type converterSrv interface {
+	Convert(sdCardFolder string, files []string, track string) (tps *model.TrackPoints, err error)
+}
+
+// method the handler of a rpc method
+type method func(c *call) (any, error)
+
+// service the json-rpc service, the methods work with the other services
+type service struct {
+	log     logging.Logger
+	chk     checkerSrv
+	exp     exporterSrv
+	tm      trackManager
+	cnv     converterSrv
+	cfg     *config.UserConfig
+	version *config.Version
+	cache   *Cache
+	methods map[string]method
+}
+
+// Init registers the json-rpc service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(inj do.Injector) (*service, error) {
+		cfg, err := do.Invoke[*config.UserConfig](inj)
+		if err != nil {
+			return nil, err
+		}
+		s := &service{
+			log:     *logging.New().WithName("RPC"),
+			chk:     do.MustInvokeAs[checkerSrv](inj),
+			exp:     do.MustInvokeAs[exporterSrv](inj),
+			tm:      do.MustInvokeAs[trackManager](inj),
+			cnv:     do.MustInvokeAs[converterSrv](inj),
+			cfg:     cfg,
+			version: do.MustInvoke[*config.Version](inj),
+			cache:   NewCache(DefaultCacheSize),
+		}
+		s.register()
+		return s, nil
+	})
+}
+
+// session a connection to a client, the responses and notifications are written line by line
+type session struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// stop is set by the shutdown method
+	stop bool
+}
+
+func (ss *session) write(v any) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.enc.Encode(v)
+}
+
+// call a single method call
+type call struct {
+	s   *session
+	req *Request
+}
+
+// params decodes the params of the request, unknown fields are rejected
+func (c *call) params(v any) error {
+	if len(c.req.Params) == 0 || bytes.Equal(c.req.Params, []byte("null")) {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(c.req.Params))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return NewError(CodeInvalidParams, "invalid params: %v", err)
+	}
+	return nil
+}
+
+// progress sends a progress notification, not for notifications of the client
+func (c *call) progress(msg string, current, total int) {
+	if c.req.IsNotification() {
+		return
+	}
+	_ = c.s.write(Notification{
+		JSONRPC: Version,
+		Method:  ProgressMethod,
+		Params: Progress{
+			ID:      c.req.ID,
+			Method:  c.req.Method,
+			Message: msg,
+			Current: current,
+			Total:   total,
+		},
+	})
+}
+
+// Serve reads one request (or batch) per line from in and writes the responses and the notifications line by line
+// to out, until the end of the input, the shutdown method or the end of the context. The requests are executed one
+// after the other.
+func (s *service) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	ss := &session{enc: json.NewEncoder(out)}
+	r := bufio.NewReader(in)
+	for !ss.stop && ctx.Err() == nil {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if werr := s.handleLine(ss, line); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleLine executes a single request or a batch
+func (s *service) handleLine(ss *session, line []byte) error {
+	line = bytes.TrimSpace(line)
+	if line[0] != '[' {
+		res := s.handleMessage(ss, line)
+		if res == nil {
+			return nil
+		}
+		return ss.write(res)
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(line, &batch); err != nil {
+		return ss.write(errorResponse(nil, NewError(CodeParseError, "parse error: %v", err)))
+	}
+	if len(batch) == 0 {
+		return ss.write(errorResponse(nil, NewError(CodeInvalidRequest, "empty batch")))
+	}
+	ress := make([]*Response, 0, len(batch))
+	for _, msg := range batch {
+		if res := s.handleMessage(ss, msg); res != nil {
+			ress = append(ress, res)
+		}
+	}
+	if len(ress) == 0 {
+		return nil
+	}
+	return ss.write(ress)
+}
+
+// handleMessage executes a request, nil for notifications
+func (s *service) handleMessage(ss *session, msg []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			return errorResponse(nil, NewError(CodeParseError, "parse error: %v", err))
+		}
+		return errorResponse(nil, NewError(CodeInvalidRequest, "invalid request: %v", err))
+	}
+	if req.JSONRPC != Version || req.Method == "" {
+		return errorResponse(req.ID, NewError(CodeInvalidRequest, "invalid request, jsonrpc must be %s and the method is needed", Version))
+	}
+	res, err := s.execute(&call{s: ss, req: &req})
+	if req.IsNotification() {
+		return nil
+	}
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	if res == nil {
+		res = model.GeneralResult{Result: true}
+	}
+	return &Response{JSONRPC: Version, ID: req.ID, Result: res}
+}
+
+// execute calls the method, a panic of a method is answered as internal error
+func (s *service) execute(c *call) (res any, err error) {
+	m, ok := s.methods[c.req.Method]
+	if !ok {
+		return nil, NewError(CodeMethodNotFound, "method %s not found", c.req.Method)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Errorf("method %s panicked: %v", c.req.Method, r)
+			err = NewError(CodeInternalError, "internal error: %v", r)
+		}
+	}()
+	s.log.Debugf("calling method %s", c.req.Method)
+	return m(c)
+}
+
+func errorResponse(id json.RawMessage, err error) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	var re *Error
+	if !errors.As(err, &re) {
+		re = &Error{Code: CodeServerError, Message: err.Error()}
+	}
+	return &Response{JSONRPC: Version, ID: id, Error: re}
+}
@@ -0,0 +1,515 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	exreg "github.com/willie68/osmltools/internal/export/registry"
+	imreg "github.com/willie68/osmltools/internal/importer/registry"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// BasePath the path prefix of all api endpoints
+	BasePath = "/api/v1"
+
+	trackExt = ".zip"
+	cardDir  = "card"
+)
+
+var (
+	// ErrTrackNotFound error for unknown track files
+	ErrTrackNotFound = errors.New("track not found")
+	// ErrTrackExists error for creating an existing track file
+	ErrTrackExists = errors.New("track already exists")
+	// ErrInvalidTrackName error for track names with path elements
+	ErrInvalidTrackName = errors.New("invalid track name")
+	// ErrNoPrivacy error for no-privacy=true on a server not started with --allow-no-privacy
+	ErrNoPrivacy = errors.New("no-privacy is not allowed on this server")
+)
+
+// TrackInfo an entry of the track list
+type TrackInfo struct {
+	Name     string       `json:"name"`
+	Size     int64        `json:"size"`
+	Modified time.Time    `json:"modified"`
+	Track    *model.Track `json:"track,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// api the http handlers of the rest api
+type api struct {
+	srv    *server
+	log    logging.Logger
+	opts   Options
+	tracks string
+	jobs   *Queue
+}
+
+func (a *api) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+BasePath+"/formats", a.formats)
+	mux.HandleFunc("POST "+BasePath+"/check", a.check)
+	mux.HandleFunc("POST "+BasePath+"/export", a.export)
+	mux.HandleFunc("POST "+BasePath+"/backup", a.backup)
+	mux.HandleFunc("GET "+BasePath+"/tracks", a.listTracks)
+	mux.HandleFunc("GET "+BasePath+"/tracks/{name}", a.getTrack)
+	mux.HandleFunc("POST "+BasePath+"/tracks/{name}", a.newTrack)
+	mux.HandleFunc("POST "+BasePath+"/tracks/{name}/data", a.addTrack)
+	mux.HandleFunc("GET "+BasePath+"/tracks/{name}/points", a.trackPoints)
+	mux.HandleFunc("GET "+BasePath+"/tracks/{name}/download", a.downloadTrack)
+	mux.HandleFunc("POST "+BasePath+"/tracks/{name}/export", a.exportTrack)
+	mux.HandleFunc("GET "+BasePath+"/jobs", a.listJobs)
+	mux.HandleFunc("GET "+BasePath+"/jobs/{id}", a.getJob)
+	mux.HandleFunc("DELETE "+BasePath+"/jobs/{id}", a.removeJob)
+	mux.HandleFunc("GET "+BasePath+"/jobs/{id}/download", a.downloadJob)
+	mux.HandleFunc("GET "+BasePath+"/jobs/{id}/files/{file...}", a.downloadFile)
+	return mux
+}
+
+// formats lists the export and import formats
+func (a *api) formats(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"export": exreg.Formats(),
+		"import": imreg.Formats(),
+	})
+}
+
+// check checks the uploaded card, the result files are the cleaned up nmea files and the report
+func (a *api) check(w http.ResponseWriter, r *http.Request) {
+	a.submitCard(w, r, "check", func(card, out string) (any, error) {
+		return a.srv.chk.Check(card, out, true, true)
+	})
+}
+
+// export exports the uploaded card in the format of the query
+func (a *api) export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format, opts, err := a.exportOptions(q)
+	if err != nil {
+		writeError(w, queryStatus(err), err)
+		return
+	}
+	a.submitCard(w, r, "export", func(card, out string) (any, error) {
+		files, err := cardFiles(card)
+		if err != nil {
+			return nil, err
+		}
+		return nil, a.srv.exp.Export(card, out, files, format.Name, q.Get("name"), opts)
+	})
+}
+
+// backup creates a backup zip of the card
+func (a *api) backup(w http.ResponseWriter, r *http.Request) {
+	a.submitCard(w, r, "backup", func(card, out string) (any, error) {
+		name, err := a.srv.bck.Backup(card, out)
+		return map[string]string{"file": name}, err
+	})
+}
+
+func (a *api) listTracks(w http.ResponseWriter, _ *http.Request) {
+	es, err := os.ReadDir(a.tracks)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	tis := make([]TrackInfo, 0)
+	for _, e := range es {
+		name, ok := strings.CutSuffix(e.Name(), trackExt)
+		if e.IsDir() || !ok {
+			continue
+		}
+		ti := TrackInfo{Name: name}
+		if info, err := e.Info(); err == nil {
+			ti.Size = info.Size()
+			ti.Modified = info.ModTime()
+		}
+		ti.Track, err = a.srv.tm.ListTrack(filepath.Join(a.tracks, e.Name()))
+		if err != nil {
+			ti.Error = err.Error()
+		}
+		tis = append(tis, ti)
+	}
+	writeJSON(w, http.StatusOK, tis)
+}
+
+func (a *api) getTrack(w http.ResponseWriter, r *http.Request) {
+	tf, err := a.trackFile(r, true)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	t, err := a.srv.tm.ListTrack(tf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// newTrack creates a new track with the data of the uploaded card, the name of the track is taken from the path
+func (a *api) newTrack(w http.ResponseWriter, r *http.Request) {
+	tf, err := a.trackFile(r, false)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	q := r.URL.Query()
+	t := model.Track{
+		Files:       make([]model.SourceData, 0),
+		Name:        r.PathValue("name"),
+		Description: q.Get("description"),
+	}
+	if v := q.Get("vesselid"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid vessel id %s", v))
+			return
+		}
+		t.VesselID = int32(id)
+	}
+	a.submitCard(w, r, "track new", func(card, _ string) (any, error) {
+		// a job queued before may have created the track
+		if fileExists(tf) {
+			return nil, ErrTrackExists
+		}
+		files, err := cardFiles(card)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.srv.tm.NewTrack(card, files, tf, t); err != nil {
+			return nil, err
+		}
+		return a.srv.tm.ListTrack(tf)
+	})
+}
+
+// addTrack adds the data of the uploaded card to the track
+func (a *api) addTrack(w http.ResponseWriter, r *http.Request) {
+	tf, err := a.trackFile(r, true)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	a.submitCard(w, r, "track add", func(card, _ string) (any, error) {
+		files, err := cardFiles(card)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.srv.tm.AddTrack(card, files, tf); err != nil {
+			return nil, err
+		}
+		return a.srv.tm.ListTrack(tf)
+	})
+}
+
+// trackPoints the waypoints of the track as json document, e.g. for showing the track on a map. The log lines are
+// only added with loglines=true. The privacy zones are applied, see privacyZones.
+func (a *api) trackPoints(w http.ResponseWriter, r *http.Request) {
+	tf, err := a.trackFile(r, true)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	logLines, err := queryBool(r.URL.Query(), "loglines")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	zones, err := a.privacyZones(r.URL.Query())
+	if err != nil {
+		writeError(w, queryStatus(err), err)
+		return
+	}
+	tps, err := a.srv.cnv.Convert("", nil, tf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	tps.ApplyPrivacyZones(zones)
+	writeJSON(w, http.StatusOK, model.NewTrackDocument(*tps, logLines))
+}
+
+func (a *api) downloadTrack(w http.ResponseWriter, r *http.Request) {
+	tf, err := a.trackFile(r, true)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	serveFile(w, r, tf)
+}
+
+// exportTrack exports the track in the format of the query
+func (a *api) exportTrack(w http.ResponseWriter, r *http.Request) {
+	tf, err := a.trackFile(r, true)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	format, opts, err := a.exportOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, queryStatus(err), err)
+		return
+	}
+	name := r.PathValue("name")
+	j, err := a.jobs.Submit("track export", nil, func(_, out string) (any, error) {
+		of := filepath.Join(out, fmt.Sprintf("%s.%s", name, format.Extension))
+		return nil, a.srv.exp.ExportTrack(tf, of, format.Name, opts)
+	})
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	accepted(w, j)
+}
+
+func (a *api) listJobs(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, a.jobs.List())
+}
+
+func (a *api) getJob(w http.ResponseWriter, r *http.Request) {
+	j, err := a.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (a *api) removeJob(w http.ResponseWriter, r *http.Request) {
+	if err := a.jobs.Remove(r.PathValue("id")); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// downloadJob streams all result files of the job as zip
+func (a *api) downloadJob(w http.ResponseWriter, r *http.Request) {
+	j, err := a.finishedJob(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", j.ID+".zip"))
+	if err := writeZip(w, j.Out(), j.Files); err != nil {
+		// the header is already written
+		a.log.Errorf("can't stream the result of job %s: %v", j.ID, err)
+	}
+}
+
+// downloadFile streams a single result file of the job
+func (a *api) downloadFile(w http.ResponseWriter, r *http.Request) {
+	j, err := a.finishedJob(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	file := r.PathValue("file")
+	if !slices.Contains(j.Files, file) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("file %s not found", file))
+		return
+	}
+	serveFile(w, r, filepath.Join(j.Out(), filepath.FromSlash(file)))
+}
+
+func (a *api) finishedJob(id string) (Job, error) {
+	j, err := a.jobs.Get(id)
+	if err != nil {
+		return j, err
+	}
+	if j.Finished == nil {
+		return j, ErrJobActive
+	}
+	return j, nil
+}
+
+// submitCard queues a job working on a card. The card is the uploaded zip or, without upload, the sd card of the
+// server.
+func (a *api) submitCard(w http.ResponseWriter, r *http.Request, kind string, run func(card, out string) (any, error)) {
+	upload := hasUpload(r)
+	if !upload && a.opts.SDCardFolder == "" {
+		writeError(w, http.StatusBadRequest, ErrNoUpload)
+		return
+	}
+	j, err := a.jobs.Submit(kind, func(j *Job) error {
+		if !upload {
+			return nil
+		}
+		return receiveZip(w, r, a.opts.MaxUpload, filepath.Join(j.In(), cardDir))
+	}, func(in, out string) (any, error) {
+		card := a.opts.SDCardFolder
+		if upload {
+			var err error
+			card, err = cardFolder(filepath.Join(in, cardDir))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return run(card, out)
+	})
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	accepted(w, j)
+}
+
+// exportOptions the export format and options of the query: format, opt (multiple), single-file, series, from, to
+// and no-privacy. The privacy zones and the vessels are taken from the user config.
+func (a *api) exportOptions(q url.Values) (exreg.Format, model.ExportOptions, error) {
+	opts := model.ExportOptions{
+		FormatOptions: q["opt"],
+		Vessels:       a.srv.cfg.Vessels,
+	}
+	name := q.Get("format")
+	if name == "" {
+		name = "NMEA"
+	}
+	format, ok := exreg.Get(name)
+	if !ok {
+		return format, opts, fmt.Errorf("the format %s is not supported. Supported formats are: %v", name, exreg.Names())
+	}
+	var err error
+	if opts.SingleFile, err = queryBool(q, "single-file"); err != nil {
+		return format, opts, err
+	}
+	if opts.PrivacyZones, err = a.privacyZones(q); err != nil {
+		return format, opts, err
+	}
+	if s := q.Get("series"); s != "" {
+		if opts.Series, err = model.ParseSeries(s); err != nil {
+			return format, opts, err
+		}
+	}
+	trim := &model.Trim{}
+	if trim.From, err = model.ParseTrimTime(q.Get("from")); err != nil {
+		return format, opts, err
+	}
+	if trim.To, err = model.ParseTrimTime(q.Get("to")); err != nil {
+		return format, opts, err
+	}
+	if !trim.IsEmpty() {
+		opts.Trim = trim
+	}
+	return format, opts, opts.Trim.Validate()
+}
+
+// trackFile the file of the track in the path, exists checks that the track file exists
+func (a *api) trackFile(r *http.Request, exists bool) (string, error) {
+	name := r.PathValue("name")
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\:`) || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidTrackName, name)
+	}
+	tf := filepath.Join(a.tracks, name+trackExt)
+	switch {
+	case exists && !fileExists(tf):
+		return "", fmt.Errorf("%w: %s", ErrTrackNotFound, name)
+	case !exists && fileExists(tf):
+		return "", fmt.Errorf("%w: %s", ErrTrackExists, name)
+	}
+	return tf, nil
+}
+
+func queryBool(q url.Values, name string) (bool, error) {
+	v := q.Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %s for %s", v, name)
+	}
+	return b, nil
+}
+
+func fileExists(fn string) bool {
+	_, err := os.Stat(fn)
+	return err == nil
+}
+
+// serveFile streams the file as attachment, with range requests for resuming downloads
+func serveFile(w http.ResponseWriter, r *http.Request, fn string) {
+	f, err := os.Open(fn)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(fn)))
+	http.ServeContent(w, r, filepath.Base(fn), info.ModTime(), f)
+}
+
+// accepted answers a queued job with its status url
+func accepted(w http.ResponseWriter, j Job) {
+	w.Header().Set("Location", BasePath+"/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// privacyZones the privacy zones of the user config. The zones are only switched off with no-privacy=true, if the
+// server allows it, the api has no authentication.
+func (a *api) privacyZones(q url.Values) (model.PrivacyZones, error) {
+	noPrivacy, err := queryBool(q, "no-privacy")
+	if err != nil {
+		return nil, err
+	}
+	if !noPrivacy {
+		return a.srv.cfg.PrivacyZones, nil
+	}
+	if !a.opts.AllowNoPrivacy {
+		return nil, ErrNoPrivacy
+	}
+	return nil, nil
+}
+
+// queryStatus the http status of an invalid query
+func queryStatus(err error) int {
+	if errors.Is(err, ErrNoPrivacy) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// statusOf the http status of an error
+func statusOf(err error) int {
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &mbe):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrJobNotFound), errors.Is(err, ErrTrackNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrJobActive), errors.Is(err, ErrTrackExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrNoUpload), errors.Is(err, ErrInvalidUpload), errors.Is(err, ErrInvalidTrackName):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/willie68/osmltools/internal/logging"
+)
+
+// JobStatus the status of a job
+type JobStatus string
+
+const (
+	// JobQueued the job waits in the queue
+	JobQueued JobStatus = "queued"
+	// JobRunning the job is running
+	JobRunning JobStatus = "running"
+	// JobDone the job is finished, the result files can be downloaded
+	JobDone JobStatus = "done"
+	// JobFailed the job is finished with an error
+	JobFailed JobStatus = "failed"
+)
+
+var (
+	// ErrQueueFull error if there are too many jobs waiting
+	ErrQueueFull = errors.New("the job queue is full, try again later")
+	// ErrJobNotFound error for unknown jobs
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobActive error for removing a job, which is not finished
+	ErrJobActive = errors.New("the job is not finished")
+)
+
+// JobFunc the work of a job, in is the folder with the uploaded data, results are written to out. The returned
+// value is the json result of the job.
+type JobFunc func(in, out string) (any, error)
+
+// Job a long running job of the queue
+type Job struct {
+	ID       string     `json:"id"`
+	Kind     string     `json:"kind"`
+	Status   JobStatus  `json:"status"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Result   any        `json:"result,omitempty"`
+	// Files the result files, relative to the output folder of the job
+	Files []string `json:"files,omitempty"`
+	dir   string
+	run   JobFunc
+}
+
+// In the folder of the uploaded data
+func (j *Job) In() string {
+	return filepath.Join(j.dir, "in")
+}
+
+// Out the folder of the result files
+func (j *Job) Out() string {
+	return filepath.Join(j.dir, "out")
+}
+
+// Queue the job queue. The jobs are executed one after the other, as the services are not made for concurrent
+// use and the server is running on small hardware.
+type Queue struct {
+	log     logging.Logger
+	dir     string
+	keep    time.Duration
+	mu      sync.RWMutex
+	jobs    map[string]*Job
+	seq     int
+	pending chan *Job
+}
+
+// NewQueue creates a new job queue, the job folders are created in dir. The jobs are only held in memory, job
+// folders of a former run are removed.
+func NewQueue(dir string, size int, keep time.Duration) (*Queue, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Queue{
+		log:     *logging.New().WithName("Jobs"),
+		dir:     dir,
+		keep:    keep,
+		jobs:    make(map[string]*Job),
+		pending: make(chan *Job, size),
+	}, nil
+}
+
+// Submit creates a new job. prepare is called with the job before it is queued, e.g. to store the uploaded data,
+// on error the job is removed.
+func (q *Queue) Submit(kind string, prepare func(j *Job) error, run JobFunc) (Job, error) {
+	q.cleanup()
+	q.mu.Lock()
+	q.seq++
+	now := time.Now()
+	j := &Job{
+		ID:      fmt.Sprintf("%s-%04d", now.Format("20060102150405"), q.seq),
+		Kind:    kind,
+		Status:  JobQueued,
+		Created: now,
+		run:     run,
+	}
+	j.dir = filepath.Join(q.dir, j.ID)
+	q.mu.Unlock()
+
+	err := os.MkdirAll(j.In(), os.ModePerm)
+	if err == nil {
+		err = os.MkdirAll(j.Out(), os.ModePerm)
+	}
+	if err == nil && prepare != nil {
+		err = prepare(j)
+	}
+	if err != nil {
+		_ = os.RemoveAll(j.dir)
+		return Job{}, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- j:
+	default:
+		_ = os.RemoveAll(j.dir)
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[j.ID] = j
+	q.log.Infof("job %s (%s) queued", j.ID, j.Kind)
+	return *j, nil
+}
+
+// Run executes the queued jobs until the context is done
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-q.pending:
+			q.execute(j)
+		}
+	}
+}
+
+func (q *Queue) execute(j *Job) {
+	q.mu.Lock()
+	started := time.Now()
+	j.Status = JobRunning
+	j.Started = &started
+	q.mu.Unlock()
+	q.log.Infof("job %s (%s) started", j.ID, j.Kind)
+
+	res, err := q.safeRun(j)
+	files, ferr := resultFiles(j.Out())
+	if err == nil {
+		err = ferr
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	finished := time.Now()
+	j.Finished = &finished
+	j.Result = res
+	j.Files = files
+	j.Status = JobDone
+	if err != nil {
+		j.Status = JobFailed
+		j.Error = err.Error()
+		q.log.Errorf("job %s (%s) failed: %v", j.ID, j.Kind, err)
+		return
+	}
+	q.log.Infof("job %s (%s) done in %s", j.ID, j.Kind, finished.Sub(started).Round(time.Millisecond))
+}
+
+// safeRun runs the job, a panic of the job fails only the job and not the server
+func (q *Queue) safeRun(j *Job) (res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return j.run(j.In(), j.Out())
+}
+
+// Get returns a copy of the job
+func (q *Queue) Get(id string) (Job, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *j, nil
+}
+
+// List returns a copy of all jobs, the newest first
+func (q *Queue) List() []Job {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].ID > jobs[b].ID
+	})
+	return jobs
+}
+
+// Remove removes a finished job with its files
+func (q *Queue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if j.Finished == nil {
+		return ErrJobActive
+	}
+	delete(q.jobs, id)
+	return os.RemoveAll(j.dir)
+}
+
+// cleanup removes the finished jobs older than the keep time
+func (q *Queue) cleanup() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, j := range q.jobs {
+		if j.Finished != nil && time.Since(*j.Finished) > q.keep {
+			delete(q.jobs, id)
+			if err := os.RemoveAll(j.dir); err != nil {
+				q.log.Errorf("can't remove job folder %s: %v", j.dir, err)
+			}
+		}
+	}
+}
+
+// resultFiles all files of the output folder, relative with slashes
+func resultFiles(out string) ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.WalkDir(out, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(out, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
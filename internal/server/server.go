@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const (
+	// DefaultListen the default listening address
+	DefaultListen = ":8080"
+	// DefaultMaxUpload the default max size of an uploaded zip in bytes
+	DefaultMaxUpload = 512 << 20
+	// DefaultKeep the default time finished jobs are kept
+	DefaultKeep = 24 * time.Hour
+	// DefaultQueueSize the default number of jobs waiting in the queue
+	DefaultQueueSize = 32
+)
+
+// Options options of the http server
+type Options struct {
+	// Listen the listening address, e.g. :8080
+	Listen string
+	// DataDir the folder of the track files and the job results
+	DataDir string
+	// SDCardFolder the sd card of the server, used for jobs without an uploaded zip
+	SDCardFolder string
+	// MaxUpload the max size of an uploaded zip in bytes
+	MaxUpload int64
+	// Keep finished jobs and their results are removed after this time
+	Keep time.Duration
+	// QueueSize the number of jobs waiting in the queue, more jobs are rejected
+	QueueSize int
+	// AllowNoPrivacy clients may switch off the privacy zones with no-privacy=true
+	AllowNoPrivacy bool
+	// Started is called after the server is listening
+	Started func(address string)
+}
+
+type checkerSrv interface {
+	Check(sdCardFolder, outputFolder string, overwrite, report bool) (*model.CheckResult, error)
+}
+
+type exporterSrv interface {
+	Export(sdCardFolder, outputFolder string, files []string, format, name string, opts model.ExportOptions) error
+	ExportTrack(trackfile, outputfile, format string, opts model.ExportOptions) error
+}
+
+type trackManager interface {
+	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
+	AddTrack(sdCardFolder string, files []string, trackfile string) error
+	ListTrack(trackfile string) (*model.Track, error)
+}
+
+type converterSrv interface {
+	Convert(sdCardFolder string, files []string, track string) (tps *model.TrackPoints, err error)
+}
+
+type backupSrv interface {
+	Backup(sdCardFolder, outputFolder string) (string, error)
+}
+
+// server the http service, a rest api over the services
+type server struct {
+	log logging.Logger
+	chk checkerSrv
+	exp exporterSrv
+	tm  trackManager
+	cnv converterSrv
+	bck backupSrv
+	cfg *config.UserConfig
+}
+
+// Init registers the http service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(inj do.Injector) (*server, error) {
+		cfg, err := do.Invoke[*config.UserConfig](inj)
+		if err != nil {
+			return nil, err
+		}
+		return &server{
+			log: *logging.New().WithName("Server"),
+			chk: do.MustInvokeAs[checkerSrv](inj),
+			exp: do.MustInvokeAs[exporterSrv](inj),
+			tm:  do.MustInvokeAs[trackManager](inj),
+			cnv: do.MustInvokeAs[converterSrv](inj),
+			bck: do.MustInvokeAs[backupSrv](inj),
+			cfg: cfg,
+		}, nil
+	})
+}
+
+// Serve starts the job queue and serves the rest api until the context is done
+func (s *server) Serve(ctx context.Context, opts Options) error {
+	if opts.Listen == "" {
+		opts.Listen = DefaultListen
+	}
+	a, err := s.newAPI(opts)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go a.jobs.Run(ctx)
+
+	hs := &http.Server{
+		Handler:           a.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		sctx, scancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer scancel()
+		_ = hs.Shutdown(sctx)
+	}()
+	s.log.Infof("serving the rest api on %s, data folder %s", ln.Addr().String(), a.opts.DataDir)
+	if opts.Started != nil {
+		opts.Started(ln.Addr().String())
+	}
+	err = hs.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// newAPI checks the options and creates the folders and the job queue
+func (s *server) newAPI(opts Options) (*api, error) {
+	if opts.DataDir == "" {
+		return nil, errors.New("no data folder given")
+	}
+	if opts.MaxUpload <= 0 {
+		opts.MaxUpload = DefaultMaxUpload
+	}
+	if opts.Keep <= 0 {
+		opts.Keep = DefaultKeep
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	dd, err := filepath.Abs(opts.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	opts.DataDir = dd
+	a := &api{
+		srv:    s,
+		log:    s.log,
+		opts:   opts,
+		tracks: filepath.Join(dd, "tracks"),
+	}
+	if err := os.MkdirAll(a.tracks, os.ModePerm); err != nil {
+		return nil, err
+	}
+	a.jobs, err = NewQueue(filepath.Join(dd, "jobs"), opts.QueueSize, opts.Keep)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
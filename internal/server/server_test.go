@@ -0,0 +1,362 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/backup"
+	"github.com/willie68/osmltools/internal/check"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/convert"
+	"github.com/willie68/osmltools/internal/export"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/track"
+)
+
+const sdcard = "../../testdata/sdcard"
+
+type ServerSuite struct {
+	suite.Suite
+	api    *api
+	ts     *httptest.Server
+	cancel context.CancelFunc
+	cfg    string
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, new(ServerSuite))
+}
+
+func (s *ServerSuite) SetupTest() {
+	dir := s.T().TempDir()
+	s.cfg = config.UserConfigFile
+	config.UserConfigFile = filepath.Join(dir, "config.json")
+	inj := do.New()
+	check.Init(inj)
+	config.Init(inj)
+	importer.Init(inj)
+	export.Init(inj)
+	backup.Init(inj)
+	track.Init(inj)
+	convert.Init(inj)
+	Init(inj)
+	srv := do.MustInvoke[*server](inj)
+	var err error
+	s.api, err = srv.newAPI(Options{DataDir: filepath.Join(dir, "data")})
+	s.Require().NoError(err)
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	go s.api.jobs.Run(ctx)
+	s.ts = httptest.NewServer(s.api.routes())
+}
+
+func (s *ServerSuite) TearDownTest() {
+	s.ts.Close()
+	s.cancel()
+	config.UserConfigFile = s.cfg
+}
+
+// cardZip zips the files of the test sd card into the folder sdcard, like a zipped card folder
+func (s *ServerSuite) cardZip(files ...string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(sdcard, f))
+		s.Require().NoError(err)
+		w, err := zw.Create("sdcard/" + f)
+		s.Require().NoError(err)
+		_, err = w.Write(data)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(zw.Close())
+	return buf.Bytes()
+}
+
+func (s *ServerSuite) do(method, path string, body []byte, v any) *http.Response {
+	req, err := http.NewRequest(method, s.ts.URL+BasePath+path, bytes.NewReader(body))
+	s.Require().NoError(err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/zip")
+	}
+	res, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	s.Require().NoError(err)
+	if v != nil {
+		s.Require().NoError(json.Unmarshal(data, v), string(data))
+	}
+	return res
+}
+
+// submit posts the data and waits for the job to finish
+func (s *ServerSuite) submit(path string, body []byte) Job {
+	var j Job
+	res := s.do(http.MethodPost, path, body, &j)
+	s.Require().Equal(http.StatusAccepted, res.StatusCode, j.Error)
+	s.Equal(BasePath+"/jobs/"+j.ID, res.Header.Get("Location"))
+	return s.wait(j.ID)
+}
+
+func (s *ServerSuite) wait(id string) Job {
+	var j Job
+	s.Require().Eventually(func() bool {
+		res := s.do(http.MethodGet, "/jobs/"+id, nil, &j)
+		s.Require().Equal(http.StatusOK, res.StatusCode)
+		return j.Finished != nil
+	}, 30*time.Second, 20*time.Millisecond)
+	return j
+}
+
+func (s *ServerSuite) download(path string) []byte {
+	res, err := http.Get(s.ts.URL + BasePath + path)
+	s.Require().NoError(err)
+	defer res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	data, err := io.ReadAll(res.Body)
+	s.Require().NoError(err)
+	return data
+}
+
+func (s *ServerSuite) TestFormats() {
+	var fs map[string][]map[string]any
+	res := s.do(http.MethodGet, "/formats", nil, &fs)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.NotEmpty(fs["export"])
+	s.NotEmpty(fs["import"])
+}
+
+func (s *ServerSuite) TestCheck() {
+	j := s.submit("/check", s.cardZip("DATA001231.DAT", "route.properties"))
+	s.Equal(JobDone, j.Status, j.Error)
+	s.Equal("check", j.Kind)
+	s.Contains(j.Files, "report.json")
+	s.NotEmpty(j.Result)
+
+	data := s.download("/jobs/" + j.ID + "/files/report.json")
+	var cr model.CheckResult
+	s.Require().NoError(json.Unmarshal(data, &cr))
+
+	// all results as zip
+	data = s.download("/jobs/" + j.ID + "/download")
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	s.Require().NoError(err)
+	s.Len(zr.File, len(j.Files))
+
+	res := s.do(http.MethodGet, "/jobs/"+j.ID+"/files/../upload.zip", nil, nil)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func (s *ServerSuite) TestExport() {
+	j := s.submit("/export?format=gpx&name=test", s.cardZip("DATA001231.DAT"))
+	s.Equal(JobDone, j.Status, j.Error)
+	s.Contains(j.Files, "tracks.json")
+	s.Contains(strings.Join(j.Files, ","), ".gpx")
+
+	var e map[string]string
+	res := s.do(http.MethodPost, "/export?format=doc", s.cardZip("DATA001231.DAT"), &e)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	s.Contains(e["error"], "not supported")
+	res = s.do(http.MethodPost, "/export?from=yesterday", s.cardZip("DATA001231.DAT"), &e)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+}
+
+func (s *ServerSuite) TestMultipartUpload() {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	s.Require().NoError(mw.WriteField("comment", "card of the trip"))
+	fw, err := mw.CreateFormFile("file", "card.zip")
+	s.Require().NoError(err)
+	_, err = fw.Write(s.cardZip("DATA001231.DAT"))
+	s.Require().NoError(err)
+	s.Require().NoError(mw.Close())
+
+	res, err := http.Post(s.ts.URL+BasePath+"/backup", mw.FormDataContentType(), &buf)
+	s.Require().NoError(err)
+	var j Job
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&j))
+	res.Body.Close()
+	s.Require().Equal(http.StatusAccepted, res.StatusCode)
+	j = s.wait(j.ID)
+	s.Equal(JobDone, j.Status, j.Error)
+	s.Require().Len(j.Files, 1)
+	s.True(strings.HasPrefix(j.Files[0], "bck_"))
+}
+
+func (s *ServerSuite) TestTracks() {
+	j := s.submit("/tracks/Ostsee?description=first%20day&vesselid=597", s.cardZip("DATA001231.DAT", "route.properties"))
+	s.Require().Equal(JobDone, j.Status, j.Error)
+
+	var e map[string]string
+	res := s.do(http.MethodPost, "/tracks/Ostsee", s.cardZip("DATA001231.DAT"), &e)
+	s.Equal(http.StatusConflict, res.StatusCode)
+
+	var tis []TrackInfo
+	s.do(http.MethodGet, "/tracks", nil, &tis)
+	s.Require().Len(tis, 1)
+	s.Equal("Ostsee", tis[0].Name)
+	s.Equal("first day", tis[0].Track.Description)
+	s.Equal(int32(597), tis[0].Track.VesselID)
+	s.Len(tis[0].Track.Files, 1)
+
+	j = s.submit("/tracks/Ostsee/data", s.cardZip("DATA001232.DAT"))
+	s.Require().Equal(JobDone, j.Status, j.Error)
+	var t model.Track
+	s.do(http.MethodGet, "/tracks/Ostsee", nil, &t)
+	s.Len(t.Files, 2)
+
+	var d model.TrackDocument
+	res = s.do(http.MethodGet, "/tracks/Ostsee/points", nil, &d)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal(model.DocumentSchema, d.Schema)
+	s.NotEmpty(d.Waypoints)
+	s.Empty(d.LogLines)
+
+	// a privacy zone around the whole track
+	wp := d.Waypoints[0]
+	s.api.srv.cfg.PrivacyZones = model.PrivacyZones{{Name: "all", Lat: wp.Lat, Lon: wp.Lon, Radius: 100000}}
+	var p model.TrackDocument
+	s.do(http.MethodGet, "/tracks/Ostsee/points", nil, &p)
+	s.Empty(p.Waypoints)
+	// the zones can only be switched off, if the server allows it
+	var e2 map[string]string
+	res = s.do(http.MethodGet, "/tracks/Ostsee/points?no-privacy=true", nil, &e2)
+	s.Equal(http.StatusForbidden, res.StatusCode)
+	s.Equal(ErrNoPrivacy.Error(), e2["error"])
+	res = s.do(http.MethodPost, "/tracks/Ostsee/export?format=GEOJSON&no-privacy=true", nil, &e2)
+	s.Equal(http.StatusForbidden, res.StatusCode)
+	s.api.opts.AllowNoPrivacy = true
+	s.do(http.MethodGet, "/tracks/Ostsee/points?no-privacy=true", nil, &p)
+	s.Len(p.Waypoints, len(d.Waypoints))
+	s.api.opts.AllowNoPrivacy = false
+	s.api.srv.cfg.PrivacyZones = nil
+
+	data := s.download("/tracks/Ostsee/download")
+	_, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	s.NoError(err)
+
+	j = s.submit("/tracks/Ostsee/export?format=GEOJSON", nil)
+	s.Require().Equal(JobDone, j.Status, j.Error)
+	s.Equal([]string{"Ostsee.geojson"}, j.Files)
+
+	res = s.do(http.MethodGet, "/tracks/Nordsee", nil, &e)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	res = s.do(http.MethodPost, "/tracks/..hidden", s.cardZip("DATA001231.DAT"), &e)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+}
+
+func (s *ServerSuite) TestBadRequests() {
+	var e map[string]string
+	res := s.do(http.MethodPost, "/check", nil, &e)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	s.Equal(ErrNoUpload.Error(), e["error"])
+
+	res = s.do(http.MethodPost, "/check", []byte("no zip"), &e)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+
+	// zip slip
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.Create("../../evil.DAT")
+	s.Require().NoError(err)
+	s.Require().NoError(zw.Close())
+	res = s.do(http.MethodPost, "/check", buf.Bytes(), &e)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	s.Contains(e["error"], "outside")
+
+	// zip bomb, each file is below the limit, both together are above
+	s.api.opts.MaxUpload = 64 << 10
+	buf.Reset()
+	zw = zip.NewWriter(&buf)
+	for _, name := range []string{"DATA000001.DAT", "DATA000002.DAT"} {
+		w, err := zw.Create(name)
+		s.Require().NoError(err)
+		_, err = w.Write(make([]byte, 512<<10))
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(zw.Close())
+	s.Less(int64(buf.Len()), s.api.opts.MaxUpload)
+	res = s.do(http.MethodPost, "/check", buf.Bytes(), &e)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	s.Contains(e["error"], "larger")
+
+	res = s.do(http.MethodGet, "/jobs/unknown", nil, &e)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	// a failed job
+	j := s.submit("/tracks/empty", s.cardZip("route.properties"))
+	s.Equal(JobFailed, j.Status)
+	s.Contains(j.Error, "no data files")
+	var jobs []Job
+	s.do(http.MethodGet, "/jobs", nil, &jobs)
+	s.Len(jobs, 1)
+	res = s.do(http.MethodDelete, "/jobs/"+j.ID, nil, nil)
+	s.Equal(http.StatusNoContent, res.StatusCode)
+	s.do(http.MethodGet, "/jobs", nil, &jobs)
+	s.Empty(jobs)
+}
+
+func (s *ServerSuite) TestQueue() {
+	q, err := NewQueue(s.T().TempDir(), 1, time.Hour)
+	s.Require().NoError(err)
+	block := make(chan struct{})
+	run := func(_, _ string) (any, error) {
+		<-block
+		return "ok", nil
+	}
+	j1, err := q.Submit("first", nil, run)
+	s.Require().NoError(err)
+	_, err = q.Submit("second", nil, run)
+	s.ErrorIs(err, ErrQueueFull)
+	s.Len(q.List(), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+	s.Eventually(func() bool {
+		j, _ := q.Get(j1.ID)
+		return j.Status == JobRunning
+	}, time.Second, time.Millisecond)
+	s.ErrorIs(q.Remove(j1.ID), ErrJobActive)
+
+	j2, err := q.Submit("panic", func(j *Job) error {
+		return os.WriteFile(filepath.Join(j.In(), "data"), []byte("x"), 0o600)
+	}, func(in, _ string) (any, error) {
+		panic(errors.New("boom " + in))
+	})
+	s.Require().NoError(err)
+	close(block)
+	s.Eventually(func() bool {
+		j, _ := q.Get(j2.ID)
+		return j.Finished != nil
+	}, time.Second, time.Millisecond)
+	j, _ := q.Get(j2.ID)
+	s.Equal(JobFailed, j.Status)
+	s.Contains(j.Error, "boom")
+	j, _ = q.Get(j1.ID)
+	s.Equal(JobDone, j.Status)
+	s.Equal("ok", j.Result)
+
+	// finished jobs older than the keep time are removed with the next job
+	q.keep = 0
+	_, err = q.Submit("third", nil, run)
+	s.Require().NoError(err)
+	_, err = q.Get(j1.ID)
+	s.ErrorIs(err, ErrJobNotFound)
+	s.NoDirExists(filepath.Join(q.dir, j2.ID))
+}
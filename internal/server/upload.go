@@ -0,0 +1,207 @@
+package server
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/willie68/osmltools/internal/importer/registry"
+)
+
+const (
+	uploadFile = "upload.zip"
+	// extractFactor the extracted files of an upload may be this factor larger than the max upload size
+	extractFactor = 10
+)
+
+var (
+	// ErrNoUpload error for requests without an uploaded zip
+	ErrNoUpload = errors.New("no zip file uploaded")
+	// ErrInvalidUpload error for uploads, which are no valid zip files
+	ErrInvalidUpload = errors.New("invalid zip file")
+)
+
+// hasUpload checks if the request has a body
+func hasUpload(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// receiveZip stores the uploaded zip, the raw body (application/zip) or the first file of a multipart form, and
+// extracts it into the folder dest. The extracted files are limited to extractFactor times maxSize.
+func receiveZip(w http.ResponseWriter, r *http.Request, maxSize int64, dest string) error {
+	body := http.MaxBytesReader(w, r.Body, maxSize)
+	defer body.Close()
+	var src io.Reader = body
+	mt, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt == "multipart/form-data" {
+		mr := multipart.NewReader(body, params["boundary"])
+		part, err := mr.NextPart()
+		for err == nil && part.FileName() == "" {
+			part, err = mr.NextPart()
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrNoUpload, err)
+		}
+		defer part.Close()
+		src = part
+	}
+	zf := filepath.Join(filepath.Dir(dest), uploadFile)
+	f, err := os.Create(zf)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	defer os.Remove(zf)
+	return extractZip(zf, dest, maxSize*extractFactor)
+}
+
+// extractZip extracts all files of the zip into the folder dest, entries outside of dest are rejected. The upload is
+// rejected, if the extracted files are larger than limit bytes, e.g. a zip bomb.
+func extractZip(zipfile, dest string, limit int64) error {
+	zr, err := zip.OpenReader(zipfile)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Mode().IsDir() {
+			continue
+		}
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%w: file name %s outside of the zip", ErrInvalidUpload, f.Name)
+		}
+		fn := filepath.Join(dest, name)
+		if err := os.MkdirAll(filepath.Dir(fn), os.ModePerm); err != nil {
+			return err
+		}
+		n, err := extractFile(f, fn, limit)
+		if err != nil {
+			return err
+		}
+		limit -= n
+	}
+	return nil
+}
+
+// extractFile extracts the file, at most limit bytes, and returns the number of bytes written
+func extractFile(f *zip.File, fn string, limit int64) (int64, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return 0, fmt.Errorf("%w: the extracted files are larger than %d bytes", ErrInvalidUpload, limit)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	df, err := os.Create(fn)
+	if err != nil {
+		return 0, err
+	}
+	// the size in the header can't be trusted
+	n, err := io.Copy(df, io.LimitReader(rc, limit+1))
+	if cerr := df.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > limit {
+		os.Remove(fn)
+		err = fmt.Errorf("%w: the extracted files are larger than %d bytes", ErrInvalidUpload, limit)
+	}
+	if err != nil || f.Modified.IsZero() {
+		return n, err
+	}
+	// the importers of sonar logs need the file time
+	return n, os.Chtimes(fn, f.Modified, f.Modified)
+}
+
+// cardFolder the folder with the logger data. Zips of the whole sd card folder have one folder at the root, the
+// folder is used as card.
+func cardFolder(dir string) (string, error) {
+	for {
+		es, err := os.ReadDir(dir)
+		if err != nil {
+			return "", err
+		}
+		if len(es) != 1 || !es[0].IsDir() {
+			return dir, nil
+		}
+		dir = filepath.Join(dir, es[0].Name())
+	}
+}
+
+// cardFiles the names of the logger data files (DATA*.DAT) and the files of the import formats in the folder
+func cardFiles(dir string) ([]string, error) {
+	es, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	exts := make([]string, 0)
+	for _, f := range registry.Formats() {
+		exts = append(exts, f.Extensions...)
+	}
+	files := make([]string, 0)
+	for _, e := range es {
+		if e.IsDir() {
+			continue
+		}
+		name := strings.ToLower(e.Name())
+		ext := strings.TrimPrefix(filepath.Ext(name), ".")
+		isData := strings.HasPrefix(name, "data") && ext == "dat"
+		if isData || slices.Contains(exts, ext) {
+			files = append(files, e.Name())
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no data files found")
+	}
+	return files, nil
+}
+
+// writeZip writes the files of the folder dir as zip
+func writeZip(w io.Writer, dir string, files []string) error {
+	zw := zip.NewWriter(w)
+	for _, name := range files {
+		if err := addZipFile(zw, dir, name); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func addZipFile(zw *zip.Writer, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	fh, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	fh.Name = name
+	fh.Method = zip.Deflate
+	zf, err := zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zf, f)
+	return err
+}
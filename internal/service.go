@@ -5,10 +5,19 @@ import (
 	"github.com/willie68/osmltools/internal/backup"
 	"github.com/willie68/osmltools/internal/check"
 	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/contour"
 	"github.com/willie68/osmltools/internal/convert"
 	"github.com/willie68/osmltools/internal/export"
+	"github.com/willie68/osmltools/internal/grid"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/pipeline"
+	"github.com/willie68/osmltools/internal/render"
+	"github.com/willie68/osmltools/internal/replay"
+	"github.com/willie68/osmltools/internal/rpc"
+	"github.com/willie68/osmltools/internal/server"
 	"github.com/willie68/osmltools/internal/track"
 	"github.com/willie68/osmltools/internal/upload"
+	"github.com/willie68/osmltools/internal/watch"
 )
 
 var (
@@ -20,9 +29,18 @@ var (
 func Init() {
 	check.Init(Inj)
 	config.Init(Inj)
+	importer.Init(Inj)
 	export.Init(Inj)
 	backup.Init(Inj)
 	track.Init(Inj)
 	convert.Init(Inj)
 	upload.Init(Inj)
+	grid.Init(Inj)
+	contour.Init(Inj)
+	replay.Init(Inj)
+	render.Init(Inj)
+	server.Init(Inj)
+	rpc.Init(Inj)
+	watch.Init(Inj)
+	pipeline.Init(Inj)
 }
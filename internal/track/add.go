@@ -4,11 +4,10 @@ import (
 	"archive/zip"
 	"errors"
 	"io"
-	"os"
 	"path/filepath"
 	"slices"
+	"sort"
 
-	"github.com/willie68/osmltools/internal/export/nmeaexporter"
 	"github.com/willie68/osmltools/internal/model"
 	"github.com/willie68/osmltools/internal/trackutils"
 )
@@ -52,6 +51,10 @@ func (m *manager) AddTrack(sdCardFolder string, files []string, trackfile string
 	}
 	tps.LogLines = append(tps.LogLines, lls...)
 	tps.LogLines = append(tps.LogLines, ll...)
+	// imported data may overlap the logger data
+	sort.SliceStable(tps.LogLines, func(i, j int) bool {
+		return tps.LogLines[i].CorrectTimeStamp.Before(tps.LogLines[j].CorrectTimeStamp)
+	})
 
 	m.log.Debugf("lines:%d, track: %v", len(tps.LogLines), track)
 	// update with new nmea file add source files to zip
@@ -59,90 +62,63 @@ func (m *manager) AddTrack(sdCardFolder string, files []string, trackfile string
 }
 
 func (m *manager) openNewZipCopyContent(sdCardFolder string, files []string, trackfile string, tps model.TrackPoints, track model.Track) error {
-	// Create a temporary file
-	tmpFile, err := os.CreateTemp(filepath.Dir(trackfile), "updated-*.zip")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tmpFile.Name()) // Clean up if needed
-
-	// Create ZIP writer to temp file
-	zipWriter := zip.NewWriter(tmpFile)
-
-	// Open original ZIP for reading
-	err = m.copyOldFiles(trackfile, zipWriter)
-	if err != nil {
-		return err
-	}
-
-	// create new NMEA File
-	jsf, err := zipWriter.Create(trackutils.NMEAFile)
-	if err != nil {
-		m.log.Errorf("Failed to add track.nmea: %v", err)
-	}
-	err = nmeaexporter.New().ExportTrack(tps, jsf)
-	if err != nil {
-		m.log.Errorf("Failed to export nmea: %v", err)
-	}
-
-	// copy new data files
-	track, err = m.copyFiles2Zip(sdCardFolder, files, zipWriter, track)
-	if err != nil {
-		m.log.Errorf("Failed to add files: %v", err)
-	}
-
-	// create Track JSON
-
-	err = m.createTrackJSON(zipWriter, track)
-	if err != nil {
-		m.log.Errorf("Failed to create JSON: %v", err)
-		return err
-	}
+	return m.writeTrackFile(trackfile, func(zipWriter *zip.Writer) (model.Track, error) {
+		// copy the old data files
+		err := m.copyOldFiles(trackfile, zipWriter, trackutils.JSONFile, trackutils.NMEAFile)
+		if err != nil {
+			return track, err
+		}
 
-	// Finalize ZIP
-	if err := zipWriter.Close(); err != nil {
-		return err
-	}
+		// create new NMEA File
+		err = m.createNMEA(zipWriter, tps)
+		if err != nil {
+			return track, err
+		}
 
-	if err := tmpFile.Close(); err != nil {
-		return err
-	}
+		// copy new data files
+		track, err = m.copyFiles2Zip(sdCardFolder, files, zipWriter, track)
+		if err != nil {
+			return track, err
+		}
 
-	// Replace original ZIP with temp file
-	return os.Rename(tmpFile.Name(), trackfile)
+		// create Track JSON
+		err = m.createTrackJSON(zipWriter, track)
+		return track, err
+	})
 }
 
-func (m *manager) copyOldFiles(trackfile string, zipWriter *zip.Writer) error {
+// copyOldFiles copies all files of the track file into the zip writer, except the files named in skip
+func (m *manager) copyOldFiles(trackfile string, zipWriter *zip.Writer, skip ...string) error {
 	r, err := zip.OpenReader(trackfile)
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 	// copy old files
 	for _, f := range r.File {
-		var fw io.Writer
-		// ignore nmea and track file
-		if f.Name == trackutils.JSONFile || f.Name == trackutils.NMEAFile {
+		if slices.Contains(skip, f.Name) {
 			continue
 		}
-
-		// Copy original file
-		rc, err := f.Open()
-		if err != nil {
+		if err := copyZipEntry(f, zipWriter); err != nil {
 			return err
 		}
-		defer rc.Close()
+	}
+	return nil
+}
 
-		fw, err = zipWriter.CreateHeader(&f.FileHeader)
-		if err != nil {
-			return err
-		}
-		if _, err := io.Copy(fw, rc); err != nil {
-			return err
-		}
+func copyZipEntry(f *zip.File, zipWriter *zip.Writer) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
 	}
+	defer rc.Close()
 
-	r.Close()
-	return nil
+	fw, err := zipWriter.CreateHeader(&f.FileHeader)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, rc)
+	return err
 }
 
 func (m *manager) getFileList(trackfile string) []string {
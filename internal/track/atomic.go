@@ -0,0 +1,132 @@
+package track
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/trackutils"
+)
+
+var (
+	// ErrInvalidTrackFile the written track file is not valid
+	ErrInvalidTrackFile = errors.New("invalid track file")
+)
+
+// writeTrackFile writes the track file crash safe. The content is written by fill into a temp file in the same folder,
+// the result is validated and only than renamed to the track file. On any error the temp file is removed and the
+// track file stays untouched. An existing track file keeps its mode, a new one is created with 0644.
+func (m *manager) writeTrackFile(trackfile string, fill func(zw *zip.Writer) (model.Track, error)) (err error) {
+	if err := os.MkdirAll(filepath.Dir(trackfile), os.ModePerm); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(trackfile), "."+filepath.Base(trackfile)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmpFile.Name()
+	defer func() {
+		// closing twice is harmless, the temp file must be closed before removing on windows
+		tmpFile.Close()
+		if err != nil {
+			os.Remove(tmpName)
+		}
+	}()
+
+	zipWriter := zip.NewWriter(tmpFile)
+	track, err := fill(zipWriter)
+	if err != nil {
+		return err
+	}
+	if err = zipWriter.Close(); err != nil {
+		return fmt.Errorf("can't finalize track file: %w", err)
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err = validateTrackFile(tmpName, track); err != nil {
+		return err
+	}
+	// the temp file is only readable by the owner, the track file keeps its mode
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(trackfile); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = os.Chmod(tmpName, mode); err != nil {
+		return err
+	}
+	m.log.Debugf("track file validated, renaming %s to %s", tmpName, trackfile)
+	return os.Rename(tmpName, trackfile)
+}
+
+// validateTrackFile checks that the track file contains a readable track.json equal to the given track,
+// a nmea file and all source data files with matching hashes
+func validateTrackFile(fn string, track model.Track) error {
+	r, err := zip.OpenReader(fn)
+	if err != nil {
+		return errors.Join(ErrInvalidTrackFile, err)
+	}
+	defer r.Close()
+
+	entries := make(map[string]*zip.File)
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+
+	if _, ok := entries[trackutils.NMEAFile]; !ok {
+		return fmt.Errorf("%w: %s is missing", ErrInvalidTrackFile, trackutils.NMEAFile)
+	}
+	jf, ok := entries[trackutils.JSONFile]
+	if !ok {
+		return fmt.Errorf("%w: %s is missing", ErrInvalidTrackFile, trackutils.JSONFile)
+	}
+	written, err := trackutils.Track(jf)
+	if err != nil {
+		return fmt.Errorf("%w: %s is not readable: %v", ErrInvalidTrackFile, trackutils.JSONFile, err)
+	}
+	if len(written.Files) != len(track.Files) {
+		return fmt.Errorf("%w: %s contains %d files, expected %d", ErrInvalidTrackFile, trackutils.JSONFile, len(written.Files), len(track.Files))
+	}
+
+	for _, sd := range track.Files {
+		f, ok := entries[sd.FileName]
+		if !ok {
+			return fmt.Errorf("%w: source file %s is missing", ErrInvalidTrackFile, sd.FileName)
+		}
+		if sd.Hash == "" {
+			continue
+		}
+		hash, err := hashZipEntry(f)
+		if err != nil {
+			return errors.Join(ErrInvalidTrackFile, err)
+		}
+		if !strings.EqualFold(hash, sd.Hash) {
+			return fmt.Errorf("%w: hash mismatch for %s", ErrInvalidTrackFile, sd.FileName)
+		}
+	}
+	return nil
+}
+
+func hashZipEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(h.Sum(nil))), nil
+}
@@ -0,0 +1,51 @@
+package track
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/trackutils"
+)
+
+// AddAttachment stores the data as file with the given name in the track file, e.g. a rendered map. An existing
+// attachment with the same name is replaced, the track data and the source files can't be overwritten.
+func (m *manager) AddAttachment(trackfile, name string, data []byte) error {
+	m.log.Infof("Adding %s to track file %s", name, trackfile)
+	if model.IsOldTrackVersion(trackfile) {
+		return errors.New("can't add an attachment to an old track file")
+	}
+	if name == "" || name != path.Base(name) {
+		return fmt.Errorf("invalid attachment name %q", name)
+	}
+	track, _, err := trackutils.ReadTrackAndNmea(trackfile)
+	if err != nil {
+		return err
+	}
+	if name == trackutils.JSONFile || name == trackutils.NMEAFile || slices.ContainsFunc(track.Files, func(sd model.SourceData) bool {
+		return sd.FileName == name
+	}) {
+		return fmt.Errorf("can't replace the track data %s with an attachment", name)
+	}
+
+	return m.writeTrackFile(trackfile, func(zipWriter *zip.Writer) (model.Track, error) {
+		err := m.copyOldFiles(trackfile, zipWriter, name)
+		if err != nil {
+			return *track, err
+		}
+		fw, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return *track, err
+		}
+		_, err = fw.Write(data)
+		return *track, err
+	})
+}
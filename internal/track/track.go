@@ -11,7 +11,6 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/samber/do/v2"
 	"github.com/willie68/osmltools/internal/export/nmeaexporter"
@@ -25,10 +24,15 @@ type checkerSrv interface {
 	CorrectTimeStamp(ls []*model.LogLine) ([]*model.LogLine, bool, error)
 }
 
+type importerSrv interface {
+	Import(file, format string) ([]*model.LogLine, error)
+}
+
 // Manager the track manager service
 type manager struct {
 	log *logging.Logger
 	chk checkerSrv
+	imp importerSrv
 }
 
 // Init init this service and provide it to di
@@ -37,6 +41,7 @@ func Init(inj do.Injector) {
 		return &manager{
 			log: logging.New().WithName("Trackmanager"),
 			chk: do.MustInvokeAs[checkerSrv](inj),
+			imp: do.MustInvokeAs[importerSrv](inj),
 		}, nil
 	})
 }
@@ -54,40 +59,33 @@ func (m *manager) NewTrack(sdCardFolder string, files []string, trackfile string
 		Name:     track.Name,
 		LogLines: ll,
 	}
-	// Create the ZIP file
-	if err := os.MkdirAll(filepath.Dir(trackfile), os.ModePerm); err != nil {
-		return err
-	}
-	outFile, err := os.Create(trackfile)
-	if err != nil {
-		return err
-	}
-	defer outFile.Close()
+	return m.writeTrackFile(trackfile, func(zipWriter *zip.Writer) (model.Track, error) {
+		err := m.createNMEA(zipWriter, *tps)
+		if err != nil {
+			return track, err
+		}
 
-	zipWriter := zip.NewWriter(outFile)
-	defer zipWriter.Close()
+		track, err = m.copyFiles2Zip(sdCardFolder, files, zipWriter, track)
+		if err != nil {
+			return track, err
+		}
 
-	jsf, err := zipWriter.Create(trackutils.NMEAFile)
-	if err != nil {
-		m.log.Errorf("Failed to add track.nmea: %v", err)
-	}
-	err = nmeaexporter.New().ExportTrack(*tps, jsf)
-	if err != nil {
-		m.log.Errorf("Failed to export nmea: %v", err)
-	}
+		err = m.createTrackJSON(zipWriter, track)
+		return track, err
+	})
+}
 
-	track, err = m.copyFiles2Zip(sdCardFolder, files, zipWriter, track)
+func (m *manager) createNMEA(zipWriter *zip.Writer, tps model.TrackPoints) error {
+	nf, err := zipWriter.Create(trackutils.NMEAFile)
 	if err != nil {
-		m.log.Errorf("Failed to add files: %v", err)
+		m.log.Errorf("Failed to add track.nmea: %v", err)
 		return err
 	}
-
-	err = m.createTrackJSON(zipWriter, track)
+	err = nmeaexporter.New().ExportTrack(tps, nf)
 	if err != nil {
-		m.log.Errorf("Failed to create JSON: %v", err)
+		m.log.Errorf("Failed to export nmea: %v", err)
 		return err
 	}
-
 	return nil
 }
 
@@ -157,12 +155,21 @@ func addFileToZip(zipWriter *zip.Writer, filename string) (*model.SourceData, er
 	return &sd, err
 }
 
+// ReadLogFiles reads the logger data files (DAT) and the files of the other formats (GPX, KML, NMEA...) with the
+// importers. All log lines are sorted by the corrected time stamp.
 func (m *manager) ReadLogFiles(files []string, sdCardFolder string) ([]*model.LogLine, error) {
 	ls := make([]*model.LogLine, 0)
-	today := time.Time{}
 
 	for _, file := range files {
 		sdf := filepath.Join(sdCardFolder, strings.TrimSpace(file))
+		if !strings.EqualFold(filepath.Ext(sdf), ".dat") {
+			lss, err := m.imp.Import(sdf, "")
+			if err != nil {
+				return nil, err
+			}
+			ls = append(ls, lss...)
+			continue
+		}
 		m.log.Infof("analysing file: %s", sdf)
 		lss, err := m.chk.AnalyseLoggerFile(nil, sdf)
 		if err != nil {
@@ -172,15 +179,10 @@ func (m *manager) ReadLogFiles(files []string, sdCardFolder string) ([]*model.Lo
 		if err != nil {
 			return nil, err
 		}
-		if len(lss) > 0 {
-			if today.IsZero() {
-				today = lss[0].CorrectTimeStamp
-			}
-			ls = append(ls, lss...)
-		}
+		ls = append(ls, lss...)
 	}
 
-	sort.Slice(ls, func(i, j int) bool {
+	sort.SliceStable(ls, func(i, j int) bool {
 		return ls[i].CorrectTimeStamp.Before(ls[j].CorrectTimeStamp)
 	})
 	return ls, nil
@@ -0,0 +1,236 @@
+package track
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/check"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/model"
+)
+
+const sdcard = "../../testdata/sdcard"
+
+type trackManager interface {
+	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
+	AddTrack(sdCardFolder string, files []string, trackfile string) error
+	ListTrack(trackfile string) (*model.Track, error)
+	TrimTrack(trackfile string, trim *model.Trim) (*model.Track, error)
+	AddAttachment(trackfile, name string, data []byte) error
+}
+
+type TrackSuite struct {
+	suite.Suite
+	tm  trackManager
+	dir string
+}
+
+func TestTrackSuite(t *testing.T) {
+	suite.Run(t, new(TrackSuite))
+}
+
+func (s *TrackSuite) SetupTest() {
+	inj := do.New()
+	check.Init(inj)
+	importer.Init(inj)
+	Init(inj)
+	s.tm = do.MustInvokeAs[trackManager](inj)
+	s.dir = s.T().TempDir()
+}
+
+func (s *TrackSuite) newTrack(tf string) {
+	err := s.tm.NewTrack(sdcard, []string{"DATA001231.DAT"}, tf, model.Track{Name: "test", VesselID: 597})
+	s.Require().NoError(err)
+}
+
+func (s *TrackSuite) dirEntries() []string {
+	es, err := os.ReadDir(s.dir)
+	s.Require().NoError(err)
+	names := make([]string, 0, len(es))
+	for _, e := range es {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func (s *TrackSuite) TestNewTrack() {
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+
+	s.Equal([]string{"track.zip"}, s.dirEntries())
+	tr, err := s.tm.ListTrack(tf)
+	s.NoError(err)
+	s.Equal("test", tr.Name)
+	s.Len(tr.Files, 1)
+	s.Equal("DATA001231.DAT", tr.Files[0].FileName)
+	s.NoError(validateTrackFile(tf, *tr))
+}
+
+func (s *TrackSuite) TestNewTrackMissingFile() {
+	tf := filepath.Join(s.dir, "track.zip")
+	err := s.tm.NewTrack(sdcard, []string{"DATA001231.DAT", "DATA009999.DAT"}, tf, model.Track{Name: "test"})
+	s.Error(err)
+	// neither the track nor a temp file is left behind
+	s.Empty(s.dirEntries())
+}
+
+func (s *TrackSuite) TestWriteTrackFileError() {
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+	before, err := os.ReadFile(tf)
+	s.Require().NoError(err)
+
+	m := s.tm.(*manager)
+	err = m.writeTrackFile(tf, func(zw *zip.Writer) (model.Track, error) {
+		if err := m.createNMEA(zw, model.TrackPoints{}); err != nil {
+			return model.Track{}, err
+		}
+		return model.Track{}, errors.New("interrupted")
+	})
+	s.EqualError(err, "interrupted")
+
+	// the track is not validated without track.json
+	err = m.writeTrackFile(tf, func(zw *zip.Writer) (model.Track, error) {
+		return model.Track{}, m.createNMEA(zw, model.TrackPoints{})
+	})
+	s.ErrorIs(err, ErrInvalidTrackFile)
+
+	after, err := os.ReadFile(tf)
+	s.Require().NoError(err)
+	s.Equal(before, after)
+	s.Equal([]string{"track.zip"}, s.dirEntries())
+}
+
+func (s *TrackSuite) TestAddTrackKeepsOldFileOnError() {
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+	before, err := os.ReadFile(tf)
+	s.Require().NoError(err)
+
+	err = s.tm.AddTrack(sdcard, []string{"DATA009999.DAT"}, tf)
+	s.Error(err)
+	after, err := os.ReadFile(tf)
+	s.Require().NoError(err)
+	s.Equal(before, after)
+	s.Equal([]string{"track.zip"}, s.dirEntries())
+
+	err = s.tm.AddTrack(sdcard, []string{"DATA001232.DAT"}, tf)
+	s.NoError(err)
+	tr, err := s.tm.ListTrack(tf)
+	s.NoError(err)
+	s.Len(tr.Files, 2)
+}
+
+func (s *TrackSuite) TestTrimTrack() {
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+
+	from := time.Date(2016, 9, 11, 10, 15, 0, 0, time.UTC)
+	_, err := s.tm.TrimTrack(tf, &model.Trim{From: from})
+	s.NoError(err)
+	tr, err := s.tm.ListTrack(tf)
+	s.NoError(err)
+	s.Require().NotNil(tr.Trim)
+	s.Equal(from, tr.Trim.From)
+	s.Len(tr.Files, 1)
+
+	_, err = s.tm.TrimTrack(tf, nil)
+	s.NoError(err)
+	tr, err = s.tm.ListTrack(tf)
+	s.NoError(err)
+	s.Nil(tr.Trim)
+}
+
+func (s *TrackSuite) TestAddAttachment() {
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+
+	s.Require().NoError(s.tm.AddAttachment(tf, "thumbnail.svg", []byte("<svg/>")))
+	s.Require().NoError(s.tm.AddAttachment(tf, "thumbnail.svg", []byte("<svg></svg>")))
+	s.Error(s.tm.AddAttachment(tf, "track.json", []byte("{}")))
+	s.Error(s.tm.AddAttachment(tf, "DATA001231.DAT", []byte("")))
+	s.Error(s.tm.AddAttachment(tf, "../thumbnail.svg", []byte("")))
+
+	r, err := zip.OpenReader(tf)
+	s.Require().NoError(err)
+	defer r.Close()
+	names := make([]string, 0)
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	s.ElementsMatch([]string{"track.nmea", "track.json", "DATA001231.DAT", "thumbnail.svg"}, names)
+	tr, err := s.tm.ListTrack(tf)
+	s.NoError(err)
+	s.Len(tr.Files, 1)
+	s.Equal([]string{"track.zip"}, s.dirEntries())
+}
+
+func (s *TrackSuite) TestFileMode() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("no unix file modes on windows")
+	}
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+	fi, err := os.Stat(tf)
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0o644), fi.Mode().Perm())
+
+	s.Require().NoError(os.Chmod(tf, 0o640))
+	s.Require().NoError(s.tm.AddAttachment(tf, "thumbnail.svg", []byte("<svg/>")))
+	fi, err = os.Stat(tf)
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0o640), fi.Mode().Perm())
+}
+
+func (s *TrackSuite) TestValidateHashMismatch() {
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+	tr, err := s.tm.ListTrack(tf)
+	s.Require().NoError(err)
+
+	tr.Files[0].Hash = "sha256:0000"
+	s.ErrorIs(validateTrackFile(tf, *tr), ErrInvalidTrackFile)
+}
+
+func (s *TrackSuite) TestAddTrackGPX() {
+	tf := filepath.Join(s.dir, "track.zip")
+	s.newTrack(tf)
+	src := s.T().TempDir()
+	gpx := `<?xml version="1.0"?>
+<gpx version="1.1" creator="phone" xmlns="http://www.topografix.com/GPX/1/1">
+<trk><trkseg>
+<trkpt lat="53.5" lon="9.9"><time>2016-09-11T10:00:00Z</time></trkpt>
+<trkpt lat="53.501" lon="9.9"><time>2016-09-11T10:00:10Z</time></trkpt>
+</trkseg></trk>
+</gpx>`
+	s.Require().NoError(os.WriteFile(filepath.Join(src, "phone.gpx"), []byte(gpx), 0o644))
+
+	s.Require().NoError(s.tm.AddTrack(src, []string{"phone.gpx"}, tf))
+	tr, err := s.tm.ListTrack(tf)
+	s.Require().NoError(err)
+	s.Len(tr.Files, 2)
+	s.Equal("phone.gpx", tr.Files[1].FileName)
+	s.NoError(validateTrackFile(tf, *tr))
+
+	r, err := zip.OpenReader(tf)
+	s.Require().NoError(err)
+	defer r.Close()
+	f, err := r.Open("track.nmea")
+	s.Require().NoError(err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	s.Require().NoError(err)
+	// the imported data is merged in time order, before the logger data starting at 10:11
+	lines := strings.Split(string(data), "\n")
+	s.True(strings.HasPrefix(lines[0], "2016-09-11 10:00:00.000000: $GPRMC,100000.00,A,5330.00000,N,00954.00000,E"), lines[0])
+	s.True(strings.HasPrefix(lines[1], "2016-09-11 10:00:10.000000: $GPRMC"), lines[1])
+}
@@ -0,0 +1,42 @@
+package track
+
+import (
+	"archive/zip"
+	"errors"
+
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/trackutils"
+)
+
+// TrimTrack saves the trim into the track.json of the track file. The nmea and the source data files are not changed,
+// so the original data can be restored by resetting the trim (trim == nil).
+func (m *manager) TrimTrack(trackfile string, trim *model.Trim) (*model.Track, error) {
+	m.log.Infof("Trimming track file %s", trackfile)
+	if model.IsOldTrackVersion(trackfile) {
+		return nil, errors.New("can't trim an old track file")
+	}
+	if err := trim.Validate(); err != nil {
+		return nil, err
+	}
+
+	track, _, err := trackutils.ReadTrackAndNmea(trackfile)
+	if err != nil {
+		return nil, err
+	}
+	if trim.IsEmpty() {
+		trim = nil
+	}
+	track.Trim = trim
+
+	err = m.writeTrackFile(trackfile, func(zipWriter *zip.Writer) (model.Track, error) {
+		err := m.copyOldFiles(trackfile, zipWriter, trackutils.JSONFile)
+		if err != nil {
+			return *track, err
+		}
+		return *track, m.createTrackJSON(zipWriter, *track)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
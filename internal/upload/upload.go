@@ -1,18 +1,26 @@
 package upload
 
 import (
+	"archive/zip"
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/export/nmeaexporter"
 	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/trackutils"
 	"github.com/zalando/go-keyring"
 )
 
@@ -49,14 +57,31 @@ func (m *manager) GetCredentials(user string) (string, error) {
 	return secret, nil
 }
 
+// Checker reads the logger data files
+type Checker interface {
+	AnalyseLoggerFile(fr *model.FileResult, lf string) ([]*model.LogLine, error)
+	CorrectTimeStamp(ls []*model.LogLine) ([]*model.LogLine, bool, error)
+}
+
 type FileUpload struct {
-	FilePath string
-	VesselID int
-	Username string
-	Hash     string
+	FilePath     string
+	VesselID     int
+	Username     string
+	Hash         string
+	PrivacyZones model.PrivacyZones
+	// Checker reads logger data files (DAT), only needed to apply the privacy zones to them
+	Checker Checker
 }
 
 func (fu *FileUpload) Upload(url string) error {
+	if len(fu.PrivacyZones) > 0 {
+		pf, err := fu.privacyFile()
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(filepath.Dir(pf))
+		fu.FilePath = pf
+	}
 	if err := fu.CalculateHash(); err != nil {
 		return err
 	}
@@ -127,6 +152,164 @@ func (fu *FileUpload) CheckDuplicate(serverURL string) (bool, error) {
 	return string(body) == "exists", nil
 }
 
+// privacyFile writes the data of the file without the positions inside the privacy zones into a temp file. A track
+// file stays a track file with its metadata and attachments, the source data files are left out as they contain the
+// unfiltered positions. Logger data files (DAT) are read with the checker and written like nmea files as nmea.
+func (fu *FileUpload) privacyFile() (string, error) {
+	ext := filepath.Ext(fu.FilePath)
+	name := strings.TrimSuffix(filepath.Base(fu.FilePath), ext)
+	var lls []*model.LogLine
+	var track *model.Track
+	var err error
+	switch strings.ToLower(ext) {
+	case ".zip":
+		if model.IsOldTrackVersion(fu.FilePath) {
+			return "", errors.New("privacy zones can't be applied to an old track file")
+		}
+		var lines []string
+		track, lines, err = trackutils.ReadTrackAndNmea(fu.FilePath)
+		if err == nil {
+			lls, err = model.ParseLines2LogLines(lines, false)
+		}
+	case ".nmea":
+		var lines []string
+		lines, err = readLines(fu.FilePath)
+		if err == nil {
+			lls, err = model.ParseLines2LogLines(lines, false)
+		}
+	case ".dat":
+		lls, err = fu.readLoggerFile()
+	default:
+		return "", fmt.Errorf("privacy zones can't be applied to %s, only track, nmea and logger data files are supported", fu.FilePath)
+	}
+	if err != nil {
+		return "", err
+	}
+	tps := model.TrackPoints{
+		LogLines: lls,
+	}
+	tps.ApplyPrivacyZones(fu.PrivacyZones)
+
+	dir, err := os.MkdirTemp("", "osml-upload-*")
+	if err != nil {
+		return "", err
+	}
+	if track != nil {
+		pf := filepath.Join(dir, name+".zip")
+		if err := fu.writeTrack(pf, *track, tps); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		return pf, nil
+	}
+	pf := filepath.Join(dir, name+".nmea")
+	if err := writeNMEA(pf, tps); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return pf, nil
+}
+
+// readLoggerFile reads the logger data file with corrected time stamps
+func (fu *FileUpload) readLoggerFile() ([]*model.LogLine, error) {
+	if fu.Checker == nil {
+		return nil, fmt.Errorf("privacy zones can't be applied to %s without a checker", fu.FilePath)
+	}
+	lls, err := fu.Checker.AnalyseLoggerFile(nil, fu.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	lls, _, err = fu.Checker.CorrectTimeStamp(lls)
+	return lls, err
+}
+
+// writeTrack writes the track file with the filtered nmea data, the track metadata and the attachments of the
+// original track file
+func (fu *FileUpload) writeTrack(fn string, track model.Track, tps model.TrackPoints) error {
+	r, err := zip.OpenReader(fu.FilePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	nf, err := zw.Create(trackutils.NMEAFile)
+	if err != nil {
+		return err
+	}
+	if err := nmeaexporter.New().ExportTrack(tps, nf); err != nil {
+		return err
+	}
+	sources := track.Files
+	track.Files = nil
+	jf, err := zw.Create(trackutils.JSONFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(jf).Encode(track); err != nil {
+		return err
+	}
+	for _, zf := range r.File {
+		if zf.Name == trackutils.NMEAFile || zf.Name == trackutils.JSONFile || slices.ContainsFunc(sources, func(sd model.SourceData) bool {
+			return sd.FileName == zf.Name
+		}) {
+			continue
+		}
+		if err := copyZipEntry(zf, zw); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func copyZipEntry(zf *zip.File, zw *zip.Writer) error {
+	rc, err := zf.OpenRaw()
+	if err != nil {
+		return err
+	}
+	w, err := zw.CreateRaw(&zf.FileHeader)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+func writeNMEA(fn string, tps model.TrackPoints) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := nmeaexporter.New().ExportTrack(tps, f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func readLines(fn string) ([]string, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0)
+	for _, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
+
 func (fu *FileUpload) CalculateHash() error {
 	file, err := os.Open(fu.FilePath)
 	if err != nil {
@@ -1,20 +1,36 @@
 package upload
 
 import (
+	"archive/zip"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/adrianmo/go-nmea"
 	"github.com/samber/do/v2"
 	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/check"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/track"
+	"github.com/willie68/osmltools/internal/trackutils"
 )
 
+const sdcard = "../../testdata/sdcard"
+
 type Manager interface {
 	StoreCredentials(user, password string) error
 	GetCredentials(user string) (string, error)
 }
 
+type trackManager interface {
+	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
+	AddAttachment(trackfile, name string, data []byte) error
+}
+
 type UploadTestSuite struct {
 	suite.Suite
 	inj do.Injector
@@ -27,6 +43,9 @@ func TestUploadTestSuite(t *testing.T) {
 
 func (s *UploadTestSuite) SetupTest() {
 	s.inj = do.New()
+	check.Init(s.inj)
+	importer.Init(s.inj)
+	track.Init(s.inj)
 	Init(s.inj)
 	s.upl = do.MustInvokeAs[Manager](s.inj)
 }
@@ -50,6 +69,92 @@ func (s *UploadTestSuite) TestGetWrongUser() {
 	s.Empty(pwd)
 }
 
+// zone a privacy zone around the first position of the logger file
+func (s *UploadTestSuite) zone(chk Checker) model.PrivacyZones {
+	lls, err := chk.AnalyseLoggerFile(nil, filepath.Join(sdcard, "DATA001231.DAT"))
+	s.Require().NoError(err)
+	for _, ll := range lls {
+		if rmc, ok := ll.NMEAMessage.(nmea.RMC); ok && rmc.Validity == nmea.ValidRMC {
+			return model.PrivacyZones{{Name: "home", Lat: rmc.Latitude, Lon: rmc.Longitude, Radius: 500}}
+		}
+	}
+	s.FailNow("no position in the logger file")
+	return nil
+}
+
+// positions the number of position sentences in the nmea lines and the number of them inside the zones
+func (s *UploadTestSuite) positions(lines []string, zones model.PrivacyZones) (count, inside int) {
+	lls, err := model.ParseLines2LogLines(lines, false)
+	s.Require().NoError(err)
+	for _, ll := range lls {
+		if rmc, ok := ll.NMEAMessage.(nmea.RMC); ok {
+			count++
+			if zones.Find(rmc.Latitude, rmc.Longitude) != nil {
+				inside++
+			}
+		}
+	}
+	return count, inside
+}
+
+func (s *UploadTestSuite) TestPrivacyTrackFile() {
+	chk := do.MustInvokeAs[Checker](s.inj)
+	zones := s.zone(chk)
+	tm := do.MustInvokeAs[trackManager](s.inj)
+	tf := filepath.Join(s.T().TempDir(), "trip.zip")
+	s.Require().NoError(tm.NewTrack(sdcard, []string{"DATA001231.DAT"}, tf, model.Track{Name: "trip", VesselID: 597}))
+	s.Require().NoError(tm.AddAttachment(tf, "map.png", []byte("png")))
+	_, lines, err := trackutils.ReadTrackAndNmea(tf)
+	s.Require().NoError(err)
+	_, inside := s.positions(lines, zones)
+	s.Require().Positive(inside)
+
+	fu := FileUpload{FilePath: tf, PrivacyZones: zones}
+	pf, err := fu.privacyFile()
+	s.Require().NoError(err)
+	defer os.RemoveAll(filepath.Dir(pf))
+	s.Equal("trip.zip", filepath.Base(pf))
+
+	tr, lines, err := trackutils.ReadTrackAndNmea(pf)
+	s.Require().NoError(err)
+	s.Equal("trip", tr.Name)
+	s.Equal(int32(597), tr.VesselID)
+	s.Empty(tr.Files)
+	count, inside := s.positions(lines, zones)
+	s.Positive(count)
+	s.Zero(inside)
+
+	r, err := zip.OpenReader(pf)
+	s.Require().NoError(err)
+	defer r.Close()
+	names := make([]string, 0)
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	s.ElementsMatch([]string{trackutils.NMEAFile, trackutils.JSONFile, "map.png"}, names)
+}
+
+func (s *UploadTestSuite) TestPrivacyLoggerFile() {
+	chk := do.MustInvokeAs[Checker](s.inj)
+	zones := s.zone(chk)
+	df := filepath.Join(sdcard, "DATA001231.DAT")
+
+	fu := FileUpload{FilePath: df, PrivacyZones: zones}
+	_, err := fu.privacyFile()
+	s.Error(err)
+
+	fu.Checker = chk
+	pf, err := fu.privacyFile()
+	s.Require().NoError(err)
+	defer os.RemoveAll(filepath.Dir(pf))
+	s.Equal("DATA001231.nmea", filepath.Base(pf))
+	lines, err := readLines(pf)
+	s.Require().NoError(err)
+	count, inside := s.positions(lines, zones)
+	s.Positive(count)
+	s.Zero(inside)
+}
+
 type CheckResponse struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
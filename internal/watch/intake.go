@@ -0,0 +1,371 @@
+package watch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/upload"
+)
+
+// Intake the result of the intake of a sd card
+type Intake struct {
+	Card string    `json:"card"`
+	Time time.Time `json:"time"`
+	// Folder the folder with the copies of the new files and the check results
+	Folder string `json:"folder,omitempty"`
+	// Files the new data files
+	Files []string `json:"files"`
+	// Duplicates the data files already taken in
+	Duplicates []string `json:"duplicates"`
+	Backup     string   `json:"backup,omitempty"`
+	// Report the check report
+	Report     string `json:"report,omitempty"`
+	ErrorCount int    `json:"errorCount"`
+	// Tracks the new or changed track files
+	Tracks   []string `json:"tracks"`
+	Uploaded []string `json:"uploaded"`
+	// Skipped data files without time stamps, they are not added to a track
+	Skipped []string `json:"skipped"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// dataFile a new data file of the card
+type dataFile struct {
+	name     string
+	hash     string
+	first    time.Time
+	last     time.Time
+	vesselID int32
+}
+
+// intake takes in the new data files of the card and runs the pipeline. The files are copied first, so the card
+// can be removed while the pipeline is running. The hashes of the files are recorded, if the pipeline has run
+// without errors, the files of a track are recorded as soon as the track is written. Tracks with a failed upload
+// stay pending and are uploaded again on the next intake.
+func (w *watcher) intake(card string, opts Options, st *state) Intake {
+	in := Intake{
+		Card:       card,
+		Time:       time.Now(),
+		Files:      make([]string, 0),
+		Duplicates: make([]string, 0),
+		Tracks:     make([]string, 0),
+		Uploaded:   make([]string, 0),
+		Skipped:    make([]string, 0),
+	}
+	dfs, err := w.newFiles(card, st, &in)
+	if err != nil {
+		return w.failed(in, err)
+	}
+	if len(dfs) == 0 {
+		w.log.Infof("sd card %s: no new data files, %d already taken in", card, len(in.Duplicates))
+		if slices.Contains(opts.Steps, StepUpload) {
+			w.uploadTracks(opts, st, &in)
+		}
+		return in
+	}
+	in.Folder, err = w.copyCard(card, dfs, opts.DataDir)
+	if err != nil {
+		return w.failed(in, err)
+	}
+	if slices.Contains(opts.Steps, StepBackup) {
+		bf := filepath.Join(opts.DataDir, "backup")
+		name, err := w.bck.Backup(card, bf)
+		if err != nil {
+			return w.failed(in, fmt.Errorf("backup: %w", err))
+		}
+		in.Backup = filepath.Join(bf, name)
+	}
+	if slices.Contains(opts.Steps, StepCheck) || slices.Contains(opts.Steps, StepTrack) {
+		if err := w.checkFiles(dfs, opts, &in); err != nil {
+			return w.failed(in, fmt.Errorf("check: %w", err))
+		}
+	}
+	if slices.Contains(opts.Steps, StepTrack) {
+		if err := w.tracks(dfs, opts, st, &in); err != nil {
+			return w.failed(in, fmt.Errorf("track: %w", err))
+		}
+	}
+	// the files without track
+	for _, df := range dfs {
+		if _, ok := st.Files[df.hash]; !ok {
+			st.record(df, card, in.Time, "")
+		}
+	}
+	if err := st.save(); err != nil {
+		return w.failed(in, err)
+	}
+	if slices.Contains(opts.Steps, StepUpload) {
+		w.uploadTracks(opts, st, &in)
+	}
+	w.log.Infof("sd card %s: %d new data files taken in", card, len(dfs))
+	return in
+}
+
+func (w *watcher) failed(in Intake, err error) Intake {
+	w.log.Errorf("intake of sd card %s failed: %v", in.Card, err)
+	in.Error = err.Error()
+	return in
+}
+
+// newFiles the data files of the card which are not taken in, by the hash of the content
+func (w *watcher) newFiles(card string, st *state, in *Intake) ([]*dataFile, error) {
+	entries, err := os.ReadDir(card)
+	if err != nil {
+		return nil, err
+	}
+	dfs := make([]*dataFile, 0)
+	for _, e := range entries {
+		if e.IsDir() || !isDataFile(e.Name()) {
+			continue
+		}
+		h, err := hashFile(filepath.Join(card, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		_, known := st.Files[h]
+		if known || slices.ContainsFunc(dfs, func(df *dataFile) bool { return df.hash == h }) {
+			in.Duplicates = append(in.Duplicates, e.Name())
+			continue
+		}
+		dfs = append(dfs, &dataFile{name: e.Name(), hash: h})
+		in.Files = append(in.Files, e.Name())
+	}
+	return dfs, nil
+}
+
+// copyCard copies the config and the new data files into a new intake folder
+func (w *watcher) copyCard(card string, dfs []*dataFile, dataDir string) (string, error) {
+	base := filepath.Join(dataDir, "intake", time.Now().Format("20060102150405"))
+	folder := base
+	for i := 1; ; i++ {
+		if _, err := os.Stat(folder); os.IsNotExist(err) {
+			break
+		}
+		folder = fmt.Sprintf("%s-%d", base, i)
+	}
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(card)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if strings.EqualFold(e.Name(), "config.dat") || slices.ContainsFunc(dfs, func(df *dataFile) bool { return df.name == e.Name() }) {
+			if err := copyFile(filepath.Join(card, e.Name()), filepath.Join(folder, e.Name())); err != nil {
+				return "", err
+			}
+		}
+	}
+	return folder, nil
+}
+
+// checkFiles checks the copied files and writes the cleaned up files, the vessel id is only evaluated with output.
+// The report is written with the check step.
+func (w *watcher) checkFiles(dfs []*dataFile, opts Options, in *Intake) error {
+	of := filepath.Join(in.Folder, "check")
+	report := slices.Contains(opts.Steps, StepCheck)
+	res, err := w.chk.Check(in.Folder, of, true, report)
+	if err != nil {
+		return err
+	}
+	if report {
+		in.Report = filepath.Join(of, "report.json")
+	}
+	in.ErrorCount = res.ErrorCount
+	for _, df := range dfs {
+		fr, ok := res.Files[df.name]
+		if !ok {
+			continue
+		}
+		df.first = fr.FirstTimestamp
+		df.last = fr.LastTimestamt
+		df.vesselID = int32(fr.VesselID)
+	}
+	return nil
+}
+
+// tracks adds the files to the track of their trip, the trips are split by the trip gap. Data of a trip already
+// taken in is added to its track, otherwise a new track is created. The state is saved after each track, so the
+// files of a written track are not taken in again, if a later track fails.
+func (w *watcher) tracks(dfs []*dataFile, opts Options, st *state, in *Intake) error {
+	files := make([]*dataFile, 0, len(dfs))
+	for _, df := range dfs {
+		if df.first.IsZero() {
+			in.Skipped = append(in.Skipped, df.name)
+			continue
+		}
+		files = append(files, df)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].first.Before(files[j].first)
+	})
+	td := filepath.Join(opts.DataDir, "tracks")
+	if err := os.MkdirAll(td, os.ModePerm); err != nil {
+		return err
+	}
+	for len(files) > 0 {
+		n := 1
+		last := files[0].last
+		for n < len(files) && files[n].vesselID == files[0].vesselID && files[n].first.Sub(last) <= opts.TripGap {
+			last = maxTime(last, files[n].last)
+			n++
+		}
+		group := files[:n]
+		files = files[n:]
+
+		names := make([]string, 0, len(group))
+		for _, df := range group {
+			names = append(names, df.name)
+		}
+		first := group[0].first
+		t := st.trip(group[0].vesselID, first, last, opts.TripGap)
+		if t != nil {
+			w.log.Infof("adding %v to the track %s", names, t.Track)
+			if err := w.tm.AddTrack(in.Folder, names, t.Track); err != nil {
+				return err
+			}
+			t.First = minTime(t.First, first)
+			t.Last = maxTime(t.Last, last)
+		} else {
+			t = &trip{
+				Track:    trackFile(td, first),
+				VesselID: group[0].vesselID,
+				First:    first,
+				Last:     last,
+			}
+			name := strings.TrimSuffix(filepath.Base(t.Track), ".zip")
+			w.log.Infof("new track %s with %v", t.Track, names)
+			err := w.tm.NewTrack(in.Folder, names, t.Track, model.Track{
+				Name:        name,
+				Description: fmt.Sprintf("trip from %s to %s", first.Local().Format(time.DateTime), last.Local().Format(time.DateTime)),
+				VesselID:    t.VesselID,
+			})
+			if err != nil {
+				return err
+			}
+			st.Trips = append(st.Trips, t)
+		}
+		for _, df := range group {
+			st.record(df, in.Card, in.Time, t.Track)
+		}
+		t.Pending = t.Pending || slices.Contains(opts.Steps, StepUpload)
+		if err := st.save(); err != nil {
+			return err
+		}
+		if !slices.Contains(in.Tracks, t.Track) {
+			in.Tracks = append(in.Tracks, t.Track)
+		}
+	}
+	return nil
+}
+
+// uploadTracks uploads the pending tracks, errors are reported in the intake. A track stays pending until its
+// upload succeeds.
+func (w *watcher) uploadTracks(opts Options, st *state, in *Intake) {
+	var zones model.PrivacyZones
+	if !opts.NoPrivacy && w.cfg != nil {
+		zones = w.cfg.PrivacyZones
+	}
+	errs := make([]string, 0)
+	for _, t := range st.Trips {
+		if !t.Pending {
+			continue
+		}
+		fu := upload.FileUpload{
+			FilePath:     t.Track,
+			VesselID:     int(t.VesselID),
+			Username:     opts.UploadUser,
+			PrivacyZones: zones,
+		}
+		if err := w.upload(fu, opts.UploadURL); err != nil {
+			w.log.Errorf("upload of %s failed: %v", t.Track, err)
+			errs = append(errs, fmt.Sprintf("upload of %s: %v", filepath.Base(t.Track), err))
+			continue
+		}
+		t.Pending = false
+		in.Uploaded = append(in.Uploaded, t.Track)
+	}
+	if err := st.save(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		in.Error = strings.Join(errs, "; ")
+	}
+}
+
+// trackFile the name of a new track file, trip_<date>_<time>.zip
+func trackFile(dir string, first time.Time) string {
+	base := filepath.Join(dir, "trip_"+first.Local().Format("20060102_1504"))
+	tf := base + ".zip"
+	for i := 1; ; i++ {
+		if _, err := os.Stat(tf); os.IsNotExist(err) {
+			return tf
+		}
+		tf = fmt.Sprintf("%s-%d.zip", base, i)
+	}
+}
+
+func hashFile(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile copies the file with its modification time
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
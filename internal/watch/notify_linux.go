@@ -0,0 +1,63 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_CLOSE_WRITE
+
+// inotify the file system notifications of linux, the events are not evaluated, every event triggers a scan
+type inotify struct {
+	fd     int
+	file   *os.File
+	events chan struct{}
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	n := &inotify{
+		fd: fd,
+		// the non blocking fd uses the runtime poller, so the read ends with close
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+	}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotify) read() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		_, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Add watches the folder, adding a folder twice is fine
+func (n *inotify) Add(dir string) error {
+	_, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+	return err
+}
+
+// Events gets a value for every change of the watched folders
+func (n *inotify) Events() <-chan struct{} {
+	return n.events
+}
+
+// Close stops the notifications
+func (n *inotify) Close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,89 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateFile the name of the intake state in the data folder
+const stateFile = "intake.json"
+
+// record a data file taken in
+type record struct {
+	File   string    `json:"file"`
+	Card   string    `json:"card"`
+	Intake time.Time `json:"intake"`
+	Track  string    `json:"track,omitempty"`
+}
+
+// trip a track file with the time range of its data, later data of the same trip is added to it
+type trip struct {
+	Track    string    `json:"track"`
+	VesselID int32     `json:"vesselID,omitempty"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	// Pending the track is changed and not uploaded yet
+	Pending bool `json:"pending,omitempty"`
+}
+
+// state the files taken in by their content hash and the trips, persisted in the data folder
+type state struct {
+	file  string
+	Files map[string]record `json:"files"`
+	Trips []*trip           `json:"trips"`
+}
+
+func loadState(dataDir string) (*state, error) {
+	st := &state{
+		file:  filepath.Join(dataDir, stateFile),
+		Files: make(map[string]record),
+		Trips: make([]*trip, 0),
+	}
+	data, err := os.ReadFile(st.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// save writes the state atomically
+func (st *state) save() error {
+	js, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := st.file + ".tmp"
+	if err := os.WriteFile(tmp, js, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.file)
+}
+
+// record records the data file taken in, with the track it was added to
+func (st *state) record(df *dataFile, card string, intake time.Time, track string) {
+	st.Files[df.hash] = record{
+		File:   df.name,
+		Card:   card,
+		Intake: intake,
+		Track:  track,
+	}
+}
+
+// trip the trip of the vessel the time range belongs to, nil if there is none
+func (st *state) trip(vesselID int32, first, last time.Time, gap time.Duration) *trip {
+	for _, t := range st.Trips {
+		if t.VesselID == vesselID && first.Sub(t.Last) <= gap && t.First.Sub(last) <= gap {
+			return t
+		}
+	}
+	return nil
+}
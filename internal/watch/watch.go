@@ -0,0 +1,320 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/logging"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/upload"
+)
+
+const (
+	// StepBackup backup of the whole card
+	StepBackup = "backup"
+	// StepCheck check of the new files with report
+	StepCheck = "check"
+	// StepTrack the new files are added to a track per trip
+	StepTrack = "track"
+	// StepUpload the tracks are uploaded, needs the track step
+	StepUpload = "upload"
+
+	// DefaultInterval the default poll interval
+	DefaultInterval = 5 * time.Second
+	// DefaultSettle the default time to wait after a card is found, before it's read
+	DefaultSettle = 2 * time.Second
+	// DefaultTripGap the default max time between two files of the same trip
+	DefaultTripGap = 4 * time.Hour
+	// maxDepth the depth of the card folders below the mount folder, e.g. /media/<user>/<label>
+	maxDepth = 2
+)
+
+var (
+	// DefaultSteps the default pipeline
+	DefaultSteps = []string{StepBackup, StepCheck, StepTrack}
+	// ErrUnknownStep the pipeline contains an unknown step
+	ErrUnknownStep = errors.New("unknown pipeline step")
+)
+
+// Options options of the watch mode
+type Options struct {
+	// Mount the folder the sd cards are mounted in, e.g. /media
+	Mount string
+	// DataDir the folder of the backups, the intakes, the track files and the intake state
+	DataDir string
+	// Steps the pipeline, see the Step constants
+	Steps []string
+	// Interval the poll interval, also used with file system notifications, as mounts are not notified
+	Interval time.Duration
+	// Settle the time to wait after a card is found, before it's read
+	Settle time.Duration
+	// TripGap files with a larger gap between them belong to different trips
+	TripGap time.Duration
+	// UploadURL the url of the upload service
+	UploadURL string
+	// UploadUser the user name for the upload
+	UploadUser string
+	// NoPrivacy the tracks are uploaded without removing the positions inside the privacy zones
+	NoPrivacy bool
+	// Once process the cards present at the start and stop
+	Once bool
+	// Started is called after the watching has started
+	Started func(notify bool)
+	// Intaken is called after each intake
+	Intaken func(in Intake)
+}
+
+// ParseSteps parses a comma separated list of pipeline steps
+func ParseSteps(s string) ([]string, error) {
+	steps := make([]string, 0)
+	for st := range strings.SplitSeq(s, ",") {
+		st = strings.ToLower(strings.TrimSpace(st))
+		if st == "" {
+			continue
+		}
+		if !slices.Contains([]string{StepBackup, StepCheck, StepTrack, StepUpload}, st) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownStep, st)
+		}
+		if !slices.Contains(steps, st) {
+			steps = append(steps, st)
+		}
+	}
+	if slices.Contains(steps, StepUpload) && !slices.Contains(steps, StepTrack) {
+		return nil, errors.New("the upload step needs the track step")
+	}
+	return steps, nil
+}
+
+type checkerSrv interface {
+	Check(sdCardFolder, outputFolder string, overwrite, report bool) (*model.CheckResult, error)
+}
+
+type trackManager interface {
+	NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error
+	AddTrack(sdCardFolder string, files []string, trackfile string) error
+}
+
+type backupSrv interface {
+	Backup(sdCardFolder, outputFolder string) (string, error)
+}
+
+// notifier file system notifications
+type notifier interface {
+	// Add watches the folder
+	Add(dir string) error
+	// Events gets a value for every change of the watched folders
+	Events() <-chan struct{}
+	Close() error
+}
+
+// watcher the watch service, takes in the data of the inserted sd cards
+type watcher struct {
+	log logging.Logger
+	chk checkerSrv
+	tm  trackManager
+	bck backupSrv
+	cfg *config.UserConfig
+	// upload uploads a track file, replaced in the tests
+	upload func(fu upload.FileUpload, url string) error
+}
+
+// Init registers the watch service
+func Init(inj do.Injector) {
+	do.Provide(inj, func(inj do.Injector) (*watcher, error) {
+		cfg, err := do.Invoke[*config.UserConfig](inj)
+		if err != nil {
+			return nil, err
+		}
+		return &watcher{
+			log: *logging.New().WithName("Watch"),
+			chk: do.MustInvokeAs[checkerSrv](inj),
+			tm:  do.MustInvokeAs[trackManager](inj),
+			bck: do.MustInvokeAs[backupSrv](inj),
+			cfg: cfg,
+			upload: func(fu upload.FileUpload, url string) error {
+				return fu.Upload(url)
+			},
+		}, nil
+	})
+}
+
+// Watch watches the mount folder for sd cards until the context is done. Every new card is taken in once, it's
+// taken in again only after it was removed. The cards are processed one after the other.
+func (w *watcher) Watch(ctx context.Context, opts Options) error {
+	opts, err := w.check(opts)
+	if err != nil {
+		return err
+	}
+	st, err := loadState(opts.DataDir)
+	if err != nil {
+		return err
+	}
+	nt, err := newNotifier()
+	if err != nil {
+		w.log.Infof("no file system notifications, polling every %s: %v", opts.Interval, err)
+		nt = nil
+	} else {
+		defer nt.Close()
+	}
+	if opts.Started != nil {
+		opts.Started(nt != nil)
+	}
+	w.log.Infof("watching %s for sd cards, data folder %s", opts.Mount, opts.DataDir)
+
+	seen := make(map[string]bool)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	var events <-chan struct{}
+	if nt != nil {
+		events = nt.Events()
+	}
+	settle := time.NewTimer(opts.Settle)
+	defer settle.Stop()
+	for {
+		cards := w.scan(opts.Mount, nt)
+		for c := range seen {
+			if !slices.Contains(cards, c) {
+				w.log.Infof("sd card %s removed", c)
+				delete(seen, c)
+			}
+		}
+		for _, c := range cards {
+			if seen[c] || ctx.Err() != nil {
+				continue
+			}
+			seen[c] = true
+			w.log.Infof("sd card %s found", c)
+			if !sleep(ctx, opts.Settle) {
+				break
+			}
+			in := w.intake(c, opts, st)
+			if opts.Intaken != nil {
+				opts.Intaken(in)
+			}
+		}
+		if opts.Once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-events:
+			// the card folder is created before the card is mounted
+			settle.Reset(opts.Settle)
+			continue
+		case <-settle.C:
+		}
+	}
+}
+
+// check checks the options and sets the defaults
+func (w *watcher) check(opts Options) (Options, error) {
+	if opts.Mount == "" {
+		return opts, errors.New("no mount folder given")
+	}
+	if fi, err := os.Stat(opts.Mount); err != nil || !fi.IsDir() {
+		return opts, fmt.Errorf("mount folder %s not exists", opts.Mount)
+	}
+	if opts.DataDir == "" {
+		return opts, errors.New("no data folder given")
+	}
+	dd, err := filepath.Abs(opts.DataDir)
+	if err != nil {
+		return opts, err
+	}
+	opts.DataDir = dd
+	if opts.Steps == nil {
+		opts.Steps = DefaultSteps
+	}
+	if slices.Contains(opts.Steps, StepUpload) && opts.UploadURL == "" {
+		return opts, errors.New("the upload step needs the url of the upload service")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Settle < 0 {
+		opts.Settle = 0
+	}
+	if opts.TripGap <= 0 {
+		opts.TripGap = DefaultTripGap
+	}
+	return opts, os.MkdirAll(opts.DataDir, os.ModePerm)
+}
+
+// scan returns the card folders below the mount folder, the folders are added to the notifier
+func (w *watcher) scan(mount string, nt notifier) []string {
+	cards := make([]string, 0)
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		if nt != nil {
+			if err := nt.Add(dir); err != nil {
+				w.log.Debugf("can't watch %s: %v", dir, err)
+			}
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		if isCard(entries) {
+			cards = append(cards, dir)
+			return
+		}
+		if depth >= maxDepth {
+			return
+		}
+		for _, e := range entries {
+			if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+				walk(filepath.Join(dir, e.Name()), depth+1)
+			}
+		}
+	}
+	walk(mount, 0)
+	return cards
+}
+
+// isCard a card folder contains the config.dat and at least one data file
+func isCard(entries []os.DirEntry) bool {
+	cfg, data := false, false
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if strings.EqualFold(e.Name(), "config.dat") {
+			cfg = true
+		}
+		if isDataFile(e.Name()) {
+			data = true
+		}
+	}
+	return cfg && data
+}
+
+// isDataFile DATA*.DAT
+func isDataFile(name string) bool {
+	n := strings.ToUpper(name)
+	return strings.HasPrefix(n, "DATA") && strings.HasSuffix(n, ".DAT")
+}
+
+// sleep waits, false if the context is done
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
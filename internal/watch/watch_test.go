@@ -0,0 +1,318 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/do/v2"
+	"github.com/stretchr/testify/suite"
+	"github.com/willie68/osmltools/internal/backup"
+	"github.com/willie68/osmltools/internal/check"
+	"github.com/willie68/osmltools/internal/config"
+	"github.com/willie68/osmltools/internal/importer"
+	"github.com/willie68/osmltools/internal/model"
+	"github.com/willie68/osmltools/internal/track"
+	"github.com/willie68/osmltools/internal/trackutils"
+	"github.com/willie68/osmltools/internal/upload"
+)
+
+const sdcard = "../../testdata/sdcard"
+
+type WatchSuite struct {
+	suite.Suite
+	w     *watcher
+	mount string
+	data  string
+	cfg   string
+}
+
+func TestWatchSuite(t *testing.T) {
+	suite.Run(t, new(WatchSuite))
+}
+
+func (s *WatchSuite) SetupTest() {
+	dir := s.T().TempDir()
+	s.mount = filepath.Join(dir, "media")
+	s.data = filepath.Join(dir, "data")
+	s.Require().NoError(os.MkdirAll(s.mount, os.ModePerm))
+	s.cfg = config.UserConfigFile
+	config.UserConfigFile = filepath.Join(dir, "config.json")
+	inj := do.New()
+	check.Init(inj)
+	config.Init(inj)
+	importer.Init(inj)
+	backup.Init(inj)
+	track.Init(inj)
+	Init(inj)
+	s.w = do.MustInvoke[*watcher](inj)
+}
+
+func (s *WatchSuite) TearDownTest() {
+	config.UserConfigFile = s.cfg
+}
+
+// insert simulates the insertion of a card, the card is written to a temp folder and moved into the mount folder
+func (s *WatchSuite) insert(name string, files ...string) string {
+	tmp := filepath.Join(s.T().TempDir(), name)
+	s.Require().NoError(os.MkdirAll(tmp, os.ModePerm))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmp, "config.dat"), []byte{0x01, 0x02}, 0o600))
+	for _, f := range files {
+		s.Require().NoError(copyFile(filepath.Join(sdcard, f), filepath.Join(tmp, f)))
+	}
+	card := filepath.Join(s.mount, "user", name)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(card), os.ModePerm))
+	s.Require().NoError(os.Rename(tmp, card))
+	return card
+}
+
+// tripFile the track file of the trip with the first time stamp
+func tripFile(dataDir, first string) string {
+	t, _ := time.Parse(time.RFC3339, first)
+	return filepath.Join(dataDir, "tracks", "trip_"+t.Local().Format("20060102_1504")+".zip")
+}
+
+func (s *WatchSuite) remove(card string) {
+	s.Require().NoError(os.RemoveAll(card))
+}
+
+func (s *WatchSuite) TestWatch() {
+	intakes := make(chan Intake, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := s.w.Watch(ctx, Options{
+			Mount:    s.mount,
+			DataDir:  s.data,
+			Interval: 20 * time.Millisecond,
+			Settle:   10 * time.Millisecond,
+			TripGap:  10 * time.Minute,
+			Intaken:  func(in Intake) { intakes <- in },
+		})
+		s.NoError(err)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	wait := func() Intake {
+		select {
+		case in := <-intakes:
+			return in
+		case <-time.After(30 * time.Second):
+			s.FailNow("no intake")
+		}
+		return Intake{}
+	}
+
+	// a folder without config is no card
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.mount, "stick"), os.ModePerm))
+	s.Require().NoError(copyFile(filepath.Join(sdcard, "DATA001235.DAT"), filepath.Join(s.mount, "stick", "DATA001235.DAT")))
+
+	card := s.insert("CARD", "DATA001231.DAT", "DATA001232.DAT")
+	in := wait()
+	s.Empty(in.Error)
+	s.Equal(card, in.Card)
+	s.Equal([]string{"DATA001231.DAT", "DATA001232.DAT"}, in.Files)
+	s.Empty(in.Duplicates)
+	s.FileExists(in.Backup)
+	s.FileExists(in.Report)
+	s.FileExists(filepath.Join(in.Folder, "config.dat"))
+	s.FileExists(filepath.Join(in.Folder, "check", "65535-DATA001231-2016-09-11.nmea"))
+	s.Require().Len(in.Tracks, 1)
+	trip := tripFile(s.data, "2016-09-11T10:12:23Z")
+	s.Equal(trip, in.Tracks[0])
+	s.FileExists(filepath.Join(s.data, stateFile))
+
+	// the same card with new data, the first trip is continued, after the gap a new trip starts
+	s.remove(card)
+	time.Sleep(100 * time.Millisecond)
+	card = s.insert("CARD", "DATA001231.DAT", "DATA001233.DAT", "DATA001234.DAT")
+	in = wait()
+	s.Empty(in.Error)
+	s.Equal([]string{"DATA001233.DAT", "DATA001234.DAT"}, in.Files)
+	s.Equal([]string{"DATA001231.DAT"}, in.Duplicates)
+	s.Equal([]string{trip, tripFile(s.data, "2016-09-11T13:19:25Z")}, in.Tracks)
+	t, _, err := trackutils.ReadTrackAndNmea(trip)
+	s.Require().NoError(err)
+	s.Len(t.Files, 3)
+
+	// nothing new
+	s.remove(card)
+	time.Sleep(100 * time.Millisecond)
+	s.insert("OTHER", "DATA001233.DAT")
+	in = wait()
+	s.Empty(in.Error)
+	s.Empty(in.Files)
+	s.Empty(in.Folder)
+	s.Equal([]string{"DATA001233.DAT"}, in.Duplicates)
+
+	// the state is kept
+	st, err := loadState(s.data)
+	s.Require().NoError(err)
+	s.Len(st.Files, 4)
+	s.Len(st.Trips, 2)
+}
+
+func (s *WatchSuite) TestOnceUpload() {
+	s.insert("CARD", "DATA001231.DAT", "DATA001232.DAT")
+	uploads := make([]upload.FileUpload, 0)
+	s.w.upload = func(fu upload.FileUpload, url string) error {
+		s.Equal("http://localhost/upload", url)
+		uploads = append(uploads, fu)
+		return nil
+	}
+	intakes := make([]Intake, 0)
+	opts := Options{
+		Mount:      s.mount,
+		DataDir:    s.data,
+		Steps:      []string{StepTrack, StepUpload},
+		UploadURL:  "http://localhost/upload",
+		UploadUser: "willie",
+		Once:       true,
+		Intaken:    func(in Intake) { intakes = append(intakes, in) },
+	}
+	s.Require().NoError(s.w.Watch(context.Background(), opts))
+	s.Require().Len(intakes, 1)
+	in := intakes[0]
+	s.Empty(in.Error)
+	s.Empty(in.Backup)
+	s.Empty(in.Report)
+	s.NoFileExists(filepath.Join(in.Folder, "check", "report.json"))
+	s.Equal(in.Tracks, in.Uploaded)
+	s.Require().Len(uploads, 1)
+	s.Equal("willie", uploads[0].Username)
+	s.Equal(65535, uploads[0].VesselID)
+
+	// a second run has nothing to do
+	intakes = intakes[:0]
+	s.Require().NoError(s.w.Watch(context.Background(), opts))
+	s.Require().Len(intakes, 1)
+	s.Empty(intakes[0].Files)
+	s.Len(uploads, 1)
+
+	opts.UploadURL = ""
+	s.Error(s.w.Watch(context.Background(), opts))
+}
+
+func (s *WatchSuite) TestRetryUpload() {
+	s.insert("CARD", "DATA001231.DAT", "DATA001232.DAT")
+	fail := true
+	uploads := make([]string, 0)
+	s.w.upload = func(fu upload.FileUpload, _ string) error {
+		if fail {
+			return errors.New("no network")
+		}
+		uploads = append(uploads, fu.FilePath)
+		return nil
+	}
+	intakes := make([]Intake, 0)
+	opts := Options{
+		Mount:      s.mount,
+		DataDir:    s.data,
+		Steps:      []string{StepTrack, StepUpload},
+		UploadURL:  "http://localhost/upload",
+		UploadUser: "willie",
+		Once:       true,
+		Intaken:    func(in Intake) { intakes = append(intakes, in) },
+	}
+	s.Require().NoError(s.w.Watch(context.Background(), opts))
+	s.Require().Len(intakes, 1)
+	s.Contains(intakes[0].Error, "no network")
+	s.Empty(intakes[0].Uploaded)
+	s.Require().Len(intakes[0].Tracks, 1)
+	st, err := loadState(s.data)
+	s.Require().NoError(err)
+	s.Require().Len(st.Trips, 1)
+	s.True(st.Trips[0].Pending)
+
+	// the next intake uploads the pending track, even without new files
+	fail = false
+	intakes = intakes[:0]
+	s.Require().NoError(s.w.Watch(context.Background(), opts))
+	s.Require().Len(intakes, 1)
+	s.Empty(intakes[0].Error)
+	s.Empty(intakes[0].Files)
+	s.Equal([]string{st.Trips[0].Track}, uploads)
+	s.Equal(uploads, intakes[0].Uploaded)
+	st, err = loadState(s.data)
+	s.Require().NoError(err)
+	s.False(st.Trips[0].Pending)
+
+	// nothing left to upload
+	intakes = intakes[:0]
+	s.Require().NoError(s.w.Watch(context.Background(), opts))
+	s.Require().Len(intakes, 1)
+	s.Empty(intakes[0].Uploaded)
+	s.Len(uploads, 1)
+}
+
+// failingTracks fails on writing the track file
+type failingTracks struct {
+	trackManager
+	fail string
+}
+
+func (f *failingTracks) NewTrack(sdCardFolder string, files []string, trackfile string, track model.Track) error {
+	if trackfile == f.fail {
+		return errors.New("disk full")
+	}
+	return f.trackManager.NewTrack(sdCardFolder, files, trackfile, track)
+}
+
+func (s *WatchSuite) TestFailedTrip() {
+	s.insert("CARD", "DATA001231.DAT", "DATA001232.DAT", "DATA001234.DAT")
+	first, second := tripFile(s.data, "2016-09-11T10:12:23Z"), tripFile(s.data, "2016-09-11T13:19:25Z")
+	tm := s.w.tm
+	s.w.tm = &failingTracks{trackManager: tm, fail: second}
+	intakes := make([]Intake, 0)
+	opts := Options{
+		Mount:   s.mount,
+		DataDir: s.data,
+		Steps:   []string{StepTrack},
+		TripGap: 10 * time.Minute,
+		Once:    true,
+		Intaken: func(in Intake) { intakes = append(intakes, in) },
+	}
+	s.Require().NoError(s.w.Watch(context.Background(), opts))
+	s.Require().Len(intakes, 1)
+	s.Contains(intakes[0].Error, "disk full")
+	st, err := loadState(s.data)
+	s.Require().NoError(err)
+	s.Len(st.Files, 2)
+	s.Len(st.Trips, 1)
+
+	// the next insertion takes in the files of the failed trip only
+	s.w.tm = tm
+	intakes = intakes[:0]
+	s.Require().NoError(s.w.Watch(context.Background(), opts))
+	s.Require().Len(intakes, 1)
+	in := intakes[0]
+	s.Empty(in.Error)
+	s.Equal([]string{"DATA001234.DAT"}, in.Files)
+	s.Equal([]string{"DATA001231.DAT", "DATA001232.DAT"}, in.Duplicates)
+	s.Equal([]string{second}, in.Tracks)
+	s.FileExists(first)
+}
+
+func (s *WatchSuite) TestParseSteps() {
+	steps, err := ParseSteps("Backup, check,track,check")
+	s.Require().NoError(err)
+	s.Equal([]string{StepBackup, StepCheck, StepTrack}, steps)
+	_, err = ParseSteps("backup,format")
+	s.ErrorIs(err, ErrUnknownStep)
+	_, err = ParseSteps("check,upload")
+	s.Error(err)
+}
+
+func (s *WatchSuite) TestOptions() {
+	s.Error(s.w.Watch(context.Background(), Options{DataDir: s.data}))
+	s.Error(s.w.Watch(context.Background(), Options{Mount: filepath.Join(s.mount, "unknown"), DataDir: s.data}))
+	s.Error(s.w.Watch(context.Background(), Options{Mount: s.mount}))
+}